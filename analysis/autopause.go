@@ -0,0 +1,207 @@
+package analysis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wa-tor/simulation"
+)
+
+// Kinds of PauseTrigger
+const (
+	TriggerFishBelow   = "fish<"
+	TriggerFishAbove   = "fish>"
+	TriggerSharksBelow = "sharks<"
+	TriggerSharksAbove = "sharks>"
+	TriggerBasin       = "basin" // First local extinction in any basin
+	TriggerSpike       = "spike" // Fish eaten in a step exceeds Value times the recent average
+)
+
+// defaultSpikeFactor is the predation spike factor of a bare "spike" trigger
+const defaultSpikeFactor = 3
+
+// spikeWarmup is the number of steps averaged before spikes are detected;
+// the average decays with the same time scale
+const spikeWarmup = 50
+
+// pauseHysteresis is the fraction of its threshold a population must move
+// back across before the trigger can fire again, so noise around the
+// threshold does not pause the simulation every few steps
+const pauseHysteresis = 0.05
+
+// PauseTrigger is a condition that pauses the simulation when it becomes true
+type PauseTrigger struct {
+	Kind  string
+	Value float64 // Population threshold or spike factor
+}
+
+// String returns the trigger in the syntax accepted by ParsePauseTriggers
+func (t PauseTrigger) String() string {
+	switch t.Kind {
+	case TriggerBasin:
+		return t.Kind
+	case TriggerSpike:
+		return fmt.Sprintf("spike=%g", t.Value)
+	default:
+		return fmt.Sprintf("%s%g", t.Kind, t.Value)
+	}
+}
+
+// ParsePauseTriggers parses a comma-separated list of triggers such as
+// "fish<200,sharks>800,basin,spike=3"
+func ParsePauseTriggers(spec string) ([]PauseTrigger, error) {
+	var triggers []PauseTrigger
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		t, err := parsePauseTrigger(field)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
+
+func parsePauseTrigger(field string) (PauseTrigger, error) {
+	if field == TriggerBasin {
+		return PauseTrigger{Kind: TriggerBasin}, nil
+	}
+	if field == TriggerSpike {
+		return PauseTrigger{Kind: TriggerSpike, Value: defaultSpikeFactor}, nil
+	}
+	if v, ok := strings.CutPrefix(field, TriggerSpike+"="); ok {
+		factor, err := strconv.ParseFloat(v, 64)
+		if err != nil || factor <= 1 {
+			return PauseTrigger{}, fmt.Errorf("invalid trigger %q, spike factor must be greater than 1", field)
+		}
+		return PauseTrigger{Kind: TriggerSpike, Value: factor}, nil
+	}
+
+	for _, kind := range []string{TriggerFishBelow, TriggerFishAbove, TriggerSharksBelow, TriggerSharksAbove} {
+		if v, ok := strings.CutPrefix(field, kind); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return PauseTrigger{}, fmt.Errorf("invalid trigger %q, threshold must be a non-negative integer", field)
+			}
+			return PauseTrigger{Kind: kind, Value: float64(n)}, nil
+		}
+	}
+	return PauseTrigger{}, fmt.Errorf("unknown trigger %q (expected fish<N, fish>N, sharks<N, sharks>N, basin or spike[=F])", field)
+}
+
+// PauseEvent describes a trigger that fired
+type PauseEvent struct {
+	Step    int
+	Trigger PauseTrigger
+	Reason  string
+	Basin   int // Basin with the local extinction for basin triggers, otherwise -1
+}
+
+// AutoPause watches a run for notable events that should pause it
+type AutoPause struct {
+	Triggers []PauseTrigger
+	Basins   *BasinTracker // Only for basin triggers
+
+	active       []bool // Whether each trigger's condition held at the last step
+	basinFired   bool
+	predation    float64 // Moving average of fish eaten per step
+	observations int
+}
+
+// NewAutoPause creates a watcher for the triggers on the given world
+func NewAutoPause(triggers []PauseTrigger, w *simulation.World) *AutoPause {
+	a := &AutoPause{Triggers: triggers}
+	a.Reset(w)
+	return a
+}
+
+// Reset starts watching a new run on w
+func (a *AutoPause) Reset(w *simulation.World) {
+	a.active = make([]bool, len(a.Triggers))
+	a.basinFired = false
+	a.predation = 0
+	a.observations = 0
+	a.Basins = nil
+	for _, t := range a.Triggers {
+		if t.Kind == TriggerBasin {
+			a.Basins = NewBasinTracker(w)
+			a.Basins.Observe(0, w)
+		}
+	}
+
+	// Conditions that already hold at the start do not fire
+	fish, sharks := w.Count()
+	for i, t := range a.Triggers {
+		a.active[i] = t.holds(fish, sharks, 0)
+	}
+}
+
+// Observe checks the triggers after a step in which eaten fish were eaten
+// and returns the first one that fired, or nil
+func (a *AutoPause) Observe(step int, w *simulation.World, eaten int) *PauseEvent {
+	fish, sharks := w.Count()
+
+	var event *PauseEvent
+	fire := func(t PauseTrigger, reason string, basin int) {
+		if event == nil {
+			event = &PauseEvent{Step: step, Trigger: t, Reason: reason, Basin: basin}
+		}
+	}
+
+	for i, t := range a.Triggers {
+		switch t.Kind {
+		case TriggerBasin:
+			for _, e := range a.Basins.Observe(step, w) {
+				if e.Kind == "extinction" && !a.basinFired {
+					a.basinFired = true
+					name := "Fish"
+					if e.Species == simulation.Shark {
+						name = "Sharks"
+					}
+					fire(t, fmt.Sprintf("%s died out in basin %d", name, e.Basin), e.Basin)
+				}
+			}
+		case TriggerSpike:
+			now := a.observations >= spikeWarmup && float64(eaten) > t.Value*a.predation && eaten > 0
+			if now && !a.active[i] {
+				fire(t, fmt.Sprintf("Predation spike: %d fish eaten (average %.1f)", eaten, a.predation), -1)
+			}
+			a.active[i] = now
+		default:
+			if a.active[i] {
+				a.active[i] = t.holds(fish, sharks, pauseHysteresis)
+			} else if t.holds(fish, sharks, 0) {
+				a.active[i] = true
+				fire(t, fmt.Sprintf("Population crossed %s (fish %d, sharks %d)", t, fish, sharks), -1)
+			}
+		}
+	}
+
+	// Update the average after checking so a spike is compared to the past
+	a.observations++
+	a.predation += (float64(eaten) - a.predation) / float64(min(a.observations, spikeWarmup))
+	return event
+}
+
+// holds reports whether a population trigger's condition is true, with the
+// threshold moved outwards by the given fraction of itself
+func (t PauseTrigger) holds(fish, sharks int, slack float64) bool {
+	margin := t.Value * slack
+	switch t.Kind {
+	case TriggerFishBelow:
+		return float64(fish) < t.Value+margin
+	case TriggerFishAbove:
+		return float64(fish) > t.Value-margin
+	case TriggerSharksBelow:
+		return float64(sharks) < t.Value+margin
+	case TriggerSharksAbove:
+		return float64(sharks) > t.Value-margin
+	default:
+		return false
+	}
+}
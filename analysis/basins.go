@@ -0,0 +1,139 @@
+// Package analysis derives ecological statistics from running worlds.
+package analysis
+
+import (
+	"wa-tor/simulation"
+)
+
+// Basins labels the connected regions of water in a world. Cells are
+// connected through their four neighbours, wrapping around the edges unless
+// the world is bounded.
+type Basins struct {
+	Width int
+	Label []int // Basin index per cell (row-major), -1 for barriers
+	Size  []int // Number of water cells per basin
+}
+
+// FindBasins flood-fills the water cells of the world into basins
+func FindBasins(w *simulation.World) *Basins {
+	b := &Basins{Width: w.Width, Label: make([]int, w.Width*w.Height)}
+	for i := range b.Label {
+		b.Label[i] = -1
+	}
+
+	var queue []int
+	for start := range b.Label {
+		y, x := start/w.Width, start%w.Width
+		if b.Label[start] >= 0 || w.Cell(y, x).Type == simulation.Barrier {
+			continue
+		}
+
+		id := len(b.Size)
+		b.Size = append(b.Size, 0)
+		b.Label[start] = id
+		queue = append(queue[:0], start)
+
+		for len(queue) > 0 {
+			k := queue[0]
+			queue = queue[1:]
+			b.Size[id]++
+
+			cy, cx := k/w.Width, k%w.Width
+			for _, d := range w.Directions(cy) {
+				ny, nx, ok := w.Neighbor(cy, cx, d[0], d[1])
+				if !ok {
+					continue
+				}
+				n := ny*w.Width + nx
+				if b.Label[n] < 0 && w.Cell(ny, nx).Type != simulation.Barrier {
+					b.Label[n] = id
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+
+	return b
+}
+
+// Count returns the fish and shark populations of every basin
+func (b *Basins) Count(w *simulation.World) (fish, sharks []int) {
+	fish = make([]int, len(b.Size))
+	sharks = make([]int, len(b.Size))
+	for i := 0; i < w.Height; i++ {
+		for j := 0; j < w.Width; j++ {
+			switch w.Cell(i, j).Type {
+			case simulation.Fish:
+				fish[b.Label[i*b.Width+j]]++
+			case simulation.Shark:
+				sharks[b.Label[i*b.Width+j]]++
+			}
+		}
+	}
+	return fish, sharks
+}
+
+// BasinEvent records a species disappearing from or returning to a basin
+type BasinEvent struct {
+	Step    int
+	Basin   int
+	Species simulation.CellType
+	Kind    string // "extinction" or "recolonization"
+}
+
+// BasinTracker follows per-basin populations over time and records local
+// extinction and recolonization events
+type BasinTracker struct {
+	Basins      *Basins
+	Fish        []int // Latest per-basin populations
+	Sharks      []int
+	Extinctions []int // Per-basin event counts
+	Recolonized []int
+	present     [][2]bool
+	observed    bool
+}
+
+// NewBasinTracker detects the basins of the world
+func NewBasinTracker(w *simulation.World) *BasinTracker {
+	b := FindBasins(w)
+	return &BasinTracker{
+		Basins:      b,
+		Extinctions: make([]int, len(b.Size)),
+		Recolonized: make([]int, len(b.Size)),
+		present:     make([][2]bool, len(b.Size)),
+	}
+}
+
+// Observe updates the per-basin populations and returns any events since
+// the previous observation
+func (t *BasinTracker) Observe(step int, w *simulation.World) []BasinEvent {
+	t.Fish, t.Sharks = t.Basins.Count(w)
+
+	var events []BasinEvent
+	for id := range t.present {
+		for s, species := range [2]simulation.CellType{simulation.Fish, simulation.Shark} {
+			now := t.Fish[id] > 0
+			if species == simulation.Shark {
+				now = t.Sharks[id] > 0
+			}
+
+			was := t.present[id][s]
+			t.present[id][s] = now
+			if !t.observed || was == now {
+				continue
+			}
+
+			e := BasinEvent{Step: step, Basin: id, Species: species, Kind: "recolonization"}
+			if was {
+				e.Kind = "extinction"
+				t.Extinctions[id]++
+			} else {
+				t.Recolonized[id]++
+			}
+			events = append(events, e)
+		}
+	}
+
+	t.observed = true
+	return events
+}
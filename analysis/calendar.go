@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// daysPerYear is the length of a simulated year
+const daysPerYear = 365
+
+// Calendar maps chronons to simulated days and years. The zero Calendar
+// maps nothing, and its methods return "".
+type Calendar struct {
+	DaysPerChronon float64
+}
+
+// Enabled reports whether the calendar maps chronons to days
+func (c Calendar) Enabled() bool {
+	return c.DaysPerChronon > 0
+}
+
+// Days returns the number of days in the given number of chronons
+func (c Calendar) Days(chronons int) float64 {
+	return float64(chronons) * c.DaysPerChronon
+}
+
+// Date returns the date reached after the given number of chronons, counting
+// from day 1 of year 1
+func (c Calendar) Date(step int) string {
+	if !c.Enabled() {
+		return ""
+	}
+	day := int(math.Floor(c.Days(step)))
+	return fmt.Sprintf("year %d, day %d", day/daysPerYear+1, day%daysPerYear+1)
+}
+
+// Duration returns the given number of chronons as days, or as years once
+// they make two
+func (c Calendar) Duration(chronons int) string {
+	if !c.Enabled() {
+		return ""
+	}
+	days := c.Days(chronons)
+	if days >= 2*daysPerYear {
+		return fmt.Sprintf("%.1f years", days/daysPerYear)
+	}
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%.4g days", days)
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"slices"
+
+	"wa-tor/simulation"
+)
+
+// ChaseTracker observes a world and follows every predator from one meal to
+// the next: how many cells it moved and how many steps it took. A hunt ends
+// in a meal, or in a failure when the predator starves first. Predators are
+// followed from the step the tracker is added; their first hunt starts then.
+type ChaseTracker struct {
+	simulation.NopObserver
+	Distance map[int]int // Hunts that ended in a meal, by cells moved
+	Duration map[int]int // Hunts that ended in a meal, by steps taken
+	Meals    int
+	Starved  int
+
+	step   int
+	width  int
+	hunts  map[int]hunt // Hunt of the predator in each cell
+	births []int        // Cells of predators born this step
+	meals  map[int]bool // Cells where fish were eaten this step
+}
+
+// hunt is the pursuit of a predator since its last meal
+type hunt struct {
+	moves int
+	start int // Step it began
+}
+
+// NewChaseTracker creates a tracker of the predators of w, which it must
+// observe
+func NewChaseTracker(w *simulation.World) *ChaseTracker {
+	return &ChaseTracker{
+		Distance: map[int]int{},
+		Duration: map[int]int{},
+		width:    w.Width,
+		hunts:    map[int]hunt{},
+		meals:    map[int]bool{},
+	}
+}
+
+// SuccessRate returns the share of finished hunts that ended in a meal, or
+// 0 before any has
+func (c *ChaseTracker) SuccessRate() float64 {
+	if c.Meals+c.Starved == 0 {
+		return 0
+	}
+	return float64(c.Meals) / float64(c.Meals+c.Starved)
+}
+
+// MeanDistance returns the average number of cells moved between meals
+func (c *ChaseTracker) MeanDistance() float64 {
+	return histogramMean(c.Distance)
+}
+
+// MeanDuration returns the average number of steps between meals
+func (c *ChaseTracker) MeanDuration() float64 {
+	return histogramMean(c.Duration)
+}
+
+// OnStepStart counts the step
+func (c *ChaseTracker) OnStepStart(*simulation.World) {
+	c.step++
+}
+
+// OnEat remembers where a fish was eaten, for the predator moving there
+func (c *ChaseTracker) OnEat(e simulation.Event) {
+	c.meals[e.Y*c.width+e.X] = true
+}
+
+// OnMove carries the hunt of a predator to its new cell, ending it if the
+// predator ate there. A predator always moves to a cell that held no
+// predator before the step, so no hunt is overwritten.
+func (c *ChaseTracker) OnMove(e simulation.Event) {
+	if e.Animal != simulation.Shark {
+		return
+	}
+	from, to := e.FromY*c.width+e.FromX, e.Y*c.width+e.X
+	h := c.hunts[from]
+	delete(c.hunts, from)
+	h.moves++
+	if c.meals[to] {
+		c.Meals++
+		c.Distance[h.moves]++
+		c.Duration[c.step-h.start]++
+		h = hunt{start: c.step}
+	}
+	c.hunts[to] = h
+}
+
+// OnBreed starts the hunt of a newborn predator, once its parent has left
+func (c *ChaseTracker) OnBreed(e simulation.Event) {
+	if e.Animal == simulation.Shark {
+		c.births = append(c.births, e.Y*c.width+e.X)
+	}
+}
+
+// OnDeath ends the hunt of a predator that starved
+func (c *ChaseTracker) OnDeath(e simulation.Event) {
+	if e.Animal == simulation.Shark {
+		c.Starved++
+	}
+}
+
+// OnStepEnd places the newborn predators and forgets the cells left empty
+func (c *ChaseTracker) OnStepEnd(w *simulation.World) {
+	for _, i := range c.births {
+		c.hunts[i] = hunt{start: c.step}
+	}
+	c.births = c.births[:0]
+	clear(c.meals)
+	for i := range c.hunts {
+		if w.Grid[i].Type != simulation.Shark {
+			delete(c.hunts, i)
+		}
+	}
+}
+
+// histogramMean returns the average value of a histogram of counts by value
+func histogramMean(h map[int]int) float64 {
+	sum, n := 0, 0
+	for v, count := range h {
+		sum += v * count
+		n += count
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// HistogramValues returns the values of a histogram in increasing order
+func HistogramValues(h map[int]int) []int {
+	values := make([]int, 0, len(h))
+	for v := range h {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	return values
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import "wa-tor/simulation"
+
+// AgeClasses counts the animals of one population by age class, for cohort
+// and survivorship analysis
+type AgeClasses struct {
+	Population string // "fish" or the name of a predator species
+	Width      int    // Chronons each class spans
+	Counts     []int  // Counts[i] animals are Width*i to Width*(i+1)-1 chronons old
+}
+
+// Total returns the number of animals in all classes
+func (a AgeClasses) Total() int {
+	n := 0
+	for _, count := range a.Counts {
+		n += count
+	}
+	return n
+}
+
+// MeanAge returns the average age in chronons, taking each animal to be in
+// the middle of its class, or 0 for an empty population
+func (a AgeClasses) MeanAge() float64 {
+	sum, n := 0.0, 0
+	for class, count := range a.Counts {
+		sum += (float64(class) + 0.5) * float64(a.Width) * float64(count)
+		n += count
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// AgeStructure bins the fish, the sharks and every other predator species by
+// age in classes width chronons wide. Populations without individuals are
+// left out; the classes run up to that of the oldest animal.
+func AgeStructure(w *simulation.World, width int) []AgeClasses {
+	names := []string{"fish", "sharks"}
+	for _, species := range w.Species {
+		names = append(names, species.Name)
+	}
+	counts := make([][]int, len(names))
+
+	for _, cell := range w.Grid {
+		pop := 0
+		switch cell.Type {
+		case simulation.Fish:
+		case simulation.Shark:
+			pop = 1 + min(cell.Species, len(w.Species))
+		default:
+			continue
+		}
+
+		class := cell.Age / width
+		for len(counts[pop]) <= class {
+			counts[pop] = append(counts[pop], 0)
+		}
+		counts[pop][class]++
+	}
+
+	var structure []AgeClasses
+	for pop, name := range names {
+		if len(counts[pop]) > 0 {
+			structure = append(structure, AgeClasses{Population: name, Width: width, Counts: counts[pop]})
+		}
+	}
+	return structure
+}
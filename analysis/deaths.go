@@ -0,0 +1,37 @@
+package analysis
+
+import "wa-tor/simulation"
+
+// DeathCauses names the causes of death in the order Deaths.Counts holds
+// them. Fish die of all three; sharks only starve.
+var DeathCauses = []string{"predation", "starvation", "old_age"}
+
+// Deaths counts the animals of one population that died during a step, by
+// cause
+type Deaths struct {
+	Population string // "fish", "sharks" or the name of a predator species
+	Counts     [3]int // Indexed like DeathCauses
+}
+
+// Total returns the number of deaths from all causes
+func (d Deaths) Total() int {
+	return d.Counts[0] + d.Counts[1] + d.Counts[2]
+}
+
+// StepDeaths splits the deaths of the last step of w by population and
+// cause: fish, sharks, then every other predator species
+func StepDeaths(w *simulation.World) []Deaths {
+	events := w.LastStep()
+	deaths := make([]Deaths, len(w.Species)+2)
+	deaths[0] = Deaths{Population: "fish", Counts: [3]int{events.FishEaten, events.FishStarved, events.FishAged}}
+	deaths[1].Population = "sharks"
+	for i, species := range w.Species {
+		deaths[i+2].Population = species.Name
+	}
+	for species, n := range events.SpeciesStarved {
+		if species+1 < len(deaths) {
+			deaths[species+1].Counts[1] = n
+		}
+	}
+	return deaths
+}
@@ -0,0 +1,140 @@
+package analysis
+
+import "fmt"
+
+// equilibriumWindows is the number of consecutive windows that must agree
+// before populations count as stable
+const equilibriumWindows = 3
+
+// EquilibriumRule defines a stable oscillation: the populations are split
+// into windows of Window chronons, and over the last equilibriumWindows of
+// them the mean and the amplitude (maximum minus minimum) of both fish and
+// sharks vary by at most Tolerance times their mean. The window should span
+// a few periods of the oscillation, or its phase makes the windows differ.
+type EquilibriumRule struct {
+	Window    int
+	Tolerance float64
+}
+
+// DefaultEquilibrium compares windows long enough for several oscillations
+// of worlds like the default one, whose period is well below 100 chronons
+var DefaultEquilibrium = EquilibriumRule{Window: 500, Tolerance: 0.1}
+
+// Oscillation summarises a population over the windows that were stable
+type Oscillation struct {
+	Mean      float64
+	Amplitude float64 // Mean of the windows' maximum minus minimum
+}
+
+// EquilibriumTracker applies an EquilibriumRule to a population time series
+type EquilibriumTracker struct {
+	Rule EquilibriumRule
+
+	// Step at which the populations were first found stable, or -1, and
+	// their oscillations over the stable windows
+	Reached int
+	Fish    Oscillation
+	Sharks  Oscillation
+
+	fish, sharks populationWindow
+	fishDone     []populationWindow // Completed windows, at most equilibriumWindows
+	sharksDone   []populationWindow
+}
+
+// populationWindow accumulates one population over a window
+type populationWindow struct {
+	n, sum, lo, hi int
+}
+
+func (p *populationWindow) add(count int) {
+	if p.n == 0 || count < p.lo {
+		p.lo = count
+	}
+	if p.n == 0 || count > p.hi {
+		p.hi = count
+	}
+	p.n++
+	p.sum += count
+}
+
+func (p populationWindow) mean() float64 {
+	return float64(p.sum) / float64(p.n)
+}
+
+// NewEquilibriumTracker creates a tracker for the given rule
+func NewEquilibriumTracker(rule EquilibriumRule) *EquilibriumTracker {
+	return &EquilibriumTracker{Rule: rule, Reached: -1}
+}
+
+// Observe records the populations at a step, which must be called once per
+// chronon in order. It returns whether the populations are now stable.
+func (t *EquilibriumTracker) Observe(step, fish, sharks int) bool {
+	if t.Ended() {
+		return true
+	}
+	t.fish.add(fish)
+	t.sharks.add(sharks)
+	if t.fish.n < t.Rule.Window {
+		return false
+	}
+
+	t.fishDone = lastWindows(t.fishDone, t.fish)
+	t.sharksDone = lastWindows(t.sharksDone, t.sharks)
+	t.fish, t.sharks = populationWindow{}, populationWindow{}
+	fishStable, fishOsc := t.stable(t.fishDone)
+	sharksStable, sharksOsc := t.stable(t.sharksDone)
+	if fishStable && sharksStable {
+		t.Reached, t.Fish, t.Sharks = step, fishOsc, sharksOsc
+	}
+	return t.Ended()
+}
+
+// lastWindows appends w to windows, keeping the last equilibriumWindows
+func lastWindows(windows []populationWindow, w populationWindow) []populationWindow {
+	windows = append(windows, w)
+	if len(windows) > equilibriumWindows {
+		windows = windows[1:]
+	}
+	return windows
+}
+
+// stable reports whether enough windows agree within the tolerance, and
+// summarises them. A population that died out is not stable.
+func (t *EquilibriumTracker) stable(windows []populationWindow) (bool, Oscillation) {
+	if len(windows) < equilibriumWindows {
+		return false, Oscillation{}
+	}
+	var osc Oscillation
+	meanLo, meanHi := windows[0].mean(), windows[0].mean()
+	ampLo, ampHi := windows[0].hi-windows[0].lo, windows[0].hi-windows[0].lo
+	for _, w := range windows {
+		osc.Mean += w.mean() / equilibriumWindows
+		osc.Amplitude += float64(w.hi-w.lo) / equilibriumWindows
+		meanLo, meanHi = min(meanLo, w.mean()), max(meanHi, w.mean())
+		ampLo, ampHi = min(ampLo, w.hi-w.lo), max(ampHi, w.hi-w.lo)
+	}
+	bound := t.Rule.Tolerance * osc.Mean
+	return osc.Mean > 0 && meanHi-meanLo <= bound && float64(ampHi-ampLo) <= bound, osc
+}
+
+// Ended reports whether the populations have been found stable
+func (t *EquilibriumTracker) Ended() bool {
+	return t.Reached >= 0
+}
+
+// Reason describes the equilibrium, or returns "" if there is none yet
+func (t *EquilibriumTracker) Reason() string {
+	if !t.Ended() {
+		return ""
+	}
+	return "Equilibrium reached"
+}
+
+// Report describes the stable oscillations of both populations
+func (t *EquilibriumTracker) Report() string {
+	if !t.Ended() {
+		return "No equilibrium"
+	}
+	return fmt.Sprintf("Fish: mean %.1f, amplitude %.1f\nSharks: mean %.1f, amplitude %.1f",
+		t.Fish.Mean, t.Fish.Amplitude, t.Sharks.Mean, t.Sharks.Amplitude)
+}
@@ -0,0 +1,41 @@
+package analysis
+
+import "testing"
+
+func TestEquilibriumTracker(t *testing.T) {
+	rule := EquilibriumRule{Window: 300, Tolerance: 0.1}
+	rising := make([]float64, 3000)
+	for i := range rising {
+		rising[i] = 100 + float64(i)
+	}
+	for _, tc := range []struct {
+		name         string
+		fish, sharks []float64
+		want         int // Step at which the populations are found stable, or -1
+	}{
+		{"steady cycle", sine(3000, 500, 200, 60, 0), sine(3000, 100, 40, 60, 15), 3*300 - 1},
+		{"flat", sine(3000, 500, 0, 60, 0), sine(3000, 100, 0, 60, 0), 3*300 - 1},
+		{"noise", noise(3000, 500, 20, 1), noise(3000, 100, 4, 2), 3*300 - 1},
+		{"growing fish", rising, sine(3000, 100, 40, 60, 15), -1},
+		{"extinct sharks", sine(3000, 500, 200, 60, 0), sine(3000, 0, 0, 60, 0), -1},
+		{"transient", append(sine(600, 2000, 1500, 60, 0), sine(2400, 500, 200, 60, 0)...), sine(3000, 100, 40, 60, 15), 5*300 - 1},
+	} {
+		tr := NewEquilibriumTracker(rule)
+		for step := range tc.fish {
+			if tr.Observe(step, int(tc.fish[step]), int(tc.sharks[step])) {
+				break
+			}
+		}
+		if tr.Reached != tc.want {
+			t.Errorf("%s: Reached = %d, want %d", tc.name, tr.Reached, tc.want)
+		}
+	}
+
+	tr := NewEquilibriumTracker(rule)
+	for step := range 900 {
+		tr.Observe(step, 500, 100)
+	}
+	if tr.Reason() == "" || tr.Fish.Mean != 500 || tr.Fish.Amplitude != 0 || tr.Sharks.Mean != 100 {
+		t.Errorf("flat populations: %q with fish %+v and sharks %+v, want an equilibrium at means 500 and 100", tr.Reason(), tr.Fish, tr.Sharks)
+	}
+}
@@ -0,0 +1,167 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Expr is an arithmetic expression over named variables, such as
+// "fish / (sharks + 1)", for metrics and rules defined by the user
+type Expr struct {
+	op          byte // '+', '-', '*', '/', '<', '>', 'l' (<=) or 'g' (>=) between left and right, 'n' to negate left, 0 for a leaf
+	number      float64
+	variable    string // Leaf variable, or "" for a number
+	left, right *Expr
+}
+
+// ParseExpr parses an expression of numbers, the given variables, the
+// operators + - * /, comparisons < > <= >= and parentheses. A comparison
+// is 1 if it holds and 0 otherwise.
+func ParseExpr(s string, variables []string) (*Expr, error) {
+	p := &exprParser{s: s, variables: variables}
+	e, err := p.comparison()
+	if err == nil && p.peek() != 0 {
+		err = fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", s, err)
+	}
+	return e, nil
+}
+
+// Eval computes the expression, looking the variables up with value.
+// Dividing by zero gives an infinity or NaN.
+func (e *Expr) Eval(value func(variable string) float64) float64 {
+	switch e.op {
+	case '+':
+		return e.left.Eval(value) + e.right.Eval(value)
+	case '-':
+		return e.left.Eval(value) - e.right.Eval(value)
+	case '*':
+		return e.left.Eval(value) * e.right.Eval(value)
+	case '/':
+		return e.left.Eval(value) / e.right.Eval(value)
+	case '<', '>', 'l', 'g':
+		l, r := e.left.Eval(value), e.right.Eval(value)
+		if e.op == '<' && l < r || e.op == '>' && l > r || e.op == 'l' && l <= r || e.op == 'g' && l >= r {
+			return 1
+		}
+		return 0
+	case 'n':
+		return -e.left.Eval(value)
+	}
+	if e.variable != "" {
+		return value(e.variable)
+	}
+	return e.number
+}
+
+// exprParser parses expressions by recursive descent
+type exprParser struct {
+	s         string
+	pos       int
+	variables []string
+}
+
+// peek skips spaces and returns the next byte, or 0 at the end
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// comparison parses sums joined by < > <= and >=
+func (p *exprParser) comparison() (*Expr, error) {
+	e, err := p.sum()
+	for err == nil && (p.peek() == '<' || p.peek() == '>') {
+		op := p.s[p.pos]
+		p.pos++
+		if p.pos < len(p.s) && p.s[p.pos] == '=' {
+			op = map[byte]byte{'<': 'l', '>': 'g'}[op]
+			p.pos++
+		}
+		var right *Expr
+		if right, err = p.sum(); err == nil {
+			e = &Expr{op: op, left: e, right: right}
+		}
+	}
+	return e, err
+}
+
+// sum parses terms joined by + and -
+func (p *exprParser) sum() (*Expr, error) {
+	e, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.s[p.pos]
+		p.pos++
+		var right *Expr
+		if right, err = p.product(); err == nil {
+			e = &Expr{op: op, left: e, right: right}
+		}
+	}
+	return e, err
+}
+
+// product parses factors joined by * and /
+func (p *exprParser) product() (*Expr, error) {
+	e, err := p.factor()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.s[p.pos]
+		p.pos++
+		var right *Expr
+		if right, err = p.factor(); err == nil {
+			e = &Expr{op: op, left: e, right: right}
+		}
+	}
+	return e, err
+}
+
+// factor parses a negation, a parenthesized comparison, a number or a
+// variable
+func (p *exprParser) factor() (*Expr, error) {
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		e, err := p.factor()
+		return &Expr{op: 'n', left: e}, err
+	case c == '(':
+		p.pos++
+		e, err := p.comparison()
+		if err == nil && p.peek() != ')' {
+			err = fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, err
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.s[start:p.pos])
+		}
+		return &Expr{number: v}, nil
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' ||
+			p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z' || p.s[p.pos] >= '0' && p.s[p.pos] <= '9') {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		if !slices.Contains(p.variables, name) {
+			return nil, fmt.Errorf("unknown variable %q (known: %s)", name, strings.Join(p.variables, ", "))
+		}
+		return &Expr{variable: name}, nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+}
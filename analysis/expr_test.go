@@ -0,0 +1,37 @@
+package analysis
+
+import "testing"
+
+func TestExprComparisons(t *testing.T) {
+	vars := map[string]float64{"fish": 3, "sharks": 2}
+	value := func(name string) float64 { return vars[name] }
+	for _, tc := range []struct {
+		src  string
+		want float64
+	}{
+		{"fish > sharks", 1},
+		{"fish < sharks", 0},
+		{"fish >= 3", 1},
+		{"fish <= 2", 0},
+		{"sharks <= 2", 1},
+		{"fish + 1 > sharks * 2", 0}, // Sums before comparisons
+		{"2 * (fish > sharks) + 1", 3},
+		{"1 < 2 < 3", 1}, // (1 < 2) < 3
+		{"-fish < -sharks", 1},
+	} {
+		e, err := ParseExpr(tc.src, []string{"fish", "sharks"})
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", tc.src, err)
+			continue
+		}
+		if got := e.Eval(value); got != tc.want {
+			t.Errorf("%q = %g, want %g", tc.src, got, tc.want)
+		}
+	}
+
+	for _, src := range []string{"fish >", "fish < = 2", "(fish > 1", "fish => 1"} {
+		if _, err := ParseExpr(src, []string{"fish"}); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded, want an error", src)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package analysis
+
+// ExtinctionRule defines quasi-extinction: a species counts as extinct once
+// it has stayed below Threshold individuals for Duration consecutive
+// chronons. The classic rule (Threshold 1, Duration 1) means "none left".
+type ExtinctionRule struct {
+	Threshold int
+	Duration  int
+}
+
+// DefaultExtinction declares a species extinct as soon as none are left
+var DefaultExtinction = ExtinctionRule{Threshold: 1, Duration: 1}
+
+// ExtinctionTracker applies an ExtinctionRule to a population time series
+type ExtinctionTracker struct {
+	Rule ExtinctionRule
+
+	// Step at which each species (fish, sharks) became quasi-extinct, i.e.
+	// the first step of the qualifying run below the threshold, or -1
+	FishExtinct  int
+	SharkExtinct int
+
+	fishSince  int // First step of the current run below threshold, or -1
+	sharkSince int
+}
+
+// NewExtinctionTracker creates a tracker for the given rule
+func NewExtinctionTracker(rule ExtinctionRule) *ExtinctionTracker {
+	return &ExtinctionTracker{
+		Rule:         rule,
+		FishExtinct:  -1,
+		SharkExtinct: -1,
+		fishSince:    -1,
+		sharkSince:   -1,
+	}
+}
+
+// Observe records the populations at a step, which must be called once per
+// chronon in order. It returns whether either species is now extinct.
+func (t *ExtinctionTracker) Observe(step, fish, sharks int) bool {
+	t.FishExtinct = t.update(step, fish, &t.fishSince, t.FishExtinct)
+	t.SharkExtinct = t.update(step, sharks, &t.sharkSince, t.SharkExtinct)
+	return t.Ended()
+}
+
+// Ended reports whether either species is extinct
+func (t *ExtinctionTracker) Ended() bool {
+	return t.FishExtinct >= 0 || t.SharkExtinct >= 0
+}
+
+// Reason describes which species died out, or returns "" if neither did
+func (t *ExtinctionTracker) Reason() string {
+	quasi := t.Rule.Threshold > 1 || t.Rule.Duration > 1
+	switch {
+	case t.FishExtinct >= 0 && quasi:
+		return "Fish quasi-extinct"
+	case t.FishExtinct >= 0:
+		return "All fish died"
+	case t.SharkExtinct >= 0 && quasi:
+		return "Sharks quasi-extinct"
+	case t.SharkExtinct >= 0:
+		return "All sharks died"
+	default:
+		return ""
+	}
+}
+
+func (t *ExtinctionTracker) update(step, count int, since *int, extinct int) int {
+	if extinct >= 0 {
+		return extinct
+	}
+	if count >= t.Rule.Threshold {
+		*since = -1
+		return -1
+	}
+	if *since < 0 {
+		*since = step
+	}
+	if step-*since+1 >= t.Rule.Duration {
+		return *since
+	}
+	return -1
+}
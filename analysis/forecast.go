@@ -0,0 +1,85 @@
+package analysis
+
+// LotkaVolterra is a discrete-time Lotka-Volterra model of the two
+// populations, advanced one chronon at a time:
+//
+//	fish'   = fish   + fish   * (FishGrowth - Predation*sharks)
+//	sharks' = sharks + sharks * (Conversion*fish - SharkDeath)
+type LotkaVolterra struct {
+	FishGrowth float64
+	Predation  float64
+	Conversion float64
+	SharkDeath float64
+}
+
+// minFitPoints is the number of transitions needed for a meaningful fit
+const minFitPoints = 10
+
+// FitLotkaVolterra fits the model to a population history by least squares
+// on the per-capita growth rates of consecutive chronons. It reports false
+// if the history is too short or either species died out in it.
+func FitLotkaVolterra(fish, sharks []float64) (LotkaVolterra, bool) {
+	n := min(len(fish), len(sharks)) - 1
+	if n < minFitPoints {
+		return LotkaVolterra{}, false
+	}
+
+	// Fish growth rate against sharks, shark growth rate against fish
+	fishRate := make([]float64, 0, n)
+	sharkRate := make([]float64, 0, n)
+	for t := range n {
+		if fish[t] <= 0 || sharks[t] <= 0 {
+			return LotkaVolterra{}, false
+		}
+		fishRate = append(fishRate, (fish[t+1]-fish[t])/fish[t])
+		sharkRate = append(sharkRate, (sharks[t+1]-sharks[t])/sharks[t])
+	}
+
+	fishIntercept, fishSlope := linearFit(sharks[:n], fishRate)
+	sharkIntercept, sharkSlope := linearFit(fish[:n], sharkRate)
+	return LotkaVolterra{
+		FishGrowth: fishIntercept,
+		Predation:  -fishSlope,
+		Conversion: sharkSlope,
+		SharkDeath: -sharkIntercept,
+	}, true
+}
+
+// Forecast iterates the model for steps chronons from the given populations.
+// Populations are clamped to [0, limit] so an unstable fit cannot overflow.
+func (m LotkaVolterra) Forecast(fish, sharks float64, steps int, limit float64) (fishOut, sharksOut []float64) {
+	fishOut = make([]float64, steps)
+	sharksOut = make([]float64, steps)
+	for t := range steps {
+		nextFish := fish + fish*(m.FishGrowth-m.Predation*sharks)
+		nextSharks := sharks + sharks*(m.Conversion*fish-m.SharkDeath)
+		fish = max(0, min(limit, nextFish))
+		sharks = max(0, min(limit, nextSharks))
+		fishOut[t], sharksOut[t] = fish, sharks
+	}
+	return fishOut, sharksOut
+}
+
+// linearFit returns the least-squares intercept and slope of y against x.
+// A constant x yields a zero slope and the mean of y.
+func linearFit(x, y []float64) (intercept, slope float64) {
+	n := float64(len(x))
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, variance float64
+	for i := range x {
+		dx := x[i] - meanX
+		cov += dx * (y[i] - meanY)
+		variance += dx * dx
+	}
+	if variance > 0 {
+		slope = cov / variance
+	}
+	return meanY - slope*meanX, slope
+}
@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"math"
+
+	"wa-tor/simulation"
+)
+
+// neighbors is the number of cells an animal can move to
+const neighbors = 4
+
+// MeanField is the mean-field approximation of Wa-Tor: the grid is replaced
+// by fish and shark densities, and every animal sees neighbours drawn
+// independently from them. Per chronon, with e the empty density:
+//
+//	eaten  = sharks * (1 - (1-fish)^4)             sharks next to a fish eat one
+//	births = (fish-eaten)/FishBreed * (1 - (1-e)^4) breeding needs an empty neighbour
+//	fish'  = fish - eaten + births
+//	sharks' = sharks + sharks/SharkBreed * (1 - sharks^4) - sharks * (1-fish)^(4*SharkStarve)
+//
+// where the last term is the chance of finding no fish for SharkStarve
+// chronons in a row. Terrain, currents, algae and energy gains are ignored.
+type MeanField struct {
+	FishBreed   float64
+	SharkBreed  float64
+	SharkStarve float64
+	Cells       float64 // Water cells the densities refer to
+	Fish        float64 // Density of fish
+	Sharks      float64 // Density of sharks
+}
+
+// NewMeanField creates a mean-field model with the parameters and current
+// populations of the world
+func NewMeanField(w *simulation.World) *MeanField {
+	cells := w.Width * w.Height
+	if w.Terrain != nil {
+		cells = w.Terrain.WaterCells()
+	}
+	fish, sharks := w.Count()
+	n := float64(max(cells, 1))
+	return &MeanField{
+		FishBreed:   float64(w.FishBreed),
+		SharkBreed:  float64(w.SharkBreed),
+		SharkStarve: float64(w.SharkStarve),
+		Cells:       n,
+		Fish:        float64(fish) / n,
+		Sharks:      float64(sharks) / n,
+	}
+}
+
+// Step advances the model by one chronon
+func (m *MeanField) Step() {
+	f, s := m.Fish, m.Sharks
+	empty := max(0, 1-f-s)
+	noFish := math.Pow(1-f, neighbors)
+
+	eaten := s * (1 - noFish)
+	births := (f - eaten) / m.FishBreed * (1 - math.Pow(1-empty, neighbors))
+	sharkBirths := s / m.SharkBreed * (1 - math.Pow(s, neighbors))
+	starved := s * math.Pow(noFish, m.SharkStarve)
+
+	m.Fish = min(1, max(0, f-eaten+births))
+	m.Sharks = min(1-m.Fish, max(0, s+sharkBirths-starved))
+}
+
+// Populations returns the model's fish and shark counts
+func (m *MeanField) Populations() (fish, sharks float64) {
+	return m.Fish * m.Cells, m.Sharks * m.Cells
+}
+
+// MeanFieldComparison runs a mean-field model alongside a simulation and
+// measures how far the simulated populations deviate from it
+type MeanFieldComparison struct {
+	Model    *MeanField
+	Steps    int
+	sumFish  float64 // Sums of squared deviations
+	sumShark float64
+}
+
+// NewMeanFieldComparison starts a comparison from the world's current state
+func NewMeanFieldComparison(w *simulation.World) *MeanFieldComparison {
+	return &MeanFieldComparison{Model: NewMeanField(w)}
+}
+
+// Observe advances the model by one chronon and records its deviation from
+// the simulated populations. It returns the model populations.
+func (c *MeanFieldComparison) Observe(fish, sharks int) (modelFish, modelSharks float64) {
+	c.Model.Step()
+	modelFish, modelSharks = c.Model.Populations()
+	c.Steps++
+	c.sumFish += (float64(fish) - modelFish) * (float64(fish) - modelFish)
+	c.sumShark += (float64(sharks) - modelSharks) * (float64(sharks) - modelSharks)
+	return modelFish, modelSharks
+}
+
+// RMS returns the root-mean-square deviation of the simulated fish and
+// shark populations from the model so far
+func (c *MeanFieldComparison) RMS() (fish, sharks float64) {
+	if c.Steps == 0 {
+		return 0, 0
+	}
+	n := float64(c.Steps)
+	return math.Sqrt(c.sumFish / n), math.Sqrt(c.sumShark / n)
+}
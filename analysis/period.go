@@ -0,0 +1,119 @@
+package analysis
+
+import "math"
+
+// maxPeriodLag is the longest period OscillationPeriod looks for, which
+// bounds its cost on long runs
+const maxPeriodLag = 1000
+
+// OscillationPeriod estimates the period of a population time series, one
+// value per chronon, as the lag of the highest peak of its autocorrelation
+// after the autocorrelation first turns negative. The first tenth of the
+// series is skipped as the transient, and lags go up to half of the rest or
+// maxPeriodLag. It returns 0 if the series does not oscillate, i.e. there is
+// no such peak above 0.1.
+func OscillationPeriod(series []float64) float64 {
+	series = series[len(series)/10:]
+	n := len(series)
+	if n < 4 {
+		return 0
+	}
+
+	var mean, variance float64
+	for _, v := range series {
+		mean += v
+	}
+	mean /= float64(n)
+	for _, v := range series {
+		variance += (v - mean) * (v - mean)
+	}
+	if variance == 0 {
+		return 0
+	}
+
+	period, peak := 0, 0.1
+	negative := false
+	for lag := 1; lag <= min(n/2, maxPeriodLag); lag++ {
+		var c float64
+		for i := lag; i < n; i++ {
+			c += (series[i] - mean) * (series[i-lag] - mean)
+		}
+		c /= variance
+		if c < 0 {
+			negative = true
+		} else if negative && c > peak {
+			period, peak = lag, c
+		}
+	}
+	return float64(period)
+}
+
+// Oscillations summarises the predator-prey cycle of a run
+type Oscillations struct {
+	FishPeriod  float64 // Period of the fish, or 0 (see OscillationPeriod)
+	SharkPeriod float64 // Period of the sharks, or 0
+	Lag         float64 // Chronons by which the sharks follow the fish, or -1 (see PhaseLag)
+}
+
+// AnalyzeOscillations estimates the periods of both populations, one value
+// per chronon each, and the lag of the sharks behind the fish
+func AnalyzeOscillations(fish, sharks []float64) Oscillations {
+	o := Oscillations{FishPeriod: OscillationPeriod(fish), SharkPeriod: OscillationPeriod(sharks)}
+	period := o.FishPeriod
+	if period == 0 {
+		period = o.SharkPeriod
+	}
+	o.Lag = PhaseLag(fish, sharks, period)
+	return o
+}
+
+// Phase returns the lag of the sharks as an angle of the fish cycle in
+// degrees, or -1 if there is no lag
+func (o Oscillations) Phase() float64 {
+	if o.Lag < 0 || o.FishPeriod == 0 {
+		return -1
+	}
+	return o.Lag / o.FishPeriod * 360
+}
+
+// PhaseLag estimates by how many chronons the predator series follows the
+// prey series, as the lag in [0, period) at which their cross-correlation
+// peaks. In Lotka-Volterra cycles the predators peak about a quarter of a
+// period after their prey. As for OscillationPeriod, the first tenth is
+// skipped. It returns -1 if period is 0 or either series is constant.
+func PhaseLag(prey, predator []float64, period float64) float64 {
+	n := min(len(prey), len(predator))
+	prey, predator = prey[n/10:n], predator[n/10:n]
+	n = len(prey)
+	if period < 1 || n < 2 {
+		return -1
+	}
+
+	var preyMean, predMean float64
+	for i := range n {
+		preyMean += prey[i]
+		predMean += predator[i]
+	}
+	preyMean /= float64(n)
+	predMean /= float64(n)
+	var preyVar, predVar float64
+	for i := range n {
+		preyVar += (prey[i] - preyMean) * (prey[i] - preyMean)
+		predVar += (predator[i] - predMean) * (predator[i] - predMean)
+	}
+	if preyVar == 0 || predVar == 0 {
+		return -1
+	}
+
+	best, peak := -1, math.Inf(-1)
+	for lag := 0; lag < min(int(period), n); lag++ {
+		var c float64
+		for i := 0; i+lag < n; i++ {
+			c += (prey[i] - preyMean) * (predator[i+lag] - predMean)
+		}
+		if c /= float64(n - lag); c > peak {
+			best, peak = lag, c
+		}
+	}
+	return float64(best)
+}
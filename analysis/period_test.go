@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// sine returns n values of a sine around mean with the given amplitude and
+// period, delayed by lag
+func sine(n int, mean, amplitude, period, lag float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = mean + amplitude*math.Sin(2*math.Pi*(float64(i)-lag)/period)
+	}
+	return s
+}
+
+// noise returns n values spread uniformly within amplitude of mean
+func noise(n int, mean, amplitude float64, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = mean + amplitude*(2*rng.Float64()-1)
+	}
+	return s
+}
+
+func TestOscillationPeriod(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		series []float64
+		want   float64
+	}{
+		{"sine", sine(2000, 500, 200, 60, 0), 60},
+		{"slow sine", sine(3000, 500, 200, 240, 0), 240},
+		{"flat", sine(2000, 500, 0, 60, 0), 0},
+		{"noise", noise(2000, 500, 200, 1), 0},
+		{"too short", sine(3, 500, 200, 2, 0), 0},
+	} {
+		if got := OscillationPeriod(tc.series); math.Abs(got-tc.want) > 1 {
+			t.Errorf("%s: OscillationPeriod() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPhaseLag(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		prey, predator []float64
+		period, want   float64
+	}{
+		{"quarter period", sine(2000, 500, 200, 60, 0), sine(2000, 100, 40, 60, 15), 60, 15},
+		{"in phase", sine(2000, 500, 200, 60, 0), sine(2000, 100, 40, 60, 0), 60, 0},
+		{"wrapped", sine(2000, 500, 200, 60, 0), sine(2000, 100, 40, 60, -10), 60, 50},
+		{"flat predator", sine(2000, 500, 200, 60, 0), sine(2000, 100, 0, 60, 0), 60, -1},
+		{"no period", sine(2000, 500, 200, 60, 0), sine(2000, 100, 40, 60, 15), 0, -1},
+	} {
+		if got := PhaseLag(tc.prey, tc.predator, tc.period); got != tc.want {
+			t.Errorf("%s: PhaseLag() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	o := AnalyzeOscillations(sine(2000, 500, 200, 60, 0), sine(2000, 100, 40, 60, 15))
+	if o.FishPeriod != 60 || o.SharkPeriod != 60 || o.Lag != 15 || o.Phase() != 90 {
+		t.Errorf("AnalyzeOscillations() = %+v with phase %v, want periods of 60 and a lag of 15, or 90 degrees", o, o.Phase())
+	}
+	if o := AnalyzeOscillations(noise(2000, 500, 200, 1), noise(2000, 100, 40, 2)); o.Lag != -1 || o.Phase() != -1 {
+		t.Errorf("AnalyzeOscillations() of noise = %+v, want no lag", o)
+	}
+}
@@ -0,0 +1,36 @@
+package analysis
+
+import "fmt"
+
+// Marker labels a chronon at which something changed during a run, such as
+// a parameter adjustment
+type Marker struct {
+	Step  int    `json:"step"`
+	Label string `json:"label"`
+}
+
+// Timeline is the ordered list of markers recorded during a run
+type Timeline struct {
+	Markers []Marker
+}
+
+// Mark records a marker at step
+func (t *Timeline) Mark(step int, label string) {
+	t.Markers = append(t.Markers, Marker{Step: step, Label: label})
+}
+
+// MarkParameter records a parameter change from old to value at step
+func (t *Timeline) MarkParameter(step int, name string, old, value float64) {
+	t.Mark(step, fmt.Sprintf("%s %g -> %g", name, old, value))
+}
+
+// Between returns the markers with from <= Step <= to
+func (t *Timeline) Between(from, to int) []Marker {
+	var markers []Marker
+	for _, m := range t.Markers {
+		if m.Step >= from && m.Step <= to {
+			markers = append(markers, m)
+		}
+	}
+	return markers
+}
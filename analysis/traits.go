@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"slices"
+
+	"wa-tor/simulation"
+)
+
+// TraitHistogram counts the animals of one population by the value of one
+// heritable trait
+type TraitHistogram struct {
+	Population string // "fish" or the name of a predator species
+	Trait      string // "breed" or "starve"
+	Counts     map[int]int
+}
+
+// Values returns the trait values present, in increasing order
+func (h TraitHistogram) Values() []int {
+	values := make([]int, 0, len(h.Counts))
+	for v := range h.Counts {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	return values
+}
+
+// Mean returns the average trait value, or 0 for an empty population
+func (h TraitHistogram) Mean() float64 {
+	sum, n := 0, 0
+	for v, count := range h.Counts {
+		sum += v * count
+		n += count
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// TraitHistograms returns the breed and starve time histograms of fish,
+// sharks and every other predator species that has individuals. Fish starve
+// times are only included when fish eat algae.
+func TraitHistograms(w *simulation.World) []TraitHistogram {
+	names := []string{"fish", "sharks"}
+	for _, species := range w.Species {
+		names = append(names, species.Name)
+	}
+	breed := make([]map[int]int, len(names))
+	starve := make([]map[int]int, len(names))
+	for pop := range names {
+		breed[pop], starve[pop] = map[int]int{}, map[int]int{}
+	}
+
+	for i := range w.Height {
+		for j := range w.Width {
+			cell := w.Cell(i, j)
+			pop := 0
+			switch cell.Type {
+			case simulation.Fish:
+			case simulation.Shark:
+				pop = 1 + min(cell.Species, len(w.Species))
+			default:
+				continue
+			}
+
+			b, s := w.Traits(cell)
+			breed[pop][b]++
+			if cell.Type == simulation.Shark || w.FishStarve > 0 {
+				starve[pop][s]++
+			}
+		}
+	}
+
+	var histograms []TraitHistogram
+	for pop, name := range names {
+		if len(breed[pop]) > 0 {
+			histograms = append(histograms, TraitHistogram{Population: name, Trait: "breed", Counts: breed[pop]})
+		}
+		if len(starve[pop]) > 0 {
+			histograms = append(histograms, TraitHistogram{Population: name, Trait: "starve", Counts: starve[pop]})
+		}
+	}
+	return histograms
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/simulation"
+)
+
+// runLinkedWorlds runs the worlds listed in the config file side by side in
+// headless mode, applying the configured migration events between them
+func runLinkedWorlds(cfg *config.Config) error {
+	configs, err := cfg.LinkedWorlds()
+	if err != nil {
+		return err
+	}
+
+	worlds := make([]*simulation.World, len(configs))
+	extinction := make([]*analysis.ExtinctionTracker, len(configs))
+	for i, c := range configs {
+		if worlds[i], err = newWorld(c); err != nil {
+			return fmt.Errorf("world %d: %w", i, err)
+		}
+		extinction[i] = analysis.NewExtinctionTracker(extinctionRule(c))
+		fish, sharks := worlds[i].Count()
+		extinction[i].Observe(0, fish, sharks)
+		fmt.Printf("World %d: %dx%d, Fish: %d, Sharks: %d, Fish Breed: %d, Shark Breed: %d, Starve: %d\n",
+			i, worlds[i].Width, worlds[i].Height, c.NumFish, c.NumShark, c.FishBreed, c.SharkBreed, c.Starve)
+	}
+	for _, m := range cfg.Migration {
+		fmt.Printf("Migration %d -> %d: %.1f%% every %d steps\n", m.From, m.To, 100*m.Fraction, m.Every)
+	}
+
+	fmt.Println("\nRunning linked worlds in headless mode...")
+	startTime := time.Now()
+	fishEaten := make([]int, len(worlds))
+	migrants := make([][2]int, len(cfg.Migration))
+
+	step := 0
+	for ; step < cfg.Steps; step++ {
+		alive := false
+		for _, t := range extinction {
+			if !t.Ended() {
+				alive = true
+			}
+		}
+		if !alive {
+			fmt.Printf("\nNo world has both species left at step %d\n", step)
+			break
+		}
+
+		for i, w := range worlds {
+			fishEaten[i] += w.Step(configs[i].Threads)
+			if cfg.Verify {
+				if err := w.CheckInvariants(); err != nil {
+					return fmt.Errorf("world %d violated an invariant at step %d: %v", i, step+1, err)
+				}
+			}
+		}
+		for i, m := range cfg.Migration {
+			if (step+1)%m.Every == 0 {
+				f, s := simulation.Migrate(worlds[m.From], worlds[m.To], m.Fraction)
+				migrants[i][0] += f
+				migrants[i][1] += s
+			}
+		}
+		for i, w := range worlds {
+			fish, sharks := w.Count()
+			extinction[i].Observe(step+1, fish, sharks)
+		}
+	}
+
+	elapsed := time.Since(startTime)
+	fmt.Printf("\nSimulation completed\n")
+	fmt.Printf("Steps completed: %d\n", step)
+	printSimulatedTime(cfg, step)
+	for i, w := range worlds {
+		fish, sharks := w.Count()
+		fmt.Printf("World %d - Fish: %d, Sharks: %d, Fish eaten: %d\n", i, fish, sharks, fishEaten[i])
+		printExtinction(extinction[i])
+	}
+	for i, m := range cfg.Migration {
+		fmt.Printf("Migrated %d -> %d - Fish: %d, Sharks: %d\n", m.From, m.To, migrants[i][0], migrants[i][1])
+	}
+	fmt.Printf("Total execution time: %v\n", elapsed)
+	return nil
+}
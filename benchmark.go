@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"wa-tor/config"
+	"wa-tor/simulation"
+)
+
+// benchmarkSteps is the number of steps timed by -benchmark without -steps
+const benchmarkSteps = 500
+
+// runBenchmark implements -benchmark: it runs the configured world from the
+// same seed for the same number of steps with 1, 2, 4, ... threads, up to
+// -threads or, if that is 1, the number of CPUs, and prints the speedup and
+// parallel efficiency of each thread count over one thread.
+func runBenchmark(cfg *config.Config) error {
+	steps := cfg.Steps
+	if steps == 0 {
+		steps = benchmarkSteps
+	}
+	most := cfg.Threads
+	if most == 1 {
+		most = runtime.NumCPU()
+	}
+	var counts []int
+	for n := 1; n < most; n *= 2 {
+		counts = append(counts, n)
+	}
+	counts = append(counts, most)
+
+	fmt.Printf("Benchmarking %d steps with up to %d threads on %d CPUs\n\n", steps, most, runtime.NumCPU())
+	fmt.Printf("%8s %12s %10s %9s %11s %8s %8s\n", "Threads", "Time", "Steps/s", "Speedup", "Efficiency", "Fish", "Sharks")
+
+	var base time.Duration
+	for _, threads := range counts {
+		world, err := newWorld(cfg)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		for range steps {
+			world.Step(threads)
+		}
+		elapsed := time.Since(start)
+		if threads == 1 {
+			base = elapsed
+		}
+
+		speedup := base.Seconds() / elapsed.Seconds()
+		fish, sharks := world.Count()
+		fmt.Printf("%8d %12v %10.1f %8.2fx %10.0f%% %8d %8d\n", threads, elapsed.Round(time.Microsecond),
+			float64(steps)/elapsed.Seconds(), speedup, 100*speedup/float64(threads), fish, sharks)
+	}
+	return nil
+}
+
+// speedupBudget bounds the serial sample timed after a window run with
+// -threads > 1, so that measuring the speedup of a long run stays short
+const speedupBudget = 2 * time.Second
+
+// printSpeedup reruns the configured world from its seed after a window run
+// with -threads > 1, first serially on one thread for at most the steps of
+// the run or speedupBudget, then for as many steps with the threads and
+// engine of the run, and prints the speedup and parallel efficiency. The
+// time of the run itself includes rendering and cannot be compared.
+func printSpeedup(cfg *config.Config, steps int) error {
+	if cfg.Threads < 2 || steps < 1 {
+		return nil
+	}
+	world, err := newWorld(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nMeasuring speedup over a serial sample (up to %d steps or %v)...\n", steps, speedupBudget)
+	start := time.Now()
+	n := 0
+	for n < steps && time.Since(start) < speedupBudget {
+		world.StepWith(simulation.EngineSerial, 1)
+		n++
+	}
+	serial := time.Since(start)
+
+	if world, err = newWorld(cfg); err != nil {
+		return err
+	}
+	engine := cfg.StepEngine()
+	start = time.Now()
+	for range n {
+		world.StepWith(engine, cfg.Threads)
+	}
+	parallel := time.Since(start)
+
+	speedup := serial.Seconds() / parallel.Seconds()
+	fmt.Printf("Serial (1 thread): %v for %d steps, %s (%d threads): %v\n",
+		serial.Round(time.Microsecond), n, engine, cfg.Threads, parallel.Round(time.Microsecond))
+	fmt.Printf("Speedup: %.2fx, efficiency: %.0f%%\n", speedup, 100*speedup/float64(cfg.Threads))
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/runner"
+	"wa-tor/simulation"
+)
+
+// setFlags collects repeated -set name=value parameter overrides
+type setFlags []string
+
+func (s *setFlags) String() string     { return strings.Join(*s, ",") }
+func (s *setFlags) Set(v string) error { *s = append(*s, v); return nil }
+
+// runBranch implements "wa-tor branch snap.json [flags]": it loads a snapshot
+// and runs several continuations with modified parameters and different
+// seeds, then reports aggregated outcome statistics.
+func runBranch(args []string) error {
+	fs := flag.NewFlagSet("branch", flag.ContinueOnError)
+	var sets setFlags
+	fs.Var(&sets, "set", "Parameter override name=value (fbreed, sbreed, starve, sgain); may be repeated")
+	runs := fs.Int("runs", 10, "Number of continuations to run")
+	steps := fs.Int("steps", 1000, "Maximum steps per continuation")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "Seed of the first continuation (run i uses seed+i)")
+	threads := fs.Int("threads", runtime.NumCPU(), "Number of continuations to run concurrently")
+	rule := analysis.DefaultExtinction
+	fs.IntVar(&rule.Threshold, "extinct-below", rule.Threshold, "Species with fewer individuals than this count as extinct (see -extinct-for)")
+	fs.IntVar(&rule.Duration, "extinct-for", rule.Duration, "Consecutive steps a species must stay below -extinct-below to count as extinct")
+
+	// Allow the snapshot path before or after the flags
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		return fmt.Errorf("usage: wa-tor branch snapshot.json [-set name=value] [-runs N]")
+	}
+	if *runs < 1 || *steps < 1 || *threads < 1 || rule.Threshold < 1 || rule.Duration < 1 {
+		return fmt.Errorf("runs, steps, threads and extinction parameters must be positive")
+	}
+
+	snap, err := simulation.LoadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	for _, s := range sets {
+		if err := applyOverride(snap, s); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Branching from step %d of %s\n", snap.Step, path)
+	fmt.Printf("Fish Breed: %d, Shark Breed: %d, Starve: %d\n", snap.FishBreed, snap.SharkBreed, snap.SharkStarve)
+	fmt.Printf("Runs: %d, Steps: %d, Seeds: %d..%d\n\n", *runs, *steps, *seed, *seed+int64(*runs-1))
+
+	results := runner.RunAll(*runs, *threads, func(i int) runner.Result {
+		s := *seed + int64(i)
+		return runner.Run(snap.World(s), s, runner.Options{MaxSteps: *steps, Threads: 1, Extinction: rule})
+	})
+
+	for _, r := range results {
+		fmt.Printf("Seed %d: %s\n", r.Seed, describeOutcome(r, snap.Step))
+	}
+	printSummary(runner.Summarize(results), snap.Step)
+	return nil
+}
+
+// applyOverride applies a single name=value override to the snapshot
+func applyOverride(snap *simulation.Snapshot, s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("invalid -set %q, expected name=value", s)
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 1 {
+		return fmt.Errorf("invalid -set %q, value must be a positive integer", s)
+	}
+
+	switch name {
+	case "fbreed":
+		snap.FishBreed = v
+	case "sbreed":
+		snap.SharkBreed = v
+	case "starve":
+		snap.SharkStarve = v
+	case "sgain":
+		snap.SharkGain = v
+	case "fishage":
+		snap.FishAge = v
+	default:
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	return nil
+}
+
+// describeOutcome formats how a continuation ended, in absolute steps
+func describeOutcome(r runner.Result, offset int) string {
+	switch {
+	case r.FishExtinct >= 0:
+		return fmt.Sprintf("fish extinct at step %d", offset+r.FishExtinct)
+	case r.SharkExtinct >= 0:
+		return fmt.Sprintf("sharks extinct at step %d", offset+r.SharkExtinct)
+	default:
+		return fmt.Sprintf("coexisting at step %d (fish %d, sharks %d)", offset+r.Steps, r.FinalFish, r.FinalSharks)
+	}
+}
+
+// printSummary prints aggregated statistics over all continuations
+func printSummary(s runner.Summary, offset int) {
+	fmt.Printf("\nOutcomes over %d runs\n", s.Runs)
+	fmt.Printf("Fish extinct: %d (%.0f%%)", s.FishExtinct, 100*float64(s.FishExtinct)/float64(s.Runs))
+	if s.FishExtinct > 0 {
+		fmt.Printf(", at step %.0f ± %.0f", float64(offset)+s.FishExtTime.Mean, s.FishExtTime.StdDev)
+	}
+	fmt.Printf("\nSharks extinct: %d (%.0f%%)", s.SharkExtinct, 100*float64(s.SharkExtinct)/float64(s.Runs))
+	if s.SharkExtinct > 0 {
+		fmt.Printf(", at step %.0f ± %.0f", float64(offset)+s.SharkExtTime.Mean, s.SharkExtTime.StdDev)
+	}
+	fmt.Printf("\nFinal fish: %.1f ± %.1f\n", s.FinalFish.Mean, s.FinalFish.StdDev)
+	fmt.Printf("Final sharks: %.1f ± %.1f\n", s.FinalSharks.Mean, s.FinalSharks.StdDev)
+	fmt.Printf("Fish eaten: %.1f ± %.1f\n", s.FishEaten.Mean, s.FishEaten.StdDev)
+	fmt.Printf("Fish range: min %.1f ± %.1f, max %.1f ± %.1f\n", s.MinFish.Mean, s.MinFish.StdDev, s.MaxFish.Mean, s.MaxFish.StdDev)
+	fmt.Printf("Shark range: min %.1f ± %.1f, max %.1f ± %.1f\n", s.MinSharks.Mean, s.MinSharks.StdDev, s.MaxSharks.Mean, s.MaxSharks.StdDev)
+}
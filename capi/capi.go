@@ -0,0 +1,108 @@
+// Package main exposes the simulation core as a C shared library so it can be
+// driven from other languages (see python/wator.py).
+//
+// Build with:
+//
+//	go build -buildmode=c-shared -o python/libwator.so ./capi
+package main
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"wa-tor/simulation"
+)
+
+// Worlds are referenced from C through integer handles, since Go pointers
+// must not be retained by foreign code
+var (
+	mu     sync.Mutex
+	worlds = map[int]*simulation.World{}
+	nextID = 1
+)
+
+func lookup(handle C.int) *simulation.World {
+	mu.Lock()
+	defer mu.Unlock()
+	return worlds[int(handle)]
+}
+
+// WatorNewWorld creates a world and returns its handle (0 on invalid parameters)
+//
+//export WatorNewWorld
+func WatorNewWorld(width, height, numFish, numShark, fishBreed, sharkBreed, starve C.int) C.int {
+	if width < 1 || height < 1 || fishBreed < 1 || sharkBreed < 1 || starve < 1 ||
+		numFish < 0 || numShark < 0 || numFish+numShark > width*height {
+		return 0
+	}
+
+	w := simulation.NewWorld(
+		int(width), int(height),
+		int(numFish), int(numShark),
+		int(fishBreed), int(sharkBreed), int(starve),
+	)
+
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	worlds[id] = w
+	return C.int(id)
+}
+
+// WatorFreeWorld releases the world referenced by handle
+//
+//export WatorFreeWorld
+func WatorFreeWorld(handle C.int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(worlds, int(handle))
+}
+
+// WatorStep advances the world one chronon and returns the number of fish eaten
+//
+//export WatorStep
+func WatorStep(handle, threads C.int) C.int {
+	w := lookup(handle)
+	if w == nil || threads < 1 {
+		return -1
+	}
+	return C.int(w.Step(int(threads)))
+}
+
+// WatorCount stores the current fish and shark populations
+//
+//export WatorCount
+func WatorCount(handle C.int, fish, sharks *C.int) C.int {
+	w := lookup(handle)
+	if w == nil {
+		return -1
+	}
+	f, s := w.Count()
+	*fish, *sharks = C.int(f), C.int(s)
+	return 0
+}
+
+// WatorGrid copies the cell types (0=empty, 1=fish, 2=shark, 3=barrier) row by row into
+// buf, which must hold at least width*height bytes
+//
+//export WatorGrid
+func WatorGrid(handle C.int, buf *C.uint8_t, size C.int) C.int {
+	w := lookup(handle)
+	if w == nil || int(size) < w.Width*w.Height {
+		return -1
+	}
+
+	out := unsafe.Slice((*uint8)(unsafe.Pointer(buf)), int(size))
+	for i, cell := range w.Grid {
+		out[i] = uint8(cell.Type)
+	}
+	return 0
+}
+
+func main() {}
@@ -0,0 +1,23 @@
+// Package clipboard copies text to the system clipboard by running the
+// platform's clipboard tool (wl-copy, xclip or xsel, pbcopy, clip).
+package clipboard
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard tool is installed
+var ErrUnavailable = errors.New("no clipboard tool available (install wl-clipboard, xclip or xsel)")
+
+// WriteText replaces the clipboard contents with text
+func WriteText(text string) error {
+	name, args, err := command()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
@@ -0,0 +1,6 @@
+package clipboard
+
+// command returns the macOS clipboard tool
+func command() (string, []string, error) {
+	return "pbcopy", nil, nil
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !darwin
+
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+)
+
+// command returns the first clipboard tool found for the running session
+func command() (string, []string, error) {
+	candidates := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append([][]string{{"wl-copy"}}, candidates...)
+	}
+	for _, c := range candidates {
+		if path, err := exec.LookPath(c[0]); err == nil {
+			return path, c[1:], nil
+		}
+	}
+	return "", nil, ErrUnavailable
+}
@@ -0,0 +1,6 @@
+package clipboard
+
+// command returns the Windows clipboard tool
+func command() (string, []string, error) {
+	return "clip", nil, nil
+}
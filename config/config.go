@@ -1,22 +1,127 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/frame"
+	"wa-tor/server"
+	"wa-tor/simulation"
 )
 
 // Config holds all simulation configuration parameters
 type Config struct {
-	NumShark   int
-	NumFish    int
-	FishBreed  int
-	SharkBreed int
-	Starve     int
-	GridSize   int
-	Threads    int
-	Steps      int
-	CellSize   int
-	UpdateFreq int
+	NumShark        int     `json:"sharks"`
+	NumFish         int     `json:"fish"`
+	FishBreed       int     `json:"fbreed"`
+	SharkBreed      int     `json:"sbreed"`
+	Starve          int     `json:"starve"`
+	SharkGain       int     `json:"sgain"`
+	FishStarve      int     `json:"fstarve"`
+	Algae           float64 `json:"algae"`
+	FishAge         int     `json:"fishage"`
+	Mutation        float64 `json:"mutation"`
+	School          float64 `json:"school"`
+	Flee            bool    `json:"flee"`
+	Stochastic      bool    `json:"stochastic"`
+	Script          string  `json:"script"`
+	PFishBreed      float64 `json:"pfbreed"`
+	PSharkBreed     float64 `json:"psbreed"`
+	PStarve         float64 `json:"pstarve"`
+	EggDelay        int     `json:"egg-delay"`
+	EggViability    float64 `json:"egg-viability"`
+	Traits          string  `json:"traits"`
+	Deaths          string  `json:"deaths"`
+	Cohorts         string  `json:"cohorts"`
+	Events          string  `json:"events"`
+	Chase           string  `json:"chase"`
+	CohortWidth     int     `json:"cohort-width"`
+	CohortEvery     int     `json:"cohort-every"`
+	MeanField       string  `json:"meanfield"`
+	GridSize        int     `json:"size"`
+	Ocean           int     `json:"ocean"`
+	Wrap            bool    `json:"wrap"`
+	TieBreak        string  `json:"tiebreak"`
+	UpdateOrder     string  `json:"update-order"`
+	Order           string  `json:"order"`
+	Topology        string  `json:"topology"`
+	Threads         int     `json:"threads"`
+	Engine          string  `json:"engine"`
+	TileSize        int     `json:"tile"`
+	Steps           int     `json:"steps"`
+	Runs            int     `json:"runs"`
+	CellSize        int     `json:"cellsize"`
+	Theme           string  `json:"theme"`
+	Sprites         string  `json:"sprites"`
+	UpdateFreq      int     `json:"updatefreq"`
+	ChrononDays     float64 `json:"chronon-days"`
+	Adaptive        bool    `json:"adaptive"`
+	HeatmapWindow   int     `json:"heatmap-window"`
+	RenderBudget    float64 `json:"render-budget"`
+	LowPower        string  `json:"lowpower"`
+	Serve           string  `json:"serve"`
+	Backpressure    string  `json:"backpressure"`
+	Pprof           bool    `json:"pprof"`
+	SnapshotAt      []int   `json:"snapshot-at"`
+	SnapshotDir     string  `json:"snapshot-dir"`
+	PNGEvery        int     `json:"snapshot-every"`
+	HashEvery       int     `json:"hash-every"`
+	DumpOnExit      string  `json:"dump-on-exit"`
+	CheckpointEvery int     `json:"checkpoint-every"`
+	CheckpointDir   string  `json:"checkpoint-dir"`
+	Resume          string  `json:"resume"`
+	Record          string  `json:"record"`
+	RecordEvery     int     `json:"record-every"`
+	RingLog         int     `json:"ringlog"`
+	FlushEvery      int     `json:"flush-every"`
+	Replay          string  `json:"replay"`
+	Benchmark       bool    `json:"benchmark"`
+	CPUProfile      string  `json:"cpuprofile"`
+	MemProfile      string  `json:"memprofile"`
+	Verify          bool    `json:"verify"`
+	Seed            int64   `json:"seed"`
+	Map             string  `json:"map"`
+	InitImage       string  `json:"init-image"`
+	Land            float64 `json:"land"`
+	Reef            float64 `json:"reef"`
+	Smooth          float64 `json:"smooth"`
+	Basin           int     `json:"basin"`
+	Corridor        int     `json:"corridor"`
+	BasinReport     string  `json:"basin-report"`
+	CurrentDir      float64 `json:"current-dir"`
+	CurrentStrength float64 `json:"current-strength"`
+	CurrentFile     string  `json:"current-file"`
+	ExtinctBelow    int     `json:"extinct-below"`
+	ExtinctFor      int     `json:"extinct-for"`
+	Equilibrium     bool    `json:"stop-on-equilibrium"`
+	EqWindow        int     `json:"equilibrium-window"`
+	EqTolerance     float64 `json:"equilibrium-tolerance"`
+	PauseOn         string  `json:"pause-on"`
+	ConfigFile      string  `json:"-"`
+
+	// Config file only: additional linked worlds and migration between them
+	Worlds    []json.RawMessage `json:"worlds,omitempty"`
+	Migration []Migration       `json:"migration,omitempty"`
+
+	// Config file only: parameter changes applied while the world runs
+	Schedule []ScheduledChange `json:"schedule,omitempty"`
+
+	// Config file only: predator species in addition to the sharks
+	Species []SpeciesConfig `json:"species,omitempty"`
+
+	// Config file only: colors replacing those of the theme, by cell kind
+	// (empty, fish, shark, barrier, reef, algae), as #rrggbb
+	Colors map[string]string `json:"colors,omitempty"`
+
+	// Config file only: metrics shown in the HUD, in order (built-in names
+	// or "label = expression")
+	HUD []string `json:"hud,omitempty"`
 }
 
 // ParseFlags parses command-line flags and returns a Config
@@ -28,14 +133,113 @@ func ParseFlags() (*Config, error) {
 	flag.IntVar(&cfg.FishBreed, "fbreed", 10, "Fish breeding time")
 	flag.IntVar(&cfg.SharkBreed, "sbreed", 10, "Shark breeding time")
 	flag.IntVar(&cfg.Starve, "starve", 8, "Shark starvation time")
+	flag.IntVar(&cfg.SharkGain, "sgain", 0, "Energy a shark gains per fish eaten, up to -starve (0=refill to -starve)")
+	flag.IntVar(&cfg.FishStarve, "fstarve", 0, "Fish starvation time without algae (0=no algae layer)")
+	flag.Float64Var(&cfg.Algae, "algae", 0.05, "Chance per step that algae grows on an empty cell (see -fstarve)")
+	flag.IntVar(&cfg.FishAge, "fishage", 0, "Maximum fish age in steps, after which fish die (0=no limit)")
+	flag.Float64Var(&cfg.Mutation, "mutation", 0, "Chance that an offspring's inherited breed or starve time changes by 1 (0=no genetics)")
+	flag.IntVar(&cfg.EggDelay, "egg-delay", 0, "Steps after which the dormant egg of a fish that died of old age or starvation hatches, if its cell stays empty (0=no eggs)")
+	flag.Float64Var(&cfg.EggViability, "egg-viability", 0.5, "Chance that a fish dying of old age or starvation leaves a viable egg (see -egg-delay)")
+	flag.BoolVar(&cfg.Stochastic, "stochastic", false, "Breed and starve with a chance every step instead of after fixed times (see -pfbreed, -psbreed, -pstarve)")
+	flag.Float64Var(&cfg.PFishBreed, "pfbreed", 0, "Chance per step that a moving fish breeds with -stochastic (0=1/-fbreed)")
+	flag.Float64Var(&cfg.PSharkBreed, "psbreed", 0, "Chance per step that a moving shark breeds with -stochastic (0=1/-sbreed)")
+	flag.Float64Var(&cfg.PStarve, "pstarve", 0, "Chance per step without fish that a shark starves with -stochastic (0=1/-starve)")
+	flag.StringVar(&cfg.Script, "script", "", "Rule file scoring the cells fish and sharks may move to (\"fish = expression\", \"shark = expression\"), or a Starlark script (.star) defining fish and shark functions")
+	flag.BoolVar(&cfg.Flee, "flee", false, "Fish prefer cells that are not next to a shark")
+	flag.Float64Var(&cfg.School, "school", 0, "Extra weight fish give a cell for each other fish next to it, so they form schools (0=no schooling)")
+	flag.StringVar(&cfg.MeanField, "meanfield", "", "Integrate the mean-field model alongside the run and write both trajectories to this CSV file")
+	flag.StringVar(&cfg.Traits, "traits", "", "Write per-step histograms of heritable breed/starve times to this CSV file")
+	flag.StringVar(&cfg.Deaths, "deaths", "", "Write per-step deaths of each population by cause (predation, starvation, old age) to this CSV file")
+	flag.StringVar(&cfg.Events, "events", "", "Write every birth, death and predation to this JSON Lines file (- for standard output)")
+	flag.StringVar(&cfg.Chase, "chase", "", "Write the distributions of cells moved and steps taken by predators between meals to this CSV file")
+	flag.StringVar(&cfg.Cohorts, "cohorts", "", "Write each population's counts by age class to this CSV file every -cohort-every steps")
+	flag.IntVar(&cfg.CohortWidth, "cohort-width", 10, "Chronons each age class of -cohorts spans")
+	flag.IntVar(&cfg.CohortEvery, "cohort-every", 10, "Steps between the age structures written to -cohorts")
 	flag.IntVar(&cfg.GridSize, "size", 80, "Grid dimensions (square)")
+	flag.IntVar(&cfg.Ocean, "ocean", 0, "Run headless in a chunked ocean this many cells a side, storing only the parts with animals; they start in the -size square in its center (0=off)")
+	flag.BoolVar(&cfg.Wrap, "wrap", true, "Wrap around the edges (torus); -wrap=false makes the edges walls")
+	flag.StringVar(&cfg.TieBreak, "tiebreak", "random", "Which animal gets a contested cell: random, energy (most energy first) or first-come (grid order)")
+	flag.StringVar(&cfg.UpdateOrder, "update-order", "", "Order the animals move in: random (shuffled every step) or scan (grid order, top left first); the same as -tiebreak random or first-come")
+	flag.StringVar(&cfg.Order, "order", "shark-first", "Which species moves first every step: shark-first, fish-first, interleaved (both in one pass) or random (chosen every step)")
+	flag.StringVar(&cfg.Topology, "topology", "square", "Which cells are neighbours: square (four) or hex (six, in hexagons; a wrapped hex world needs an even height)")
 	flag.IntVar(&cfg.Threads, "threads", 1, "Number of threads to use")
+	flag.StringVar(&cfg.Engine, "engine", "", "Step engine: serial, parallel or gpu (default: parallel with more than one thread); T switches it in the window")
+	flag.IntVar(&cfg.TileSize, "tile", simulation.DefaultTileSize, "Side in cells of the tiles threads take work in (at least 2, or 3 with -flee)")
 	flag.IntVar(&cfg.Steps, "steps", 0, "Number of simulation steps (0=infinite)")
-	flag.IntVar(&cfg.CellSize, "cellsize", 8, "Size of each cell in pixels")
+	flag.IntVar(&cfg.Runs, "runs", 1, "Run this many headless simulations with the seeds -seed, -seed+1, ..., -threads at a time, and report the mean and spread of their outcomes")
+	flag.IntVar(&cfg.CellSize, "cellsize", 0, "Size of each cell in pixels (0=the largest that fits the monitor in the window, 8 in image files)")
+	flag.StringVar(&cfg.Theme, "theme", frame.ThemeNames[0], "Cell colors: "+strings.Join(frame.ThemeNames, ", ")+" (colorblind is safe for red-green color blindness)")
+	flag.StringVar(&cfg.Sprites, "sprites", "", "Draw animals with the fish.png, shark.png and <species>.png images in this directory, turned to their last move")
 	flag.IntVar(&cfg.UpdateFreq, "updatefreq", 3, "Update frequency (higher=slower, 1=every frame)")
+	flag.Float64Var(&cfg.ChrononDays, "chronon-days", 0, "Simulated days per chronon, shown as dates in the HUD and a day column in reports (0=off)")
+	flag.BoolVar(&cfg.Adaptive, "adaptive", false, "Step every frame while the ocean is sparse and slow down to twice -updatefreq as it fills")
+	flag.IntVar(&cfg.HeatmapWindow, "heatmap-window", 200, "Steps of predation the heatmap shown with H covers (0=no heatmap)")
+	flag.Float64Var(&cfg.RenderBudget, "render-budget", 12, "Milliseconds drawing a frame may take before detail is dropped (0=always full detail)")
+	flag.StringVar(&cfg.LowPower, "lowpower", "auto", "Low-power window for long runs (lower tick rate, vsync, no redraw while unchanged): on, off or auto (on while on battery)")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "Random seed for the world and map generator (0=random)")
+	flag.StringVar(&cfg.Map, "map", "", "Map generator (perlin or maze) or ASCII map file with # for land (default: open ocean)")
+	flag.StringVar(&cfg.InitImage, "init-image", "", "Image of the initial world, one pixel per cell: green fish, red sharks, black land, blue water (replaces -size, -map, -fish and -sharks)")
+	flag.Float64Var(&cfg.Land, "land", 0.3, "Fraction of cells that become land (perlin map)")
+	flag.Float64Var(&cfg.Reef, "reef", 0.1, "Fraction of water cells that become reef (perlin map)")
+	flag.Float64Var(&cfg.Smooth, "smooth", 16, "Approximate land mass size in cells (perlin map)")
+	flag.IntVar(&cfg.Basin, "basin", 20, "Spacing of basins in cells (maze map)")
+	flag.IntVar(&cfg.Corridor, "corridor", 2, "Width of straits between basins (maze map)")
+	flag.Float64Var(&cfg.CurrentDir, "current-dir", 0, "Direction the ocean current flows towards in degrees (0=east, 90=north)")
+	flag.Float64Var(&cfg.CurrentStrength, "current-strength", 0, "Strength of the ocean current from 0 (none) to 1 (never swim against it)")
+	flag.StringVar(&cfg.CurrentFile, "current-file", "", "Load a per-cell ocean current field from this file instead")
+	flag.StringVar(&cfg.BasinReport, "basin-report", "", "Track per-basin populations and write them to this CSV file")
+	flag.IntVar(&cfg.ExtinctBelow, "extinct-below", 1, "Species with fewer individuals than this count as extinct (see -extinct-for)")
+	flag.IntVar(&cfg.ExtinctFor, "extinct-for", 1, "Consecutive steps a species must stay below -extinct-below to count as extinct")
+	flag.BoolVar(&cfg.Equilibrium, "stop-on-equilibrium", false, "Stop with a report once both populations oscillate stably (see -equilibrium-window, -equilibrium-tolerance)")
+	flag.IntVar(&cfg.EqWindow, "equilibrium-window", analysis.DefaultEquilibrium.Window, "Steps per window compared by -stop-on-equilibrium; should span a few oscillations")
+	flag.Float64Var(&cfg.EqTolerance, "equilibrium-tolerance", analysis.DefaultEquilibrium.Tolerance, "Largest change of the mean and amplitude between windows, relative to the mean, that counts as stable")
+	flag.StringVar(&cfg.PauseOn, "pause-on", "", "Comma-separated events that pause the window (fish<N, fish>N, sharks<N, sharks>N, basin, spike[=F])")
+	flag.StringVar(&cfg.Serve, "serve", "", "Serve the simulation over HTTP on this address (e.g. :8080)")
+	flag.StringVar(&cfg.Backpressure, "backpressure", server.Disconnect, "What the -serve stream does with clients that fall behind: disconnect, drop (resync with a keyframe), sample=N (every Nth step while behind) or pause (hold the simulation)")
+	flag.BoolVar(&cfg.Pprof, "pprof", false, "Also serve the net/http/pprof profiles under /debug/pprof/ with -serve; anyone who can reach the server can read them")
+	flag.Func("snapshot-at", "Comma-separated steps at which to save state snapshots (e.g. 100,1000)", func(s string) error {
+		steps, err := parseIntList(s)
+		cfg.SnapshotAt = steps
+		return err
+	})
+	flag.IntVar(&cfg.PNGEvery, "snapshot-every", 0, "Save a PNG image of the grid every N steps (0=off)")
+	flag.IntVar(&cfg.HashEvery, "hash-every", 0, "Print a hash of the grid every N steps, to check that runs with the same seed agree (0=off)")
+	flag.StringVar(&cfg.DumpOnExit, "dump-on-exit", "", "Save a snapshot of the world to this file when the run ends, also when interrupted with Ctrl+C")
+	flag.IntVar(&cfg.CheckpointEvery, "checkpoint-every", 0, "Save a checkpoint to resume the run from every N steps (0=off)")
+	flag.StringVar(&cfg.CheckpointDir, "checkpoint-dir", ".", "Directory for checkpoints")
+	flag.StringVar(&cfg.Resume, "resume", "", "Continue the run from a checkpoint saved with -checkpoint-every (replaces -size, -map, -fish, -sharks and the breeding parameters)")
+	flag.StringVar(&cfg.SnapshotDir, "snapshot-dir", ".", "Directory for state snapshots and PNG images")
+	flag.StringVar(&cfg.Record, "record", "", "Record the run to an animated GIF file, or a replay file if it ends in .wtr")
+	flag.IntVar(&cfg.RecordEvery, "record-every", 1, "Capture a GIF or replay frame every N steps")
+	flag.IntVar(&cfg.FlushEvery, "flush-every", 100, "Write CSV reports and .wtr recordings to disk every N steps")
+	flag.IntVar(&cfg.RingLog, "ringlog", 0, "Keep the last N steps on disk so D saves them as a GIF (0=off)")
+	flag.BoolVar(&cfg.Benchmark, "benchmark", false, "Time -steps steps (default 500) with 1, 2, 4, ... threads up to -threads (default all CPUs) and print the speedup")
+	flag.StringVar(&cfg.CPUProfile, "cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	flag.StringVar(&cfg.MemProfile, "memprofile", "", "Write a heap profile to this file when the run ends, for go tool pprof")
+	flag.BoolVar(&cfg.Verify, "verify", false, "Check the grid and population bookkeeping after every step and stop at the first violation")
+	flag.StringVar(&cfg.Replay, "replay", "", "Play back a .wtr file recorded with -record instead of simulating")
+	flag.StringVar(&cfg.ConfigFile, "config", "", "JSON configuration file (keys are flag names; flags take precedence)")
+
+	// Remembered preferences give way to the config file and the flags
+	if s, err := LoadSettings(); err == nil && s.Theme != "" {
+		if _, err := frame.Theme(s.Theme); err == nil {
+			cfg.Theme = s.Theme
+		}
+	}
+
+	// Apply the config file before parsing so explicit flags override it
+	if path := findConfigFlag(os.Args[1:]); path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
 
 	flag.Parse()
 
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
 	// Validate parameters
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -47,21 +251,334 @@ func ParseFlags() (*Config, error) {
 // Validate checks if configuration parameters are valid
 func (c *Config) Validate() error {
 	if c.NumShark < 0 || c.NumFish < 0 || c.FishBreed < 1 || c.SharkBreed < 1 ||
-		c.Starve < 1 || c.GridSize < 1 || c.Threads < 1 {
+		c.Starve < 1 || c.GridSize < 1 || c.Threads < 1 || c.TileSize < 2 || c.UpdateFreq < 1 || c.Runs < 1 {
 		return fmt.Errorf("all parameters must be positive")
 	}
 
-	if c.NumShark+c.NumFish > (c.GridSize * c.GridSize) {
+	if c.Flee && c.TileSize < 3 {
+		return fmt.Errorf("-flee needs -tile 3 or more, since fleeing fish look two cells ahead")
+	}
+
+	if err := c.validateSpecies(); err != nil {
+		return err
+	}
+
+	if c.InitImage != "" && c.Map != "" {
+		return fmt.Errorf("-init-image and -map both set the land; use only one")
+	}
+
+	// The size of a map file or image is only known once it is loaded
+	if c.MapFile() == "" && c.InitImage == "" && c.Animals() > (c.GridSize*c.GridSize) {
 		return fmt.Errorf("too many entities for grid size")
 	}
 
+	if c.RecordEvery < 1 || c.FlushEvery < 1 || c.CellSize < 0 || c.RenderBudget < 0 || c.ChrononDays < 0 || c.HeatmapWindow < 0 || c.PNGEvery < 0 || c.HashEvery < 0 || c.CheckpointEvery < 0 || c.RingLog < 0 || c.ExtinctBelow < 1 || c.ExtinctFor < 1 || c.EqWindow < 1 || c.CohortWidth < 1 || c.CohortEvery < 1 {
+		return fmt.Errorf("all parameters must be positive")
+	}
+
+	if c.EqTolerance < 0 {
+		return fmt.Errorf("equilibrium-tolerance must not be negative")
+	}
+
+	if c.FishStarve < 0 || c.SharkGain < 0 || c.FishAge < 0 || c.Algae < 0 || c.Algae > 1 {
+		return fmt.Errorf("fstarve, sgain and fishage must not be negative and algae must be in [0, 1]")
+	}
+
+	if _, err := simulation.ParseTieBreak(c.TieBreak); err != nil {
+		return err
+	}
+	if c.UpdateOrder != "" && c.UpdateOrder != "random" && c.UpdateOrder != "scan" {
+		return fmt.Errorf("unknown update order %q (expected random or scan)", c.UpdateOrder)
+	}
+	if c.UpdateOrder != "" && c.TieBreak != "random" {
+		return fmt.Errorf("-update-order and -tiebreak %s both set the order animals move in; use only one", c.TieBreak)
+	}
+	if _, err := simulation.ParseOrder(c.Order); err != nil {
+		return err
+	}
+	if _, err := simulation.ParseTopology(c.Topology); err != nil {
+		return err
+	}
+
+	if c.Engine != "" {
+		if _, err := simulation.ParseEngine(c.Engine); err != nil {
+			return err
+		}
+	}
+	if c.Engine == "gpu" && c.TieBreakPolicy() != simulation.TieRandom {
+		return fmt.Errorf("-engine gpu settles contested cells at random; it cannot follow -tiebreak %s", c.TieBreakPolicy())
+	}
+
+	if c.Mutation < 0 || c.Mutation > 1 {
+		return fmt.Errorf("mutation must be in [0, 1]")
+	}
+
+	for _, p := range []float64{c.PFishBreed, c.PSharkBreed, c.PStarve} {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("pfbreed, psbreed and pstarve must be in [0, 1]")
+		}
+	}
+
+	if c.School < 0 {
+		return fmt.Errorf("school must not be negative")
+	}
+
+	if c.EggDelay < 0 || c.EggViability < 0 || c.EggViability > 1 {
+		return fmt.Errorf("egg-delay must not be negative and egg-viability must be in [0, 1]")
+	}
+
+	if c.CurrentStrength < 0 || c.CurrentStrength > 1 {
+		return fmt.Errorf("current-strength must be in [0, 1]")
+	}
+	if c.CurrentStrength > 0 && c.CurrentFile != "" {
+		return fmt.Errorf("-current-strength and -current-file cannot be combined")
+	}
+
+	if _, err := c.Palette(); err != nil {
+		return err
+	}
+
+	switch c.LowPower {
+	case "on", "off", "auto":
+	default:
+		return fmt.Errorf("lowpower must be on, off or auto")
+	}
+
+	if _, err := server.ParseBackpressure(c.Backpressure); err != nil {
+		return err
+	}
+	if c.Pprof && c.Serve == "" {
+		return fmt.Errorf("-pprof needs -serve")
+	}
+
+	if _, err := analysis.ParsePauseTriggers(c.PauseOn); err != nil {
+		return fmt.Errorf("pause-on: %v", err)
+	}
+
+	switch c.Map {
+	case "":
+	case "perlin":
+		if c.Land < 0 || c.Land > 0.9 || c.Reef < 0 || c.Reef > 1 || c.Smooth < 1 {
+			return fmt.Errorf("land must be in [0, 0.9], reef in [0, 1] and smooth at least 1")
+		}
+	case "maze":
+		if c.Basin < 4 || c.Corridor < 1 || c.Corridor > c.Basin/2 {
+			return fmt.Errorf("basin must be at least 4 and corridor between 1 and basin/2")
+		}
+	}
+
+	if c.Replay != "" && (c.Serve != "" || c.Record != "" || c.Steps > 0 || len(c.Worlds) > 0) {
+		return fmt.Errorf("-replay cannot be combined with -serve, -record, -steps or linked worlds")
+	}
+
+	if c.Resume != "" && (c.Map != "" || c.InitImage != "" || c.Serve != "" || c.Benchmark || c.Ocean > 0 || len(c.Worlds) > 0) {
+		return fmt.Errorf("-resume cannot be combined with -map, -init-image, -serve, -benchmark, -ocean or linked worlds")
+	}
+
+	if c.Benchmark && (c.Serve != "" || c.Replay != "" || len(c.Worlds) > 0) {
+		return fmt.Errorf("-benchmark cannot be combined with -serve, -replay or linked worlds")
+	}
+
+	if c.Serve != "" && (c.Record != "" || c.BasinReport != "" || c.Traits != "" || c.Deaths != "" || c.Cohorts != "" || c.Events != "" || c.Chase != "" || c.MeanField != "") {
+		return fmt.Errorf("-record, -basin-report, -traits, -deaths, -cohorts, -events, -chase and -meanfield cannot be combined with -serve")
+	}
+
+	if c.Ocean > 0 {
+		if err := c.validateOcean(); err != nil {
+			return err
+		}
+	}
+
+	if c.Runs > 1 {
+		if err := c.validateRuns(); err != nil {
+			return err
+		}
+	}
+
+	for _, step := range c.SnapshotAt {
+		if step < 0 {
+			return fmt.Errorf("snapshot steps must not be negative")
+		}
+	}
+
+	if len(c.Worlds) > 0 {
+		if err := c.validateLinkedWorlds(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateSchedule(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// validateOcean checks that -ocean is combined only with the rules and
+// outputs of the chunked world
+func (c *Config) validateOcean() error {
+	if c.Ocean < c.GridSize {
+		return fmt.Errorf("-ocean must be at least -size, the square of it the animals start in")
+	}
+	if c.Map != "" || c.InitImage != "" || c.FishStarve > 0 || c.FishAge > 0 || c.Mutation > 0 || c.School > 0 || c.Flee || c.Stochastic || c.Script != "" || c.EggDelay > 0 || c.CurrentStrength > 0 || c.CurrentFile != "" ||
+		c.TieBreakPolicy() != simulation.TieRandom || c.MoveOrder() != simulation.OrderSharkFirst || c.Topology != "square" || len(c.Species) > 0 || len(c.Worlds) > 0 || len(c.Schedule) > 0 {
+		return fmt.Errorf("-ocean only supports fish and sharks on a square grid with random tie-breaks, moving sharks first, without -map, -init-image, -fstarve, -fishage, -mutation, -stochastic, -script, -school, -flee, eggs, currents, species, linked worlds or schedules")
+	}
+	if c.Serve != "" || c.Replay != "" || c.Benchmark || c.Verify || c.Record != "" || c.RingLog > 0 || c.PNGEvery > 0 || c.HashEvery > 0 || c.CheckpointEvery > 0 || c.Equilibrium ||
+		len(c.SnapshotAt) > 0 || c.DumpOnExit != "" || c.BasinReport != "" || c.Traits != "" || c.Deaths != "" || c.Cohorts != "" || c.Events != "" || c.Chase != "" || c.MeanField != "" {
+		return fmt.Errorf("-ocean runs headless, without -serve, -replay, -benchmark, -verify, -record, -ringlog, -hash-every, -stop-on-equilibrium, checkpoints, snapshots or reports")
+	}
+	return nil
+}
+
+// validateRuns checks that -runs is combined only with what its headless
+// runs support: they report their outcomes and nothing else
+func (c *Config) validateRuns() error {
+	if c.Steps < 1 {
+		return fmt.Errorf("-runs needs -steps to bound every run")
+	}
+	if c.Serve != "" || c.Replay != "" || c.Benchmark || c.Ocean > 0 || c.Resume != "" || c.Verify || len(c.Worlds) > 0 || len(c.Schedule) > 0 ||
+		c.Record != "" || c.RingLog > 0 || c.PNGEvery > 0 || c.HashEvery > 0 || c.CheckpointEvery > 0 || len(c.SnapshotAt) > 0 || c.DumpOnExit != "" ||
+		c.BasinReport != "" || c.Traits != "" || c.Deaths != "" || c.Cohorts != "" || c.Events != "" || c.Chase != "" || c.MeanField != "" {
+		return fmt.Errorf("-runs cannot be combined with -serve, -replay, -benchmark, -ocean, -resume, -verify, linked worlds, schedules, recordings, snapshots, checkpoints or reports")
+	}
+	return nil
+}
+
+// Rules returns the rule set selected with -stochastic
+func (c *Config) Rules() simulation.RuleSet {
+	if !c.Stochastic {
+		return simulation.Classic{}
+	}
+	return simulation.Stochastic{FishBreed: c.PFishBreed, SharkBreed: c.PSharkBreed, SharkStarve: c.PStarve}
+}
+
+// TieBreakPolicy returns the tie-break policy selected with -tiebreak, or
+// with -update-order, whose scan is the first-come policy
+func (c *Config) TieBreakPolicy() simulation.TieBreak {
+	if c.UpdateOrder == "scan" {
+		return simulation.TieFirstCome
+	}
+	t, _ := simulation.ParseTieBreak(c.TieBreak)
+	return t
+}
+
+// MoveOrder returns which species moves first, selected with -order
+func (c *Config) MoveOrder() simulation.Order {
+	o, _ := simulation.ParseOrder(c.Order)
+	return o
+}
+
+// StepEngine returns the engine selected with -engine, or the default for
+// -threads
+func (c *Config) StepEngine() simulation.Engine {
+	if e, err := simulation.ParseEngine(c.Engine); err == nil {
+		return e
+	}
+	return simulation.DefaultEngine(c.Threads)
+}
+
+// Animals returns the initial number of fish and predators of all species
+func (c *Config) Animals() int {
+	n := c.NumFish + c.NumShark
+	for _, s := range c.Species {
+		n += s.Count
+	}
+	return n
+}
+
+// MapFile returns the ASCII map file selected with -map, or "" if -map
+// names a generator or is unset
+func (c *Config) MapFile() string {
+	switch c.Map {
+	case "", "perlin", "maze":
+		return ""
+	default:
+		return c.Map
+	}
+}
+
 // Print displays the configuration parameters
 func (c *Config) Print() {
 	fmt.Printf("Wa-Tor Simulation\n")
-	fmt.Printf("Grid: %dx%d, Fish: %d, Sharks: %d\n", c.GridSize, c.GridSize, c.NumFish, c.NumShark)
-	fmt.Printf("Fish Breed: %d, Shark Breed: %d, Starve: %d\n", c.FishBreed, c.SharkBreed, c.Starve)
-	fmt.Printf("Threads: %d, Max Steps: %d\n\n", c.Threads, c.Steps)
+	if c.Resume != "" {
+		fmt.Printf("Resuming: %s\n", c.Resume)
+	} else if c.InitImage != "" {
+		fmt.Printf("Grid: %s\n", c.InitImage)
+	} else if c.MapFile() != "" {
+		fmt.Printf("Grid: %s, Fish: %d, Sharks: %d\n", c.MapFile(), c.NumFish, c.NumShark)
+	} else {
+		fmt.Printf("Grid: %dx%d, Fish: %d, Sharks: %d\n", c.GridSize, c.GridSize, c.NumFish, c.NumShark)
+	}
+	if c.Ocean > 0 {
+		fmt.Printf("Ocean: %dx%d (chunked), with the grid at its center\n", c.Ocean, c.Ocean)
+	}
+	if c.Resume == "" {
+		fmt.Printf("Fish Breed: %d, Shark Breed: %d, Starve: %d\n", c.FishBreed, c.SharkBreed, c.Starve)
+	}
+	if cal := c.Calendar(); cal.Enabled() {
+		fmt.Printf("Calendar: %s per chronon, fish breed every %s, sharks every %s and starve after %s\n",
+			cal.Duration(1), cal.Duration(c.FishBreed), cal.Duration(c.SharkBreed), cal.Duration(c.Starve))
+	}
+	if c.SharkGain > 0 {
+		fmt.Printf("Shark Energy Gain: %d\n", c.SharkGain)
+	}
+	if c.FishStarve > 0 {
+		fmt.Printf("Fish Starve: %d, Algae Growth: %g\n", c.FishStarve, c.Algae)
+	}
+	if c.FishAge > 0 {
+		fmt.Printf("Fish Max Age: %d\n", c.FishAge)
+		if cal := c.Calendar(); cal.Enabled() {
+			fmt.Printf("Fish Lifespan: %s\n", cal.Duration(c.FishAge))
+		}
+	}
+	for _, s := range c.Species {
+		fmt.Printf("%s: %d, Breed: %d, Starve: %d\n", s.Name, s.Count, s.Breed, s.Starve)
+	}
+	if c.Mutation > 0 {
+		fmt.Printf("Mutation: %g\n", c.Mutation)
+	}
+	if c.School > 0 {
+		fmt.Printf("Schooling: %g\n", c.School)
+	}
+	if c.Stochastic {
+		fmt.Printf("Stochastic rules: fish breed %g, shark breed %g, shark starve %g (0=1/time)\n", c.PFishBreed, c.PSharkBreed, c.PStarve)
+	}
+	if c.Script != "" {
+		fmt.Printf("Script: %s\n", c.Script)
+	}
+	if c.Flee {
+		fmt.Printf("Fish flee sharks\n")
+	}
+	if c.EggDelay > 0 {
+		fmt.Printf("Egg Delay: %d, Egg Viability: %g\n", c.EggDelay, c.EggViability)
+	}
+	if c.Map != "" && c.MapFile() == "" {
+		fmt.Printf("Map: %s\n", c.Map)
+	}
+	if !c.Wrap {
+		fmt.Printf("Edges: bounded\n")
+	}
+	if c.Equilibrium {
+		fmt.Printf("Stop on equilibrium: windows of %d steps, tolerance %g\n", c.EqWindow, c.EqTolerance)
+	}
+	fmt.Printf("Threads: %d, Max Steps: %d, Seed: %d, Tie-break: %s\n\n", c.Threads, c.Steps, c.Seed, c.TieBreakPolicy())
+}
+
+// Calendar returns the mapping of chronons to days set by -chronon-days
+func (c *Config) Calendar() analysis.Calendar {
+	return analysis.Calendar{DaysPerChronon: c.ChrononDays}
+}
+
+// parseIntList parses a comma-separated list of integers
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid list value %q", field)
+		}
+		values = append(values, v)
+	}
+	return values, nil
 }
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+	"time"
+
+	"wa-tor/frame"
+	"wa-tor/simulation"
+)
+
+// Migration periodically moves a fraction of the animals of one linked
+// world to another
+type Migration struct {
+	From     int     `json:"from"`     // Index into the list of worlds
+	To       int     `json:"to"`       // Index into the list of worlds
+	Every    int     `json:"every"`    // Steps between migration events
+	Fraction float64 `json:"fraction"` // Share of each species that migrates
+}
+
+// ScheduledChange sets parameters of the running world after the given
+// step, e.g. {"step": 500, "set": {"fbreed": 5}}. Names are flag names.
+type ScheduledChange struct {
+	Step int                `json:"step"`
+	Set  map[string]float64 `json:"set"`
+}
+
+// SpeciesConfig adds a predator species with its own parameters and color,
+// e.g. {"type": "shark", "name": "Tiger", "count": 50, "breed": 10,
+// "starve": 6, "color": "#ff8000"}. Only "shark" types are supported.
+type SpeciesConfig struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Breed  int    `json:"breed"`
+	Starve int    `json:"starve"`
+	Color  string `json:"color"`
+}
+
+// Species converts the configuration to a simulation species
+func (s SpeciesConfig) Species() (simulation.Species, error) {
+	c, err := parseColor(s.Color)
+	if err != nil {
+		return simulation.Species{}, fmt.Errorf("species %q: %v", s.Name, err)
+	}
+	return simulation.Species{
+		Name:   s.Name,
+		Breed:  s.Breed,
+		Starve: s.Starve,
+		Color:  c,
+	}, nil
+}
+
+// Palette returns the colors of the -theme with the "colors" of the
+// configuration file applied, e.g. {"fish": "#ffffff"}
+func (c *Config) Palette() (frame.Palette, error) {
+	p, err := frame.Theme(c.Theme)
+	if err != nil {
+		return p, err
+	}
+	for name, value := range c.Colors {
+		v, err := parseColor(value)
+		if err == nil {
+			err = p.SetColor(name, v)
+		}
+		if err != nil {
+			return p, fmt.Errorf("colors: %v", err)
+		}
+	}
+	return p, nil
+}
+
+// parseColor parses an opaque color written as #rrggbb
+func parseColor(s string) (color.RGBA, error) {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil || len(s) != 7 {
+		return color.RGBA{}, fmt.Errorf("color must be #rrggbb, not %q", s)
+	}
+	return color.RGBA{r, g, b, 255}, nil
+}
+
+// LoadFile applies the settings of a JSON configuration file. Keys are the
+// command-line flag names; keys that are absent keep their current value.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := decodeStrict(data, c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// WithOverrides returns a validated copy of the configuration with the JSON
+// settings in data applied. Linked worlds are dropped, and a missing seed
+// is replaced by a new random one.
+func (c *Config) WithOverrides(data []byte) (*Config, error) {
+	o := *c
+	o.Worlds, o.Migration = nil, nil
+	o.Seed = 0
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := decodeStrict(data, &o); err != nil {
+			return nil, err
+		}
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// decodeStrict decodes JSON into v, rejecting unknown keys
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// findConfigFlag returns the value of -config in args, if present
+func findConfigFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// LinkedWorlds returns the configuration of every world listed under
+// "worlds" in the config file. Each entry overrides the top-level settings,
+// and world i is seeded with Seed+i.
+func (c *Config) LinkedWorlds() ([]*Config, error) {
+	worlds := make([]*Config, len(c.Worlds))
+	for i, raw := range c.Worlds {
+		w := *c
+		w.Worlds, w.Migration = nil, nil
+		w.Seed = c.Seed + int64(i)
+
+		if err := decodeStrict(raw, &w); err != nil {
+			return nil, fmt.Errorf("world %d: %w", i, err)
+		}
+		worlds[i] = &w
+	}
+	return worlds, nil
+}
+
+// validateLinkedWorlds checks the per-world settings and migration links
+func (c *Config) validateLinkedWorlds() error {
+	if c.Steps < 1 || c.Serve != "" {
+		return fmt.Errorf("linked worlds are only supported in headless mode (-steps > 0)")
+	}
+
+	worlds, err := c.LinkedWorlds()
+	if err != nil {
+		return err
+	}
+	for i, w := range worlds {
+		if w.Steps != c.Steps {
+			return fmt.Errorf("world %d: all linked worlds must run the same number of steps", i)
+		}
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("world %d: %w", i, err)
+		}
+	}
+
+	for _, m := range c.Migration {
+		if m.From < 0 || m.From >= len(worlds) || m.To < 0 || m.To >= len(worlds) || m.From == m.To {
+			return fmt.Errorf("migration from %d to %d: invalid world index", m.From, m.To)
+		}
+		if m.Every < 1 || m.Fraction < 0 || m.Fraction > 1 {
+			return fmt.Errorf("migration from %d to %d: every must be positive and fraction in [0, 1]", m.From, m.To)
+		}
+	}
+	return nil
+}
+
+// validateSpecies checks the additional predator species
+func (c *Config) validateSpecies() error {
+	for i := range c.Species {
+		s := &c.Species[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("Species %d", i+1)
+		}
+		if s.Type != "shark" {
+			return fmt.Errorf("species %q: type must be \"shark\"", s.Name)
+		}
+		if s.Count < 0 || s.Breed < 1 || s.Starve < 1 {
+			return fmt.Errorf("species %q: count must not be negative, breed and starve must be positive", s.Name)
+		}
+		if _, err := s.Species(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSchedule checks the steps and parameters of scheduled changes
+func (c *Config) validateSchedule() error {
+	if len(c.Schedule) > 0 && len(c.Worlds) > 0 {
+		return fmt.Errorf("a schedule cannot be combined with linked worlds")
+	}
+	for _, change := range c.Schedule {
+		if change.Step < 0 {
+			return fmt.Errorf("schedule: steps must not be negative")
+		}
+		for name, value := range change.Set {
+			if err := simulation.CheckParameter(name, value); err != nil {
+				return fmt.Errorf("schedule at step %d: %w", change.Step, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Settings are the preferences the window remembers between runs, kept as
+// JSON in wa-tor/settings.json under the user's configuration directory.
+// ParseFlags applies them before the config file and the flags.
+type Settings struct {
+	Theme string `json:"theme,omitempty"` // Theme last chosen with L
+}
+
+// settingsPath returns the file Settings are kept in
+func settingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wa-tor", "settings.json"), nil
+}
+
+// LoadSettings reads the remembered preferences; there are none until
+// they are first saved
+func LoadSettings() (Settings, error) {
+	var s Settings
+	path, err := settingsPath()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+// Save remembers the preferences for later runs
+func (s Settings) Save() error {
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,73 @@
+// Package dialog shows native file dialogs by running the tools that ship
+// with each platform (zenity or kdialog, AppleScript, PowerShell), so no
+// cgo or GUI toolkit is needed.
+package dialog
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrCancelled is returned when the user closes the dialog without
+	// choosing a file
+	ErrCancelled = errors.New("dialog cancelled")
+
+	// ErrUnavailable is returned when no dialog tool is installed
+	ErrUnavailable = errors.New("no file dialog available (install zenity or kdialog)")
+)
+
+// Filter restricts the files shown to those matching the glob patterns
+type Filter struct {
+	Name     string   // Description, e.g. "PNG images"
+	Patterns []string // Glob patterns, e.g. "*.png"
+}
+
+// SaveFile asks for a file to write, suggesting defaultName
+func SaveFile(title, defaultName string, filters ...Filter) (string, error) {
+	return saveFile(title, defaultName, filters)
+}
+
+// OpenFile asks for an existing file to read
+func OpenFile(title string, filters ...Filter) (string, error) {
+	return openFile(title, filters)
+}
+
+// run executes a dialog tool and returns the path it prints. A non-zero
+// exit status without an error message means the user cancelled.
+func run(name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && (exit.ExitCode() == 1 || strings.Contains(stderr.String(), "-128")) {
+		return "", ErrCancelled
+	}
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimRight(string(out), "\r\n")
+	if path == "" {
+		return "", ErrCancelled
+	}
+	return path, nil
+}
+
+// extensions returns the file extensions of the filters' patterns, without
+// the leading "*."
+func extensions(filters []Filter) []string {
+	var exts []string
+	for _, f := range filters {
+		for _, p := range f.Patterns {
+			if ext, ok := strings.CutPrefix(p, "*."); ok {
+				exts = append(exts, ext)
+			}
+		}
+	}
+	return exts
+}
@@ -0,0 +1,27 @@
+package dialog
+
+import "strings"
+
+func saveFile(title, defaultName string, filters []Filter) (string, error) {
+	script := "POSIX path of (choose file name with prompt " + appleString(title) +
+		" default name " + appleString(defaultName) + ")"
+	return run("osascript", "-e", script)
+}
+
+func openFile(title string, filters []Filter) (string, error) {
+	script := "POSIX path of (choose file with prompt " + appleString(title)
+	if exts := extensions(filters); len(exts) > 0 {
+		types := make([]string, len(exts))
+		for i, ext := range exts {
+			types[i] = appleString(ext)
+		}
+		script += " of type {" + strings.Join(types, ", ") + "}"
+	}
+	return run("osascript", "-e", script+")")
+}
+
+// appleString quotes s as an AppleScript string literal
+func appleString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
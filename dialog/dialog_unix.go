@@ -0,0 +1,49 @@
+//go:build !windows && !darwin
+
+package dialog
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func saveFile(title, defaultName string, filters []Filter) (string, error) {
+	if path, err := exec.LookPath("zenity"); err == nil {
+		args := []string{"--file-selection", "--save", "--confirm-overwrite",
+			"--title=" + title, "--filename=" + defaultName}
+		return run(path, append(args, zenityFilters(filters)...)...)
+	}
+	if path, err := exec.LookPath("kdialog"); err == nil {
+		return run(path, "--title", title, "--getsavefilename", defaultName, kdialogFilter(filters))
+	}
+	return "", ErrUnavailable
+}
+
+func openFile(title string, filters []Filter) (string, error) {
+	if path, err := exec.LookPath("zenity"); err == nil {
+		args := []string{"--file-selection", "--title=" + title}
+		return run(path, append(args, zenityFilters(filters)...)...)
+	}
+	if path, err := exec.LookPath("kdialog"); err == nil {
+		return run(path, "--title", title, "--getopenfilename", ".", kdialogFilter(filters))
+	}
+	return "", ErrUnavailable
+}
+
+// zenityFilters formats filters as "Name | *.a *.b" options
+func zenityFilters(filters []Filter) []string {
+	var args []string
+	for _, f := range filters {
+		args = append(args, "--file-filter="+f.Name+" | "+strings.Join(f.Patterns, " "))
+	}
+	return args
+}
+
+// kdialogFilter formats filters as "Name (*.a *.b)" lines
+func kdialogFilter(filters []Filter) string {
+	lines := make([]string, len(filters))
+	for i, f := range filters {
+		lines[i] = f.Name + " (" + strings.Join(f.Patterns, " ") + ")"
+	}
+	return strings.Join(lines, "\n")
+}
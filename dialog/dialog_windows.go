@@ -0,0 +1,33 @@
+package dialog
+
+import "strings"
+
+func saveFile(title, defaultName string, filters []Filter) (string, error) {
+	return showDialog("SaveFileDialog", title, defaultName, filters)
+}
+
+func openFile(title string, filters []Filter) (string, error) {
+	return showDialog("OpenFileDialog", title, "", filters)
+}
+
+// showDialog runs a Windows Forms file dialog through PowerShell
+func showDialog(kind, title, defaultName string, filters []Filter) (string, error) {
+	parts := make([]string, len(filters))
+	for i, f := range filters {
+		patterns := strings.Join(f.Patterns, ";")
+		parts[i] = f.Name + " (" + patterns + ")|" + patterns
+	}
+
+	script := "Add-Type -AssemblyName System.Windows.Forms;" +
+		"$d = New-Object System.Windows.Forms." + kind + ";" +
+		"$d.Title = " + psString(title) + ";" +
+		"$d.FileName = " + psString(defaultName) + ";" +
+		"$d.Filter = " + psString(strings.Join(parts, "|")) + ";" +
+		"if ($d.ShowDialog() -eq 'OK') { $d.FileName } else { exit 1 }"
+	return run("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+// psString quotes s as a PowerShell single-quoted string literal
+func psString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"wa-tor/simulation"
+)
+
+// Default densities of fish and sharks: those of the default 80x80 world
+const (
+	defaultFishDensity  = 500.0 / (80 * 80)
+	defaultSharkDensity = 100.0 / (80 * 80)
+)
+
+// Steps run before and during the calibration
+const (
+	estimateWarmup = 5
+	estimateSteps  = 20
+)
+
+// runEstimate implements "wa-tor estimate [flags]": it times a few steps of
+// a small world with the same densities and parameters, and predicts the
+// memory and speed of a run at the requested size.
+func runEstimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ContinueOnError)
+	size := fs.Int("size", 80, "Grid dimensions (square) of the planned run")
+	fish := fs.Int("fish", -1, "Starting population of fish (default: the density of the default world)")
+	sharks := fs.Int("sharks", -1, "Starting population of sharks (default: the density of the default world)")
+	fishBreed := fs.Int("fbreed", 10, "Fish breeding time")
+	sharkBreed := fs.Int("sbreed", 10, "Shark breeding time")
+	starve := fs.Int("starve", 8, "Shark starvation time")
+	threads := fs.Int("threads", 1, "Number of threads of the planned run")
+	steps := fs.Int("steps", 1000, "Number of steps of the planned run")
+	calibrate := fs.Int("calibrate", 256, "Grid dimensions of the calibration run (at most -size)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size < 1 || *fishBreed < 1 || *sharkBreed < 1 || *starve < 1 || *threads < 1 || *steps < 1 || *calibrate < 1 {
+		return fmt.Errorf("all parameters must be positive")
+	}
+
+	cells := *size * *size
+	if *fish < 0 {
+		*fish = int(float64(cells) * defaultFishDensity)
+	}
+	if *sharks < 0 {
+		*sharks = int(float64(cells) * defaultSharkDensity)
+	}
+	if *fish+*sharks > cells {
+		return fmt.Errorf("too many entities for grid size")
+	}
+
+	fmt.Printf("Estimating a %dx%d world (%d cells), Fish: %d, Sharks: %d, Threads: %d\n",
+		*size, *size, cells, *fish, *sharks, *threads)
+	if *threads > runtime.NumCPU() {
+		fmt.Printf("Note: this machine has fewer than %d CPUs, so the planned run may be faster than estimated\n", *threads)
+	}
+
+	// Calibrate on a smaller world with the same densities
+	small := min(*calibrate, *size)
+	scale := float64(small*small) / float64(cells)
+	world := simulation.NewWorld(small, small, int(float64(*fish)*scale), int(float64(*sharks)*scale),
+		*fishBreed, *sharkBreed, *starve)
+	for range estimateWarmup {
+		world.Step(*threads)
+	}
+	start := time.Now()
+	for range estimateSteps {
+		world.Step(*threads)
+	}
+	perCell := time.Since(start).Seconds() / estimateSteps / float64(small*small)
+	fmt.Printf("Calibration: %dx%d for %d steps, %.1f ns per cell per step\n",
+		small, small, estimateSteps, perCell*1e9)
+
+	// Memory is dominated by the two grids Step alternates between, its
+	// moved flags and the list of animals (coordinates and type), all sized
+	// by the grid. Speed depends on the populations, which change as the run
+	// goes on, so it is only a guide.
+	grid := int64(cells) * int64(unsafe.Sizeof(simulation.Cell{}))
+	moved := int64(cells)
+	entities := int64(cells) * int64(unsafe.Sizeof([3]int{}))
+	live := 2*grid + moved + entities
+	fmt.Printf("Memory: ~%s (two grids of %s, moved flags %s, animal list %s), up to ~%s with garbage collection\n",
+		formatBytes(live), formatBytes(grid), formatBytes(moved), formatBytes(entities), formatBytes(2*live))
+
+	perStep := max(time.Duration(perCell*float64(cells)*float64(time.Second)), time.Nanosecond)
+	fmt.Printf("Speed: ~%.2f steps/s (%v per step), %d steps in ~%v\n",
+		1/perStep.Seconds(), perStep.Round(time.Microsecond), *steps, (perStep * time.Duration(*steps)).Round(time.Second))
+	return nil
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n), 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp-1])
+}
@@ -0,0 +1,132 @@
+// Package frame renders World grids to images and raw arrays without
+// depending on a graphics backend, so frames can be produced headless.
+package frame
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+
+	"wa-tor/simulation"
+)
+
+// Palette holds the colors used for each cell type
+type Palette struct {
+	Empty   color.RGBA
+	Fish    color.RGBA
+	Shark   color.RGBA
+	Barrier color.RGBA
+	Reef    color.RGBA // Empty reef cells
+	Algae   color.RGBA // Empty cells covered in algae
+}
+
+// DefaultPalette is the classic dark-blue ocean with green fish and red sharks
+var DefaultPalette = Palette{
+	Empty:   color.RGBA{0, 0, 50, 255},
+	Fish:    color.RGBA{0, 255, 0, 255},
+	Shark:   color.RGBA{255, 0, 0, 255},
+	Barrier: color.RGBA{140, 115, 85, 255},
+	Reef:    color.RGBA{0, 60, 90, 255},
+	Algae:   color.RGBA{20, 80, 30, 255},
+}
+
+// Color returns the palette color for a cell type
+func (p Palette) Color(t simulation.CellType) color.RGBA {
+	switch t {
+	case simulation.Fish:
+		return p.Fish
+	case simulation.Shark:
+		return p.Shark
+	case simulation.Barrier:
+		return p.Barrier
+	default:
+		return p.Empty
+	}
+}
+
+// CellColor returns the color of the cell at (y, x), including terrain
+func (p Palette) CellColor(w *simulation.World, y, x int) color.RGBA {
+	t := w.Cell(y, x).Type
+	if id := w.Cell(y, x).Species; t == simulation.Shark && id > 0 && id <= len(w.Species) {
+		return w.Species[id-1].Color
+	}
+	if t == simulation.Empty && w.Cell(y, x).Algae {
+		return p.Algae
+	}
+	if t == simulation.Empty && w.Terrain.IsReef(y, x) {
+		return p.Reef
+	}
+	return p.Color(t)
+}
+
+// Image renders the world grid with each cell drawn as a scale x scale block
+func Image(w *simulation.World, p Palette, scale int) *image.RGBA {
+	scale = max(scale, 1)
+	img := image.NewRGBA(image.Rect(0, 0, w.Width*scale, w.Height*scale))
+
+	for i := 0; i < w.Height; i++ {
+		for j := 0; j < w.Width; j++ {
+			c := p.CellColor(w, i, j)
+			for dy := range scale {
+				for dx := range scale {
+					img.SetRGBA(j*scale+dx, i*scale+dy, c)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// SavePNG renders the world to a PNG file
+func SavePNG(path string, w *simulation.World, p Palette, scale int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, Image(w, p, scale)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteNPY writes the cell types (0=empty, 1=fish, 2=shark, 3=barrier) as a
+// (height, width) uint8 array in NumPy's .npy format, loadable with numpy.load
+func WriteNPY(out io.Writer, w *simulation.World) error {
+	header := fmt.Sprintf("{'descr': '|u1', 'fortran_order': False, 'shape': (%d, %d), }", w.Height, w.Width)
+
+	// Magic (6) + version (2) + header length (2) + header must be a
+	// multiple of 64 bytes, terminated by a newline
+	total := 10 + len(header) + 1
+	padding := (64 - total%64) % 64
+	for range padding {
+		header += " "
+	}
+	header += "\n"
+
+	prefix := []byte("\x93NUMPY\x01\x00")
+	prefix = binary.LittleEndian.AppendUint16(prefix, uint16(len(header)))
+	if _, err := out.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(out, header); err != nil {
+		return err
+	}
+
+	row := make([]byte, w.Width)
+	for i := 0; i < w.Height; i++ {
+		for j := 0; j < w.Width; j++ {
+			row[j] = byte(w.Cell(i, j).Type)
+		}
+		if _, err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
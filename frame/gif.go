@@ -0,0 +1,214 @@
+package frame
+
+import (
+	"bufio"
+	"compress/lzw"
+	"fmt"
+	"image/color"
+	"os"
+
+	"wa-tor/simulation"
+)
+
+// gifBaseColors is the number of palette entries for cell types, reef and
+// algae
+const gifBaseColors = 6
+
+// GIFRecorder writes every N-th step of a run to an animated GIF as it
+// runs, so a long recording does not hold its frames in memory. Each frame
+// carries its own color table, as the palette grows with new species.
+type GIFRecorder struct {
+	file          *os.File
+	buf           *bufio.Writer
+	every         int
+	scale         int
+	delay         int
+	palette       color.Palette
+	width, height int // Size of the first frame in pixels
+	frames        int
+	pix           []byte // Palette indexes of the frame being written
+	err           error
+}
+
+// NewGIFRecorder starts an animated GIF at path capturing one frame every
+// `every` steps, drawing each cell as a scale x scale block
+func NewGIFRecorder(path string, every, scale int, p Palette) (*GIFRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GIFRecorder{
+		file:    f,
+		buf:     bufio.NewWriter(f),
+		every:   max(every, 1),
+		scale:   max(scale, 1),
+		delay:   4, // 100ths of a second, i.e. 25 frames per second
+		palette: p.indexed(),
+	}, nil
+}
+
+// Capture encodes the world as a frame if step falls on the capture interval
+func (r *GIFRecorder) Capture(step int, w *simulation.World) {
+	if step%r.every != 0 || r.err != nil {
+		return
+	}
+
+	width, height := w.Width*r.scale, w.Height*r.scale
+	if r.frames == 0 {
+		r.width, r.height = width, height
+		r.writeHeader()
+	} else if width != r.width || height != r.height {
+		return // A world of another size, such as an opened snapshot
+	}
+
+	r.palette = extendPalette(r.palette, w)
+	r.pix = r.pix[:0]
+	for i := 0; i < w.Height; i++ {
+		start := len(r.pix)
+		for j := 0; j < w.Width; j++ {
+			idx := paletteIndex(r.palette, w, i, j)
+			for range r.scale {
+				r.pix = append(r.pix, idx)
+			}
+		}
+		for range r.scale - 1 {
+			r.pix = append(r.pix, r.pix[start:start+width]...)
+		}
+	}
+	r.writeFrame()
+	r.frames++
+}
+
+// Frames returns the number of frames captured so far
+func (r *GIFRecorder) Frames() int {
+	return r.frames
+}
+
+// Close finishes the file and reports the first error that occurred while
+// recording
+func (r *GIFRecorder) Close() error {
+	if r.err == nil && r.frames == 0 {
+		r.err = fmt.Errorf("no frames were captured")
+	}
+	if r.err == nil {
+		r.buf.WriteByte(0x3b) // Trailer
+		r.err = r.buf.Flush()
+	}
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// writeHeader writes the GIF header, the screen of the first frame without
+// a global color table and the extension looping the animation forever
+func (r *GIFRecorder) writeHeader() {
+	r.buf.WriteString("GIF89a")
+	r.writeUint16(r.width, r.height)
+	r.buf.Write([]byte{0, 0, 0})
+	r.buf.Write([]byte{0x21, 0xff, 11})
+	r.buf.WriteString("NETSCAPE2.0")
+	r.buf.Write([]byte{3, 1, 0, 0, 0})
+}
+
+// writeFrame writes the delay, the image descriptor and color table and the
+// LZW-compressed pixels of the frame in r.pix
+func (r *GIFRecorder) writeFrame() {
+	r.buf.Write([]byte{0x21, 0xf9, 4, 0})
+	r.writeUint16(r.delay)
+	r.buf.Write([]byte{0, 0})
+
+	bits := 1
+	for 1<<bits < len(r.palette) {
+		bits++
+	}
+	r.buf.WriteByte(0x2c)
+	r.writeUint16(0, 0, r.width, r.height)
+	r.buf.WriteByte(0x80 | byte(bits-1)) // Local color table of 2^bits colors
+	for i := range 1 << bits {
+		c := color.RGBA{}
+		if i < len(r.palette) {
+			c = color.RGBAModel.Convert(r.palette[i]).(color.RGBA)
+		}
+		r.buf.Write([]byte{c.R, c.G, c.B})
+	}
+
+	litWidth := max(bits, 2)
+	r.buf.WriteByte(byte(litWidth))
+	blocks := &gifBlockWriter{w: r.buf}
+	lw := lzw.NewWriter(blocks, lzw.LSB, litWidth)
+	if _, err := lw.Write(r.pix); err != nil {
+		r.err = err
+		return
+	}
+	if err := lw.Close(); err != nil {
+		r.err = err
+		return
+	}
+	blocks.flush()
+	r.buf.WriteByte(0) // Block terminator
+}
+
+// writeUint16 writes each value as a little-endian 16-bit number
+func (r *GIFRecorder) writeUint16(values ...int) {
+	for _, v := range values {
+		r.buf.Write([]byte{byte(v), byte(v >> 8)})
+	}
+}
+
+// gifBlockWriter splits the compressed pixels into the data sub-blocks of
+// a GIF image, each at most 255 bytes led by its length
+type gifBlockWriter struct {
+	w     *bufio.Writer
+	block []byte
+}
+
+func (b *gifBlockWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.block = append(b.block, c)
+		if len(b.block) == 255 {
+			b.flush()
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the bytes collected so far as a sub-block
+func (b *gifBlockWriter) flush() {
+	if len(b.block) == 0 {
+		return
+	}
+	b.w.WriteByte(byte(len(b.block)))
+	b.w.Write(b.block)
+	b.block = b.block[:0]
+}
+
+// indexed returns the palette as GIF colors indexed by cell type, followed
+// by reef and algae
+func (p Palette) indexed() color.Palette {
+	return color.Palette{p.Empty, p.Fish, p.Shark, p.Barrier, p.Reef, p.Algae}
+}
+
+// extendPalette appends the colors of additional predator species, which
+// follow the six cell colors
+func extendPalette(palette color.Palette, w *simulation.World) color.Palette {
+	for len(palette) < gifBaseColors+len(w.Species) && len(palette) < 256 {
+		palette = append(palette, w.Species[len(palette)-gifBaseColors].Color)
+	}
+	return palette
+}
+
+// paletteIndex returns the palette entry of the cell at (y, x)
+func paletteIndex(palette color.Palette, w *simulation.World, y, x int) uint8 {
+	cell := w.Cell(y, x)
+	switch {
+	case cell.Type == simulation.Shark && cell.Species > 0 && gifBaseColors+cell.Species-1 < len(palette):
+		return uint8(gifBaseColors + cell.Species - 1)
+	case cell.Type == simulation.Empty && cell.Algae:
+		return 5
+	case cell.Type == simulation.Empty && w.Terrain.IsReef(y, x):
+		return 4
+	default:
+		return uint8(cell.Type)
+	}
+}
@@ -0,0 +1,124 @@
+package frame
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+
+	"wa-tor/simulation"
+)
+
+// RingLog keeps the last frames of a run in a fixed-size file on disk, like
+// a dashcam, so the recent past can be saved after something interesting
+// happened. Each slot holds the step followed by one palette index per cell.
+type RingLog struct {
+	file          *os.File
+	slots         int
+	width, height int
+	palette       color.Palette
+	next          int   // Slot written by the next capture
+	count         int   // Slots holding a frame
+	steps         []int // Step held by each slot
+}
+
+// ringStepSize is the size of the step stored at the start of each slot
+const ringStepSize = 8
+
+// NewRingLog creates or truncates the ring log file at path, keeping the
+// last slots frames
+func NewRingLog(path string, slots int, p Palette) (*RingLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	slots = max(slots, 1)
+	return &RingLog{file: f, slots: slots, palette: p.indexed(), steps: make([]int, slots)}, nil
+}
+
+// Capture writes the world over the oldest frame. A world of a different
+// size, such as one opened from a snapshot, starts the log over.
+func (r *RingLog) Capture(step int, w *simulation.World) {
+	if w.Width != r.width || w.Height != r.height {
+		r.width, r.height = w.Width, w.Height
+		r.next, r.count = 0, 0
+	}
+	r.palette = extendPalette(r.palette, w)
+
+	slot := make([]byte, ringStepSize, ringStepSize+w.Width*w.Height)
+	binary.LittleEndian.PutUint64(slot, uint64(step))
+	for i := 0; i < w.Height; i++ {
+		for j := 0; j < w.Width; j++ {
+			slot = append(slot, paletteIndex(r.palette, w, i, j))
+		}
+	}
+	if _, err := r.file.WriteAt(slot, int64(r.next)*int64(len(slot))); err != nil {
+		fmt.Printf("Error writing ring log: %v\n", err)
+		return
+	}
+
+	r.steps[r.next] = step
+	r.next = (r.next + 1) % r.slots
+	r.count = min(r.count+1, r.slots)
+}
+
+// Frames returns the number of frames the log holds
+func (r *RingLog) Frames() int {
+	return r.count
+}
+
+// Steps returns the steps of the oldest and newest frame in the log
+func (r *RingLog) Steps() (first, last int) {
+	return r.steps[(r.next-r.count+r.slots)%r.slots], r.steps[(r.next-1+r.slots)%r.slots]
+}
+
+// Dump writes the logged frames, oldest first, as an animated GIF drawing
+// each cell as a scale x scale block
+func (r *RingLog) Dump(path string, scale int) error {
+	if r.count == 0 {
+		return fmt.Errorf("the ring log is empty")
+	}
+	scale = max(scale, 1)
+
+	size := ringStepSize + r.width*r.height
+	slot := make([]byte, size)
+	anim := &gif.GIF{}
+	for k := range r.count {
+		idx := (r.next - r.count + k + r.slots) % r.slots
+		if _, err := r.file.ReadAt(slot, int64(idx)*int64(size)); err != nil {
+			return err
+		}
+
+		img := image.NewPaletted(image.Rect(0, 0, r.width*scale, r.height*scale), r.palette)
+		cells := slot[ringStepSize:]
+		for i := 0; i < r.height; i++ {
+			for j := 0; j < r.width; j++ {
+				for dy := range scale {
+					row := (i*scale + dy) * img.Stride
+					for dx := range scale {
+						img.Pix[row+j*scale+dx] = cells[i*r.width+j]
+					}
+				}
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, 4)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close closes the log file, which stays on disk
+func (r *RingLog) Close() error {
+	return r.file.Close()
+}
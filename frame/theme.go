@@ -0,0 +1,84 @@
+package frame
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// ThemeNames lists the palettes selectable by name, the first being the
+// default
+var ThemeNames = []string{"classic", "high-contrast", "colorblind", "grayscale", "light"}
+
+// themes holds the palettes of ThemeNames
+var themes = map[string]Palette{
+	"classic": DefaultPalette,
+	// Yellow fish and magenta sharks on black, for low-quality displays
+	"high-contrast": {
+		Empty:   color.RGBA{0, 0, 0, 255},
+		Fish:    color.RGBA{255, 255, 0, 255},
+		Shark:   color.RGBA{255, 0, 255, 255},
+		Barrier: color.RGBA{160, 160, 160, 255},
+		Reef:    color.RGBA{0, 0, 110, 255},
+		Algae:   color.RGBA{0, 70, 70, 255},
+	},
+	// Sky blue fish and orange sharks from the Okabe-Ito palette, which stay
+	// distinct with red-green color blindness
+	"colorblind": {
+		Empty:   color.RGBA{15, 15, 35, 255},
+		Fish:    color.RGBA{86, 180, 233, 255},
+		Shark:   color.RGBA{230, 159, 0, 255},
+		Barrier: color.RGBA{120, 110, 100, 255},
+		Reef:    color.RGBA{40, 40, 80, 255},
+		Algae:   color.RGBA{55, 55, 30, 255},
+	},
+	// Shades of gray that survive printing in black and white
+	"grayscale": {
+		Empty:   color.RGBA{20, 20, 20, 255},
+		Fish:    color.RGBA{150, 150, 150, 255},
+		Shark:   color.RGBA{255, 255, 255, 255},
+		Barrier: color.RGBA{90, 90, 90, 255},
+		Reef:    color.RGBA{40, 40, 40, 255},
+		Algae:   color.RGBA{60, 60, 60, 255},
+	},
+	// Green fish and red sharks on a pale ocean, for bright rooms and
+	// projectors; the window draws its text dark on it
+	"light": {
+		Empty:   color.RGBA{235, 240, 245, 255},
+		Fish:    color.RGBA{0, 150, 60, 255},
+		Shark:   color.RGBA{210, 30, 30, 255},
+		Barrier: color.RGBA{170, 150, 120, 255},
+		Reef:    color.RGBA{200, 225, 235, 255},
+		Algae:   color.RGBA{215, 235, 200, 255},
+	},
+}
+
+// Theme returns the palette with the given name
+func Theme(name string) (Palette, error) {
+	if p, ok := themes[name]; ok {
+		return p, nil
+	}
+	return Palette{}, fmt.Errorf("unknown theme %q (expected %s)", name, strings.Join(ThemeNames, ", "))
+}
+
+// SetColor changes the color of a kind of cell by name: empty, fish, shark,
+// barrier, reef or algae
+func (p *Palette) SetColor(name string, c color.RGBA) error {
+	switch name {
+	case "empty":
+		p.Empty = c
+	case "fish":
+		p.Fish = c
+	case "shark":
+		p.Shark = c
+	case "barrier":
+		p.Barrier = c
+	case "reef":
+		p.Reef = c
+	case "algae":
+		p.Algae = c
+	default:
+		return fmt.Errorf("unknown cell kind %q (expected empty, fish, shark, barrier, reef or algae)", name)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/simulation"
+)
+
+// headlessProgressEvery is how often a run without -steps reports progress
+const headlessProgressEvery = 1000
+
+// runHeadless runs the simulation without a window from step start up to
+// step -steps, or until a species dies out if -steps is 0, or until
+// interrupt is closed
+func runHeadless(world *simulation.World, cfg *config.Config, start int, afterStep func(int, *simulation.World), interrupt <-chan struct{}) {
+	fmt.Println("Running in headless mode...")
+	startTime := time.Now()
+	totalFishEaten := 0
+	var timing simulation.StepTiming
+
+	extinction := analysis.NewExtinctionTracker(extinctionRule(cfg))
+	fish, sharks := world.Count()
+	extinction.Observe(start, fish, sharks)
+	equilibrium := analysis.NewEquilibriumTracker(equilibriumRule(cfg))
+	equilibrium.Observe(start, fish, sharks)
+
+	engine := cfg.StepEngine()
+	step := start
+	for ; cfg.Steps == 0 || step < cfg.Steps; step++ {
+		// Check termination conditions
+		if extinction.Ended() {
+			fmt.Printf("\n%s at step %d\n", extinction.Reason(), step)
+			break
+		}
+		if interrupted(interrupt) {
+			fmt.Printf("\nInterrupted at step %d\n", step)
+			break
+		}
+		if cfg.Equilibrium && equilibrium.Ended() {
+			fmt.Printf("\n%s at step %d\n%s\n", equilibrium.Reason(), step, equilibrium.Report())
+			break
+		}
+
+		// Perform simulation step
+		fishEaten := world.StepWith(engine, cfg.Threads)
+		totalFishEaten += fishEaten
+		timing.Add(world.LastStepTiming())
+		fish, sharks = world.Count()
+		extinction.Observe(step+1, fish, sharks)
+		equilibrium.Observe(step+1, fish, sharks)
+		afterStep(step+1, world)
+		if cfg.Steps == 0 && (step+1)%headlessProgressEvery == 0 {
+			fmt.Printf("Step %d - Fish: %d, Sharks: %d\n", step+1, fish, sharks)
+		}
+	}
+	if cfg.Steps > 0 && step == cfg.Steps {
+		fmt.Printf("\nReached max steps: %d\n", step)
+	}
+
+	elapsed := time.Since(startTime)
+
+	// Print final statistics
+	fmt.Printf("\nSimulation completed\n")
+	fmt.Printf("Steps completed: %d\n", step)
+	printSimulatedTime(cfg, step)
+	fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
+	fmt.Printf("Final state hash: %s\n", simulation.ShortHash(world.Hash()))
+	printSpecies(world)
+	printExtinction(extinction)
+	fmt.Printf("Total fish eaten: %d\n", totalFishEaten)
+	fmt.Printf("Total execution time: %v\n", elapsed)
+	if step > start {
+		fmt.Printf("Average time per step: %v\n", elapsed/time.Duration(step-start))
+	}
+	printStepTiming(timing.Per(step-start), step-start)
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/frame"
+	"wa-tor/replay"
+	"wa-tor/simulation"
+)
+
+// runHooks collects callbacks run after every simulation step and once
+// when the run ends
+type runHooks struct {
+	steps    []func(int, *simulation.World)
+	finishes []func()
+}
+
+// onStep registers a callback run after every step
+func (h *runHooks) onStep(fn func(int, *simulation.World)) {
+	h.steps = append(h.steps, fn)
+}
+
+// onFinish registers a callback run when the simulation ends
+func (h *runHooks) onFinish(fn func()) {
+	h.finishes = append(h.finishes, fn)
+}
+
+// afterStep calls every step callback in order
+func (h *runHooks) afterStep(step int, world *simulation.World) {
+	for _, fn := range h.steps {
+		fn(step, world)
+	}
+}
+
+// finish calls every finish callback in order
+func (h *runHooks) finish() {
+	for _, fn := range h.finishes {
+		fn()
+	}
+}
+
+// stepHeader returns the header of the leading columns of CSV reports: the
+// step, followed by the simulated day with -chronon-days
+func stepHeader(cfg *config.Config) string {
+	if cfg.Calendar().Enabled() {
+		return "step,day"
+	}
+	return "step"
+}
+
+// stepColumns returns the leading columns of a CSV report row for a step
+func stepColumns(cfg *config.Config, step int) string {
+	if cal := cfg.Calendar(); cal.Enabled() {
+		return fmt.Sprintf("%d,%g", step, cal.Days(step))
+	}
+	return fmt.Sprint(step)
+}
+
+// reportFile is a CSV report written through a buffer. It is flushed to
+// disk every -flush-every steps, so a run that crashes or is killed keeps
+// the rows written up to the last flush.
+type reportFile struct {
+	*bufio.Writer
+	file *os.File
+}
+
+// createReport creates a report file, or writes to standard output if path
+// is "-", and registers the hook that flushes it
+func createReport(cfg *config.Config, path string, hooks *runHooks) (*reportFile, error) {
+	f := os.Stdout
+	if path != "-" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return nil, err
+		}
+	}
+	r := &reportFile{Writer: bufio.NewWriter(f), file: f}
+	hooks.onStep(func(step int, _ *simulation.World) {
+		if step > 0 && step%cfg.FlushEvery == 0 {
+			r.flush()
+		}
+	})
+	return r, nil
+}
+
+// flush writes the buffered rows and asks the system to commit them to
+// disk. Errors are kept by the buffer and reported by Close.
+func (r *reportFile) flush() {
+	if r.Writer.Flush() == nil {
+		r.file.Sync()
+	}
+}
+
+// Close writes the remaining rows and closes the file, unless it is
+// standard output
+func (r *reportFile) Close() error {
+	err := r.Writer.Flush()
+	if r.file == os.Stdout {
+		return err
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// scheduleHook returns a function that applies the parameter changes
+// scheduled in the config file and marks them on the timeline
+func scheduleHook(cfg *config.Config, timeline *analysis.Timeline) func(int, *simulation.World) {
+	return func(step int, world *simulation.World) {
+		for _, change := range cfg.Schedule {
+			if change.Step != step {
+				continue
+			}
+			for _, name := range slices.Sorted(maps.Keys(change.Set)) {
+				old, err := world.SetParameter(name, change.Set[name])
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				timeline.MarkParameter(step, name, old, change.Set[name])
+				fmt.Printf("Step %d: %s changed from %g to %g\n", step, name, old, change.Set[name])
+			}
+		}
+	}
+}
+
+// snapshotHook returns a function that saves a snapshot whenever the world
+// reaches one of the steps requested with -snapshot-at
+func snapshotHook(cfg *config.Config) func(int, *simulation.World) {
+	at := make(map[int]bool, len(cfg.SnapshotAt))
+	for _, step := range cfg.SnapshotAt {
+		at[step] = true
+	}
+
+	return func(step int, world *simulation.World) {
+		if !at[step] {
+			return
+		}
+		path := filepath.Join(cfg.SnapshotDir, fmt.Sprintf("snapshot-%06d.json", step))
+		if err := world.Snapshot(step).Save(path); err != nil {
+			fmt.Printf("Error saving snapshot: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved snapshot at step %d to %s\n", step, path)
+	}
+}
+
+// pngHook returns a function that saves a PNG image of the grid every
+// -snapshot-every steps
+func pngHook(cfg *config.Config) func(int, *simulation.World) {
+	return func(step int, world *simulation.World) {
+		if cfg.PNGEvery > 0 && step%cfg.PNGEvery == 0 {
+			savePNG(cfg, step, world)
+		}
+	}
+}
+
+// checkpointHook returns a function that saves a checkpoint every
+// -checkpoint-every steps after start, the step the run began or resumed
+// at. The checkpoint holds the state of the world's generator, so a run
+// resumed from it continues as this one does.
+func checkpointHook(cfg *config.Config, start int) func(int, *simulation.World) {
+	return func(step int, world *simulation.World) {
+		if cfg.CheckpointEvery == 0 || step <= start || step%cfg.CheckpointEvery != 0 {
+			return
+		}
+		path := filepath.Join(cfg.CheckpointDir, fmt.Sprintf("checkpoint-%06d.bin", step))
+		if err := world.Snapshot(step).SaveCheckpoint(path); err != nil {
+			fmt.Printf("Error saving checkpoint: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved checkpoint at step %d to %s\n", step, path)
+	}
+}
+
+// oscillationHooks records the populations of every step and reports the
+// predator-prey cycle when the run ends. Jumps in the step, such as a
+// restart or an opened snapshot, start the record over.
+func oscillationHooks(hooks *runHooks) {
+	var fish, sharks []float64
+	last := -1
+	hooks.onStep(func(step int, world *simulation.World) {
+		if step != last+1 {
+			fish, sharks = fish[:0], sharks[:0]
+		}
+		last = step
+		f, s := world.Count()
+		fish = append(fish, float64(f))
+		sharks = append(sharks, float64(s))
+	})
+	hooks.onFinish(func() { printOscillations(analysis.AnalyzeOscillations(fish, sharks)) })
+}
+
+// dumpHooks saves a snapshot of the world to -dump-on-exit when the run
+// ends, whether it finished or was interrupted
+func dumpHooks(cfg *config.Config, hooks *runHooks) {
+	var last *simulation.World
+	lastStep := 0
+	hooks.onStep(func(step int, world *simulation.World) {
+		last, lastStep = world, step
+	})
+	hooks.onFinish(func() {
+		if err := last.Snapshot(lastStep).Save(cfg.DumpOnExit); err != nil {
+			fmt.Printf("Error saving snapshot: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved final state at step %d to %s\n", lastStep, cfg.DumpOnExit)
+	})
+}
+
+// hashHook returns a function that prints the hash of the grid every
+// -hash-every steps
+func hashHook(cfg *config.Config) func(int, *simulation.World) {
+	return func(step int, world *simulation.World) {
+		if cfg.HashEvery > 0 && step%cfg.HashEvery == 0 {
+			fmt.Printf("Step %d hash: %s\n", step, simulation.ShortHash(world.Hash()))
+		}
+	}
+}
+
+// savePNG writes the grid as frame-<step>.png in the snapshot directory
+func savePNG(cfg *config.Config, step int, world *simulation.World) {
+	path := filepath.Join(cfg.SnapshotDir, fmt.Sprintf("frame-%06d.png", step))
+	if err := frame.SavePNG(path, world, palette(cfg), fileCellSize(cfg)); err != nil {
+		fmt.Printf("Error saving image: %v\n", err)
+		return
+	}
+	fmt.Printf("Saved image at step %d to %s\n", step, path)
+}
+
+// gifHooks records the run to the animated GIF given with -record
+func gifHooks(cfg *config.Config, hooks *runHooks) error {
+	recorder, err := frame.NewGIFRecorder(cfg.Record, cfg.RecordEvery, fileCellSize(cfg), palette(cfg))
+	if err != nil {
+		return err
+	}
+	hooks.onStep(recorder.Capture)
+	hooks.onFinish(func() {
+		if err := recorder.Close(); err != nil {
+			fmt.Printf("Error saving recording: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved %d frames to %s\n", recorder.Frames(), cfg.Record)
+	})
+	return nil
+}
+
+// replayHooks records the run to the .wtr file given with -record. The
+// markers of the timeline are added when the run ends.
+func replayHooks(cfg *config.Config, timeline *analysis.Timeline, hooks *runHooks) error {
+	recorder, err := replay.Create(cfg.Record, cfg.RecordEvery)
+	if err != nil {
+		return err
+	}
+	hooks.onStep(func(step int, world *simulation.World) {
+		recorder.Capture(step, world)
+		if step > 0 && step%cfg.FlushEvery == 0 {
+			recorder.Flush()
+		}
+	})
+	hooks.onFinish(func() {
+		for _, m := range timeline.Markers {
+			recorder.Mark(m.Step, m.Label)
+		}
+		if err := recorder.Close(); err != nil {
+			fmt.Printf("Error saving replay: %v\n", err)
+			return
+		}
+		fmt.Printf("Saved %d frames to %s\n", recorder.Frames(), cfg.Record)
+	})
+	return nil
+}
+
+// ringLogHooks keeps the last -ringlog steps in ringlog.bin in the snapshot
+// directory and returns the log so it can be dumped on request
+func ringLogHooks(cfg *config.Config, hooks *runHooks) (*frame.RingLog, error) {
+	ring, err := frame.NewRingLog(filepath.Join(cfg.SnapshotDir, "ringlog.bin"), cfg.RingLog, palette(cfg))
+	if err != nil {
+		return nil, err
+	}
+	hooks.onStep(ring.Capture)
+	hooks.onFinish(func() { ring.Close() })
+	return ring, nil
+}
+
+// dumpRingLog saves the steps held by the ring log as an animated GIF
+// named after the steps it covers
+func dumpRingLog(cfg *config.Config, ring *frame.RingLog) {
+	if ring.Frames() == 0 {
+		fmt.Println("Ring log is empty")
+		return
+	}
+	first, last := ring.Steps()
+	path := filepath.Join(cfg.SnapshotDir, fmt.Sprintf("replay-%06d-%06d.gif", first, last))
+	if err := ring.Dump(path, fileCellSize(cfg)); err != nil {
+		fmt.Printf("Error saving ring log: %v\n", err)
+		return
+	}
+	fmt.Printf("Saved steps %d-%d from the ring log to %s\n", first, last, path)
+}
+
+// basinHooks tracks per-basin populations, printing local extinction and
+// recolonization events and writing populations to -basin-report as CSV
+func basinHooks(cfg *config.Config, world *simulation.World, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.BasinReport, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, stepHeader(cfg)+",basin,fish,sharks")
+
+	tracker := analysis.NewBasinTracker(world)
+	fmt.Printf("Detected %d basins\n", len(tracker.Basins.Size))
+
+	hooks.onStep(func(step int, world *simulation.World) {
+		for _, e := range tracker.Observe(step, world) {
+			name := "fish"
+			if e.Species == simulation.Shark {
+				name = "sharks"
+			}
+			fmt.Printf("Step %d: %s %s in basin %d\n", e.Step, name, e.Kind, e.Basin)
+		}
+		for id := range tracker.Basins.Size {
+			fmt.Fprintf(f, "%s,%d,%d,%d\n", stepColumns(cfg, step), id, tracker.Fish[id], tracker.Sharks[id])
+		}
+	})
+
+	hooks.onFinish(func() {
+		fmt.Printf("\nBasin  Cells   Fish  Sharks  Extinctions  Recolonizations\n")
+		for id, size := range tracker.Basins.Size {
+			fmt.Printf("%5d  %5d  %5d  %6d  %11d  %15d\n", id, size,
+				tracker.Fish[id], tracker.Sharks[id], tracker.Extinctions[id], tracker.Recolonized[id])
+		}
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing basin report: %v\n", err)
+			return
+		}
+		fmt.Printf("Basin populations written to %s\n", cfg.BasinReport)
+	})
+
+	return nil
+}
+
+// traitHooks writes the histograms of heritable traits after every step to
+// -traits as CSV and prints the final mean of each trait
+func traitHooks(cfg *config.Config, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.Traits, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, stepHeader(cfg)+",population,trait,value,count")
+
+	var last []analysis.TraitHistogram
+	hooks.onStep(func(step int, world *simulation.World) {
+		last = analysis.TraitHistograms(world)
+		for _, h := range last {
+			for _, v := range h.Values() {
+				fmt.Fprintf(f, "%s,%s,%s,%d,%d\n", stepColumns(cfg, step), h.Population, h.Trait, v, h.Counts[v])
+			}
+		}
+	})
+
+	hooks.onFinish(func() {
+		fmt.Println()
+		for _, h := range last {
+			fmt.Printf("Mean %s %s time: %.2f\n", h.Population, h.Trait, h.Mean())
+		}
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing trait histograms: %v\n", err)
+			return
+		}
+		fmt.Printf("Trait histograms written to %s\n", cfg.Traits)
+	})
+
+	return nil
+}
+
+// deathHooks writes the deaths of every population by cause after every step
+// to -deaths as CSV and prints the totals
+func deathHooks(cfg *config.Config, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.Deaths, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "%s,population,%s\n", stepHeader(cfg), strings.Join(analysis.DeathCauses, ","))
+
+	var totals []analysis.Deaths
+	hooks.onStep(func(step int, world *simulation.World) {
+		if step == 0 {
+			return
+		}
+		deaths := analysis.StepDeaths(world)
+		for i, d := range deaths {
+			fmt.Fprintf(f, "%s,%s,%d,%d,%d\n", stepColumns(cfg, step), d.Population, d.Counts[0], d.Counts[1], d.Counts[2])
+			if i == len(totals) {
+				totals = append(totals, analysis.Deaths{Population: d.Population})
+			}
+			for cause, n := range d.Counts {
+				totals[i].Counts[cause] += n
+			}
+		}
+	})
+
+	hooks.onFinish(func() {
+		fmt.Printf("\nDeaths       Predation  Starvation  Old age\n")
+		for _, d := range totals {
+			fmt.Printf("%-10s  %10d  %10d  %7d\n", d.Population, d.Counts[0], d.Counts[1], d.Counts[2])
+		}
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing death causes: %v\n", err)
+			return
+		}
+		fmt.Printf("Death causes written to %s\n", cfg.Deaths)
+	})
+
+	return nil
+}
+
+// cohortHooks writes the age structure of every population every
+// -cohort-every steps to -cohorts as CSV and prints the final mean ages
+func cohortHooks(cfg *config.Config, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.Cohorts, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, stepHeader(cfg)+",population,min_age,max_age,count")
+
+	var last []analysis.AgeClasses
+	hooks.onStep(func(step int, world *simulation.World) {
+		if step%cfg.CohortEvery != 0 {
+			return
+		}
+		last = analysis.AgeStructure(world, cfg.CohortWidth)
+		for _, a := range last {
+			for class, count := range a.Counts {
+				fmt.Fprintf(f, "%s,%s,%d,%d,%d\n", stepColumns(cfg, step), a.Population, class*a.Width, (class+1)*a.Width-1, count)
+			}
+		}
+	})
+
+	hooks.onFinish(func() {
+		fmt.Println()
+		for _, a := range last {
+			fmt.Printf("Mean %s age: %.1f chronons (%d animals)\n", a.Population, a.MeanAge(), a.Total())
+		}
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing age structure: %v\n", err)
+			return
+		}
+		fmt.Printf("Age structure written to %s\n", cfg.Cohorts)
+	})
+
+	return nil
+}
+
+// eventRecord is a line of the -events log
+type eventRecord struct {
+	Step   int                  `json:"step"`
+	Event  simulation.EventKind `json:"event"`
+	X      int                  `json:"x"`
+	Y      int                  `json:"y"`
+	Animal string               `json:"animal"`
+}
+
+// eventLog observes the worlds of a run and writes their births, deaths
+// and predation to -events as JSON Lines
+type eventLog struct {
+	simulation.NopObserver
+	enc     *json.Encoder
+	world   *simulation.World
+	pending []simulation.Event // Events of the step being taken
+	written int
+}
+
+func (l *eventLog) OnBreed(e simulation.Event) { l.pending = append(l.pending, e) }
+func (l *eventLog) OnEat(e simulation.Event)   { l.pending = append(l.pending, e) }
+func (l *eventLog) OnDeath(e simulation.Event) { l.pending = append(l.pending, e) }
+
+// afterStep writes the events of a step. It observes the world from its
+// first step on, and moves over whenever the window continues with
+// another world.
+func (l *eventLog) afterStep(step int, world *simulation.World) {
+	if world != l.world {
+		if l.world != nil {
+			l.world.RemoveObserver(l)
+		}
+		l.world = world
+		world.AddObserver(l)
+	}
+	for _, e := range l.pending {
+		animal := "fish"
+		if e.Animal == simulation.Shark {
+			animal = "shark"
+			if e.Species > 0 {
+				animal = world.SpeciesName(e.Species)
+			}
+		}
+		l.enc.Encode(eventRecord{Step: step, Event: e.Kind, X: e.X, Y: e.Y, Animal: animal})
+	}
+	l.written += len(l.pending)
+	l.pending = l.pending[:0]
+}
+
+// eventHooks writes every birth, death and predation to -events as JSON
+// Lines through an eventLog
+func eventHooks(cfg *config.Config, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.Events, hooks)
+	if err != nil {
+		return err
+	}
+	log := &eventLog{enc: json.NewEncoder(f)}
+	hooks.onStep(log.afterStep)
+
+	hooks.onFinish(func() {
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing events: %v\n", err)
+			return
+		}
+		if cfg.Events != "-" {
+			fmt.Printf("\n%d events written to %s\n", log.written, cfg.Events)
+		}
+	})
+
+	return nil
+}
+
+// chaseHooks follows every predator from meal to meal and, when the run
+// ends, writes the distributions of the cells moved and the steps taken
+// between meals to -chase as CSV and prints the hunting success rate
+func chaseHooks(cfg *config.Config, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.Chase, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, "metric,value,count")
+
+	// The window may continue with another world, whose predators are
+	// followed from then on
+	var world *simulation.World
+	var tracker *analysis.ChaseTracker
+	hooks.onStep(func(step int, w *simulation.World) {
+		if w == world {
+			return
+		}
+		if world != nil {
+			world.RemoveObserver(tracker)
+		}
+		fresh := analysis.NewChaseTracker(w)
+		if tracker != nil {
+			fresh.Distance, fresh.Duration = tracker.Distance, tracker.Duration
+			fresh.Meals, fresh.Starved = tracker.Meals, tracker.Starved
+		}
+		world, tracker = w, fresh
+		w.AddObserver(tracker)
+	})
+
+	hooks.onFinish(func() {
+		for _, v := range analysis.HistogramValues(tracker.Distance) {
+			fmt.Fprintf(f, "distance,%d,%d\n", v, tracker.Distance[v])
+		}
+		for _, v := range analysis.HistogramValues(tracker.Duration) {
+			fmt.Fprintf(f, "steps,%d,%d\n", v, tracker.Duration[v])
+		}
+		fmt.Printf("\nHunts: %d meals, %d starved, %.1f%% success\n", tracker.Meals, tracker.Starved, 100*tracker.SuccessRate())
+		fmt.Printf("Between meals: %.2f cells moved, %.2f steps\n", tracker.MeanDistance(), tracker.MeanDuration())
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing chase statistics: %v\n", err)
+			return
+		}
+		fmt.Printf("Chase statistics written to %s\n", cfg.Chase)
+	})
+
+	return nil
+}
+
+// meanFieldHooks integrates the mean-field model alongside the simulation,
+// writing both trajectories to -meanfield as CSV and printing how far the
+// simulation deviated from the model
+func meanFieldHooks(cfg *config.Config, world *simulation.World, hooks *runHooks) error {
+	f, err := createReport(cfg, cfg.MeanField, hooks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, stepHeader(cfg)+",fish,sharks,meanfield_fish,meanfield_sharks")
+	fish, sharks := world.Count()
+	fmt.Fprintf(f, "%s,%d,%d,%d,%d\n", stepColumns(cfg, 0), fish, sharks, fish, sharks)
+
+	comparison := analysis.NewMeanFieldComparison(world)
+	hooks.onStep(func(step int, world *simulation.World) {
+		if step == 0 {
+			return
+		}
+		fish, sharks := world.Count()
+		modelFish, modelSharks := comparison.Observe(fish, sharks)
+		fmt.Fprintf(f, "%s,%d,%d,%.1f,%.1f\n", stepColumns(cfg, step), fish, sharks, modelFish, modelSharks)
+	})
+
+	hooks.onFinish(func() {
+		rmsFish, rmsSharks := comparison.RMS()
+		fmt.Printf("\nDeviation from the mean-field model over %d steps (RMS): fish %.1f, sharks %.1f\n",
+			comparison.Steps, rmsFish, rmsSharks)
+		if err := f.Close(); err != nil {
+			fmt.Printf("Error writing mean-field comparison: %v\n", err)
+			return
+		}
+		fmt.Printf("Mean-field comparison written to %s\n", cfg.MeanField)
+	})
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"wa-tor/replay"
+	"wa-tor/simulation"
+)
+
+// runInfo implements "wa-tor info file.wtr|snap.json": it prints the
+// parameters, seed and steps of a replay or snapshot and whether the file is
+// intact, reading it record by record or cell by cell.
+func runInfo(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: wa-tor info file.wtr|snapshot.json")
+	}
+	path := args[0]
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(f, head)
+	if replay.IsReplay(head[:n]) {
+		info, err := replay.Inspect(path)
+		if info == nil {
+			return err
+		}
+		fmt.Printf("File: %s (replay, %s)\n", path, formatBytes(stat.Size()))
+		printSnapshotInfo(info.Header)
+		fmt.Printf("Steps: %d to %d (%d steps, %d frames, %d keyframes)\n",
+			info.FirstStep, info.LastStep, info.LastStep-info.FirstStep, info.Frames, info.Keyframes)
+		for _, m := range info.Markers {
+			fmt.Printf("Step %d: %s\n", m.Step, m.Label)
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("Integrity: damaged, %v\n", err)
+		case !info.Complete:
+			fmt.Printf("Integrity: cut short after frame %d (the recording was not closed), playable up to it\n", info.Frames)
+		default:
+			fmt.Println("Integrity: OK")
+		}
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := simulation.InspectSnapshot(f)
+	if info == nil {
+		return fmt.Errorf("%s is neither a replay nor a snapshot: %v", path, err)
+	}
+	fmt.Printf("File: %s (snapshot, %s)\n", path, formatBytes(stat.Size()))
+	printSnapshotInfo(info)
+	fmt.Printf("Step: %d, Fish: %d, Sharks: %d\n", info.Step, info.Fish, info.Sharks)
+	if err != nil {
+		fmt.Printf("Integrity: damaged, %v\n", err)
+	} else {
+		fmt.Println("Integrity: OK")
+	}
+	return nil
+}
+
+// printSnapshotInfo prints the grid, parameters and seed of a snapshot
+func printSnapshotInfo(info *simulation.SnapshotInfo) {
+	edges := "wrapped"
+	if info.Bounded {
+		edges = "bounded"
+	}
+	terrain := ""
+	if len(info.Layers) > 0 {
+		terrain = ", terrain: " + strings.Join(info.Layers, ", ")
+	}
+	fmt.Printf("Grid: %dx%d, %s%s\n", info.Width, info.Height, edges, terrain)
+	fmt.Printf("Fish Breed: %d, Shark Breed: %d, Starve: %d", info.FishBreed, info.SharkBreed, info.SharkStarve)
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{
+		{"sgain", float64(info.SharkGain)}, {"fstarve", float64(info.FishStarve)}, {"algae", info.AlgaeGrowth},
+		{"fishage", float64(info.FishAge)}, {"mutation", info.Mutation},
+		{"school", info.Schooling},
+	} {
+		if p.value != 0 {
+			fmt.Printf(", %s: %g", p.name, p.value)
+		}
+	}
+	fmt.Println()
+	for _, s := range info.Species {
+		fmt.Printf("Species %s: breed %d, starve %d\n", s.Name, s.Breed, s.Starve)
+	}
+
+	tieBreak := info.TieBreak
+	if tieBreak == "" {
+		tieBreak = simulation.TieRandom.String()
+	}
+	seed := "unknown"
+	if info.Seed != 0 {
+		seed = fmt.Sprint(info.Seed)
+	}
+	fmt.Printf("Seed: %s, Tie-break: %s\n", seed, tieBreak)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// catchInterrupt returns a channel that is closed at the first Ctrl+C or
+// SIGTERM, so the run can stop cleanly and report. A second interrupt
+// ends the program at once, as usual.
+func catchInterrupt() <-chan struct{} {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		close(done)
+	}()
+	return done
+}
+
+// interrupted reports whether the channel of catchInterrupt is closed
+func interrupted(interrupt <-chan struct{}) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+		return false
+	}
+}
@@ -2,17 +2,46 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"time"
+	"os"
 
+	"wa-tor/analysis"
 	"wa-tor/config"
-	"wa-tor/rendering"
+	"wa-tor/frame"
+	"wa-tor/power"
 	"wa-tor/simulation"
-
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
 func main() {
+	// Dispatch subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "branch":
+			if err := runBranch(os.Args[2:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "estimate":
+			if err := runEstimate(os.Args[2:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "info":
+			if err := runInfo(os.Args[2:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "sweep":
+			if err := runSweep(os.Args[2:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Parse configuration from command-line flags
 	cfg, err := config.ParseFlags()
 	if err != nil {
@@ -20,88 +49,147 @@ func main() {
 		return
 	}
 
+	// Profile everything that follows with -cpuprofile and -memprofile
+	stopProfiles, err := startProfiles(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer stopProfiles()
+
+	// Linked worlds replace the single world of the command line
+	if len(cfg.Worlds) > 0 {
+		if err := runLinkedWorlds(cfg); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return
+	}
+
+	// Play back a recorded run instead of simulating
+	if cfg.Replay != "" {
+		if err := runReplay(cfg); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return
+	}
+
 	// Display configuration
 	cfg.Print()
 
-	// Create world with configuration parameters
-	world := simulation.NewWorld(
-		cfg.GridSize, cfg.GridSize,
-		cfg.NumFish, cfg.NumShark,
-		cfg.FishBreed, cfg.SharkBreed, cfg.Starve,
-	)
+	// Chunked oceans run headless on their own
+	if cfg.Ocean > 0 {
+		runOcean(cfg)
+		return
+	}
 
-	// Run in headless mode if steps is specified
-	if cfg.Steps > 0 {
-		runHeadless(world, cfg)
+	// Measure the speedup of more threads instead of running
+	if cfg.Benchmark {
+		if err := runBenchmark(cfg); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
 		return
 	}
 
-	// Create game with rendering configuration
-	game := rendering.NewGame(
-		world,
-		cfg.Threads,
-		cfg.CellSize,
-		cfg.Steps,
-		cfg.UpdateFreq,
-	)
-
-	// Set up window
-	ebiten.SetWindowSize(cfg.GridSize*cfg.CellSize, cfg.GridSize*cfg.CellSize)
-	ebiten.SetWindowTitle("Wa-Tor Simulation")
-	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-
-	// Run game
-	if err := ebiten.RunGame(game); err != nil {
-		if err != ebiten.Termination {
-			log.Fatal(err)
+	// Run several seeds and report the spread of their outcomes
+	if cfg.Runs > 1 {
+		if err := runMany(cfg); err != nil {
+			fmt.Printf("Error: %v\n", err)
 		}
+		return
 	}
 
-	// Print final statistics
-	fish, sharks := world.Count()
-	step, fishEaten, elapsed := game.GetStats()
-	fmt.Printf("\nSimulation completed at step %d\n", step)
-	fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
-	fmt.Printf("Total fish eaten: %d\n", fishEaten)
-	fmt.Printf("Total execution time: %v\n", elapsed)
-	if step > 0 {
-		fmt.Printf("Average time per step: %v\n", elapsed/time.Duration(step))
+	if err := runSimulation(cfg); err != nil {
+		fmt.Printf("Error: %v\n", err)
 	}
 }
 
-func runHeadless(world *simulation.World, cfg *config.Config) {
-	fmt.Println("Running in headless mode...")
-	startTime := time.Now()
-	totalFishEaten := 0
+// printSimulatedTime prints the time the steps stand for with -chronon-days
+func printSimulatedTime(cfg *config.Config, step int) {
+	if cal := cfg.Calendar(); cal.Enabled() {
+		fmt.Printf("Simulated time: %s, ending on %s\n", cal.Duration(step), cal.Date(step))
+	}
+}
 
-	for step := 0; step < cfg.Steps; step++ {
-		fish, sharks := world.Count()
+// printStepTiming prints the mean time the phases of a step took
+func printStepTiming(mean simulation.StepTiming, steps int) {
+	if steps > 0 {
+		fmt.Printf("Time per step: %v\n", mean)
+	}
+}
 
-		// Check termination conditions
-		if fish == 0 {
-			fmt.Printf("\nAll fish died at step %d\n", step)
-			break
+// resetFunc returns a function that builds a new world from JSON settings
+// applied on top of the configuration
+func resetFunc(cfg *config.Config) func(params []byte) (*simulation.World, error) {
+	return func(params []byte) (*simulation.World, error) {
+		c, err := cfg.WithOverrides(params)
+		if err != nil {
+			return nil, err
 		}
-		if sharks == 0 {
-			fmt.Printf("\nAll sharks died at step %d\n", step)
-			break
+		return newWorld(c)
+	}
+}
+
+// extinctionRule returns the quasi-extinction rule selected by the flags
+func extinctionRule(cfg *config.Config) analysis.ExtinctionRule {
+	return analysis.ExtinctionRule{Threshold: cfg.ExtinctBelow, Duration: cfg.ExtinctFor}
+}
+
+// equilibriumRule returns the rule -stop-on-equilibrium stops at
+func equilibriumRule(cfg *config.Config) analysis.EquilibriumRule {
+	return analysis.EquilibriumRule{Window: cfg.EqWindow, Tolerance: cfg.EqTolerance}
+}
+
+// palette returns the cell colors of -theme with the colors of the
+// configuration file applied
+func palette(cfg *config.Config) frame.Palette {
+	p, _ := cfg.Palette()
+	return p
+}
+
+// lowPower reports whether the window runs in low-power mode, which
+// -lowpower auto selects while the computer is on battery
+func lowPower(cfg *config.Config) bool {
+	switch cfg.LowPower {
+	case "on":
+		return true
+	case "auto":
+		if power.OnBattery() {
+			fmt.Println("Running on battery: low-power mode (use -lowpower off for full speed)")
+			return true
 		}
+	}
+	return false
+}
 
-		// Perform simulation step
-		fishEaten := world.Step(cfg.Threads)
-		totalFishEaten += fishEaten
+// printExtinction reports when each species became (quasi-)extinct
+func printExtinction(t *analysis.ExtinctionTracker) {
+	if t.FishExtinct >= 0 {
+		fmt.Printf("Fish extinct since step %d\n", t.FishExtinct)
 	}
+	if t.SharkExtinct >= 0 {
+		fmt.Printf("Sharks extinct since step %d\n", t.SharkExtinct)
+	}
+}
 
-	elapsed := time.Since(startTime)
-	fish, sharks := world.Count()
+// printOscillations reports the periods of both populations and how far
+// the sharks lag behind the fish
+func printOscillations(o analysis.Oscillations) {
+	if o.FishPeriod == 0 && o.SharkPeriod == 0 {
+		fmt.Println("Oscillation period: populations do not oscillate")
+		return
+	}
+	fmt.Printf("Oscillation period - Fish: %.0f steps, Sharks: %.0f steps\n", o.FishPeriod, o.SharkPeriod)
+	if o.Phase() >= 0 {
+		fmt.Printf("Sharks lag fish by %.0f steps (%.0f° of the cycle)\n", o.Lag, o.Phase())
+	}
+}
 
-	// Print final statistics
-	fmt.Printf("\nSimulation completed\n")
-	fmt.Printf("Steps completed: %d\n", cfg.Steps)
-	fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
-	fmt.Printf("Total fish eaten: %d\n", totalFishEaten)
-	fmt.Printf("Total execution time: %v\n", elapsed)
-	if cfg.Steps > 0 {
-		fmt.Printf("Average time per step: %v\n", elapsed/time.Duration(cfg.Steps))
+// printSpecies prints the predators of each species when there are several
+func printSpecies(world *simulation.World) {
+	if len(world.Species) == 0 {
+		return
+	}
+	for id, n := range world.CountSpecies() {
+		fmt.Printf("  %s: %d\n", world.SpeciesName(id), n)
 	}
 }
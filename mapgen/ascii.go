@@ -0,0 +1,103 @@
+package mapgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wa-tor/maps"
+	"wa-tor/simulation"
+)
+
+// Characters with a meaning in ASCII maps; anything else is open water
+const (
+	asciiLand = '#'
+	asciiReef = '*'
+)
+
+// ReadASCII parses a map drawn as ASCII art with one line per row of the
+// grid: '#' is land, '*' is reef and any other character is open water.
+// Short lines are padded with water to the width of the longest line.
+func ReadASCII(r io.Reader) (*simulation.Terrain, error) {
+	var rows []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rows = append(rows, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Trailing blank lines are not part of the map
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1]
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len([]rune(row)))
+	}
+	if width == 0 {
+		return nil, fmt.Errorf("map is empty")
+	}
+
+	height := len(rows)
+	t := simulation.NewOcean(width, height)
+	land := make([]bool, width*height)
+	reef := make([]bool, width*height)
+	hasReef := false
+	for y, row := range rows {
+		for x, ch := range []rune(row) {
+			switch ch {
+			case asciiLand:
+				land[y*width+x] = true
+			case asciiReef:
+				reef[y*width+x] = true
+				hasReef = true
+			}
+		}
+	}
+
+	t.Land = land
+	if hasReef {
+		t.Reef = reef
+	}
+	return t, nil
+}
+
+// LoadASCII reads an ASCII map file, see ReadASCII. A path that does not
+// exist is looked up among the bundled maps by its base name, with or
+// without the .txt extension, so "-map islands" works from any directory.
+func LoadASCII(path string) (*simulation.Terrain, error) {
+	f, err := openMap(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := ReadASCII(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// openMap opens a map file, falling back to the bundled maps
+func openMap(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	name := filepath.Base(path)
+	if filepath.Ext(name) == "" {
+		name += ".txt"
+	}
+	if bundled, berr := maps.Files.Open(name); berr == nil {
+		return bundled, nil
+	}
+	return nil, err
+}
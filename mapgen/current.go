@@ -0,0 +1,84 @@
+package mapgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"wa-tor/simulation"
+)
+
+// ReadCurrent parses an ocean current field into t. The input has one line
+// per row of the grid, each with one "east,north" pair per cell separated by
+// whitespace. Vectors longer than 1 are shortened to length 1.
+func ReadCurrent(r io.Reader, t *simulation.Terrain) error {
+	east := make([]float64, 0, t.Width*t.Height)
+	north := make([]float64, 0, t.Width*t.Height)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	rows := 0
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		rows++
+		if len(fields) != t.Width {
+			return fmt.Errorf("row %d has %d vectors, expected %d", rows, len(fields), t.Width)
+		}
+		for _, field := range fields {
+			e, n, err := parseVector(field)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", rows, err)
+			}
+			if length := math.Hypot(e, n); length > 1 {
+				e, n = e/length, n/length
+			}
+			east = append(east, e)
+			north = append(north, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if rows != t.Height {
+		return fmt.Errorf("found %d rows, expected %d", rows, t.Height)
+	}
+
+	t.CurrentEast, t.CurrentNorth = east, north
+	return nil
+}
+
+// LoadCurrent reads an ocean current file into t, see ReadCurrent
+func LoadCurrent(path string, t *simulation.Terrain) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := ReadCurrent(f, t); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// parseVector parses an "east,north" pair
+func parseVector(s string) (east, north float64, err error) {
+	es, ns, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid vector %q, expected east,north", s)
+	}
+	if east, err = strconv.ParseFloat(es, 64); err == nil {
+		north, err = strconv.ParseFloat(ns, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid vector %q, expected east,north", s)
+	}
+	return east, north, nil
+}
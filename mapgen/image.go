@@ -0,0 +1,77 @@
+package mapgen
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF decoder for ReadImage
+	_ "image/jpeg" // Register the JPEG decoder for ReadImage
+	_ "image/png"  // Register the PNG decoder for ReadImage
+	"io"
+	"os"
+
+	"wa-tor/simulation"
+)
+
+// imageDark is the brightness below which a pixel is land: no channel may
+// reach it, out of 0xffff
+const imageDark = 0x4000
+
+// ReadImage reads an initial world drawn as a PNG, GIF or JPEG image with
+// one pixel per cell: dark pixels are land and the others are what their
+// strongest channel says, green a fish, red a shark and blue open water.
+// Pixels with no strongest channel, such as white or grey, and mostly
+// transparent ones are water too. It returns the terrain and the animal of each cell
+// in row-major order, Empty for water and Barrier for land.
+func ReadImage(r io.Reader) (*simulation.Terrain, []simulation.CellType, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return nil, nil, fmt.Errorf("image is empty")
+	}
+
+	t := simulation.NewOcean(width, height)
+	t.Land = make([]bool, width*height)
+	cells := make([]simulation.CellType, width*height)
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			cells[i] = pixelType(img.At(b.Min.X+x, b.Min.Y+y).RGBA())
+			t.Land[i] = cells[i] == simulation.Barrier
+		}
+	}
+	return t, cells, nil
+}
+
+// pixelType classifies a pixel, see ReadImage
+func pixelType(r, g, b, a uint32) simulation.CellType {
+	switch {
+	case a < 0x8000:
+		return simulation.Empty
+	case max(r, g, b) < imageDark:
+		return simulation.Barrier
+	case g > r && g > b:
+		return simulation.Fish
+	case r > g && r > b:
+		return simulation.Shark
+	}
+	return simulation.Empty
+}
+
+// LoadImage reads an initial world from an image file, see ReadImage
+func LoadImage(path string) (*simulation.Terrain, []simulation.CellType, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	t, cells, err := ReadImage(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, cells, nil
+}
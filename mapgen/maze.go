@@ -0,0 +1,96 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"wa-tor/simulation"
+)
+
+// MazeOptions controls the basin-and-channel generator
+type MazeOptions struct {
+	Seed          int64
+	BasinSize     int // Spacing of basin centers in cells
+	CorridorWidth int // Width of the straits connecting basins
+}
+
+// Maze generates square basins on a regular lattice connected by narrow
+// straits. The straits form a random spanning tree over the toroidal lattice
+// (a maze), so every basin is reachable but typically only through a few
+// bottlenecks.
+func Maze(width, height int, opt MazeOptions) *simulation.Terrain {
+	rng := rand.New(rand.NewSource(opt.Seed))
+	nx := max(1, width/opt.BasinSize)
+	ny := max(1, height/opt.BasinSize)
+	cellW, cellH := width/nx, height/ny
+
+	t := simulation.NewOcean(width, height)
+	t.Land = make([]bool, width*height)
+	for i := range t.Land {
+		t.Land[i] = true
+	}
+
+	// carve opens a rectangle, wrapping around the world edges
+	carve := func(y0, x0, h, w int) {
+		for dy := range h {
+			for dx := range w {
+				y := ((y0+dy)%height + height) % height
+				x := ((x0+dx)%width + width) % width
+				t.Land[y*width+x] = false
+			}
+		}
+	}
+	center := func(bx, by int) (int, int) {
+		return by*cellH + cellH/2, bx*cellW + cellW/2
+	}
+
+	// Open the basins, leaving a land margin around each
+	marginX, marginY := max(1, cellW/4), max(1, cellH/4)
+	for by := range ny {
+		for bx := range nx {
+			carve(by*cellH+marginY, bx*cellW+marginX, cellH-2*marginY, cellW-2*marginX)
+		}
+	}
+
+	// Randomized depth-first search over the lattice picks the straits
+	visited := make([]bool, nx*ny)
+	stack := []int{rng.Intn(nx * ny)}
+	visited[stack[0]] = true
+	half := opt.CorridorWidth / 2
+
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		bx, by := cur%nx, cur/nx
+
+		type move struct{ dx, dy int }
+		var options []move
+		for _, m := range []move{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := ((by+m.dy+ny)%ny)*nx + (bx+m.dx+nx)%nx
+			if !visited[next] {
+				options = append(options, m)
+			}
+		}
+		if len(options) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		m := options[rng.Intn(len(options))]
+		cy, cx := center(bx, by)
+		switch {
+		case m.dx > 0:
+			carve(cy-half, cx, opt.CorridorWidth, cellW+1)
+		case m.dx < 0:
+			carve(cy-half, cx-cellW, opt.CorridorWidth, cellW+1)
+		case m.dy > 0:
+			carve(cy, cx-half, cellH+1, opt.CorridorWidth)
+		default:
+			carve(cy-cellH, cx-half, cellH+1, opt.CorridorWidth)
+		}
+
+		next := ((by+m.dy+ny)%ny)*nx + (bx+m.dx+nx)%nx
+		visited[next] = true
+		stack = append(stack, next)
+	}
+
+	return t
+}
@@ -0,0 +1,141 @@
+// Package mapgen procedurally generates world terrain.
+package mapgen
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+
+	"wa-tor/simulation"
+)
+
+// PerlinOptions controls the noise-based ocean generator
+type PerlinOptions struct {
+	Seed         int64
+	LandFraction float64 // Share of all cells that become land
+	ReefFraction float64 // Share of water cells, the shallowest, that become reef
+	FeatureSize  float64 // Approximate size of land masses in cells; larger is smoother
+}
+
+// Perlin generates land masses, reefs and a temperature gradient from
+// fractal gradient noise. The noise tiles seamlessly, so the terrain has no
+// visible seam across the toroidal edges.
+func Perlin(width, height int, opt PerlinOptions) *simulation.Terrain {
+	rng := rand.New(rand.NewSource(opt.Seed))
+	elevation := fractalNoise(rng, width, height, opt.FeatureSize, 4)
+	warmth := fractalNoise(rng, width, height, opt.FeatureSize*2, 2)
+
+	t := simulation.NewOcean(width, height)
+	n := width * height
+	t.Land = make([]bool, n)
+	t.Reef = make([]bool, n)
+	t.Temperature = make([]float64, n)
+
+	// Land is the highest LandFraction of cells, reefs the shallowest water
+	landLevel := quantile(elevation, 1-opt.LandFraction)
+	waterCells := n - int(opt.LandFraction*float64(n))
+	reefLevel := quantile(elevation, 1-opt.LandFraction-opt.ReefFraction*float64(waterCells)/float64(n))
+
+	lo, hi := slices.Min(warmth), slices.Max(warmth)
+	for i := range height {
+		// Warm at the equator (middle row), cold towards the poles
+		latitude := 1 - math.Abs(2*(float64(i)+0.5)/float64(height)-1)
+		for j := range width {
+			k := i*width + j
+			switch {
+			case opt.LandFraction > 0 && elevation[k] >= landLevel:
+				t.Land[k] = true
+			case opt.ReefFraction > 0 && elevation[k] >= reefLevel:
+				t.Reef[k] = true
+			}
+
+			local := 0.5
+			if hi > lo {
+				local = (warmth[k] - lo) / (hi - lo)
+			}
+			t.Temperature[k] = 0.75*latitude + 0.25*local
+		}
+	}
+
+	return t
+}
+
+// quantile returns the value below which fraction q of values fall
+func quantile(values []float64, q float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	idx := int(q * float64(len(sorted)))
+	return sorted[min(max(idx, 0), len(sorted)-1)]
+}
+
+// fractalNoise sums several octaves of tileable gradient noise sampled at
+// every cell, starting with features of roughly featureSize cells
+func fractalNoise(rng *rand.Rand, width, height int, featureSize float64, octaves int) []float64 {
+	values := make([]float64, width*height)
+	amplitude := 1.0
+
+	for o := range octaves {
+		scale := featureSize / math.Pow(2, float64(o))
+		if scale < 1 {
+			break
+		}
+		px := max(1, int(math.Round(float64(width)/scale)))
+		py := max(1, int(math.Round(float64(height)/scale)))
+		g := newGradientGrid(rng, px, py)
+
+		for i := range height {
+			for j := range width {
+				x := float64(j) * float64(px) / float64(width)
+				y := float64(i) * float64(py) / float64(height)
+				values[i*width+j] += amplitude * g.at(x, y)
+			}
+		}
+		amplitude /= 2
+	}
+
+	return values
+}
+
+// gradientGrid holds random unit gradients on a periodic lattice
+type gradientGrid struct {
+	px, py int
+	grad   [][2]float64
+}
+
+func newGradientGrid(rng *rand.Rand, px, py int) *gradientGrid {
+	g := &gradientGrid{px: px, py: py, grad: make([][2]float64, px*py)}
+	for i := range g.grad {
+		a := rng.Float64() * 2 * math.Pi
+		g.grad[i] = [2]float64{math.Cos(a), math.Sin(a)}
+	}
+	return g
+}
+
+// at evaluates Perlin noise at lattice coordinates (x, y), wrapping at the
+// lattice period
+func (g *gradientGrid) at(x, y float64) float64 {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	dot := func(ix, iy int, dx, dy float64) float64 {
+		v := g.grad[((iy%g.py+g.py)%g.py)*g.px+(ix%g.px+g.px)%g.px]
+		return v[0]*dx + v[1]*dy
+	}
+
+	n00 := dot(x0, y0, fx, fy)
+	n10 := dot(x0+1, y0, fx-1, fy)
+	n01 := dot(x0, y0+1, fx, fy-1)
+	n11 := dot(x0+1, y0+1, fx-1, fy-1)
+
+	u, v := fade(fx), fade(fy)
+	return lerp(lerp(n00, n10, u), lerp(n01, n11, u), v)
+}
+
+// fade is Perlin's quintic smoothstep 6t^5 - 15t^4 + 10t^3
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
@@ -0,0 +1,10 @@
+// Package maps holds the ASCII maps that ship with the simulation. They are
+// embedded so a release binary can load them from any directory.
+package maps
+
+import "embed"
+
+// Files holds the bundled maps by file name, such as islands.txt
+//
+//go:embed *.txt
+var Files embed.FS
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/simulation"
+)
+
+// runOcean runs the fish and sharks of the -size square in the center of a
+// chunked -ocean in headless mode, reporting how many chunks hold animals
+func runOcean(cfg *config.Config) {
+	world := simulation.NewChunkedWorld(cfg.Seed, cfg.Ocean, cfg.Ocean, cfg.FishBreed, cfg.SharkBreed, cfg.Starve)
+	world.SharkGain = cfg.SharkGain
+	world.Bounded = !cfg.Wrap
+	corner := (cfg.Ocean - cfg.GridSize) / 2
+	world.Scatter(corner, corner, cfg.GridSize, cfg.GridSize, cfg.NumFish, cfg.NumShark)
+
+	fmt.Printf("Running a %dx%d chunked ocean in headless mode...\n", cfg.Ocean, cfg.Ocean)
+	startTime := time.Now()
+	totalFishEaten := 0
+	peakChunks := world.Chunks()
+
+	extinction := analysis.NewExtinctionTracker(extinctionRule(cfg))
+	fish, sharks := world.Count()
+	extinction.Observe(0, fish, sharks)
+
+	step := 0
+	for ; cfg.Steps == 0 || step < cfg.Steps; step++ {
+		if extinction.Ended() {
+			fmt.Printf("\n%s at step %d\n", extinction.Reason(), step)
+			break
+		}
+
+		totalFishEaten += world.Step()
+		fish, sharks = world.Count()
+		extinction.Observe(step+1, fish, sharks)
+		peakChunks = max(peakChunks, world.Chunks())
+		if (step+1)%headlessProgressEvery == 0 {
+			fmt.Printf("Step %d - Fish: %d, Sharks: %d, Chunks: %d\n", step+1, fish, sharks, world.Chunks())
+		}
+	}
+	if cfg.Steps > 0 && step == cfg.Steps {
+		fmt.Printf("\nReached max steps: %d\n", step)
+	}
+
+	elapsed := time.Since(startTime)
+	fmt.Printf("\nSimulation completed\n")
+	fmt.Printf("Steps completed: %d\n", step)
+	printSimulatedTime(cfg, step)
+	fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
+	printExtinction(extinction)
+	fmt.Printf("Total fish eaten: %d\n", totalFishEaten)
+	fmt.Printf("Chunks of %dx%d cells: %d (peak %d)\n", simulation.ChunkSize, simulation.ChunkSize, world.Chunks(), peakChunks)
+	fmt.Printf("Total execution time: %v\n", elapsed)
+	if step > 0 {
+		fmt.Printf("Average time per step: %v\n", elapsed/time.Duration(step))
+	}
+}
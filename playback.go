@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"wa-tor/config"
+	"wa-tor/rendering"
+	"wa-tor/replay"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// runReplay plays back the .wtr file given with -replay in the window. If
+// no window can be opened it prints a summary of the recording instead.
+func runReplay(cfg *config.Config) error {
+	rp, err := replay.Load(cfg.Replay)
+	if err != nil {
+		return err
+	}
+	player := replay.NewPlayer(rp)
+	world := player.World
+	last := rp.Frames() - 1
+	fmt.Printf("Replay %s: %dx%d, %d frames from step %d to %d\n",
+		cfg.Replay, world.Width, world.Height, rp.Frames(), rp.Step(0), rp.Step(last))
+
+	cellSize := windowCellSize(cfg, world.Width, world.Height)
+	game := rendering.NewGame(world, cfg.Threads, cellSize, 0, cfg.UpdateFreq, extinctionRule(cfg))
+	game.SetPalette(palette(cfg))
+	if cfg.Sprites != "" {
+		if err := game.SetSprites(cfg.Sprites); err != nil {
+			return err
+		}
+	}
+	game.SetReplay(player)
+	game.SetAdaptiveSpeed(cfg.Adaptive)
+	game.SetCalendar(cfg.Calendar())
+	if len(cfg.HUD) > 0 {
+		if err := game.SetHUD(cfg.HUD); err != nil {
+			return err
+		}
+	}
+	game.SetRenderBudget(time.Duration(cfg.RenderBudget * float64(time.Millisecond)))
+	game.SetLowPower(lowPower(cfg))
+	game.SetOnScreenshot(func(step int, world *simulation.World) {
+		savePNG(cfg, step, world)
+	})
+
+	ebiten.SetWindowSize(world.Width*cellSize, world.Height*cellSize)
+	ebiten.SetWindowTitle("Wa-Tor Replay")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+
+	if err := runWindow(game); err != nil {
+		if game.Started() {
+			return err
+		}
+		fmt.Printf("\nCould not open a window: %v\n", err)
+		player.Seek(last)
+		fish, sharks := player.World.Count()
+		fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
+		for _, m := range rp.Markers {
+			fmt.Printf("Step %d: %s\n", m.Step, m.Label)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Package power reports whether the computer runs on battery, so long
+// simulations can switch to a low-power mode while it does.
+package power
+
+// OnBattery reports whether the computer is running on battery. It
+// returns false when the power source cannot be determined.
+func OnBattery() bool {
+	return onBattery()
+}
@@ -0,0 +1,15 @@
+package power
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// onBattery asks pmset which power source is drawn from
+func onBattery() bool {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(out), "'Battery Power'")
+}
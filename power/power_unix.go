@@ -0,0 +1,45 @@
+//go:build !windows && !darwin
+
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// supplyDir is where Linux lists the power supplies
+const supplyDir = "/sys/class/power_supply"
+
+// onBattery reports whether no mains supply is online and a battery is
+// discharging
+func onBattery() bool {
+	supplies, err := os.ReadDir(supplyDir)
+	if err != nil {
+		return false
+	}
+	discharging := false
+	for _, s := range supplies {
+		dir := filepath.Join(supplyDir, s.Name())
+		switch readValue(dir, "type") {
+		case "Mains":
+			if readValue(dir, "online") == "1" {
+				return false
+			}
+		case "Battery":
+			if readValue(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
+
+// readValue returns the trimmed contents of an attribute file of a supply
+func readValue(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
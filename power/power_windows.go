@@ -0,0 +1,27 @@
+package power
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus is the SYSTEM_POWER_STATUS structure of the Windows API
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// onBattery reports whether Windows says the AC line is offline
+func onBattery() bool {
+	var status systemPowerStatus
+	if r, _, _ := getSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return false
+	}
+	return status.ACLineStatus == 0
+}
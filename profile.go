@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"wa-tor/config"
+)
+
+// startProfiles starts the CPU profile of -cpuprofile and returns a
+// function that stops it and writes the heap profile of -memprofile
+func startProfiles(cfg *config.Config) (func(), error) {
+	var cpu *os.File
+	if cfg.CPUProfile != "" {
+		f, err := os.Create(cfg.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("creating CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting CPU profile: %w", err)
+		}
+		cpu = f
+	}
+
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+			fmt.Printf("CPU profile written to %s\n", cfg.CPUProfile)
+		}
+		if cfg.MemProfile != "" {
+			if err := writeMemProfile(cfg.MemProfile); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("Heap profile written to %s\n", cfg.MemProfile)
+		}
+	}, nil
+}
+
+// writeMemProfile writes the heap profile to path, after a garbage
+// collection so that it shows the memory still in use
+func writeMemProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating heap profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("writing heap profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+#!/usr/bin/env python3
+"""
+Thin ctypes wrapper around the Wa-Tor simulation core.
+
+Build the shared library first:
+    go build -buildmode=c-shared -o python/libwator.so ./capi
+"""
+
+import ctypes
+import os
+import sys
+
+import numpy as np
+
+EMPTY, FISH, SHARK, BARRIER = 0, 1, 2, 3
+
+def _load_library():
+    """Locate and load the compiled shared library next to this file."""
+    ext = {'darwin': '.dylib', 'win32': '.dll'}.get(sys.platform, '.so')
+    path = os.environ.get('WATOR_LIB',
+                          os.path.join(os.path.dirname(__file__), 'libwator' + ext))
+    lib = ctypes.CDLL(path)
+
+    lib.WatorNewWorld.argtypes = [ctypes.c_int] * 7
+    lib.WatorNewWorld.restype = ctypes.c_int
+    lib.WatorFreeWorld.argtypes = [ctypes.c_int]
+    lib.WatorFreeWorld.restype = None
+    lib.WatorStep.argtypes = [ctypes.c_int, ctypes.c_int]
+    lib.WatorStep.restype = ctypes.c_int
+    lib.WatorCount.argtypes = [ctypes.c_int,
+                               ctypes.POINTER(ctypes.c_int),
+                               ctypes.POINTER(ctypes.c_int)]
+    lib.WatorCount.restype = ctypes.c_int
+    lib.WatorGrid.argtypes = [ctypes.c_int, ctypes.c_void_p, ctypes.c_int]
+    lib.WatorGrid.restype = ctypes.c_int
+    return lib
+
+_lib = _load_library()
+
+class World:
+    """A Wa-Tor world living in the Go engine."""
+
+    def __init__(self, size=80, fish=500, sharks=100, fbreed=10, sbreed=10, starve=8):
+        self.width = self.height = size
+        self._handle = _lib.WatorNewWorld(size, size, fish, sharks, fbreed, sbreed, starve)
+        if self._handle == 0:
+            raise ValueError("invalid world parameters")
+        self._grid = np.zeros((self.height, self.width), dtype=np.uint8)
+
+    def step(self, threads=1):
+        """Advance one chronon and return the number of fish eaten."""
+        return _lib.WatorStep(self._handle, threads)
+
+    def count(self):
+        """Return (fish, sharks) populations."""
+        fish, sharks = ctypes.c_int(), ctypes.c_int()
+        _lib.WatorCount(self._handle, ctypes.byref(fish), ctypes.byref(sharks))
+        return fish.value, sharks.value
+
+    def grid(self):
+        """Return the cell types as a (height, width) uint8 array.
+
+        The array is reused between calls; copy it if you need to keep a frame.
+        """
+        _lib.WatorGrid(self._handle, self._grid.ctypes.data, self._grid.size)
+        return self._grid
+
+    def close(self):
+        """Release the world in the Go engine."""
+        if self._handle:
+            _lib.WatorFreeWorld(self._handle)
+            self._handle = 0
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def __del__(self):
+        self.close()
+
+if __name__ == '__main__':
+    with World() as w:
+        for _ in range(100):
+            w.step()
+        print("Fish: %d, Sharks: %d" % w.count())
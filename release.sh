@@ -0,0 +1,32 @@
+#!/bin/bash
+
+# Wa-Tor Release Build Script
+# Builds stripped, self-contained binaries into dist/. The dashboard and the
+# bundled maps are embedded, so each binary runs on its own.
+#
+# The window needs cgo on Linux and macOS, so those binaries are built for
+# the machine running the script; Windows binaries cross-compile from anywhere.
+
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+DIST="dist"
+mkdir -p $DIST
+
+build() {
+    local goos=$1 goarch=$2 ext=""
+    [ "$goos" = "windows" ] && ext=".exe"
+    local out="$DIST/wa-tor-$VERSION-$goos-$goarch$ext"
+    echo "Building $out..."
+    if ! GOOS=$goos GOARCH=$goarch go build -trimpath -ldflags="-s -w" -o "$out"; then
+        echo "Build failed for $goos/$goarch!"
+        exit 1
+    fi
+}
+
+build "$(go env GOHOSTOS)" "$(go env GOHOSTARCH)"
+for arch in amd64 arm64; do
+    [ "$(go env GOHOSTOS)/$(go env GOHOSTARCH)" = "windows/$arch" ] || build windows $arch
+done
+
+echo ""
+echo "Release binaries in $DIST/:"
+ls -l $DIST
@@ -0,0 +1,42 @@
+package rendering
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Colors that highlight the trigger of an automatic pause
+var (
+	ColorPauseBasin  = color.RGBA{255, 200, 0, 90}  // Translucent yellow over the basin that lost a species
+	ColorPauseBorder = color.RGBA{255, 200, 0, 255} // Yellow frame for whole-world triggers
+)
+
+// drawPauseHighlight marks what triggered an automatic pause: the basin with
+// a local extinction, or a frame around the whole world for population and
+// predation triggers
+func (g *Game) drawPauseHighlight(screen *ebiten.Image) {
+	event := g.pauseEvent
+	if event == nil {
+		return
+	}
+
+	size := float32(g.cellSize)
+	if event.Basin < 0 || g.autoPause.Basins == nil {
+		w := float32(g.world.Width) * size
+		h := float32(g.world.Height) * size
+		vector.StrokeRect(screen, 2, 2, w-4, h-4, 4, ColorPauseBorder, false)
+		return
+	}
+
+	basins := g.autoPause.Basins.Basins
+	for i := 0; i < g.world.Height; i++ {
+		for j := 0; j < g.world.Width; j++ {
+			if basins.Label[i*basins.Width+j] == event.Basin {
+				x, y := g.toScreen(i, j)
+				vector.FillRect(screen, x, y, size, size, ColorPauseBasin, false)
+			}
+		}
+	}
+}
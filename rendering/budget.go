@@ -0,0 +1,101 @@
+package rendering
+
+import (
+	"fmt"
+	"time"
+)
+
+// Degradation levels of the drawing, each dropping more detail than the one
+// before. Levels above degradeGrid double the size of the grid blocks again.
+const (
+	degradeOverlays = 1 // Skip the legend and the current arrows
+	degradeChart    = 2 // Also draw the chart at a quarter of its resolution
+	degradeGrid     = 3 // Also draw the grid in blocks of 2x2 cells
+	maxDegrade      = 5 // Blocks of 8x8 cells
+)
+
+// Frames to wait after changing the level before judging it, long enough
+// for the average cost to settle, and the longest wait before trying more
+// detail again after an attempt that proved too slow
+const (
+	budgetSettle  = 30
+	budgetMaxHold = 64 * budgetSettle
+)
+
+// renderBudget drops detail from the drawing while frames take longer to
+// draw than the budget, so a large grid does not slow the simulation down
+// with it, and brings the detail back once drawing is cheap again
+type renderBudget struct {
+	limit     time.Duration // Drawing time allowed per frame; 0 never degrades
+	cost      time.Duration // Moving average of the drawing time
+	level     int
+	wait      int  // Frames before the level may change again
+	hold      int  // Frames to wait after degrading before trying more detail
+	recovered bool // The last change added detail
+}
+
+// newRenderBudget creates a budget of limit per frame
+func newRenderBudget(limit time.Duration) *renderBudget {
+	return &renderBudget{limit: limit, wait: budgetSettle, hold: budgetSettle}
+}
+
+// observe records how long a frame took to draw and adjusts the level. A
+// level that was left for more detail and had to be returned to waits twice
+// as long before the next attempt, so the drawing does not flicker between
+// two levels.
+func (b *renderBudget) observe(d time.Duration) {
+	if b.limit <= 0 {
+		return
+	}
+	b.cost += (d - b.cost) / 8
+	if b.wait > 0 {
+		b.wait--
+		return
+	}
+
+	switch {
+	case b.cost > b.limit && b.level < maxDegrade:
+		if b.recovered {
+			b.hold = min(2*b.hold, budgetMaxHold)
+		}
+		b.level++
+		b.recovered = false
+		b.wait = b.hold
+	case b.cost < b.limit/2 && b.level > 0:
+		b.level--
+		b.recovered = true
+		b.wait = budgetSettle
+	}
+}
+
+// gridBlock returns the number of cells along each side of the blocks the
+// grid is drawn in
+func (b *renderBudget) gridBlock() int {
+	if b.level < degradeGrid {
+		return 1
+	}
+	return 1 << (b.level - degradeGrid + 1)
+}
+
+// chartStride returns how many chart points are drawn as one
+func (b *renderBudget) chartStride() int {
+	if b.level < degradeChart {
+		return 1
+	}
+	return 4
+}
+
+// status describes the detail dropped for the HUD, or "" at full detail
+func (b *renderBudget) status() string {
+	if b.level == 0 {
+		return ""
+	}
+	dropped := "no overlays"
+	if b.level >= degradeChart {
+		dropped += ", coarse chart"
+	}
+	if n := b.gridBlock(); n > 1 {
+		dropped += fmt.Sprintf(", %dx%d grid blocks", n, n)
+	}
+	return fmt.Sprintf("Reduced detail to draw within %v: %s", b.limit, dropped)
+}
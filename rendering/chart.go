@@ -0,0 +1,216 @@
+package rendering
+
+import (
+	"image/color"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	chartHistory  = 300 // Chronons of history shown and used for the forecast fit
+	chartForecast = 200 // Chronons forecast ahead of the current step
+	chartHeight   = 120 // Height of the chart in pixels, at most a third of the screen
+)
+
+// Fish deaths by cause, indexed like analysis.DeathCauses. Starving
+// predators are drawn in the color of their species.
+var chartFishDeaths = [3]color.RGBA{{220, 60, 60, 255}, {230, 170, 40, 255}, {150, 150, 150, 255}}
+
+// populationChart plots recent populations along the bottom of the screen
+// and a dotted Lotka-Volterra forecast of where they are heading
+type populationChart struct {
+	visible        bool
+	limit          float64 // Upper bound for forecast populations
+	step           int     // Step of the latest observation
+	timeline       *analysis.Timeline
+	fish, sharks   []float64
+	forecastFish   []float64
+	forecastSharks []float64
+	meanField      *analysis.MeanFieldComparison // Model drawn alongside the history, if any
+	modelFish      []float64
+	modelSharks    []float64
+	deaths         [][]analysis.Deaths // Deaths of each step by population and cause
+}
+
+// newPopulationChart creates a chart for a world with the given number of cells
+func newPopulationChart(cells int) *populationChart {
+	return &populationChart{limit: float64(cells)}
+}
+
+// toggle shows or hides the chart
+func (c *populationChart) toggle() {
+	c.visible = !c.visible
+}
+
+// observe appends the populations of a step and refits the forecast
+func (c *populationChart) observe(step, fish, sharks int) {
+	c.step = step
+	c.fish = appendWindow(c.fish, float64(fish))
+	c.sharks = appendWindow(c.sharks, float64(sharks))
+	if c.meanField != nil {
+		modelFish, modelSharks := c.meanField.Observe(fish, sharks)
+		c.modelFish = appendWindow(c.modelFish, modelFish)
+		c.modelSharks = appendWindow(c.modelSharks, modelSharks)
+	}
+
+	// Fit only the history since the latest marker, which may have changed
+	// the dynamics
+	now := len(c.fish) - 1
+	from := 0
+	if c.timeline != nil {
+		for _, m := range c.timeline.Between(c.step-now, c.step) {
+			from = max(from, now-(c.step-m.Step))
+		}
+	}
+
+	c.forecastFish, c.forecastSharks = nil, nil
+	if model, ok := analysis.FitLotkaVolterra(c.fish[from:], c.sharks[from:]); ok {
+		c.forecastFish, c.forecastSharks = model.Forecast(c.fish[now], c.sharks[now], chartForecast, c.limit)
+	}
+}
+
+// observeDeaths appends the deaths of the latest step, shown stacked along
+// the top of the chart
+func (c *populationChart) observeDeaths(deaths []analysis.Deaths) {
+	c.deaths = append(c.deaths, deaths)
+	if len(c.deaths) > chartHistory {
+		c.deaths = append(c.deaths[:0], c.deaths[len(c.deaths)-chartHistory:]...)
+	}
+}
+
+// compareMeanField starts drawing the mean-field model from the world's
+// current state alongside the history
+func (c *populationChart) compareMeanField(w *simulation.World) {
+	c.meanField = analysis.NewMeanFieldComparison(w)
+	fish, sharks := c.meanField.Model.Populations()
+	c.modelFish, c.modelSharks = []float64{fish}, []float64{sharks}
+}
+
+// appendWindow appends v and drops values older than chartHistory
+func appendWindow(values []float64, v float64) []float64 {
+	values = append(values, v)
+	if len(values) > chartHistory {
+		values = append(values[:0], values[len(values)-chartHistory:]...)
+	}
+	return values
+}
+
+// draw renders the chart across the bottom of screen in the colors of
+// theme, joining every stride-th point only when stride is above 1
+func (c *populationChart) draw(screen *ebiten.Image, colors *colorRegistry, theme Theme, stride int) {
+	if !c.visible || len(c.fish) == 0 {
+		return
+	}
+
+	bounds := screen.Bounds()
+	width := float32(bounds.Dx())
+	height := float32(min(chartHeight, bounds.Dy()/3))
+	top := float32(bounds.Dy()) - height
+	vector.FillRect(screen, 0, top, width, height, theme.Background, false)
+
+	peak := 1.0
+	for _, series := range [][]float64{c.fish, c.sharks, c.forecastFish, c.forecastSharks, c.modelFish, c.modelSharks} {
+		for _, v := range series {
+			peak = max(peak, v)
+		}
+	}
+
+	dx := width / float32(chartHistory+chartForecast)
+	point := func(i int, v float64) (float32, float32) {
+		return float32(i) * dx, top + height - 2 - float32(v/peak)*(height-4)
+	}
+
+	// Vertical lines at timeline markers within the history
+	now := len(c.fish) - 1
+	if c.timeline != nil {
+		for _, m := range c.timeline.Between(c.step-now, c.step) {
+			x, _ := point(now-(c.step-m.Step), 0)
+			vector.StrokeLine(screen, x, top, x, top+height, 1, theme.Marker, false)
+		}
+	}
+
+	fishColor, sharkColor := colors.palette.Fish, colors.speciesColor(0)
+
+	// Solid history lines
+	for _, s := range []struct {
+		values []float64
+		color  color.Color
+	}{{c.fish, fishColor}, {c.sharks, sharkColor}} {
+		for i := stride; i < len(s.values); i += stride {
+			x0, y0 := point(i-stride, s.values[i-stride])
+			x1, y1 := point(i, s.values[i])
+			vector.StrokeLine(screen, x0, y0, x1, y1, 1, s.color, false)
+		}
+	}
+
+	// Dashed mean-field model lines, aligned with the end of the history
+	offset := len(c.fish) - len(c.modelFish)
+	for _, s := range []struct {
+		values []float64
+		color  color.Color
+	}{{c.modelFish, fishColor}, {c.modelSharks, sharkColor}} {
+		for i := stride; i < len(s.values); i += stride {
+			if (i/stride)%6 >= 3 {
+				continue
+			}
+			x0, y0 := point(offset+i-stride, s.values[i-stride])
+			x1, y1 := point(offset+i, s.values[i])
+			vector.StrokeLine(screen, x0, y0, x1, y1, 1, s.color, false)
+		}
+	}
+
+	c.drawDeaths(screen, colors, top, height/3, dx, stride)
+
+	// The current step, followed by dotted forecast lines
+	nowX, _ := point(now, 0)
+	vector.StrokeLine(screen, nowX, top, nowX, top+height, 1, theme.Now, false)
+	for _, s := range []struct {
+		values []float64
+		color  color.Color
+	}{{c.forecastFish, fishColor}, {c.forecastSharks, sharkColor}} {
+		for i := 0; i < len(s.values); i += 4 * stride {
+			x, y := point(now+1+i, s.values[i])
+			vector.FillRect(screen, x-1, y-1, 2, 2, s.color, false)
+		}
+	}
+}
+
+// drawDeaths draws a column per step in a strip of the given height at the
+// top of the chart, stacking fish deaths by cause and then the predators
+// starved by species, scaled to the most deaths in a step. Only every
+// stride-th step is drawn when stride is above 1.
+func (c *populationChart) drawDeaths(screen *ebiten.Image, colors *colorRegistry, top, height, dx float32, stride int) {
+	peak := 1
+	for _, deaths := range c.deaths {
+		total := 0
+		for _, d := range deaths {
+			total += d.Total()
+		}
+		peak = max(peak, total)
+	}
+
+	offset := len(c.fish) - len(c.deaths)
+	scale := height / float32(peak)
+	for i := 0; i < len(c.deaths); i += stride {
+		x := float32(offset+i) * dx
+		y := top
+		for pop, d := range c.deaths[i] {
+			for cause, n := range d.Counts {
+				if n == 0 {
+					continue
+				}
+				col := chartFishDeaths[cause]
+				if pop > 0 {
+					col = colors.speciesColor(pop - 1)
+				}
+				h := float32(n) * scale
+				vector.FillRect(screen, x, y, max(1, dx*float32(stride)), h, col, false)
+				y += h
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package rendering
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+
+	"wa-tor/frame"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Size of the legend swatches and lines in pixels; the debug font is 6
+// pixels wide and 16 high
+const (
+	legendSwatch = 10
+	legendLine   = 16
+	legendChar   = 6
+)
+
+// Colors of the classic palette, which cells used to be drawn in
+//
+// Deprecated: cells are drawn in the colors of the game's palette, see
+// frame.Theme; use frame.DefaultPalette for the classic colors.
+var (
+	ColorEmpty   = frame.DefaultPalette.Empty
+	ColorFish    = frame.DefaultPalette.Fish
+	ColorShark   = frame.DefaultPalette.Shark
+	ColorBarrier = frame.DefaultPalette.Barrier
+	ColorReef    = frame.DefaultPalette.Reef
+	ColorAlgae   = frame.DefaultPalette.Algae
+)
+
+// legendEntry is one line of the legend: a color and what it stands for
+type legendEntry struct {
+	label string
+	color color.RGBA
+}
+
+// colorRegistry holds the colors cells are drawn in. Predators are keyed by
+// species ID, 0 being the sharks, so every species configured for the world
+// gets its own color and legend line.
+type colorRegistry struct {
+	palette frame.Palette
+	species map[int]legendEntry
+	mode    colorMode
+	oldest  [2]int // Age of the oldest fish and predator, for colorByAge
+}
+
+// colorMode is what animals are colored by, cycled with cycleColors
+type colorMode int
+
+const (
+	colorByKind  colorMode = iota // Fish and each predator species in their own color
+	colorByState                  // Shaded by breeding timer or energy
+	colorByAge                    // Shaded by how long they have lived
+)
+
+// newColorRegistry creates a registry from a palette and the predator
+// species of a world
+func newColorRegistry(p frame.Palette, w *simulation.World) *colorRegistry {
+	r := &colorRegistry{palette: p, species: map[int]legendEntry{}}
+	r.register(0, w.SpeciesName(0), p.Shark)
+	for i, s := range w.Species {
+		r.register(i+1, s.Name, s.Color)
+	}
+	return r
+}
+
+// register sets the color and legend label of a predator species
+func (r *colorRegistry) register(id int, label string, c color.RGBA) {
+	r.species[id] = legendEntry{label: label, color: c}
+}
+
+// speciesColor returns the color of a predator species, or that of the
+// sharks for an unknown species
+func (r *colorRegistry) speciesColor(id int) color.RGBA {
+	if e, ok := r.species[id]; ok {
+		return e.color
+	}
+	return r.palette.Shark
+}
+
+// cellColor returns the color of the cell at (y, x), and false for open
+// water, which is the background
+func (r *colorRegistry) cellColor(w *simulation.World, y, x int) (color.RGBA, bool) {
+	cell := w.Cell(y, x)
+	switch {
+	case cell.Type == simulation.Fish && r.mode == colorByAge:
+		return r.ageShade(r.palette.Fish, cell.Age, r.oldest[0]), true
+	case cell.Type == simulation.Shark && r.mode == colorByAge:
+		return r.ageShade(r.speciesColor(cell.Species), cell.Age, r.oldest[1]), true
+	case cell.Type == simulation.Fish && r.mode == colorByState:
+		breed, _ := w.Traits(cell)
+		return shade(r.palette.Fish).at(float64(cell.BreedTime) / float64(breed)), true
+	case cell.Type == simulation.Fish:
+		return r.palette.Fish, true
+	case cell.Type == simulation.Shark && r.mode == colorByState:
+		_, starve := w.Traits(cell)
+		return shade(r.speciesColor(cell.Species)).at(float64(cell.Energy) / float64(starve)), true
+	case cell.Type == simulation.Shark:
+		return r.speciesColor(cell.Species), true
+	case cell.Type == simulation.Barrier:
+		return r.palette.Barrier, true
+	case cell.Algae:
+		return r.palette.Algae, true
+	case w.Terrain.IsReef(y, x):
+		return r.palette.Reef, true
+	}
+	return r.palette.Empty, false
+}
+
+// ageShade returns c for a newborn animal, darkening with age down to the
+// dark end of its shade for the oldest
+func (r *colorRegistry) ageShade(c color.RGBA, age, oldest int) color.RGBA {
+	return shade(c).at(1 - float64(age)/float64(max(oldest, 1)))
+}
+
+// observeAges records the age of the oldest fish and predator in the world,
+// or the maximum fish age if fish die of old age, before drawing by age
+func (r *colorRegistry) observeAges(w *simulation.World) {
+	r.oldest = [2]int{w.FishAge, 0}
+	for _, cell := range w.Grid {
+		switch {
+		case cell.Type == simulation.Fish && w.FishAge == 0:
+			r.oldest[0] = max(r.oldest[0], cell.Age)
+		case cell.Type == simulation.Shark:
+			r.oldest[1] = max(r.oldest[1], cell.Age)
+		}
+	}
+}
+
+// legend returns a line for every kind of cell the world can show: fish,
+// each predator species, and algae, reef and land if the world has them
+func (r *colorRegistry) legend(w *simulation.World) []legendEntry {
+	entries := []legendEntry{{label: "Fish", color: r.palette.Fish}}
+	switch r.mode {
+	case colorByState:
+		entries[0].label += " (bright: ready to breed)"
+	case colorByAge:
+		entries[0].label += fmt.Sprintf(" (bright: newborn, dark: %d steps old)", r.oldest[0])
+	}
+	ids := make([]int, 0, len(r.species))
+	for id := range r.species {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		e := r.species[id]
+		switch r.mode {
+		case colorByState:
+			e.label += " (bright: well fed)"
+		case colorByAge:
+			e.label += fmt.Sprintf(" (bright: newborn, dark: %d steps old)", r.oldest[1])
+		}
+		entries = append(entries, e)
+	}
+
+	if w.FishStarve > 0 {
+		entries = append(entries, legendEntry{label: "Algae", color: r.palette.Algae})
+	}
+	if t := w.Terrain; t != nil {
+		if t.Reef != nil {
+			entries = append(entries, legendEntry{label: "Reef", color: r.palette.Reef})
+		}
+		if t.WaterCells() < t.Width*t.Height {
+			entries = append(entries, legendEntry{label: "Land", color: r.palette.Barrier})
+		}
+	}
+	return entries
+}
+
+// cycleColors switches from coloring animals by kind to shading them by
+// state: fish from dark to bright as their breeding timer runs out, and
+// predators from bright to dark as their energy runs out; then to shading
+// them from bright when newborn to dark when oldest; then back
+func (g *Game) cycleColors() {
+	g.colors.mode = (g.colors.mode + 1) % (colorByAge + 1)
+}
+
+// drawLegend draws the legend in the top right corner of the screen
+func (g *Game) drawLegend(screen *ebiten.Image) {
+	entries := g.colors.legend(g.world)
+	width := 0
+	for _, e := range entries {
+		width = max(width, len(e.label))
+	}
+	width = legendSwatch + 4 + width*legendChar
+
+	left := screen.Bounds().Dx() - width - 4
+	vector.FillRect(screen, float32(left-4), 0, float32(width+8), float32(len(entries)*legendLine+4), g.theme.Background, false)
+	for i, e := range entries {
+		y := i*legendLine + 2
+		vector.FillRect(screen, float32(left), float32(y+3), legendSwatch, legendSwatch, e.color, false)
+		g.printAt(screen, e.label, left+legendSwatch+4, y)
+	}
+}
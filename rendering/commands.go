@@ -0,0 +1,242 @@
+package rendering
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// paletteRows is the number of matching commands the command palette lists
+const paletteRows = 12
+
+// command is an action of the command palette, with the keys that also
+// run it, if any
+type command struct {
+	name   string
+	keys   string
+	action func()
+}
+
+// commandPalette lists the runtime actions by name, so they can be found
+// and run without knowing their keys. While it is open, typing filters
+// the list, UP/DOWN select, ENTER runs and ESCAPE closes it.
+type commandPalette struct {
+	open     bool
+	query    string
+	selected int
+	matches  []command // Commands matching query, best first
+}
+
+// bindCommandKeys binds Ctrl+P to open the command palette; ':' opens it
+// too, see updateCommands
+func (g *Game) bindCommandKeys() {
+	g.keys.bindWith(modCtrl, ebiten.KeyP, g.openCommands)
+}
+
+// commands returns the actions that apply to the current run
+func (g *Game) commands() []command {
+	cmds := []command{
+		{"Pause or resume", "SPACE", g.togglePause},
+		{"Save PNG screenshot", "P", g.takeScreenshot},
+		{"Export PNG...", "E", g.exportPNG},
+		{"Save snapshot...", "S", g.saveSnapshot},
+		{"Open snapshot...", "O", g.openSnapshot},
+		{"Copy stats", "Ctrl+C", func() { g.copyStats(false) }},
+		{"Copy stats with image", "Ctrl+Shift+C", func() { g.copyStats(true) }},
+		{"Toggle population chart", "G", func() { g.chart.toggle() }},
+		{"Cycle colors", "C", g.cycleColors},
+		{"Faster", "+", g.faster},
+		{"Slower", "-", g.slower},
+		{"Toggle parameter panel", "TAB", func() { g.panel.open = !g.panel.open }},
+		{"Switch engine", "T", g.switchEngine},
+	}
+	if g.paused {
+		cmds = append(cmds, command{"Step once", "RIGHT", g.singleStep})
+	}
+	for i, p := range panelParameters {
+		cmds = append(cmds, command{"Set " + strings.ToLower(p.label), "", func() {
+			g.panel.open, g.panel.selected = true, i
+		}})
+	}
+	if g.world.Predation() != nil {
+		cmds = append(cmds, command{"Toggle predation heatmap", "H", g.toggleHeatmap})
+	}
+	if g.world.Terrain != nil && g.world.Terrain.CurrentEast != nil {
+		cmds = append(cmds, command{"Toggle ocean currents", "A", g.toggleCurrents})
+	}
+	if g.dump != nil {
+		cmds = append(cmds, command{"Save recent steps as GIF", "D", g.dumpRecent})
+	}
+	if g.themeSwitch != nil {
+		cmds = append(cmds, command{"Switch light/dark theme", "L", g.toggleTheme})
+	}
+	if g.reset != nil && g.seed != 0 {
+		cmds = append(cmds,
+			command{"Restart", "R", func() { g.restart(g.seed) }},
+			command{"Restart with a new seed", "Shift+R", func() { g.restart(rand.Int63()) }})
+	}
+	if !g.world.Bounded {
+		cmds = append(cmds, command{"Jump to the top left cell", "0", g.resetView})
+		if g.world.Predation() != nil {
+			cmds = append(cmds, command{"Jump to where most fish are eaten", "", g.jumpToPredation})
+		}
+	}
+	return cmds
+}
+
+// openCommands opens the command palette with an empty query
+func (g *Game) openCommands() {
+	g.palette = commandPalette{open: true}
+	g.filterCommands()
+}
+
+// updateCommands handles the keyboard for the command palette and reports
+// whether it is open, in which case the hotkeys must not see the keys
+func (g *Game) updateCommands() bool {
+	chars := ebiten.AppendInputChars(nil)
+	if !g.palette.open {
+		if slices.Contains(chars, ':') {
+			g.openCommands()
+			return true
+		}
+		return false
+	}
+
+	p := &g.palette
+	query := p.query
+	for _, r := range chars {
+		if unicode.IsPrint(r) {
+			query += string(r)
+		}
+	}
+	if r := []rune(query); inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(r) > 0 {
+		query = string(r[:len(r)-1])
+	}
+	if query != p.query {
+		p.query = query
+		g.filterCommands()
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		p.open = false
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) && p.selected > 0:
+		p.selected--
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) && p.selected < min(len(p.matches), paletteRows)-1:
+		p.selected++
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		p.open = false
+		if p.selected < len(p.matches) {
+			p.matches[p.selected].action()
+		}
+	}
+	return true
+}
+
+// filterCommands lists the commands matching the query, best first
+func (g *Game) filterCommands() {
+	p := &g.palette
+	type match struct {
+		command
+		score int
+	}
+	var found []match
+	for _, c := range g.commands() {
+		if score, ok := fuzzyScore(p.query, c.name); ok {
+			found = append(found, match{c, score})
+		}
+	}
+	slices.SortStableFunc(found, func(a, b match) int { return b.score - a.score })
+
+	p.matches = p.matches[:0]
+	for _, m := range found {
+		p.matches = append(p.matches, m.command)
+	}
+	p.selected = 0
+}
+
+// fuzzyScore reports whether the letters of query appear in name in order,
+// ignoring case, and scores the match: letters that follow each other or
+// start a word count more
+func fuzzyScore(query, name string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(strings.ToLower(name))
+	score, qi, last := 0, 0, -2
+	for i, r := range n {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 2
+		}
+		if i == 0 || n[i-1] == ' ' {
+			score += 3
+		}
+		last = i
+		qi++
+	}
+	return score, qi == len(q)
+}
+
+// jumpToPredation scrolls the torus so the cell where most fish were
+// eaten during the heatmap window is in the middle of the window
+func (g *Game) jumpToPredation() {
+	counts := g.world.Predation()
+	most := 0
+	for i, n := range counts {
+		if n > counts[most] {
+			most = i
+		}
+	}
+	if len(counts) == 0 || counts[most] == 0 {
+		fmt.Println("No fish eaten yet")
+		return
+	}
+	g.view.y = wrap(most/g.world.Width-g.world.Height/2, g.world.Height)
+	g.view.x = wrap(most%g.world.Width-g.world.Width/2, g.world.Width)
+}
+
+// drawCommands draws the open command palette at the top of the screen
+func (g *Game) drawCommands(screen *ebiten.Image) {
+	p := &g.palette
+	if !p.open {
+		return
+	}
+	lines := []string{"> " + p.query + "_"}
+	for i, c := range p.matches[:min(len(p.matches), paletteRows)] {
+		marker := "  "
+		if i == p.selected {
+			marker = "> "
+		}
+		line := marker + c.name
+		if c.keys != "" {
+			line += " (" + c.keys + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(p.matches) == 0 {
+		lines = append(lines, "  No matching command")
+	}
+	lines = append(lines, "ENTER run, ESC close")
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line)*legendChar)
+	}
+	left := (screen.Bounds().Dx() - width) / 2
+	top := 24
+	vector.FillRect(screen, float32(left-4), float32(top), float32(width+8), float32(len(lines)*legendLine+4), g.theme.Background, false)
+	for i, line := range lines {
+		g.printAt(screen, line, left, top+i*legendLine+2)
+	}
+}
@@ -0,0 +1,51 @@
+package rendering
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// currentArrowSpacing is the minimum distance between current arrows in pixels
+const currentArrowSpacing = 24
+
+// toggleCurrents shows or hides the ocean current arrows
+func (g *Game) toggleCurrents() {
+	g.showCurrents = !g.showCurrents
+}
+
+// drawCurrents draws an arrow showing the ocean current every few cells.
+// An arrow of full current strength spans most of the distance to the next.
+func (g *Game) drawCurrents(screen *ebiten.Image) {
+	if !g.showCurrents || g.world.Terrain == nil || g.world.Terrain.CurrentEast == nil {
+		return
+	}
+
+	every := max(1, (currentArrowSpacing+g.cellSize-1)/g.cellSize)
+	half := float64(every*g.cellSize) * 0.4
+	for y := every / 2; y < g.world.Height; y += every {
+		for x := every / 2; x < g.world.Width; x += every {
+			east, north := g.world.Terrain.CurrentAt(y, x)
+			length := math.Hypot(east, north)
+			if length == 0 {
+				continue
+			}
+
+			left, top := g.toScreen(y, x)
+			cx := float64(left) + 0.5*float64(g.cellSize)
+			cy := float64(top) + 0.5*float64(g.cellSize)
+			dx, dy := east*half, -north*half // Screen y grows southwards
+			tipX, tipY := cx+dx, cy+dy
+			vector.StrokeLine(screen, float32(cx-dx), float32(cy-dy), float32(tipX), float32(tipY), 1, g.theme.Current, true)
+
+			// Arrow head
+			angle := math.Atan2(-dy, -dx)
+			for _, side := range []float64{-0.5, 0.5} {
+				hx := tipX + 5*math.Cos(angle+side)
+				hy := tipY + 5*math.Sin(angle+side)
+				vector.StrokeLine(screen, float32(tipX), float32(tipY), float32(hx), float32(hy), 1, g.theme.Current, true)
+			}
+		}
+	}
+}
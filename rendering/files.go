@@ -0,0 +1,240 @@
+package rendering
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io/fs"
+	"math/rand"
+	"os"
+
+	"wa-tor/analysis"
+	"wa-tor/clipboard"
+	"wa-tor/dialog"
+	"wa-tor/frame"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	snapshotFilter = dialog.Filter{Name: "Wa-Tor snapshots", Patterns: []string{"*.json"}}
+	pngFilter      = dialog.Filter{Name: "PNG images", Patterns: []string{"*.png"}}
+)
+
+// saveSnapshot asks where to save the current state as a snapshot
+func (g *Game) saveSnapshot() {
+	snap := g.world.Snapshot(g.step)
+	g.showDialog(func() (string, error) {
+		return dialog.SaveFile("Save snapshot", fmt.Sprintf("snapshot-%06d.json", snap.Step), snapshotFilter)
+	}, func(path string) error {
+		if err := snap.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("Saved snapshot at step %d to %s\n", snap.Step, path)
+		return nil
+	})
+}
+
+// exportPNG asks where to save an image of the current grid
+func (g *Game) exportPNG() {
+	step := g.step
+	img := frame.Image(g.world, g.colors.palette, g.cellSize)
+	g.showDialog(func() (string, error) {
+		return dialog.SaveFile("Export image", fmt.Sprintf("frame-%06d.png", step), pngFilter)
+	}, func(path string) error {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Saved image at step %d to %s\n", step, path)
+		return nil
+	})
+}
+
+// openSnapshot asks for a snapshot and continues the simulation from it
+func (g *Game) openSnapshot() {
+	g.showDialog(func() (string, error) {
+		return dialog.OpenFile("Open snapshot", snapshotFilter)
+	}, func(path string) error {
+		snap, err := simulation.LoadSnapshot(path)
+		if err != nil {
+			return err
+		}
+		g.pending <- func() { g.loadSnapshot(snap, path) }
+		return nil
+	})
+}
+
+// loadSnapshot continues the simulation from a snapshot of the same size
+func (g *Game) loadSnapshot(snap *simulation.Snapshot, name string) {
+	world := snap.World(rand.Int63())
+	if err := g.replaceWorld(world, snap.Step); err != nil {
+		fmt.Printf("Error: %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("Loaded snapshot at step %d from %s\n", snap.Step, name)
+}
+
+// handleDrop loads a snapshot or configuration file dropped on the window.
+// Snapshots continue where they were saved; configuration files start a new
+// world through the reset function, like POST /reset in server mode.
+func (g *Game) handleDrop() {
+	files := ebiten.DroppedFiles()
+	if files == nil {
+		return
+	}
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	name := entries[0].Name()
+	data, err := fs.ReadFile(files, name)
+	if err != nil {
+		fmt.Printf("Error: %s: %v\n", name, err)
+		return
+	}
+
+	// Snapshots are the only JSON files with a "cells" key
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		fmt.Printf("Error: %s: %v\n", name, err)
+		return
+	}
+	if _, ok := keys["cells"]; ok {
+		snap, err := simulation.ParseSnapshot(data)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", name, err)
+			return
+		}
+		g.loadSnapshot(snap, name)
+		return
+	}
+
+	if g.reset == nil {
+		fmt.Printf("Error: %s: loading configuration files is not supported\n", name)
+		return
+	}
+	world, err := g.reset(data)
+	if err == nil {
+		err = g.replaceWorld(world, 0)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("Started a new world from %s\n", name)
+}
+
+// replaceWorld continues with another world of the same size, which the
+// per-step hooks rely on
+func (g *Game) replaceWorld(world *simulation.World, step int) error {
+	if world.Width != g.world.Width || world.Height != g.world.Height {
+		return fmt.Errorf("grid is %dx%d but the world is %dx%d",
+			world.Width, world.Height, g.world.Width, g.world.Height)
+	}
+	g.setWorld(world, step)
+	return nil
+}
+
+// copyStats copies the statistics block to the clipboard, followed by the
+// grid as a base64 PNG data URI if withImage is set
+func (g *Game) copyStats(withImage bool) {
+	text := g.statsText()
+	if withImage {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, frame.Image(g.world, g.colors.palette, g.cellSize)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		text += "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()) + "\n"
+	}
+
+	if err := clipboard.WriteText(text); err != nil {
+		fmt.Printf("Error copying to clipboard: %v\n", err)
+		return
+	}
+	fmt.Printf("Copied statistics at step %d to the clipboard\n", g.step)
+}
+
+// showDialog runs a file dialog without blocking the game loop and passes
+// the chosen path to done. Only one dialog is shown at a time.
+func (g *Game) showDialog(ask func() (string, error), done func(path string) error) {
+	if g.dialogOpen {
+		return
+	}
+	g.dialogOpen = true
+
+	go func() {
+		defer func() { g.pending <- func() { g.dialogOpen = false } }()
+
+		path, err := ask()
+		if err == nil {
+			err = done(path)
+		}
+		if err != nil && !errors.Is(err, dialog.ErrCancelled) {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}()
+}
+
+// runPending runs the actions queued by dialogs on the game loop
+func (g *Game) runPending() {
+	for {
+		select {
+		case fn := <-g.pending:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// setWorld continues the simulation from another world at the given step
+func (g *Game) setWorld(world *simulation.World, step int) {
+	world.TrackPredation(g.world.PredationWindow())
+	g.world.RemoveObserver(g)
+	world.AddObserver(g)
+	g.world = world
+	colors := newColorRegistry(g.colors.palette, world)
+	colors.mode = g.colors.mode
+	g.colors = colors
+	g.step = step
+	g.fishEaten = 0
+	g.counter = 0
+	g.painting = false
+	g.extinction = analysis.NewExtinctionTracker(g.extinction.Rule)
+	if g.equilibrium != nil {
+		g.equilibrium = analysis.NewEquilibriumTracker(g.equilibrium.Rule)
+	}
+
+	chart := newPopulationChart(world.Width * world.Height)
+	chart.visible, chart.timeline = g.chart.visible, g.chart.timeline
+	meanField := g.chart.meanField != nil
+	g.chart = chart
+	g.pauseEvent = nil
+	if g.autoPause != nil {
+		g.autoPause.Reset(world)
+	}
+
+	fish, sharks := world.Count()
+	g.extinction.Observe(step, fish, sharks)
+	if g.equilibrium != nil {
+		g.equilibrium.Observe(step, fish, sharks)
+	}
+	g.chart.observe(step, fish, sharks)
+	g.adjustSpeed(fish, sharks)
+	if meanField {
+		g.chart.compareMeanField(world)
+	}
+}
@@ -2,53 +2,231 @@ package rendering
 
 import (
 	"fmt"
-	"image/color"
 	"time"
 
+	"wa-tor/analysis"
+	"wa-tor/frame"
+	"wa-tor/replay"
 	"wa-tor/simulation"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
-// Colors for rendering
-var (
-	ColorEmpty = color.RGBA{0, 0, 50, 255}  // Dark blue for empty cells
-	ColorFish  = color.RGBA{0, 255, 0, 255} // Green for fish
-	ColorShark = color.RGBA{255, 0, 0, 255} // Red for sharks
-)
-
-// Game implements ebiten.Game interface
+// Game implements ebiten.Game interface. It observes the world it shows
+// to follow up every step.
 type Game struct {
-	world      *simulation.World
-	threads    int
-	cellSize   int
-	step       int
-	maxSteps   int
-	updateFreq int
-	counter    int
-	paused     bool
-	ended      bool
-	endReason  string
-	fishEaten  int
-	startTime  time.Time
+	simulation.NopObserver
+	world        *simulation.World
+	threads      int
+	engine       simulation.Engine // Engine steps are taken with, see switchEngine
+	gpu          gpuStepper        // Graphics card of the gpu engine
+	cellSize     int
+	step         int
+	maxSteps     int
+	updateFreq   int
+	adaptive     bool // Adjust the frames between steps to the populations
+	frames       int  // Frames between steps
+	burst        int  // Steps taken each time, see faster
+	counter      int
+	started      bool
+	paused       bool
+	ended        bool
+	endReason    string
+	fishEaten    int
+	timing       simulation.StepTiming // Phases of the steps taken, in total, see StepTiming
+	timed        int                   // Steps taken, for timing
+	startTime    time.Time
+	painting     bool
+	paintType    simulation.CellType
+	afterStep    func(step int, world *simulation.World)
+	screenshot   func(step int, world *simulation.World)
+	dump         func()
+	keys         hotkeys
+	extinction   *analysis.ExtinctionTracker
+	equilibrium  *analysis.EquilibriumTracker // Ends the run once populations are stable, if set
+	interrupt    <-chan struct{}              // Closed to end the run, see SetInterrupt
+	chart        *populationChart
+	colors       *colorRegistry
+	autoPause    *analysis.AutoPause
+	pauseEvent   *analysis.PauseEvent // Trigger that paused the simulation, until resumed
+	showCurrents bool
+	showHeatmap  bool
+	reset        func(params []byte) (*simulation.World, error)
+	dialogOpen   bool           // A file dialog is being shown
+	pending      chan func()    // Actions from file dialogs to run on the game loop
+	replay       *replay.Player // Recorded run shown instead of the simulation, if any
+	budget       *renderBudget
+	hud          []hudLine // Metrics shown in the HUD (nil=all built-in ones)
+	calendar     analysis.Calendar
+	sprites      spriteSet     // Images animals are drawn with, if any
+	view         view          // Scroll of the torus, see handlePan
+	panel        settingsPanel // Parameters TAB edits, see bindPanelKeys
+	seed         int64         // Seed R restarts with (0=restarting is disabled)
+	lowPower     bool          // Run at a lower tick rate and skip unchanged frames
+	redraw       redrawState   // Last frame drawn in low-power mode
+	theme        Theme         // Colors of the text, panels and overlays
+	themeName    string        // Name of the palette, see SetThemeSwitch
+	darkTheme    string        // Dark palette L switches back to
+	themeSwitch  func(name string) frame.Palette
+	palette      commandPalette // Actions found by name, see bindCommandKeys
+	textLayer    *ebiten.Image  // Debug text to tint, see printAt
 }
 
 // NewGame creates a new Game instance
-func NewGame(world *simulation.World, threads, cellSize, maxSteps, updateFreq int) *Game {
-	return &Game{
+func NewGame(world *simulation.World, threads, cellSize, maxSteps, updateFreq int, extinction analysis.ExtinctionRule) *Game {
+	g := &Game{
 		world:      world,
 		threads:    threads,
+		engine:     simulation.DefaultEngine(threads),
 		cellSize:   cellSize,
 		maxSteps:   maxSteps,
 		updateFreq: updateFreq,
+		frames:     updateFreq,
+		burst:      1,
 		startTime:  time.Now(),
+		extinction: analysis.NewExtinctionTracker(extinction),
+		chart:      newPopulationChart(world.Width * world.Height),
+		colors:     newColorRegistry(frame.DefaultPalette, world),
+		theme:      DarkTheme,
+		pending:    make(chan func(), 4),
+		budget:     newRenderBudget(0),
+	}
+	fish, sharks := world.Count()
+	g.extinction.Observe(0, fish, sharks)
+	g.chart.observe(0, fish, sharks)
+	world.AddObserver(g)
+
+	g.keys.bind(ebiten.KeySpace, g.togglePause)
+	g.keys.bind(ebiten.KeyArrowRight, g.singleStep)
+	g.keys.bind(ebiten.KeyP, g.takeScreenshot)
+	g.keys.bind(ebiten.KeyG, func() { g.chart.toggle() })
+	g.keys.bind(ebiten.KeyS, g.saveSnapshot)
+	g.keys.bind(ebiten.KeyO, g.openSnapshot)
+	g.keys.bind(ebiten.KeyE, g.exportPNG)
+	g.keys.bind(ebiten.KeyA, g.toggleCurrents)
+	g.keys.bind(ebiten.KeyC, g.cycleColors)
+	g.keys.bind(ebiten.KeyH, g.toggleHeatmap)
+	g.keys.bind(ebiten.KeyD, g.dumpRecent)
+	g.keys.bind(ebiten.KeyDigit0, g.resetView)
+	g.keys.bindWith(modCtrl, ebiten.KeyC, func() { g.copyStats(false) })
+	g.keys.bindWith(modCtrl|modShift, ebiten.KeyC, func() { g.copyStats(true) })
+	g.bindSpeedKeys()
+	g.bindPanelKeys()
+	g.bindRestartKeys()
+	g.keys.bind(ebiten.KeyT, g.switchEngine)
+	g.keys.bind(ebiten.KeyL, g.toggleTheme)
+	g.bindCommandKeys()
+
+	return g
+}
+
+// SetAfterStep registers a function called after every simulation step
+func (g *Game) SetAfterStep(fn func(step int, world *simulation.World)) {
+	g.afterStep = fn
+}
+
+// SetReset registers the function that builds a new world from a JSON
+// configuration file dropped on the window
+func (g *Game) SetReset(fn func(params []byte) (*simulation.World, error)) {
+	g.reset = fn
+}
+
+// SetTimeline sets the markers drawn on the population chart
+func (g *Game) SetTimeline(t *analysis.Timeline) {
+	g.chart.timeline = t
+}
+
+// SetAutoPause pauses the simulation whenever one of the triggers fires
+func (g *Game) SetAutoPause(triggers []analysis.PauseTrigger) {
+	g.autoPause = nil
+	if len(triggers) > 0 {
+		g.autoPause = analysis.NewAutoPause(triggers, g.world)
+	}
+}
+
+// SetStep sets the step the world is at, as when it was resumed from a
+// checkpoint
+func (g *Game) SetStep(step int) {
+	g.step = step
+}
+
+// SetInterrupt ends the run as soon as ch is closed, as when the program
+// is interrupted from the terminal
+func (g *Game) SetInterrupt(ch <-chan struct{}) {
+	g.interrupt = ch
+}
+
+// SetEquilibrium ends the run with a report once the populations oscillate
+// stably under the rule
+func (g *Game) SetEquilibrium(rule analysis.EquilibriumRule) {
+	g.equilibrium = analysis.NewEquilibriumTracker(rule)
+	fish, sharks := g.world.Count()
+	g.equilibrium.Observe(g.step, fish, sharks)
+}
+
+// stable reports whether the run has reached the equilibrium it stops at
+func (g *Game) stable() bool {
+	return g.equilibrium != nil && g.equilibrium.Ended()
+}
+
+// SetMeanField draws the mean-field model alongside the populations on the
+// chart and shows how far the simulation deviates from it
+func (g *Game) SetMeanField(enabled bool) {
+	g.chart.meanField = nil
+	g.chart.modelFish, g.chart.modelSharks = nil, nil
+	if enabled {
+		g.chart.compareMeanField(g.world)
 	}
 }
 
+// SetCalendar shows the date each step corresponds to in the HUD
+func (g *Game) SetCalendar(c analysis.Calendar) {
+	g.calendar = c
+}
+
+// SetPalette sets the colors cells are drawn and exported in, and the
+// theme that suits them
+func (g *Game) SetPalette(p frame.Palette) {
+	colors := newColorRegistry(p, g.world)
+	colors.mode = g.colors.mode
+	g.colors = colors
+	g.theme = ThemeFor(p)
+}
+
+// SetRenderBudget sets how long drawing a frame may take before detail is
+// dropped to keep the simulation rate (0=always draw full detail)
+func (g *Game) SetRenderBudget(limit time.Duration) {
+	g.budget = newRenderBudget(limit)
+}
+
+// SetOnScreenshot registers the function that saves an image when P is pressed
+func (g *Game) SetOnScreenshot(fn func(step int, world *simulation.World)) {
+	g.screenshot = fn
+}
+
+// SetOnDump registers the function that saves the recent past when D is
+// pressed
+func (g *Game) SetOnDump(fn func()) {
+	g.dump = fn
+}
+
+// Started reports whether the window has started running the game
+func (g *Game) Started() bool {
+	return g.started
+}
+
 // Update updates the game state
 func (g *Game) Update() error {
+	g.started = true
+	g.noteInput()
+	g.runPending()
+	if g.replay != nil {
+		g.updateReplay()
+		return nil
+	}
+	g.handleDrop()
 	if g.ended {
 		return nil
 	}
@@ -61,30 +239,47 @@ func (g *Game) Update() error {
 		return ebiten.Termination
 	}
 
-	fish, sharks := g.world.Count()
-	if fish == 0 {
-		fmt.Printf("\nAll fish died at step %d\n", g.step)
+	if g.extinction.Ended() {
 		g.ended = true
-		g.endReason = "All fish died"
+		g.endReason = g.extinction.Reason()
+		fmt.Printf("\n%s at step %d\n", g.endReason, g.step)
 		return ebiten.Termination
 	}
-	if sharks == 0 {
-		fmt.Printf("\nAll sharks died at step %d\n", g.step)
+
+	select {
+	case <-g.interrupt:
 		g.ended = true
-		g.endReason = "All sharks died"
+		g.endReason = "Interrupted"
+		fmt.Printf("\nInterrupted at step %d\n", g.step)
 		return ebiten.Termination
+	default:
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
-		g.paused = !g.paused
-		time.Sleep(200 * time.Millisecond)
+	if g.stable() {
+		g.ended = true
+		g.endReason = g.equilibrium.Reason()
+		fmt.Printf("\n%s at step %d\n%s\n", g.endReason, g.step, g.equilibrium.Report())
+		return ebiten.Termination
+	}
+
+	if !g.updateCommands() {
+		g.keys.update()
 	}
+	g.handleWheel()
+	g.handlePan()
 
-	if !g.paused {
+	if g.paused {
+		g.handleMouse()
+	} else {
+		g.painting = false
 		g.counter++
-		if g.counter >= g.updateFreq {
-			g.fishEaten += g.world.Step(g.threads)
-			g.step++
+		if g.counter >= g.frames {
+			for range g.burst {
+				g.advance()
+				if g.paused || g.extinction.Ended() || g.stable() || (g.maxSteps > 0 && g.step >= g.maxSteps) {
+					break
+				}
+			}
 			g.counter = 0
 		}
 	}
@@ -92,67 +287,222 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// togglePause pauses or resumes the simulation
+func (g *Game) togglePause() {
+	g.paused = !g.paused
+	g.counter = 0
+	g.pauseEvent = nil
+}
+
+// singleStep advances one step while paused. With the settings panel open
+// it raises the selected parameter instead.
+func (g *Game) singleStep() {
+	if g.panel.open {
+		g.changeParameter(1)
+	} else if g.paused {
+		g.advance()
+	}
+}
+
+// takeScreenshot saves the current grid through the screenshot callback
+func (g *Game) takeScreenshot() {
+	if g.screenshot != nil {
+		g.screenshot(g.step, g.world)
+	}
+}
+
+// dumpRecent saves the recent past through the dump callback
+func (g *Game) dumpRecent() {
+	if g.dump != nil {
+		g.dump()
+	}
+}
+
+// advance performs exactly one simulation step, which the game follows up
+// as an observer of the world
+func (g *Game) advance() {
+	if g.engine == simulation.EngineGPU {
+		g.world.SetGPU(&g.gpu)
+	}
+	g.world.StepWith(g.engine, g.threads)
+}
+
+// SetEngine sets the engine the following steps are taken with
+func (g *Game) SetEngine(e simulation.Engine) {
+	g.engine = e
+}
+
+// switchEngine continues the run with the next engine, marking the switch
+// on the timeline
+func (g *Game) switchEngine() {
+	g.engine = g.engine.Next()
+	if g.chart.timeline != nil {
+		g.chart.timeline.Mark(g.step, "engine: "+string(g.engine))
+	}
+	fmt.Printf("Step %d: switched to the %s engine\n", g.step, g.engine)
+}
+
+// OnStepEnd updates the counters, the chart and the triggers after every
+// step of the world
+func (g *Game) OnStepEnd(world *simulation.World) {
+	eaten := world.LastStep().FishEaten
+	g.fishEaten += eaten
+	g.timing.Add(world.LastStepTiming())
+	g.timed++
+	g.step++
+	fish, sharks := world.Count()
+	g.extinction.Observe(g.step, fish, sharks)
+	if g.equilibrium != nil {
+		g.equilibrium.Observe(g.step, fish, sharks)
+	}
+	g.chart.observe(g.step, fish, sharks)
+	g.chart.observeDeaths(analysis.StepDeaths(world))
+	g.adjustSpeed(fish, sharks)
+	if g.autoPause != nil {
+		if event := g.autoPause.Observe(g.step, world, eaten); event != nil {
+			fmt.Printf("Auto-paused at step %d: %s\n", event.Step, event.Reason)
+			g.paused = true
+			g.pauseEvent = event
+		}
+	}
+	if g.afterStep != nil {
+		g.afterStep(g.step, world)
+	}
+}
+
+// handleMouse lets the user edit the grid while paused. Clicking a cell
+// cycles it Empty -> Fish -> Shark, and dragging paints the chosen type.
+func (g *Game) handleMouse() {
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.painting = false
+		return
+	}
+
+	y, x, ok := g.cellAt(ebiten.CursorPosition())
+	if !ok {
+		return
+	}
+
+	current := g.world.Cell(y, x).Type
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && current != simulation.Barrier {
+		g.paintType = (current + 1) % simulation.Barrier
+		g.painting = true
+	}
+	if !g.painting || current == g.paintType || current == simulation.Barrier {
+		return
+	}
+
+	cell := simulation.Cell{Type: g.paintType}
+	switch g.paintType {
+	case simulation.Fish:
+		cell.Energy = g.world.FishStarve
+	case simulation.Shark:
+		cell.Energy = g.world.SharkStarve
+	}
+	g.world.SetCell(y, x, cell)
+}
+
 // Draw renders the game
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(ColorEmpty)
+	if !g.needsRedraw() {
+		return
+	}
+	start := time.Now()
+	defer func() { g.budget.observe(time.Since(start)) }()
+	screen.Fill(g.colors.palette.Empty)
+	if g.colors.mode == colorByAge {
+		g.colors.observeAges(g.world)
+	}
+
+	// Over budget, each block of cells is drawn in the color of its top left
+	// cell, as squares even on a hex world
+	block := g.budget.gridBlock()
+	if g.world.Hexagonal() && block == 1 {
+		g.drawHexes(screen)
+	} else {
+		for i := 0; i < g.world.Height; i += block {
+			for j := 0; j < g.world.Width; j += block {
+				wy, wx := g.toWorld(i, j)
+				c, ok := g.colors.cellColor(g.world, wy, wx)
+				if !ok {
+					continue
+				}
 
-	for i := 0; i < g.world.Height; i++ {
-		for j := 0; j < g.world.Width; j++ {
-			cell := g.world.Grid[i][j]
-			if cell.Type != simulation.Empty {
 				x := float32(j * g.cellSize)
 				y := float32(i * g.cellSize)
-				w := float32(g.cellSize)
-				h := float32(g.cellSize)
-
-				var c color.Color
-				if cell.Type == simulation.Fish {
-					c = ColorFish
-				} else {
-					c = ColorShark
+				if block == 1 && g.drawSprite(screen, g.world.Cell(wy, wx), x, y, c) {
+					continue
 				}
-
+				w := float32(min(block, g.world.Width-j) * g.cellSize)
+				h := float32(min(block, g.world.Height-i) * g.cellSize)
 				vector.FillRect(screen, x, y, w, h, c, false)
 			}
 		}
 	}
 
-	fish, sharks := g.world.Count()
-	elapsed := time.Since(g.startTime)
-	status := "Running"
-	if g.paused {
-		status = "Paused"
-	}
-	if g.ended {
-		status = "ENDED: " + g.endReason
+	if g.world.Bounded {
+		w := float32(g.world.Width * g.cellSize)
+		h := float32(g.world.Height * g.cellSize)
+		vector.StrokeRect(screen, 1, 1, w-2, h-2, 2, g.theme.Border, false)
 	}
 
-	stepsDisplay := fmt.Sprintf("%d/%d", g.step, g.maxSteps)
-	if g.maxSteps == 0 {
-		stepsDisplay = fmt.Sprintf("%d (infinite)", g.step)
+	g.drawPauseHighlight(screen)
+	if g.budget.level < degradeOverlays {
+		g.drawHeatmap(screen)
+		g.drawCurrents(screen)
 	}
+	g.chart.draw(screen, g.colors, g.theme, g.budget.chartStride())
+	if g.budget.level < degradeOverlays {
+		g.drawLegend(screen)
+	}
+	g.drawPanel(screen)
+	g.drawCommands(screen)
 
-	message := fmt.Sprintf(
-		"Wa-Tor Simulation [%s]\n"+
-			"Step: %s\n"+
-			"Fish: %d\n"+
-			"Sharks: %d\n"+
-			"Fish Eaten: %d\n"+
-			"Threads: %d\n"+
-			"Time: %.1fs\n"+
-			"FPS: %.0f\n"+
-			"Update: every %d frames\n",
-		status, stepsDisplay, fish, sharks, g.fishEaten, g.threads,
-		elapsed.Seconds(), ebiten.ActualFPS(), g.updateFreq,
-	)
-
-	if g.ended {
+	message := g.statsText()
+	if status := g.budget.status(); status != "" {
+		message += "\n" + status
+	}
+	if g.replay != nil {
+		message += "\nPress SPACE to play/pause, P to save PNG"
+		message += "\nLEFT/RIGHT to step, PGUP/PGDN to jump"
+		message += "\nHOME/END for the first/last frame"
+		message += "\n+/- to change the speed"
+		message += "\nG to show the population chart"
+	} else if g.ended {
 		message += "\nClose window to exit"
 	} else {
-		message += "\nPress SPACE to pause"
+		message += "\nPress SPACE to pause, P to save PNG"
+		message += "\nG to show the population chart"
+		message += "\n+/- to change the speed, C to change colors"
+		message += "\nS/O to save/open a snapshot, E to export PNG"
+		message += "\nCtrl+C to copy stats (+Shift with image)"
+		message += "\nTAB to change parameters, T to switch engines"
+		message += "\n: or Ctrl+P to find any action by name"
+		if g.themeSwitch != nil {
+			message += "\nL to switch between light and dark"
+		}
+		if g.reset != nil && g.seed != 0 {
+			message += "\nR to restart, Shift+R with a new seed"
+		}
+		if g.dump != nil {
+			message += "\nD to save the recent steps as GIF"
+		}
+		if g.world.Predation() != nil {
+			message += "\nH to show where fish are eaten"
+		}
+		if g.world.Terrain != nil && g.world.Terrain.CurrentEast != nil {
+			message += "\nA to show ocean currents"
+		}
+		if !g.world.Bounded {
+			message += "\nRight-drag to scroll, 0 to reset"
+		}
+		if g.paused {
+			message += "\nRIGHT to single-step"
+			message += "\nClick/drag to edit cells"
+		}
 	}
 
-	ebitenutil.DebugPrint(screen, message)
+	g.printAt(screen, message, 0, 0)
 }
 
 // Layout sets the game screen size
@@ -160,7 +510,18 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return g.world.Width * g.cellSize, g.world.Height * g.cellSize
 }
 
+// World returns the simulated world, which changes when a snapshot is opened
+func (g *Game) World() *simulation.World {
+	return g.world
+}
+
 // GetStats returns the final statistics of the simulation
 func (g *Game) GetStats() (step int, fishEaten int, elapsed time.Duration) {
 	return g.step, g.fishEaten, time.Since(g.startTime)
 }
+
+// StepTiming returns the mean time the phases of the steps taken so far
+// took, and the number of steps
+func (g *Game) StepTiming() (simulation.StepTiming, int) {
+	return g.timing.Per(g.timed), g.timed
+}
@@ -0,0 +1,91 @@
+package rendering
+
+import (
+	_ "embed"
+	"fmt"
+
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed gpustep.kage
+var gpuStepShader []byte
+
+// gpuStepper is the graphics card of the gpu engine: it uploads the grid,
+// runs the four passes of gpustep.kage on it and reads the result back.
+// Images can only be read while the game loop runs, so the window is the
+// only mode that steps on the GPU; headless runs and the server take the
+// steps of the gpu engine on the CPU.
+type gpuStepper struct {
+	shader *ebiten.Shader
+	failed bool // The shader did not compile, so every step falls back
+
+	// The grid before the step, after the sharks moved and after the fish
+	// moved, and the cells the animals picked
+	before, sharks, after, picks *ebiten.Image
+}
+
+// Ready implements simulation.GPU, compiling the shader the first time
+func (s *gpuStepper) Ready() bool {
+	if s.shader == nil && !s.failed {
+		shader, err := ebiten.NewShader(gpuStepShader)
+		if err != nil {
+			fmt.Printf("Error: gpu engine: %v; stepping on the CPU instead\n", err)
+			s.failed = true
+			return false
+		}
+		s.shader = shader
+	}
+	return !s.failed
+}
+
+// Step implements simulation.GPU
+func (s *gpuStepper) Step(pixels []byte, width, height int, p simulation.GPUParams) {
+	if s.before == nil || s.before.Bounds().Dx() != width || s.before.Bounds().Dy() != height {
+		s.allocate(width, height)
+	}
+
+	uniforms := map[string]any{
+		"Size":        []float32{float32(width), float32(height)},
+		"Seed":        float32(p.Seed),
+		"FishBreed":   float32(p.FishBreed),
+		"SharkBreed":  float32(p.SharkBreed),
+		"SharkStarve": float32(p.SharkStarve),
+		"SharkGain":   float32(p.SharkGain),
+		"Bounded":     float32(0),
+	}
+	if p.Bounded {
+		uniforms["Bounded"] = float32(1)
+	}
+
+	s.before.WritePixels(pixels)
+	s.pass(s.picks, 0, uniforms, s.before, nil, nil)
+	s.pass(s.sharks, 1, uniforms, s.before, nil, s.picks)
+	s.pass(s.picks, 2, uniforms, s.before, s.sharks, nil)
+	s.pass(s.after, 3, uniforms, nil, s.sharks, s.picks)
+	s.after.ReadPixels(pixels)
+}
+
+// allocate creates the images for a grid of the given size
+func (s *gpuStepper) allocate(width, height int) {
+	for _, img := range []*ebiten.Image{s.before, s.sharks, s.after, s.picks} {
+		if img != nil {
+			img.Deallocate()
+		}
+	}
+	s.before = ebiten.NewImage(width, height)
+	s.sharks = ebiten.NewImage(width, height)
+	s.after = ebiten.NewImage(width, height)
+	s.picks = ebiten.NewImage(width, height)
+}
+
+// pass runs one pass of the shader into dst, with the grid before the
+// step, the grid after the sharks moved and the picks as its images
+func (s *gpuStepper) pass(dst *ebiten.Image, pass int, uniforms map[string]any, before, sharks, picks *ebiten.Image) {
+	uniforms["Pass"] = float32(pass)
+	opts := &ebiten.DrawRectShaderOptions{Blend: ebiten.BlendCopy, Uniforms: uniforms}
+	opts.Images = [4]*ebiten.Image{before, sharks, picks}
+	b := dst.Bounds()
+	dst.DrawRectShader(b.Dx(), b.Dy(), s.shader, opts)
+}
@@ -0,0 +1,99 @@
+//go:build gpu
+
+// The gpu engine can only read its grid back in the game loop, so these
+// tests open a window and need a display and a graphics card:
+//
+//	go test -tags gpu ./rendering
+
+package rendering
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Steps of the comparison, the first gpuWarmup of which are not measured
+// while the populations settle into their cycle
+const (
+	gpuSteps         = 2000
+	gpuWarmup        = 500
+	gpuStepsPerFrame = 50
+)
+
+// gpuComparison steps the same world with the gpu and the serial engine in
+// the game loop and averages their populations
+type gpuComparison struct {
+	gpu, serial *simulation.World
+	stepper     gpuStepper
+	step        int
+	fish        [2]float64 // Mean fish of the gpu and serial engines
+	sharks      [2]float64
+	skip        string // Why the comparison could not run
+}
+
+func (c *gpuComparison) Update() error {
+	if c.step == 0 {
+		if !c.stepper.Ready() {
+			c.skip = "the gpu engine's shader does not compile on this card"
+			return ebiten.Termination
+		}
+		c.gpu.SetGPU(&c.stepper)
+	}
+	for range gpuStepsPerFrame {
+		c.gpu.StepWith(simulation.EngineGPU, 1)
+		c.serial.StepWith(simulation.EngineSerial, 1)
+		if c.step++; c.step > gpuWarmup {
+			for k, w := range []*simulation.World{c.gpu, c.serial} {
+				fish, sharks := w.Count()
+				c.fish[k] += float64(fish) / (gpuSteps - gpuWarmup)
+				c.sharks[k] += float64(sharks) / (gpuSteps - gpuWarmup)
+			}
+		}
+		if c.step == gpuSteps {
+			return ebiten.Termination
+		}
+	}
+	return nil
+}
+
+func (c *gpuComparison) Draw(*ebiten.Image) {}
+
+func (c *gpuComparison) Layout(int, int) (int, int) { return 64, 64 }
+
+var comparison = &gpuComparison{
+	gpu:    simulation.NewSeededWorld(7, 128, 128, 3000, 600, 3, 10, 3),
+	serial: simulation.NewSeededWorld(7, 128, 128, 3000, 600, 3, 10, 3),
+}
+
+func TestMain(m *testing.M) {
+	ebiten.SetWindowSize(64, 64)
+	if err := ebiten.RunGame(comparison); err != nil {
+		comparison.skip = fmt.Sprintf("no game loop: %v", err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestGPUEngineMatchesSerialPopulations(t *testing.T) {
+	if comparison.skip != "" {
+		t.Skip(comparison.skip)
+	}
+	// Contested cells are settled differently on the card, so only the
+	// cycle the populations settle into should agree
+	const tolerance = 0.15
+	for _, p := range []struct {
+		name  string
+		means [2]float64
+	}{{"fish", comparison.fish}, {"sharks", comparison.sharks}} {
+		gpu, serial := p.means[0], p.means[1]
+		if serial == 0 || math.Abs(gpu-serial) > tolerance*serial {
+			t.Errorf("mean %s = %.0f with the gpu engine and %.0f with the serial one, want them within %.0f%%",
+				p.name, gpu, serial, 100*tolerance)
+		}
+	}
+}
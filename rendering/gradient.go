@@ -0,0 +1,26 @@
+package rendering
+
+import "image/color"
+
+// gradientFloor is the share of its brightness a color keeps at the dark end
+// of its shade
+const gradientFloor = 0.25
+
+// gradient is a linear ramp between two colors
+type gradient struct {
+	from, to color.RGBA
+}
+
+// shade returns the gradient from a dark version of c to c itself
+func shade(c color.RGBA) gradient {
+	dark := func(v uint8) uint8 { return uint8(float64(v) * gradientFloor) }
+	return gradient{from: color.RGBA{dark(c.R), dark(c.G), dark(c.B), c.A}, to: c}
+}
+
+// at returns the color a fraction t of the way along the gradient, t being
+// clamped to [0, 1]
+func (g gradient) at(t float64) color.RGBA {
+	t = max(0, min(1, t))
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5) }
+	return color.RGBA{mix(g.from.R, g.to.R), mix(g.from.G, g.to.G), mix(g.from.B, g.to.B), mix(g.from.A, g.to.A)}
+}
@@ -0,0 +1,47 @@
+package rendering
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Opacity of the heatmap over the cells with the most fish eaten, and the
+// least it fades to over cells with few
+const (
+	heatmapAlpha    = 200
+	heatmapMinAlpha = 40
+)
+
+// ColorHeatmap is the hue of the predation heatmap
+var ColorHeatmap = color.NRGBA{255, 140, 0, heatmapAlpha}
+
+// toggleHeatmap shows or hides the predation heatmap
+func (g *Game) toggleHeatmap() {
+	g.showHeatmap = !g.showHeatmap
+}
+
+// drawHeatmap shades every cell where fish were eaten during the world's
+// predation window, more opaque the more fish were eaten there
+func (g *Game) drawHeatmap(screen *ebiten.Image) {
+	counts := g.world.Predation()
+	if !g.showHeatmap || counts == nil {
+		return
+	}
+
+	peak := int32(1)
+	for _, n := range counts {
+		peak = max(peak, n)
+	}
+	size := float32(g.cellSize)
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		c := ColorHeatmap
+		c.A = uint8(heatmapMinAlpha + (heatmapAlpha-heatmapMinAlpha)*n/peak)
+		x, y := g.toScreen(i/g.world.Width, i%g.world.Width)
+		vector.FillRect(screen, x, y, size, size, c, false)
+	}
+}
@@ -0,0 +1,53 @@
+package rendering
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// drawHexes draws the cells of a hex world as hexagons, see
+// simulation.Hex. Odd rows are shifted half a cell to the right by
+// toScreen; on a torus the half cell they push past the right edge is drawn
+// again at the left.
+func (g *Game) drawHexes(screen *ebiten.Image) {
+	size := float32(g.cellSize)
+	for i := 0; i < g.world.Height; i++ {
+		for j := 0; j < g.world.Width; j++ {
+			wy, wx := g.toWorld(i, j)
+			c, ok := g.colors.cellColor(g.world, wy, wx)
+			if !ok {
+				continue
+			}
+
+			x, y := g.toScreen(wy, wx)
+			if wy%2 != 0 && j == g.world.Width-1 && !g.world.Bounded {
+				fillHexagon(screen, x-size*float32(g.world.Width), y, size, c)
+			}
+			if g.drawSprite(screen, g.world.Cell(wy, wx), x, y, c) {
+				continue
+			}
+			fillHexagon(screen, x, y, size, c)
+		}
+	}
+}
+
+// fillHexagon fills the pointy-topped hexagon of the cell whose square has
+// its top left corner at (x, y). The hexagon is as wide as the square and
+// reaches a sixth of it into the rows above and below, where it meets the
+// hexagons of those rows.
+func fillHexagon(dst *ebiten.Image, x, y, size float32, c color.RGBA) {
+	var p vector.Path
+	p.MoveTo(x+size/2, y-size/6)
+	p.LineTo(x+size, y+size/6)
+	p.LineTo(x+size, y+size*5/6)
+	p.LineTo(x+size/2, y+size*7/6)
+	p.LineTo(x, y+size*5/6)
+	p.LineTo(x, y+size/6)
+	p.Close()
+
+	op := &vector.DrawPathOptions{}
+	op.ColorScale.ScaleWithColor(c)
+	vector.FillPath(dst, &p, nil, op)
+}
@@ -0,0 +1,283 @@
+package rendering
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// hudMetric is a block of lines the HUD can show, by the name the config
+// file's "hud" list uses. text returns "" where the metric does not apply.
+type hudMetric struct {
+	name string
+	text func(g *Game) string
+}
+
+// hudMetrics are the built-in metrics in the order the HUD shows them by
+// default
+var hudMetrics = []hudMetric{
+	{"status", (*Game).statusText},
+	{"step", (*Game).stepText},
+	{"hash", func(g *Game) string { return "Hash: " + simulation.ShortHash(g.world.Hash()) }},
+	{"date", func(g *Game) string {
+		if !g.calendar.Enabled() {
+			return ""
+		}
+		return "Date: " + g.calendar.Date(g.step)
+	}},
+	{"fish", func(g *Game) string {
+		fish, _ := g.world.Count()
+		return fmt.Sprintf("Fish: %d", fish)
+	}},
+	{"sharks", func(g *Game) string {
+		_, sharks := g.world.Count()
+		return fmt.Sprintf("Sharks: %d", sharks)
+	}},
+	{"eaten", func(g *Game) string { return fmt.Sprintf("Fish Eaten: %d", g.fishEaten) }},
+	{"threads", func(g *Game) string {
+		return fmt.Sprintf("Threads: %d, %s (tie-break: %s)", g.threads, g.engine, g.world.TieBreak)
+	}},
+	{"time", func(g *Game) string { return fmt.Sprintf("Time: %.1fs", time.Since(g.startTime).Seconds()) }},
+	{"timing", func(g *Game) string {
+		if g.step == 0 {
+			return ""
+		}
+		return "Step Time: " + g.world.LastStepTiming().String()
+	}},
+	{"fps", func(g *Game) string { return fmt.Sprintf("FPS: %.0f", ebiten.ActualFPS()) }},
+	{"update", (*Game).speedText},
+	{"algae", func(g *Game) string {
+		if g.world.FishStarve == 0 {
+			return ""
+		}
+		return fmt.Sprintf("Algae: %d", g.world.CountAlgae())
+	}},
+	{"eggs", func(g *Game) string {
+		if g.world.EggDelay == 0 {
+			return ""
+		}
+		return fmt.Sprintf("Eggs: %d", g.world.CountEggs())
+	}},
+	{"species", func(g *Game) string {
+		var lines []string
+		if len(g.world.Species) > 0 {
+			for id, n := range g.world.CountSpecies() {
+				lines = append(lines, fmt.Sprintf("  %s: %d", g.world.SpeciesName(id), n))
+			}
+		}
+		return strings.Join(lines, "\n")
+	}},
+	{"deaths", (*Game).deathsText},
+	{"meanfield", func(g *Game) string {
+		if g.chart.meanField == nil {
+			return ""
+		}
+		fish, sharks := g.chart.meanField.RMS()
+		return fmt.Sprintf("Mean-field RMS: fish %.0f, sharks %.0f", fish, sharks)
+	}},
+	{"traits", func(g *Game) string {
+		var lines []string
+		if g.world.Mutation > 0 {
+			for _, h := range analysis.TraitHistograms(g.world) {
+				lines = append(lines, fmt.Sprintf("Mean %s %s: %.1f", h.Population, h.Trait, h.Mean()))
+			}
+		}
+		return strings.Join(lines, "\n")
+	}},
+}
+
+// hudVariables are the variables custom HUD metrics can use. fish_born to
+// sharks_starved count the events of the last step.
+var hudVariables = []string{
+	"step", "days", "fish", "sharks", "eaten", "algae", "water", "cells", "time", "fps",
+	"fish_born", "sharks_born", "fish_eaten", "fish_starved", "fish_aged", "sharks_starved",
+}
+
+// hudLine is one entry of the HUD: a built-in metric, or a custom
+// expression shown under its label
+type hudLine struct {
+	metric *hudMetric
+	label  string
+	expr   *analysis.Expr
+}
+
+// SetHUD chooses the metrics shown in the HUD and their order. Each item is
+// the name of a built-in metric, or "label = expression" for a custom metric
+// computed from hudVariables, such as "Ratio = fish / sharks".
+func (g *Game) SetHUD(items []string) error {
+	lines, err := parseHUD(items)
+	if err != nil {
+		return err
+	}
+	g.hud = lines
+	return nil
+}
+
+// CheckHUD reports whether SetHUD would accept items, so that a mistake can
+// be reported before the run starts
+func CheckHUD(items []string) error {
+	_, err := parseHUD(items)
+	return err
+}
+
+// parseHUD looks up the built-in metrics and parses the custom ones
+func parseHUD(items []string) ([]hudLine, error) {
+	lines := make([]hudLine, 0, len(items))
+	for _, item := range items {
+		if label, expr, ok := strings.Cut(item, "="); ok {
+			e, err := analysis.ParseExpr(expr, hudVariables)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, hudLine{label: strings.TrimSpace(label), expr: e})
+			continue
+		}
+
+		line := hudLine{}
+		for i := range hudMetrics {
+			if hudMetrics[i].name == strings.TrimSpace(item) {
+				line.metric = &hudMetrics[i]
+			}
+		}
+		if line.metric == nil {
+			names := make([]string, len(hudMetrics))
+			for i, m := range hudMetrics {
+				names[i] = m.name
+			}
+			return nil, fmt.Errorf("unknown HUD metric %q (built-in: %s; or label = expression)", item, strings.Join(names, ", "))
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// statsText returns the statistics block shown in the HUD
+func (g *Game) statsText() string {
+	lines := g.hud
+	if lines == nil {
+		for i := range hudMetrics {
+			lines = append(lines, hudLine{metric: &hudMetrics[i]})
+		}
+	}
+
+	message := ""
+	for _, line := range lines {
+		text := ""
+		if line.metric != nil {
+			text = line.metric.text(g)
+		} else {
+			text = fmt.Sprintf("%s: %s", line.label, formatMetric(line.expr.Eval(g.variable)))
+		}
+		if text != "" {
+			message += text + "\n"
+		}
+	}
+	return message
+}
+
+// statusText returns the title line with the state of the run
+func (g *Game) statusText() string {
+	status := "Running"
+	if g.paused {
+		status = "Paused"
+	}
+	if g.pauseEvent != nil {
+		status = "AUTO-PAUSED: " + g.pauseEvent.Reason
+	}
+	if g.ended {
+		status = "ENDED: " + g.endReason
+	}
+	if g.replay != nil {
+		status = g.replayStatus()
+	}
+	return fmt.Sprintf("Wa-Tor Simulation [%s]", status)
+}
+
+// stepText returns the step out of the last one, if known
+func (g *Game) stepText() string {
+	switch {
+	case g.replay != nil:
+		return fmt.Sprintf("Step: %d/%d", g.step, g.replay.Replay.Step(g.replay.Replay.Frames()-1))
+	case g.maxSteps == 0:
+		return fmt.Sprintf("Step: %d (infinite)", g.step)
+	}
+	return fmt.Sprintf("Step: %d/%d", g.step, g.maxSteps)
+}
+
+// deathsText returns the deaths of the last step by population and cause
+func (g *Game) deathsText() string {
+	n := len(g.chart.deaths)
+	if n == 0 {
+		return ""
+	}
+	text := "Deaths:"
+	for _, d := range g.chart.deaths[n-1] {
+		if d.Total() > 0 {
+			text += fmt.Sprintf(" %s %d/%d/%d", d.Population, d.Counts[0], d.Counts[1], d.Counts[2])
+		}
+	}
+	return text + " (eaten/starved/old)"
+}
+
+// variable returns the value of one of hudVariables
+func (g *Game) variable(name string) float64 {
+	events := g.world.LastStep()
+	switch name {
+	case "step":
+		return float64(g.step)
+	case "days":
+		return g.calendar.Days(g.step)
+	case "fish", "sharks":
+		fish, sharks := g.world.Count()
+		if name == "fish" {
+			return float64(fish)
+		}
+		return float64(sharks)
+	case "eaten":
+		return float64(g.fishEaten)
+	case "algae":
+		return float64(g.world.CountAlgae())
+	case "water":
+		if g.world.Terrain == nil {
+			return float64(g.world.Width * g.world.Height)
+		}
+		return float64(g.world.Terrain.WaterCells())
+	case "cells":
+		return float64(g.world.Width * g.world.Height)
+	case "time":
+		return time.Since(g.startTime).Seconds()
+	case "fps":
+		return ebiten.ActualFPS()
+	case "fish_born":
+		return float64(events.FishBorn)
+	case "sharks_born":
+		return float64(events.SharksBorn)
+	case "fish_eaten":
+		return float64(events.FishEaten)
+	case "fish_starved":
+		return float64(events.FishStarved)
+	case "fish_aged":
+		return float64(events.FishAged)
+	case "sharks_starved":
+		return float64(events.SharksStarved)
+	}
+	return math.NaN()
+}
+
+// formatMetric formats the value of a custom metric: whole numbers in full,
+// others to four significant digits
+func formatMetric(v float64) string {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return "-"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
@@ -0,0 +1,54 @@
+package rendering
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// modifiers is a set of modifier keys held with a hotkey
+type modifiers int
+
+const (
+	modCtrl modifiers = 1 << iota
+	modShift
+)
+
+// hotkey binds a key to an action fired once per key press
+type hotkey struct {
+	key    ebiten.Key
+	mods   modifiers
+	action func()
+}
+
+// hotkeys is the set of keyboard shortcuts handled by the game
+type hotkeys struct {
+	bindings []hotkey
+}
+
+// bind registers an action for a key pressed without modifiers
+func (h *hotkeys) bind(key ebiten.Key, action func()) {
+	h.bindWith(0, key, action)
+}
+
+// bindWith registers an action for a key pressed with exactly the given
+// modifiers held
+func (h *hotkeys) bindWith(mods modifiers, key ebiten.Key, action func()) {
+	h.bindings = append(h.bindings, hotkey{key: key, mods: mods, action: action})
+}
+
+// update fires the actions of all keys pressed since the last frame
+func (h *hotkeys) update() {
+	var held modifiers
+	if ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta) {
+		held |= modCtrl
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		held |= modShift
+	}
+
+	for _, b := range h.bindings {
+		if b.mods == held && inpututil.IsKeyJustPressed(b.key) {
+			b.action()
+		}
+	}
+}
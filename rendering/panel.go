@@ -0,0 +1,93 @@
+package rendering
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// panelParameter is a parameter the settings panel edits, by the name
+// World.SetParameter takes
+type panelParameter struct {
+	name  string
+	label string
+}
+
+// panelParameters are the rows of the settings panel
+var panelParameters = []panelParameter{
+	{"fbreed", "Fish breed"},
+	{"sbreed", "Shark breed"},
+	{"starve", "Shark starve"},
+}
+
+// settingsPanel is the panel TAB shows to change parameters of the running
+// world: UP/DOWN select a row and LEFT/RIGHT change its value by one
+type settingsPanel struct {
+	open     bool
+	selected int
+}
+
+// bindPanelKeys binds the keys of the settings panel. The arrow keys only
+// act while it is open; RIGHT is bound with singleStep.
+func (g *Game) bindPanelKeys() {
+	g.keys.bind(ebiten.KeyTab, func() { g.panel.open = !g.panel.open })
+	g.keys.bind(ebiten.KeyArrowUp, func() { g.selectParameter(-1) })
+	g.keys.bind(ebiten.KeyArrowDown, func() { g.selectParameter(1) })
+	g.keys.bind(ebiten.KeyArrowLeft, func() { g.changeParameter(-1) })
+}
+
+// selectParameter moves the selection of the open panel by delta rows
+func (g *Game) selectParameter(delta int) {
+	if g.panel.open {
+		n := len(panelParameters)
+		g.panel.selected = ((g.panel.selected+delta)%n + n) % n
+	}
+}
+
+// changeParameter adds delta to the selected parameter of the open panel,
+// applying it from the next step and marking it on the timeline
+func (g *Game) changeParameter(delta int) {
+	if !g.panel.open {
+		return
+	}
+	name := panelParameters[g.panel.selected].name
+	current, _ := g.world.Parameter(name)
+	value := current + float64(delta)
+	old, err := g.world.SetParameter(name, value)
+	if err != nil {
+		return // Already at the smallest value
+	}
+	if g.chart.timeline != nil {
+		g.chart.timeline.MarkParameter(g.step, name, old, value)
+	}
+	fmt.Printf("Step %d: %s changed from %g to %g\n", g.step, name, old, value)
+}
+
+// drawPanel draws the open settings panel below the legend
+func (g *Game) drawPanel(screen *ebiten.Image) {
+	if !g.panel.open {
+		return
+	}
+	lines := []string{"Parameters (TAB to close)"}
+	for i, p := range panelParameters {
+		value, _ := g.world.Parameter(p.name)
+		marker := "  "
+		if i == g.panel.selected {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%-12s < %3g >", marker, p.label, value))
+	}
+	lines = append(lines, "UP/DOWN select, LEFT/RIGHT change")
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line)*legendChar)
+	}
+	left := screen.Bounds().Dx() - width - 4
+	top := len(g.colors.legend(g.world))*legendLine + 8
+	vector.FillRect(screen, float32(left-4), float32(top), float32(width+8), float32(len(lines)*legendLine+4), g.theme.Background, false)
+	for i, line := range lines {
+		g.printAt(screen, line, left, top+i*legendLine+2)
+	}
+}
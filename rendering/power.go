@@ -0,0 +1,68 @@
+package rendering
+
+import (
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Ticks per second in low-power mode, a third of ebiten's default, and the
+// frames after which the screen is redrawn even if nothing changed
+const (
+	lowPowerTPS     = 20
+	lowPowerRefresh = 60
+)
+
+// redrawState is what the screen showed when it was last drawn in low-power
+// mode, to skip frames that would draw the same
+type redrawState struct {
+	world  *simulation.World
+	step   int
+	paused bool
+	input  bool // Keys or buttons were used since the last redraw
+	idle   int  // Frames skipped since the last redraw
+}
+
+// SetLowPower lowers the tick rate, waits for vsync and keeps the last frame
+// on screen instead of redrawing it while nothing changes, for long runs on
+// battery. The speed keys still work within the lower tick rate.
+func (g *Game) SetLowPower(enabled bool) {
+	g.lowPower = enabled
+	if enabled {
+		ebiten.SetTPS(lowPowerTPS)
+		ebiten.SetVsyncEnabled(true)
+	} else {
+		ebiten.SetTPS(ebiten.DefaultTPS)
+	}
+	ebiten.SetScreenClearedEveryFrame(!enabled)
+}
+
+// noteInput remembers whether the user pressed anything this tick, which
+// may change what is drawn without stepping the world
+func (g *Game) noteInput() {
+	if !g.lowPower {
+		return
+	}
+	_, dy := ebiten.Wheel()
+	if len(inpututil.AppendPressedKeys(nil)) > 0 || len(inpututil.AppendJustReleasedKeys(nil)) > 0 || dy != 0 ||
+		ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+		g.redraw.input = true
+	}
+}
+
+// needsRedraw reports whether the frame must be drawn: always outside
+// low-power mode, otherwise when the world, the step or the pause state
+// changed, after input, or once every lowPowerRefresh frames
+func (g *Game) needsRedraw() bool {
+	if !g.lowPower {
+		return true
+	}
+	r := &g.redraw
+	if !r.input && r.world == g.world && r.step == g.step && r.paused == g.paused && r.idle < lowPowerRefresh {
+		r.idle++
+		return false
+	}
+	*r = redrawState{world: g.world, step: g.step, paused: g.paused}
+	return true
+}
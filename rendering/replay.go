@@ -0,0 +1,102 @@
+package rendering
+
+import (
+	"fmt"
+
+	"wa-tor/analysis"
+	"wa-tor/replay"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// replaySeekFraction is the share of the recording skipped by PageUp/PageDown
+const replaySeekFraction = 10
+
+// SetReplay turns the window into a player for a recorded run. The world
+// shown is the player's, and the simulation is never stepped: playing
+// advances through the recorded frames, SPACE pauses, LEFT/RIGHT step one
+// frame, PAGEUP/PAGEDOWN jump a tenth of the recording, HOME/END go to its
+// ends, +/- change the speed and C cycles the animal colors.
+func (g *Game) SetReplay(p *replay.Player) {
+	g.replay = p
+	g.world.RemoveObserver(g) // Replays are never stepped
+	g.world = p.World
+	colors := newColorRegistry(g.colors.palette, p.World)
+	colors.mode = g.colors.mode
+	g.colors = colors
+	g.chart.timeline = &analysis.Timeline{Markers: p.Replay.Markers}
+	g.seekReplay(p.Frame())
+
+	jump := max(1, p.Replay.Frames()/replaySeekFraction)
+	g.keys = hotkeys{}
+	g.keys.bind(ebiten.KeySpace, g.togglePause)
+	g.keys.bind(ebiten.KeyArrowRight, func() { g.seekReplay(g.replay.Frame() + 1) })
+	g.keys.bind(ebiten.KeyArrowLeft, func() { g.seekReplay(g.replay.Frame() - 1) })
+	g.keys.bind(ebiten.KeyPageDown, func() { g.seekReplay(g.replay.Frame() + jump) })
+	g.keys.bind(ebiten.KeyPageUp, func() { g.seekReplay(g.replay.Frame() - jump) })
+	g.keys.bind(ebiten.KeyHome, func() { g.seekReplay(0) })
+	g.keys.bind(ebiten.KeyEnd, func() { g.seekReplay(g.replay.Replay.Frames() - 1) })
+	g.keys.bind(ebiten.KeyP, g.takeScreenshot)
+	g.keys.bind(ebiten.KeyG, func() { g.chart.toggle() })
+	g.keys.bind(ebiten.KeyE, g.exportPNG)
+	g.keys.bind(ebiten.KeyC, g.cycleColors)
+	g.keys.bind(ebiten.KeyDigit0, g.resetView)
+	g.bindSpeedKeys()
+}
+
+// updateReplay advances the replay while it plays
+func (g *Game) updateReplay() {
+	g.keys.update()
+	g.handleWheel()
+	g.handlePan()
+	if g.paused {
+		return
+	}
+
+	g.counter++
+	if g.counter < g.frames {
+		return
+	}
+	g.counter = 0
+	for range g.burst {
+		if g.replay.Frame() == g.replay.Replay.Frames()-1 {
+			g.paused = true
+			return
+		}
+		g.seekReplay(g.replay.Frame() + 1)
+	}
+}
+
+// seekReplay shows frame i of the replay. Moving to the next frame extends
+// the population chart; any other jump restarts it.
+func (g *Game) seekReplay(i int) {
+	i = max(0, min(i, g.replay.Replay.Frames()-1))
+	if i == g.replay.Frame() && g.step == g.replay.Step() {
+		return
+	}
+	next := i == g.replay.Frame()+1
+	g.replay.Seek(i)
+	step := g.replay.Step()
+	fish, sharks := g.world.Count()
+	g.adjustSpeed(fish, sharks)
+	if next && step > g.step {
+		g.step = step
+		g.chart.observe(step, fish, sharks)
+		return
+	}
+
+	g.step = step
+	chart := newPopulationChart(g.world.Width * g.world.Height)
+	chart.visible, chart.timeline = g.chart.visible, g.chart.timeline
+	g.chart = chart
+	g.chart.observe(step, fish, sharks)
+}
+
+// replayStatus returns the HUD status of the replay
+func (g *Game) replayStatus() string {
+	status := "Playing"
+	if g.paused {
+		status = "Paused"
+	}
+	return fmt.Sprintf("Replay %s, frame %d/%d", status, g.replay.Frame()+1, g.replay.Replay.Frames())
+}
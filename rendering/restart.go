@@ -0,0 +1,38 @@
+package rendering
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// bindRestartKeys binds R to restart with the seed of the current run and
+// Shift+R to restart with a new random seed
+func (g *Game) bindRestartKeys() {
+	g.keys.bind(ebiten.KeyR, func() { g.restart(g.seed) })
+	g.keys.bindWith(modShift, ebiten.KeyR, func() { g.restart(rand.Int63()) })
+}
+
+// SetSeed sets the seed the world was created with, which R restarts from
+func (g *Game) SetSeed(seed int64) {
+	g.seed = seed
+}
+
+// restart builds a new world from the original configuration with the
+// given seed through the reset function and continues from its step 0
+func (g *Game) restart(seed int64) {
+	if g.reset == nil || seed == 0 {
+		return
+	}
+	world, err := g.reset(fmt.Appendf(nil, `{"seed": %d}`, seed))
+	if err == nil {
+		err = g.replaceWorld(world, 0)
+	}
+	if err != nil {
+		fmt.Printf("Error restarting: %v\n", err)
+		return
+	}
+	g.seed = seed
+	fmt.Printf("Restarted with seed %d\n", seed)
+}
@@ -0,0 +1,95 @@
+package rendering
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	adaptiveCrowded = 0.5 // Share of the water filled with animals at which adaptive speed is slowest
+	maxUpdateFreq   = 60  // Slowest speed reachable with -, one step a second at 60 FPS
+	maxBurst        = 64  // Fastest speed reachable with +, in steps per frame
+)
+
+// bindSpeedKeys binds + and - (on the main keyboard or the keypad) to
+// change the speed
+func (g *Game) bindSpeedKeys() {
+	for _, mods := range []modifiers{0, modShift} {
+		g.keys.bindWith(mods, ebiten.KeyEqual, g.faster)
+		g.keys.bindWith(mods, ebiten.KeyMinus, g.slower)
+	}
+	g.keys.bind(ebiten.KeyNumpadAdd, g.faster)
+	g.keys.bind(ebiten.KeyNumpadSubtract, g.slower)
+}
+
+// handleWheel changes the speed when the mouse wheel turns with Ctrl held
+func (g *Game) handleWheel() {
+	if !ebiten.IsKeyPressed(ebiten.KeyControl) && !ebiten.IsKeyPressed(ebiten.KeyMeta) {
+		return
+	}
+	if _, dy := ebiten.Wheel(); dy > 0 {
+		g.faster()
+	} else if dy < 0 {
+		g.slower()
+	}
+}
+
+// faster raises the speed a notch: one frame fewer between steps down to
+// every frame, then twice the steps per frame
+func (g *Game) faster() {
+	if g.updateFreq > 1 {
+		g.updateFreq--
+	} else {
+		g.burst = min(maxBurst, g.burst*2)
+	}
+	g.adjustSpeed(g.world.Count())
+}
+
+// slower lowers the speed a notch, undoing faster
+func (g *Game) slower() {
+	if g.burst > 1 {
+		g.burst /= 2
+	} else {
+		g.updateFreq = min(maxUpdateFreq, g.updateFreq+1)
+	}
+	g.adjustSpeed(g.world.Count())
+}
+
+// speedText returns the HUD line showing how fast the world is stepped
+func (g *Game) speedText() string {
+	text := fmt.Sprintf("Update: every %d frames", g.frames)
+	switch {
+	case g.burst > 1 && g.frames == 1:
+		text = fmt.Sprintf("Update: %d steps per frame", g.burst)
+	case g.burst > 1:
+		text = fmt.Sprintf("Update: %d steps every %d frames", g.burst, g.frames)
+	}
+	if g.adaptive {
+		text += " (adaptive)"
+	}
+	return text
+}
+
+// SetAdaptiveSpeed lets the populations set the pace: the window steps
+// every frame while the ocean is nearly empty and slows down to twice the
+// update frequency as animals fill the water, so sparse phases pass quickly
+// and dense ones can be watched.
+func (g *Game) SetAdaptiveSpeed(enabled bool) {
+	g.adaptive = enabled
+	g.adjustSpeed(g.world.Count())
+}
+
+// adjustSpeed sets the number of frames between steps from the populations
+func (g *Game) adjustSpeed(fish, sharks int) {
+	g.frames = g.updateFreq
+	if !g.adaptive {
+		return
+	}
+	water := g.world.Width * g.world.Height
+	if g.world.Terrain != nil {
+		water = g.world.Terrain.WaterCells()
+	}
+	crowding := min(1, float64(fish+sharks)/(adaptiveCrowded*float64(max(1, water))))
+	g.frames = 1 + int(crowding*float64(2*g.updateFreq-1)+0.5)
+}
@@ -0,0 +1,105 @@
+package rendering
+
+import (
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// spriteSet holds the images animals are drawn with instead of squares, by
+// lower-case file name without the extension: fish, shark, and one per
+// predator species that has its own
+type spriteSet map[string]*ebiten.Image
+
+// SetSprites draws animals with the PNG images in dir instead of squares:
+// fish.png and shark.png, which are required, and <species>.png for
+// predator species named in lower case. Sprites face right and are turned
+// to the direction each animal last moved in.
+func (g *Game) SetSprites(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		return err
+	}
+	sprites := spriteSet{}
+	for _, path := range paths {
+		img, err := loadSprite(path)
+		if err != nil {
+			return err
+		}
+		sprites[strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))] = img
+	}
+	for _, name := range []string{"fish", "shark"} {
+		if sprites[name] == nil {
+			return fmt.Errorf("sprites: %s has no %s.png", dir, name)
+		}
+	}
+	g.sprites = sprites
+	return nil
+}
+
+// loadSprite reads a PNG image
+func loadSprite(path string) (*ebiten.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ebiten.NewImageFromImage(img), nil
+}
+
+// sprite returns the image of the animal in a cell, or nil for other cells
+func (s spriteSet) sprite(w *simulation.World, cell simulation.Cell) *ebiten.Image {
+	switch cell.Type {
+	case simulation.Fish:
+		return s["fish"]
+	case simulation.Shark:
+		if img := s[strings.ToLower(w.SpeciesName(cell.Species))]; img != nil && cell.Species > 0 {
+			return img
+		}
+		return s["shark"]
+	}
+	return nil
+}
+
+// drawSprite draws the animal in cell over the square at (x, y), turned to
+// its heading and tinted with c unless animals are colored by kind. It
+// returns false if there are no sprites or the cell holds no animal.
+func (g *Game) drawSprite(screen *ebiten.Image, cell simulation.Cell, x, y float32, c color.RGBA) bool {
+	img := g.sprites.sprite(g.world, cell)
+	if img == nil {
+		return false
+	}
+
+	size := float64(g.cellSize)
+	bounds := img.Bounds()
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Translate(-float64(bounds.Dx())/2, -float64(bounds.Dy())/2)
+	op.GeoM.Scale(size/float64(bounds.Dx()), size/float64(bounds.Dy()))
+	switch cell.Heading {
+	case simulation.HeadingUp:
+		op.GeoM.Rotate(-math.Pi / 2)
+	case simulation.HeadingDown:
+		op.GeoM.Rotate(math.Pi / 2)
+	case simulation.HeadingLeft:
+		op.GeoM.Scale(-1, 1) // Mirrored rather than upside down
+	}
+	op.GeoM.Translate(float64(x)+size/2, float64(y)+size/2)
+	if g.colors.mode != colorByKind {
+		op.ColorScale.ScaleWithColor(c)
+	}
+	screen.DrawImage(img, op)
+	return true
+}
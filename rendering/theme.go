@@ -0,0 +1,113 @@
+package rendering
+
+import (
+	"fmt"
+	"image/color"
+
+	"wa-tor/frame"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Theme holds the colors the window draws around and over the cells, whose
+// colors come from the palette: the text, the background of the chart,
+// legend and settings panel, and the lines drawn over the grid
+type Theme struct {
+	Text       color.RGBA // HUD, legend and panel text
+	Background color.RGBA // Behind the chart, legend and settings panel
+	Marker     color.RGBA // Timeline markers such as parameter changes on the chart
+	Now        color.RGBA // The current step on the chart
+	Border     color.RGBA // Walls around bounded worlds
+	Current    color.RGBA // Ocean current arrows
+}
+
+// DarkTheme suits palettes with a dark ocean, such as all but the light one
+var DarkTheme = Theme{
+	Text:       color.RGBA{255, 255, 255, 255},
+	Background: color.RGBA{0, 0, 0, 200}, // Keeps the grid faintly visible behind the chart
+	Marker:     color.RGBA{255, 200, 0, 255},
+	Now:        color.RGBA{96, 96, 96, 255},
+	Border:     color.RGBA{200, 200, 200, 255},
+	Current:    color.RGBA{255, 255, 255, 160},
+}
+
+// LightTheme suits palettes with a light ocean
+var LightTheme = Theme{
+	Text:       color.RGBA{20, 20, 20, 255},
+	Background: color.RGBA{255, 255, 255, 210},
+	Marker:     color.RGBA{200, 120, 0, 255},
+	Now:        color.RGBA{150, 150, 150, 255},
+	Border:     color.RGBA{60, 60, 60, 255},
+	Current:    color.RGBA{0, 0, 0, 140},
+}
+
+// Colors of the walls around bounded worlds and of the ocean current
+// arrows in the dark theme
+//
+// Deprecated: use the Border and Current colors of the game's Theme.
+var (
+	ColorBorder  = DarkTheme.Border
+	ColorCurrent = DarkTheme.Current
+)
+
+// lightTheme is the name of the palette L switches to from a dark one
+const lightTheme = "light"
+
+// ThemeFor returns the theme that suits the palette: the light theme if its
+// empty cells are light, and the dark theme otherwise
+func ThemeFor(p frame.Palette) Theme {
+	e := p.Empty
+	if 299*int(e.R)+587*int(e.G)+114*int(e.B) > 128*1000 {
+		return LightTheme
+	}
+	return DarkTheme
+}
+
+// SetThemeSwitch lets L switch between the light theme and a dark one.
+// name is the theme the window starts with, and palette returns the cell
+// colors of a theme by name, remembering the choice for later runs.
+func (g *Game) SetThemeSwitch(name string, palette func(name string) frame.Palette) {
+	g.themeName = name
+	g.themeSwitch = palette
+}
+
+// toggleTheme switches from a dark theme to the light one, or from the
+// light theme back to the dark one the window started with (the default if
+// it started light)
+func (g *Game) toggleTheme() {
+	if g.themeSwitch == nil {
+		return
+	}
+	name := lightTheme
+	if g.themeName == lightTheme {
+		name = g.darkTheme
+		if name == "" {
+			name = frame.ThemeNames[0]
+		}
+	} else {
+		g.darkTheme = g.themeName
+	}
+	g.SetPalette(g.themeSwitch(name))
+	g.themeName = name
+	fmt.Printf("Theme: %s\n", name)
+}
+
+// printAt draws text at (x, y) in the text color of the theme. Debug text
+// is always white, so other colors draw it on a layer tinted as it is
+// copied to the screen.
+func (g *Game) printAt(screen *ebiten.Image, text string, x, y int) {
+	if g.theme.Text == DarkTheme.Text {
+		ebitenutil.DebugPrintAt(screen, text, x, y)
+		return
+	}
+	size := screen.Bounds().Size()
+	if g.textLayer == nil || g.textLayer.Bounds().Size() != size {
+		g.textLayer = ebiten.NewImage(size.X, size.Y)
+	}
+	g.textLayer.Clear()
+	ebitenutil.DebugPrintAt(g.textLayer, text, x, y)
+	op := &ebiten.DrawImageOptions{}
+	op.ColorScale.ScaleWithColor(g.theme.Text)
+	screen.DrawImage(g.textLayer, op)
+}
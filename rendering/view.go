@@ -0,0 +1,94 @@
+package rendering
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// view is how far the torus is scrolled: the cell at the top left of the
+// window is (y, x), and cells past the bottom and right edges wrap around to
+// the top and left, so waves crossing an edge can be followed without a seam
+type view struct {
+	y, x           int
+	dragging       bool
+	fromX, fromY   int // Cursor position where the drag started
+	startY, startX int // View when the drag started
+}
+
+// pan returns the scroll of the view, which is always (0, 0) on a bounded
+// world since its edges do not wrap
+func (g *Game) pan() (int, int) {
+	if g.world.Bounded {
+		return 0, 0
+	}
+	return wrap(g.view.y, g.world.Height), wrap(g.view.x, g.world.Width)
+}
+
+// toScreen returns the top left pixel of the world cell (y, x) in the
+// window. On a hex world odd rows are shifted half a cell to the right.
+func (g *Game) toScreen(y, x int) (float32, float32) {
+	py, px := g.pan()
+	sy, sx := wrap(y-py, g.world.Height), wrap(x-px, g.world.Width)
+	left := float32(sx * g.cellSize)
+	if g.world.Hexagonal() && y%2 != 0 {
+		left += float32(g.cellSize) / 2
+	}
+	return left, float32(sy * g.cellSize)
+}
+
+// toWorld returns the world cell shown at row sy and column sx of the
+// window's cells
+func (g *Game) toWorld(sy, sx int) (int, int) {
+	py, px := g.pan()
+	return wrap(sy+py, g.world.Height), wrap(sx+px, g.world.Width)
+}
+
+// cellAt returns the world cell under the pixel (cx, cy) of the window, or
+// false if there is none, taking the shifted rows of a hex world into
+// account like toScreen
+func (g *Game) cellAt(cx, cy int) (int, int, bool) {
+	if cx < 0 || cy < 0 || cy/g.cellSize >= g.world.Height {
+		return 0, 0, false
+	}
+	sy := cy / g.cellSize
+	if wy, _ := g.toWorld(sy, 0); g.world.Hexagonal() && wy%2 != 0 {
+		cx -= g.cellSize / 2
+		if cx < 0 && !g.world.Bounded {
+			cx += g.world.Width * g.cellSize // The half cell wrapped around from the right edge
+		}
+	}
+	if cx < 0 || cx/g.cellSize >= g.world.Width {
+		return 0, 0, false
+	}
+	y, x := g.toWorld(sy, cx/g.cellSize)
+	return y, x, true
+}
+
+// handlePan scrolls the torus while the right mouse button drags it
+func (g *Game) handlePan() {
+	if g.world.Bounded {
+		return
+	}
+	cx, cy := ebiten.CursorPosition()
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
+		g.view.dragging = true
+		g.view.fromX, g.view.fromY = cx, cy
+		g.view.startY, g.view.startX = g.view.y, g.view.x
+	case !ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight):
+		g.view.dragging = false
+	case g.view.dragging:
+		g.view.y = wrap(g.view.startY-(cy-g.view.fromY)/g.cellSize, g.world.Height)
+		g.view.x = wrap(g.view.startX-(cx-g.view.fromX)/g.cellSize, g.world.Width)
+	}
+}
+
+// resetView scrolls back to the world's top left cell
+func (g *Game) resetView() {
+	g.view = view{}
+}
+
+// wrap returns i modulo n in [0, n)
+func wrap(i, n int) int {
+	return (i%n + n) % n
+}
@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+// Info describes a replay file without its frames
+type Info struct {
+	Header    *simulation.SnapshotInfo // World at the first frame, without its grid
+	Frames    int
+	Keyframes int
+	FirstStep int
+	LastStep  int
+	Markers   []analysis.Marker
+	Complete  bool // The recording was closed, rather than cut short by the run dying
+}
+
+// IsReplay reports whether data, such as the first bytes of a file, starts
+// like a replay file
+func IsReplay(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Inspect reads the header of a replay and walks its records, skipping the
+// contents of the frames, so only one record is in memory at a time. A file
+// cut short is not an error, but is not Complete. If the header could be
+// read the info is returned even when the file turns out to be damaged,
+// together with the error.
+func Inspect(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil || string(head) != magic {
+		return nil, errFormat
+	}
+	r := bufio.NewReader(flate.NewReader(bufio.NewReader(f)))
+
+	info := &Info{}
+	for {
+		err := info.skipRecord(r)
+		if errors.Is(err, io.EOF) {
+			info.Complete = true
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil && info.Header == nil {
+			return nil, err
+		}
+		if err != nil {
+			return info, fmt.Errorf("damaged after frame %d: %w", info.Frames, err)
+		}
+	}
+	if info.Header == nil || info.Frames == 0 {
+		return nil, errFormat
+	}
+	return info, nil
+}
+
+// skipRecord reads one record, decoding only the header, the step of frames
+// and markers. Like readRecord it returns io.EOF at the end of the stream and
+// io.ErrUnexpectedEOF if the stream stops in the middle of a record.
+func (info *Info) skipRecord(r *bufio.Reader) error {
+	kind, err := r.ReadByte()
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil {
+		return unexpected(err)
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return unexpected(err)
+	}
+	if n > 1<<30 {
+		return errFormat
+	}
+	body := byteLimiter{&io.LimitedReader{R: r, N: int64(n)}}
+
+	var header *simulation.SnapshotInfo
+	var step uint64
+	var label []byte
+	if kind == recordHeader {
+		if header, err = simulation.InspectSnapshot(body); err != nil {
+			return unexpected(err)
+		}
+	} else {
+		if info.Header == nil {
+			return errFormat
+		}
+		if step, err = binary.ReadUvarint(body); err != nil {
+			return unexpected(err)
+		}
+		if kind == recordKeyframe && body.N != int64(info.Header.Cells) {
+			return errFormat
+		}
+		if kind == recordMarker {
+			label = make([]byte, body.N)
+			if _, err := io.ReadFull(body, label); err != nil {
+				return unexpected(err)
+			}
+		}
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return unexpected(err)
+	}
+	if body.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	switch kind {
+	case recordHeader:
+		info.Header = header
+	case recordKeyframe, recordDiff:
+		if kind == recordKeyframe {
+			info.Keyframes++
+		} else if info.Frames == 0 {
+			return errFormat
+		}
+		if info.Frames == 0 {
+			info.FirstStep = int(step)
+		}
+		info.LastStep = int(step)
+		info.Frames++
+	case recordMarker:
+		info.Markers = append(info.Markers, analysis.Marker{Step: int(step), Label: string(label)})
+	}
+	return nil
+}
+
+// unexpected turns the end of the stream inside a record into
+// io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// byteLimiter is a limited reader that can also read single bytes, for
+// binary.ReadUvarint
+type byteLimiter struct {
+	*io.LimitedReader
+}
+
+func (b byteLimiter) ReadByte() (byte, error) {
+	var c [1]byte
+	_, err := io.ReadFull(b, c[:])
+	return c[0], err
+}
@@ -0,0 +1,205 @@
+package replay
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+// Replay is a recorded run loaded into memory
+type Replay struct {
+	Initial *simulation.Snapshot // World at the first frame, with terrain and parameters
+	Markers []analysis.Marker
+	frames  []recordedFrame
+}
+
+// recordedFrame is one frame as stored: a keyframe or the changes since the
+// previous frame
+type recordedFrame struct {
+	step     int
+	keyframe []byte
+	changes  []change
+}
+
+// change sets one cell
+type change struct {
+	index int
+	cell  byte
+}
+
+// Load reads a replay written by a Recorder. A file cut short because the
+// recording run died is read up to its last complete frame.
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil || string(head) != magic {
+		return nil, errFormat
+	}
+	r := bufio.NewReader(flate.NewReader(bufio.NewReader(f)))
+
+	rp := &Replay{}
+	for {
+		kind, payload, err := readRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := rp.add(kind, payload); err != nil {
+			return nil, err
+		}
+	}
+	if rp.Initial == nil || len(rp.frames) == 0 {
+		return nil, errFormat
+	}
+	return rp, nil
+}
+
+// add decodes one record
+func (rp *Replay) add(kind byte, payload []byte) error {
+	if kind == recordHeader {
+		snap, err := simulation.ParseSnapshot(payload)
+		if err != nil {
+			return err
+		}
+		rp.Initial = snap
+		return nil
+	}
+	if rp.Initial == nil {
+		return errFormat
+	}
+
+	step, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return errFormat
+	}
+	payload = payload[n:]
+	size := rp.Initial.Width * rp.Initial.Height
+
+	switch kind {
+	case recordKeyframe:
+		if len(payload) != size {
+			return errFormat
+		}
+		rp.frames = append(rp.frames, recordedFrame{step: int(step), keyframe: payload})
+	case recordDiff:
+		if len(rp.frames) == 0 {
+			return errFormat
+		}
+		count, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return errFormat
+		}
+		payload = payload[n:]
+		changes := make([]change, 0, min(count, uint64(size)))
+		index := -1
+		for range count {
+			gap, n := binary.Uvarint(payload)
+			if n <= 0 || n >= len(payload) {
+				return errFormat
+			}
+			index += int(gap)
+			if index >= size {
+				return errFormat
+			}
+			changes = append(changes, change{index: index, cell: payload[n]})
+			payload = payload[n+1:]
+		}
+		rp.frames = append(rp.frames, recordedFrame{step: int(step), changes: changes})
+	case recordMarker:
+		rp.Markers = append(rp.Markers, analysis.Marker{Step: int(step), Label: string(payload)})
+	}
+	return nil
+}
+
+// Frames returns the number of recorded frames
+func (rp *Replay) Frames() int {
+	return len(rp.frames)
+}
+
+// Step returns the simulation step of frame i
+func (rp *Replay) Step(i int) int {
+	return rp.frames[i].step
+}
+
+// Find returns the last frame at or before step, or 0 if step precedes
+// the recording
+func (rp *Replay) Find(step int) int {
+	i := sort.Search(len(rp.frames), func(i int) bool { return rp.frames[i].step > step })
+	return max(0, i-1)
+}
+
+// Player shows the frames of a replay in a world that can be rendered
+type Player struct {
+	Replay *Replay
+	World  *simulation.World
+	frame  int
+	cells  []byte
+}
+
+// NewPlayer creates a player positioned at the first frame
+func NewPlayer(rp *Replay) *Player {
+	p := &Player{Replay: rp, World: rp.Initial.World(0), frame: -1}
+	p.Seek(0)
+	return p
+}
+
+// Frame returns the index of the frame shown
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// Step returns the simulation step of the frame shown
+func (p *Player) Step() int {
+	return p.Replay.Step(p.frame)
+}
+
+// Seek shows frame i, clamped to the recorded frames
+func (p *Player) Seek(i int) {
+	i = max(0, min(i, p.Replay.Frames()-1))
+	if i != p.frame+1 || p.cells == nil {
+		// Rebuild from the latest keyframe
+		start := i
+		for p.Replay.frames[start].keyframe == nil {
+			start--
+		}
+		p.cells = append(p.cells[:0], p.Replay.frames[start].keyframe...)
+		for k := start + 1; k <= i; k++ {
+			p.apply(p.Replay.frames[k])
+		}
+	} else {
+		p.apply(p.Replay.frames[i])
+	}
+	p.frame = i
+
+	w := p.World
+	for y := range w.Height {
+		for x := range w.Width {
+			w.SetCell(y, x, decodeCell(p.cells[y*w.Width+x]))
+		}
+	}
+}
+
+// apply updates the cells to the given frame from the one before it
+func (p *Player) apply(f recordedFrame) {
+	if f.keyframe != nil {
+		copy(p.cells, f.keyframe)
+		return
+	}
+	for _, c := range f.changes {
+		p.cells[c.index] = c.cell
+	}
+}
@@ -0,0 +1,215 @@
+// Package replay records runs to .wtr files and plays them back.
+//
+// A .wtr file is the magic "WTR1" followed by a DEFLATE stream of records.
+// The first record holds the initial world as a JSON snapshot, so terrain,
+// parameters and species colors are known on playback. Every record is a
+// kind byte, the payload length as an unsigned varint and the payload:
+//
+//	header:   JSON snapshot
+//	keyframe: step, then width*height cell bytes
+//	diff:     step, count, then count (gap, cell byte) pairs where gap is the
+//	          distance from the previous changed cell index + 1
+//	marker:   step, then the label
+//
+// A cell byte holds the cell type in bits 0-1, algae in bit 2 and the
+// predator species in bits 3-7. Energies and breed times are not recorded.
+package replay
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"wa-tor/simulation"
+)
+
+const magic = "WTR1"
+
+// Record kinds
+const (
+	recordHeader   byte = 0
+	recordKeyframe byte = 1
+	recordDiff     byte = 2
+	recordMarker   byte = 3
+)
+
+// keyframeEvery is the number of frames between keyframes, which bounds
+// the work of seeking
+const keyframeEvery = 100
+
+// Recorder writes every N-th step of a run to a .wtr file as it runs
+type Recorder struct {
+	file   *os.File
+	buf    *bufio.Writer
+	zw     *flate.Writer
+	every  int
+	frames int
+	cells  []byte // Cell bytes of the previous frame
+	err    error
+}
+
+// Create starts a replay file at path, capturing a frame every `every` steps
+func Create(path string, every int) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	buf.WriteString(magic)
+	zw, _ := flate.NewWriter(buf, flate.BestSpeed)
+	return &Recorder{file: f, buf: buf, zw: zw, every: max(every, 1)}, nil
+}
+
+// Capture adds the world as a frame if step falls on the capture interval.
+// The first frame also records the world's terrain and parameters.
+func (r *Recorder) Capture(step int, w *simulation.World) {
+	if step%r.every != 0 || r.err != nil {
+		return
+	}
+
+	cells := encodeCells(w)
+	if r.cells != nil && len(cells) != len(r.cells) {
+		return // A world of another size, such as an opened snapshot
+	}
+	payload := binary.AppendUvarint(nil, uint64(step))
+	switch {
+	case r.cells == nil:
+		header, err := json.Marshal(w.Snapshot(step))
+		if err != nil {
+			r.err = err
+			return
+		}
+		r.write(recordHeader, header)
+		fallthrough
+	case r.frames%keyframeEvery == 0:
+		r.write(recordKeyframe, append(payload, cells...))
+	default:
+		r.write(recordDiff, appendDiff(payload, r.cells, cells))
+	}
+	r.cells = cells
+	r.frames++
+}
+
+// Mark records a labelled marker, such as a parameter change, at step
+func (r *Recorder) Mark(step int, label string) {
+	payload := binary.AppendUvarint(nil, uint64(step))
+	r.write(recordMarker, append(payload, label...))
+}
+
+// Frames returns the number of frames captured so far
+func (r *Recorder) Frames() int {
+	return r.frames
+}
+
+// Flush writes the frames captured so far to disk, so a run that dies
+// leaves a replay that can be played up to this point
+func (r *Recorder) Flush() {
+	if r.err == nil {
+		r.err = r.zw.Flush()
+	}
+	if r.err == nil {
+		r.err = r.buf.Flush()
+	}
+	if r.err == nil {
+		r.file.Sync()
+	}
+}
+
+// Close finishes the file and reports the first error that occurred while
+// recording
+func (r *Recorder) Close() error {
+	if r.err == nil {
+		r.err = r.zw.Close()
+	}
+	if r.err == nil {
+		r.err = r.buf.Flush()
+	}
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// write appends a record to the compressed stream
+func (r *Recorder) write(kind byte, payload []byte) {
+	if r.err != nil {
+		return
+	}
+	head := binary.AppendUvarint([]byte{kind}, uint64(len(payload)))
+	if _, err := r.zw.Write(head); err != nil {
+		r.err = err
+		return
+	}
+	_, r.err = r.zw.Write(payload)
+}
+
+// encodeCells returns the cell bytes of the grid in row-major order
+func encodeCells(w *simulation.World) []byte {
+	cells := make([]byte, 0, w.Width*w.Height)
+	for _, c := range w.Grid {
+		cells = append(cells, encodeCell(c))
+	}
+	return cells
+}
+
+// encodeCell packs the visible state of a cell into a byte
+func encodeCell(c simulation.Cell) byte {
+	b := byte(c.Type) & 0x3
+	if c.Algae {
+		b |= 1 << 2
+	}
+	return b | byte(min(c.Species, 31))<<3
+}
+
+// decodeCell unpacks a cell byte; hidden state such as energy is zero
+func decodeCell(b byte) simulation.Cell {
+	return simulation.Cell{
+		Type:    simulation.CellType(b & 0x3),
+		Algae:   b&(1<<2) != 0,
+		Species: int(b >> 3),
+	}
+}
+
+// appendDiff appends the changes from prev to cells to payload
+func appendDiff(payload, prev, cells []byte) []byte {
+	var changes []byte
+	count, last := 0, -1
+	for i, b := range cells {
+		if prev[i] != b {
+			changes = binary.AppendUvarint(changes, uint64(i-last))
+			changes = append(changes, b)
+			count++
+			last = i
+		}
+	}
+	payload = binary.AppendUvarint(payload, uint64(count))
+	return append(payload, changes...)
+}
+
+// errFormat reports a file that is not a valid replay
+var errFormat = errors.New("not a valid .wtr replay file")
+
+// readRecord reads one record, returning io.EOF at the end of the stream
+// and io.ErrUnexpectedEOF if the stream stops in the middle of a record
+func readRecord(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	if err != nil || n > 1<<30 {
+		return 0, nil, errFormat
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return kind, payload, nil
+}
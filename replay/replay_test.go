@@ -0,0 +1,141 @@
+package replay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wa-tor/simulation"
+)
+
+// record runs a world for steps steps, recording every `every` steps to
+// path, and returns the cell bytes of each recorded frame
+func record(t *testing.T, path string, steps, every int) (*Recorder, [][]byte) {
+	t.Helper()
+	w := simulation.NewWorld(24, 16, 120, 20, 3, 8, 3)
+	r, err := Create(path, every)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames [][]byte
+	for step := range steps {
+		if step > 0 {
+			w.Step(1)
+		}
+		r.Capture(step, w)
+		if step%every == 0 {
+			frames = append(frames, encodeCells(w))
+		}
+	}
+	return r, frames
+}
+
+func TestReplayPlaysBackWhatWasRecorded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.wtr")
+	r, want := record(t, path, 3*keyframeEvery*2, 2)
+	r.Mark(40, "fish=100")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rp, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.Frames() != len(want) || rp.Step(len(want)-1) != 2*(len(want)-1) {
+		t.Fatalf("Load() has %d frames up to step %d, want %d up to step %d",
+			rp.Frames(), rp.Step(rp.Frames()-1), len(want), 2*(len(want)-1))
+	}
+	if len(rp.Markers) != 1 || rp.Markers[0].Step != 40 || rp.Markers[0].Label != "fish=100" {
+		t.Errorf("Markers = %v, want fish=100 at step 40", rp.Markers)
+	}
+
+	p := NewPlayer(rp)
+	check := func(i int) {
+		t.Helper()
+		p.Seek(i)
+		if got := encodeCells(p.World); p.Frame() != i || !bytes.Equal(got, want[i]) {
+			t.Fatalf("frame %d differs from the recording after seeking", i)
+		}
+	}
+	for i := range want {
+		check(i) // Played forward, one diff at a time
+	}
+	for _, i := range []int{0, len(want) - 1, keyframeEvery, keyframeEvery - 1, 3, 2*keyframeEvery + 17, 1} {
+		check(i)
+	}
+	if p.Seek(len(want) + 10); p.Frame() != len(want)-1 {
+		t.Errorf("seeking past the end shows frame %d, want the last, %d", p.Frame(), len(want)-1)
+	}
+
+	info, err := Inspect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Complete || info.Frames != len(want) || info.Keyframes != 3 || info.FirstStep != 0 || info.LastStep != 2*(len(want)-1) || len(info.Markers) != 1 {
+		t.Errorf("Inspect() = %+v, want a complete file of %d frames, 3 of them keyframes, and one marker", info, len(want))
+	}
+}
+
+func TestReplayCutShortKeepsItsCompleteFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.wtr")
+	r, want := record(t, path, 150, 1)
+	r.Flush()
+	flushed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	// The run died right after the flush, or while writing a frame
+	for _, data := range [][]byte{flushed, flushed[:len(flushed)*3/5]} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		rp, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() of a file cut at %d bytes: %v", len(data), err)
+		}
+		p := NewPlayer(rp)
+		p.Seek(rp.Frames() - 1)
+		if rp.Frames() == 0 || !bytes.Equal(encodeCells(p.World), want[rp.Frames()-1]) {
+			t.Errorf("the last of the %d frames loaded differs from the recording", rp.Frames())
+		}
+
+		if len(data) < len(flushed) && rp.Frames() >= len(want) {
+			t.Errorf("Load() of a file cut at %d bytes read all %d frames", len(data), rp.Frames())
+		}
+
+		info, err := Inspect(path)
+		if err != nil || info.Complete || info.Frames != rp.Frames() {
+			t.Errorf("Inspect() = %+v, %v, want an incomplete file of %d frames", info, err, rp.Frames())
+		}
+	}
+}
+
+func TestDamagedReplayIsReported(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.wtr")
+	r, _ := record(t, path, 10, 1)
+	r.write(recordKeyframe, []byte{10, 1, 2, 3}) // Too short for the grid
+	r.Capture(11, simulation.NewWorld(24, 16, 0, 0, 3, 8, 3))
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Inspect(path)
+	if err == nil || !strings.Contains(err.Error(), "damaged after frame 10") || info == nil || info.Frames != 10 {
+		t.Errorf("Inspect() = %+v, %v, want 10 frames and the damage after them", info, err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() of a damaged file succeeded")
+	}
+
+	other := filepath.Join(dir, "other.wtr")
+	os.WriteFile(other, []byte("WTR0 not a replay"), 0o644)
+	if info, err := Inspect(other); err == nil || info != nil {
+		t.Errorf("Inspect() of another file = %+v, %v, want an error", info, err)
+	}
+}
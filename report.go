@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"wa-tor/config"
+	"wa-tor/simulation"
+)
+
+// reportSteps is the number of recent steps whose events a bug report lists
+const reportSteps = 100
+
+// issuesURL is where users are asked to attach bug reports
+const issuesURL = "https://github.com/baldeagle0125/Wa-Tor-Project/issues"
+
+// bugReport follows a run so that a panic or an invariant violation can be
+// saved as a bundle for reproducing it: the configuration with its seed, a
+// snapshot of the world, the events of the last steps and the stack trace
+type bugReport struct {
+	cfg    *config.Config
+	world  *simulation.World
+	step   int
+	recent []reportedStep // Ring of the last reportSteps steps
+	next   int
+}
+
+// reportedStep is what happened during one step, for events.csv
+type reportedStep struct {
+	step   int
+	events simulation.StepEvents
+}
+
+// observe is a step hook that remembers the world and its last events. It
+// must run before any hook that can fail.
+func (r *bugReport) observe(step int, world *simulation.World) {
+	r.world, r.step = world, step
+	if step == 0 {
+		return
+	}
+	s := reportedStep{step: step, events: world.LastStep()}
+	if len(r.recent) < reportSteps {
+		r.recent = append(r.recent, s)
+		return
+	}
+	r.recent[r.next] = s
+	r.next = (r.next + 1) % reportSteps
+}
+
+// verify is a step hook that checks the invariants of the world and, at the
+// first violation, saves a bug report and stops the program. It must run
+// before any hook that changes the world.
+func (r *bugReport) verify(step int, world *simulation.World) {
+	if err := world.CheckInvariants(); err != nil {
+		fmt.Printf("\nInvariant violated at step %d: %v\n", step, err)
+		r.save(fmt.Sprintf("Invariant violated at step %d: %v", step, err), debug.Stack(), step)
+		os.Exit(1)
+	}
+}
+
+// recoverPanic saves a bug report if the program panics, then lets the
+// panic continue. It must be deferred.
+func (r *bugReport) recoverPanic() {
+	if p := recover(); p != nil {
+		r.save(fmt.Sprintf("panic after step %d: %v", r.step, p), debug.Stack(), r.step+1)
+		panic(p)
+	}
+}
+
+// save writes the bundle to a new directory in the snapshot directory and
+// tells the user what to do with it. Running its config.json takes the given
+// number of steps, which reach the failure.
+func (r *bugReport) save(reason string, stack []byte, steps int) {
+	dir := filepath.Join(r.cfg.SnapshotDir, "bugreport-"+time.Now().Format("20060102-150405"))
+	if err := r.write(dir, reason, stack, steps); err != nil {
+		fmt.Printf("Error writing bug report: %v\n", err)
+		return
+	}
+	fmt.Printf("\nA bug report was saved in %s\n", dir)
+	fmt.Printf("Please attach it, zipped, to a new issue at %s\n", issuesURL)
+	fmt.Printf("To reproduce the run headless: wa-tor -config %s\n", filepath.Join(dir, "config.json"))
+}
+
+// write creates the bundle files in dir
+func (r *bugReport) write(dir, reason string, stack []byte, steps int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var info strings.Builder
+	fmt.Fprintf(&info, "%s\n\n", reason)
+	fmt.Fprintf(&info, "Seed: %d, threads: %d, step: %d\n", r.cfg.Seed, r.cfg.Threads, r.step)
+	fmt.Fprintf(&info, "Go: %s, OS: %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&info, "Command line: %s\n\n", strings.Join(os.Args, " "))
+	fmt.Fprintf(&info, "Runs with the same configuration and seed take the same steps, unless cells\n")
+	fmt.Fprintf(&info, "or parameters were edited or a snapshot was opened in the window.\n")
+	fmt.Fprintf(&info, "snapshot.json holds the world at step %d and events.csv the events of the\n", r.step)
+	fmt.Fprintf(&info, "steps before.\n\n")
+	info.Write(stack)
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte(info.String()), 0o644); err != nil {
+		return err
+	}
+
+	cfg := *r.cfg
+	cfg.Steps = steps
+	data, err := json.MarshalIndent(&cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0o644); err != nil {
+		return err
+	}
+
+	if r.world != nil {
+		if err := r.world.Snapshot(r.step).Save(filepath.Join(dir, "snapshot.json")); err != nil {
+			return err
+		}
+	}
+	return r.writeEvents(filepath.Join(dir, "events.csv"))
+}
+
+// writeEvents writes the events of the recent steps, oldest first
+func (r *bugReport) writeEvents(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"step", "fish", "sharks", "fish_born", "sharks_born", "fish_eaten", "fish_starved", "fish_aged", "sharks_starved"})
+	for i := range r.recent {
+		s := r.recent[(r.next+i)%len(r.recent)]
+		e := s.events
+		row := []int{s.step, e.Fish, e.Sharks, e.FishBorn, e.SharksBorn, e.FishEaten, e.FishStarved, e.FishAged, e.SharksStarved}
+		record := make([]string, len(row))
+		for j, v := range row {
+			record[j] = strconv.Itoa(v)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
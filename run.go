@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/frame"
+	"wa-tor/mapgen"
+	"wa-tor/rendering"
+	"wa-tor/script"
+	"wa-tor/server"
+	"wa-tor/simulation"
+)
+
+// runSimulation builds the world of the configuration with the hooks of
+// its outputs and runs it in the window, headless or behind the server
+func runSimulation(cfg *config.Config) error {
+	if err := rendering.CheckHUD(cfg.HUD); err != nil {
+		return err
+	}
+
+	// Create world with configuration parameters, or continue a checkpoint
+	var world *simulation.World
+	start := 0
+	var err error
+	if cfg.Resume != "" {
+		world, start, err = resumeWorld(cfg)
+	} else {
+		world, err = newWorld(cfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Collect per-step and end-of-run callbacks
+	timeline := &analysis.Timeline{}
+	hooks := &runHooks{}
+	report := &bugReport{cfg: cfg, world: world}
+	defer report.recoverPanic()
+	hooks.onStep(report.observe)
+	if cfg.Verify {
+		hooks.onStep(report.verify)
+	}
+	hooks.onStep(scheduleHook(cfg, timeline))
+	hooks.onStep(snapshotHook(cfg))
+	hooks.onStep(pngHook(cfg))
+	hooks.onStep(hashHook(cfg))
+	hooks.onStep(checkpointHook(cfg, start))
+	oscillationHooks(hooks)
+	if cfg.DumpOnExit != "" {
+		dumpHooks(cfg, hooks)
+	}
+	if strings.HasSuffix(cfg.Record, ".wtr") {
+		if err := replayHooks(cfg, timeline, hooks); err != nil {
+			return err
+		}
+	} else if cfg.Record != "" {
+		if err := gifHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.BasinReport != "" {
+		if err := basinHooks(cfg, world, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.MeanField != "" {
+		if err := meanFieldHooks(cfg, world, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.Traits != "" {
+		if err := traitHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.Deaths != "" {
+		if err := deathHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.Cohorts != "" {
+		if err := cohortHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.Events != "" {
+		if err := eventHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	if cfg.Chase != "" {
+		if err := chaseHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	var ring *frame.RingLog
+	if cfg.RingLog > 0 {
+		if ring, err = ringLogHooks(cfg, hooks); err != nil {
+			return err
+		}
+	}
+	afterStep := hooks.afterStep
+	afterStep(start, world)
+
+	// Serve over HTTP instead of opening a window, until interrupted
+	interrupt := catchInterrupt()
+	if cfg.Serve != "" {
+		srv := server.New(world, cfg.Threads, cfg.Steps, cfg.UpdateFreq, extinctionRule(cfg))
+		srv.SetAfterStep(afterStep)
+		srv.SetEngine(cfg.StepEngine())
+		srv.SetReset(resetFunc(cfg))
+		backpressure, _ := server.ParseBackpressure(cfg.Backpressure)
+		srv.SetBackpressure(backpressure)
+		srv.SetPalette(palette(cfg))
+		srv.SetProfiling(cfg.Pprof)
+		if err := srv.Run(cfg.Serve, interrupt); err != nil {
+			log.Fatal(err)
+		}
+		hooks.finish()
+		return nil
+	}
+
+	// Run in headless mode if steps is specified
+	if cfg.Steps > 0 {
+		runHeadless(world, cfg, start, afterStep, interrupt)
+		hooks.finish()
+		return nil
+	}
+
+	return runGame(cfg, world, start, timeline, hooks, ring, interrupt)
+}
+
+// newWorld creates a world on the terrain selected by the configuration
+func newWorld(cfg *config.Config) (*simulation.World, error) {
+	var terrain *simulation.Terrain
+	var animals []simulation.CellType
+	var err error
+	if cfg.InitImage != "" {
+		terrain, animals, err = mapgen.LoadImage(cfg.InitImage)
+	} else {
+		terrain, err = buildTerrain(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	topology, _ := simulation.ParseTopology(cfg.Topology)
+	if _, hex := topology.(simulation.Hex); hex && cfg.Wrap && terrain.Height%2 != 0 {
+		return nil, fmt.Errorf("a wrapped hex world needs an even height, not %d rows", terrain.Height)
+	}
+	if cfg.CurrentFile != "" {
+		if err := mapgen.LoadCurrent(cfg.CurrentFile, terrain); err != nil {
+			return nil, err
+		}
+	} else if cfg.CurrentStrength > 0 {
+		angle := cfg.CurrentDir * math.Pi / 180
+		terrain.SetCurrent(cfg.CurrentStrength*math.Cos(angle), cfg.CurrentStrength*math.Sin(angle))
+	}
+	// The animals of an image take the place of -fish and -sharks
+	numFish, numShark := cfg.NumFish, cfg.NumShark
+	if animals != nil {
+		numFish, numShark = 0, 0
+	} else if terrain.WaterCells() < cfg.Animals() {
+		return nil, fmt.Errorf("too many entities for the %d water cells of the map", terrain.WaterCells())
+	}
+	world := simulation.NewWorldOnTerrain(
+		cfg.Seed, terrain,
+		numFish, numShark,
+		cfg.FishBreed, cfg.SharkBreed, cfg.Starve,
+	)
+	for i, t := range animals {
+		if t == simulation.Fish || t == simulation.Shark {
+			world.AddAnimal(i/world.Width, i%world.Width, t)
+		}
+	}
+	world.Bounded = !cfg.Wrap
+	world.TieBreak = cfg.TieBreakPolicy()
+	world.Order = cfg.MoveOrder()
+	world.Topology = topology
+	world.TileSize = cfg.TileSize
+	world.SharkGain = cfg.SharkGain
+	if cfg.FishStarve > 0 {
+		world.SetAlgae(cfg.FishStarve, cfg.Algae)
+	}
+	if cfg.FishAge > 0 {
+		world.SetFishAge(cfg.FishAge)
+	}
+	for _, sc := range cfg.Species {
+		s, err := sc.Species()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := world.AddSpecies(s, sc.Count); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Mutation > 0 {
+		world.SetMutation(cfg.Mutation)
+	}
+	world.Schooling = cfg.School
+	world.Flee = cfg.Flee
+	world.Rules = cfg.Rules()
+	if cfg.Script != "" {
+		rules, err := script.Load(cfg.Script, world.Rules)
+		if err != nil {
+			return nil, err
+		}
+		world.Rules = rules
+	}
+	if cfg.EggDelay > 0 {
+		world.SetEggs(cfg.EggDelay, cfg.EggViability)
+	}
+	return world, nil
+}
+
+// resumeWorld continues the run from the -resume checkpoint and returns the
+// step it was saved at. Rule sets the checkpoint does not keep, such as a
+// -script, come from the configuration, as do the tiles.
+func resumeWorld(cfg *config.Config) (*simulation.World, int, error) {
+	snap, err := simulation.LoadCheckpoint(cfg.Resume)
+	if err != nil {
+		return nil, 0, err
+	}
+	world, err := snap.Continue()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", cfg.Resume, err)
+	}
+	world.TileSize = cfg.TileSize
+	if world.Rules == nil {
+		world.Rules = cfg.Rules()
+	}
+	if cfg.Script != "" {
+		rules, err := script.Load(cfg.Script, world.Rules)
+		if err != nil {
+			return nil, 0, err
+		}
+		world.Rules = rules
+	}
+	fmt.Printf("Resuming from step %d of %s\n", snap.Step, cfg.Resume)
+	return world, snap.Step, nil
+}
+
+// buildTerrain creates or loads the terrain selected with -map
+func buildTerrain(cfg *config.Config) (*simulation.Terrain, error) {
+	if path := cfg.MapFile(); path != "" {
+		return mapgen.LoadASCII(path)
+	}
+
+	switch cfg.Map {
+	case "perlin":
+		return mapgen.Perlin(cfg.GridSize, cfg.GridSize, mapgen.PerlinOptions{
+			Seed:         cfg.Seed,
+			LandFraction: cfg.Land,
+			ReefFraction: cfg.Reef,
+			FeatureSize:  cfg.Smooth,
+		}), nil
+	case "maze":
+		return mapgen.Maze(cfg.GridSize, cfg.GridSize, mapgen.MazeOptions{
+			Seed:          cfg.Seed,
+			BasinSize:     cfg.Basin,
+			CorridorWidth: cfg.Corridor,
+		}), nil
+	default:
+		return simulation.NewOcean(cfg.GridSize, cfg.GridSize), nil
+	}
+}
@@ -0,0 +1,193 @@
+// Package runner executes headless simulations and aggregates their outcomes.
+package runner
+
+import (
+	"math"
+	"sync"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+// Result summarizes a single headless run
+type Result struct {
+	Seed         int64
+	Steps        int // Steps actually completed
+	FishExtinct  int // Step at which fish became extinct, or -1
+	SharkExtinct int // Step at which sharks became extinct, or -1
+	FinalFish    int
+	FinalSharks  int
+	FishEaten    int
+	MeanFish     float64 // Mean populations over the steps completed
+	MeanSharks   float64
+	MinFish      int // Smallest and largest populations from the start on
+	MaxFish      int
+	MinSharks    int
+	MaxSharks    int
+	Period       float64 // Oscillation period of the fish, or 0 (see analysis.OscillationPeriod)
+	SharkPeriod  float64 // Oscillation period of the sharks, or 0
+	PhaseLag     float64 // Chronons by which the sharks follow the fish, or -1 (see analysis.PhaseLag)
+	Equilibrium  int     // Step at which the populations were found stable, or -1 (see Options.Equilibrium)
+}
+
+// Options controls a headless run
+type Options struct {
+	MaxSteps   int
+	Threads    int
+	Extinction analysis.ExtinctionRule
+
+	// Stop once the populations oscillate stably under this rule (nil=run
+	// on until MaxSteps or an extinction)
+	Equilibrium *analysis.EquilibriumRule
+}
+
+// Run steps the world until MaxSteps is reached, either species becomes
+// extinct under the extinction rule or, if asked, the populations reach an
+// equilibrium
+func Run(w *simulation.World, seed int64, opt Options) Result {
+	r := Result{Seed: seed, Equilibrium: -1}
+	ext := analysis.NewExtinctionTracker(opt.Extinction)
+	fish, sharks := w.Count()
+	ext.Observe(0, fish, sharks)
+	var eq *analysis.EquilibriumTracker
+	if opt.Equilibrium != nil {
+		eq = analysis.NewEquilibriumTracker(*opt.Equilibrium)
+		eq.Observe(0, fish, sharks)
+	}
+	r.MinFish, r.MaxFish, r.MinSharks, r.MaxSharks = fish, fish, sharks, sharks
+	series := make([]float64, 0, opt.MaxSteps)
+	sharkSeries := make([]float64, 0, opt.MaxSteps)
+
+	for r.Steps < opt.MaxSteps && !ext.Ended() && r.Equilibrium < 0 {
+		r.FishEaten += w.Step(opt.Threads)
+		r.Steps++
+		fish, sharks = w.Count()
+		ext.Observe(r.Steps, fish, sharks)
+		if eq != nil && eq.Observe(r.Steps, fish, sharks) {
+			r.Equilibrium = eq.Reached
+		}
+		r.MinFish, r.MaxFish = min(r.MinFish, fish), max(r.MaxFish, fish)
+		r.MinSharks, r.MaxSharks = min(r.MinSharks, sharks), max(r.MaxSharks, sharks)
+		series = append(series, float64(fish))
+		sharkSeries = append(sharkSeries, float64(sharks))
+	}
+
+	r.FinalFish, r.FinalSharks = fish, sharks
+	if r.Steps > 0 {
+		var sumFish, sumSharks float64
+		for i := range series {
+			sumFish += series[i]
+			sumSharks += sharkSeries[i]
+		}
+		r.MeanFish = sumFish / float64(r.Steps)
+		r.MeanSharks = sumSharks / float64(r.Steps)
+	}
+	osc := analysis.AnalyzeOscillations(series, sharkSeries)
+	r.Period, r.SharkPeriod, r.PhaseLag = osc.FishPeriod, osc.SharkPeriod, osc.Lag
+	r.FishExtinct, r.SharkExtinct = ext.FishExtinct, ext.SharkExtinct
+	return r
+}
+
+// Stat is the mean and standard deviation of a sample
+type Stat struct {
+	Mean   float64
+	StdDev float64
+	N      int
+}
+
+// NewStat computes the mean and sample standard deviation of values
+func NewStat(values []float64) Stat {
+	s := Stat{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+
+	if s.N > 1 {
+		var sq float64
+		for _, v := range values {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sq / float64(s.N-1))
+	}
+	return s
+}
+
+// Summary aggregates the outcomes of several runs
+type Summary struct {
+	Runs         int
+	FishExtinct  int // Runs in which fish died out
+	SharkExtinct int // Runs in which sharks died out
+	FishExtTime  Stat
+	SharkExtTime Stat
+	FinalFish    Stat
+	FinalSharks  Stat
+	FishEaten    Stat
+	MinFish      Stat // Smallest and largest populations of each run
+	MaxFish      Stat
+	MinSharks    Stat
+	MaxSharks    Stat
+}
+
+// Summarize aggregates a set of run results
+func Summarize(results []Result) Summary {
+	s := Summary{Runs: len(results)}
+	var fishExt, sharkExt, fish, sharks, eaten []float64
+	var minFish, maxFish, minSharks, maxSharks []float64
+
+	for _, r := range results {
+		if r.FishExtinct >= 0 {
+			s.FishExtinct++
+			fishExt = append(fishExt, float64(r.FishExtinct))
+		}
+		if r.SharkExtinct >= 0 {
+			s.SharkExtinct++
+			sharkExt = append(sharkExt, float64(r.SharkExtinct))
+		}
+		fish = append(fish, float64(r.FinalFish))
+		sharks = append(sharks, float64(r.FinalSharks))
+		eaten = append(eaten, float64(r.FishEaten))
+		minFish = append(minFish, float64(r.MinFish))
+		maxFish = append(maxFish, float64(r.MaxFish))
+		minSharks = append(minSharks, float64(r.MinSharks))
+		maxSharks = append(maxSharks, float64(r.MaxSharks))
+	}
+
+	s.FishExtTime = NewStat(fishExt)
+	s.SharkExtTime = NewStat(sharkExt)
+	s.FinalFish = NewStat(fish)
+	s.FinalSharks = NewStat(sharks)
+	s.FishEaten = NewStat(eaten)
+	s.MinFish, s.MaxFish = NewStat(minFish), NewStat(maxFish)
+	s.MinSharks, s.MaxSharks = NewStat(minSharks), NewStat(maxSharks)
+	return s
+}
+
+// RunAll calls run for indices 0..n-1 using up to workers goroutines and
+// returns the results in index order
+func RunAll(n, workers int, run func(i int) Result) []Result {
+	results := make([]Result, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = run(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
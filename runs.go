@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wa-tor/config"
+	"wa-tor/runner"
+)
+
+// runMany runs -runs headless simulations of the configured world with the
+// seeds -seed, -seed+1, ..., up to -threads of them at a time, each in a
+// world of its own stepped by one thread. It prints how each run ended and
+// the mean and standard deviation of the outcomes over all of them.
+func runMany(cfg *config.Config) error {
+	opt := runner.Options{MaxSteps: cfg.Steps, Threads: 1, Extinction: extinctionRule(cfg)}
+	if cfg.Equilibrium {
+		rule := equilibriumRule(cfg)
+		opt.Equilibrium = &rule
+	}
+	fmt.Printf("Runs: %d, Seeds: %d..%d, %d at a time\n\n", cfg.Runs, cfg.Seed, cfg.Seed+int64(cfg.Runs-1), min(cfg.Threads, cfg.Runs))
+
+	startTime := time.Now()
+	errs := make([]error, cfg.Runs)
+	results := runner.RunAll(cfg.Runs, cfg.Threads, func(i int) runner.Result {
+		c := *cfg
+		c.Seed = cfg.Seed + int64(i)
+		world, err := newWorld(&c)
+		if err != nil {
+			errs[i] = fmt.Errorf("seed %d: %w", c.Seed, err)
+			return runner.Result{Seed: c.Seed}
+		}
+		return runner.Run(world, c.Seed, opt)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		fmt.Printf("Seed %d: %s\n", r.Seed, describeOutcome(r, 0))
+	}
+	printSummary(runner.Summarize(results), 0)
+	fmt.Printf("Total execution time: %v\n", time.Since(startTime))
+	return nil
+}
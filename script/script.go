@@ -0,0 +1,232 @@
+// Package script lets users change how fish and sharks move without
+// recompiling, with a rule file read at startup (-script). A rule file
+// scores every cell an animal could take, and the animal takes the best of
+// them:
+//
+//	# Fish keep away from sharks, sharks chase fish
+//	fish = 1 - 3 * sharks
+//	shark = 10 * prey + fish - stay
+//
+// Rules are expressions as in analysis.ParseExpr over the variables listed
+// in Variables. A Starlark script (.star) instead defines fish and shark
+// functions that are given the animal and the cells it could take and
+// return the one it moves to, see LoadStarlark. Breeding and starving are
+// left to the rule set the script is layered on.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+// Variables are what a rule knows about the moving animal and the cell it
+// scores. fish, sharks and land count the 8 cells around the scored cell,
+// leaving out the moving animal; cells beyond the walls of a bounded world
+// count as land.
+var Variables = []string{
+	"energy", // Energy of the animal after this chronon's hunger
+	"age",    // Age of the animal in chronons
+	"breed",  // Chronons since the animal last bred
+	"stay",   // 1 for the animal's own cell, 0 for a neighbour
+	"dx",     // Column offset of the cell: -1, 0 or 1
+	"dy",     // Row offset of the cell: -1, 0 or 1
+	"prey",   // 1 if the cell holds a fish a shark can eat
+	"algae",  // 1 if algae grows in the cell
+	"fish",   // Fish around the cell
+	"sharks", // Predators around the cell
+	"land",   // Land around the cell
+}
+
+// Rules moves animals by the decisions of a script and leaves everything
+// else to the RuleSet it embeds. An animal whose kind has no rule moves as
+// the embedded rules say.
+type Rules struct {
+	simulation.RuleSet
+	fish, shark decider
+}
+
+// decider picks the move of an animal at (y, x) among its own cell and the
+// free cells, as returned by Turn.Free
+type decider interface {
+	decide(t *simulation.Turn, y, x int, animal simulation.Cell, cells [][]int) simulation.Move
+}
+
+// Load reads a script from a file: a Starlark script if its name ends in
+// .star, see LoadStarlark, and a rule file otherwise, see Parse
+func Load(path string, base simulation.RuleSet) (*Rules, error) {
+	if strings.HasSuffix(path, ".star") {
+		return LoadStarlark(path, base)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parse(bufio.NewScanner(f), base, path)
+}
+
+// Parse reads a script of "fish = expression" and "shark = expression"
+// lines, where blank lines and those starting with # are ignored, and
+// layers it on base
+func Parse(src string, base simulation.RuleSet) (*Rules, error) {
+	return parse(bufio.NewScanner(strings.NewReader(src)), base, "script")
+}
+
+// parse reads the lines of a script, reporting errors as name:line
+func parse(lines *bufio.Scanner, base simulation.RuleSet, name string) (*Rules, error) {
+	r := &Rules{RuleSet: base}
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, src, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"fish = ...\" or \"shark = ...\"", name, n)
+		}
+		e, err := analysis.ParseExpr(strings.TrimSpace(src), Variables)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		switch strings.TrimSpace(kind) {
+		case "fish":
+			r.fish = scoreRule{e}
+		case "shark":
+			r.shark = scoreRule{e}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown rule %q (fish or shark)", name, n, strings.TrimSpace(kind))
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if r.fish == nil && r.shark == nil {
+		return nil, fmt.Errorf("%s: no fish or shark rule", name)
+	}
+	return r, nil
+}
+
+// MoveShark lets the embedded rules age the shark and decide whether it
+// starves for want of fish, then moves it to the best scoring fish, empty
+// cell or its own. A hungry shark that chooses not to eat starves.
+func (r *Rules) MoveShark(t *simulation.Turn, y, x int) (simulation.Move, bool) {
+	in, alive := r.RuleSet.MoveShark(t, y, x)
+	if !alive || r.shark == nil {
+		return in, alive
+	}
+	cells := append(t.Free(y, x, simulation.Fish), t.Free(y, x, simulation.Empty)...)
+	in = r.shark.decide(t, y, x, in.Animal, cells)
+	in.Eats = t.Cell(in.To).Type == simulation.Fish && in.To != in.From
+	return in, in.Eats || in.Animal.Energy > 0
+}
+
+// MoveFish lets the embedded rules age the fish and decide whether it dies
+// of old age, then moves it to the best scoring empty cell or its own
+func (r *Rules) MoveFish(t *simulation.Turn, y, x int) (simulation.Move, bool) {
+	in, alive := r.RuleSet.MoveFish(t, y, x)
+	if !alive || r.fish == nil {
+		return in, alive
+	}
+	return r.fish.decide(t, y, x, in.Animal, t.Free(y, x, simulation.Empty)), true
+}
+
+// scoreRule is the expression of a rule file for one kind of animal
+type scoreRule struct {
+	expr *analysis.Expr
+}
+
+// decide scores the animal's own cell and the free cells and returns the
+// move to the best one, breaking ties at random. Cells scoring NaN are
+// never taken.
+func (r scoreRule) decide(t *simulation.Turn, y, x int, animal simulation.Cell, cells [][]int) simulation.Move {
+	w := t.World
+	var best [][]int
+	top := math.Inf(-1)
+	for _, c := range append([][]int{{y, x, 0, 0}}, cells...) {
+		s := r.expr.Eval(func(name string) float64 { return variable(t, animal, y, x, c, name) })
+		if s > top {
+			best, top = best[:0], s
+		}
+		if s == top {
+			best = append(best, c)
+		}
+	}
+
+	stay := simulation.Move{From: y*w.Width + x, To: y*w.Width + x, Animal: animal}
+	if len(best) == 0 {
+		return stay
+	}
+	c := best[t.Rand().Intn(len(best))]
+	if c[2] == 0 && c[3] == 0 {
+		return stay
+	}
+	return t.To(y, x, animal, c)
+}
+
+// variable returns the value of a rule variable for the animal at (y, x)
+// scoring cell c, given as {y, x, dy, dx}. The scored cell is seen as
+// Turn.Free sees it, so sharks moving after the fish find them where they
+// settled.
+func variable(t *simulation.Turn, animal simulation.Cell, y, x int, c []int, name string) float64 {
+	w := t.World
+	cell := t.Cell(c[0]*w.Width + c[1])
+	switch name {
+	case "energy":
+		return float64(animal.Energy)
+	case "age":
+		return float64(animal.Age)
+	case "breed":
+		return float64(animal.BreedTime)
+	case "stay":
+		return flag(c[2] == 0 && c[3] == 0)
+	case "dx":
+		return float64(c[3])
+	case "dy":
+		return float64(c[2])
+	case "prey":
+		return flag(animal.Type == simulation.Shark && cell.Type == simulation.Fish)
+	case "algae":
+		return flag(cell.Algae)
+	case "fish":
+		return float64(around(w, y, x, c, simulation.Fish))
+	case "sharks":
+		return float64(around(w, y, x, c, simulation.Shark))
+	case "land":
+		return float64(around(w, y, x, c, simulation.Barrier))
+	}
+	return 0
+}
+
+// around counts the cells of cellType among the 8 around cell c, leaving
+// out the animal's own cell at (y, x)
+func around(w *simulation.World, y, x int, c []int, cellType simulation.CellType) int {
+	n := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			ny, nx, ok := w.Neighbor(c[0], c[1], dy, dx)
+			switch {
+			case dy == 0 && dx == 0, ok && ny == y && nx == x:
+			case !ok:
+				n += int(flag(cellType == simulation.Barrier))
+			case w.Grid[ny*w.Width+nx].Type == cellType:
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// flag returns 1 for true and 0 for false
+func flag(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"wa-tor/simulation"
+)
+
+func TestParseReportsMistakesByLine(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{"# only a comment\n", "no fish or shark rule"},
+		{"fish 1", "script:1: expected"},
+		{"\nturtle = 1", "script:2: unknown rule \"turtle\""},
+		{"fish = 1\nshark = speed", "script:2: expression \"speed\": unknown variable \"speed\""},
+		{"shark = prey >", "script:1: expression \"prey >\": unexpected end"},
+	} {
+		if _, err := Parse(tc.src, simulation.Classic{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", tc.src, err, tc.want)
+		}
+	}
+
+	r, err := Parse("# Fish keep away from sharks\n\nfish = 1 - 3 * sharks\nshark = 10 * prey + fish - stay", simulation.Classic{})
+	if err != nil || r.fish == nil || r.shark == nil {
+		t.Errorf("Parse() = %+v, %v, want both rules", r, err)
+	}
+}
+
+func TestAnimalsTakeTheBestScoringCell(t *testing.T) {
+	// The fish scores dx, so it moves right, and the shark has no fish
+	// around any cell, so staying scores best
+	rules, err := Parse("fish = dx\nshark = 2 * stay + fish", simulation.Classic{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := simulation.NewSeededWorld(1, 5, 5, 0, 0, 10, 10, 3)
+	w.Bounded = true
+	w.TieBreak = simulation.TieFirstCome
+	w.Rules = rules
+	w.SetCell(2, 2, simulation.Cell{Type: simulation.Fish})
+	w.SetCell(0, 0, simulation.Cell{Type: simulation.Shark, Energy: 3})
+
+	w.Step(1)
+
+	if got := w.Cell(2, 3).Type; got != simulation.Fish {
+		t.Errorf("cell (2, 3) = %v, want fish", got)
+	}
+	if got := w.Cell(0, 0).Type; got != simulation.Shark {
+		t.Errorf("cell (0, 0) = %v, want the shark to stay", got)
+	}
+}
+
+func TestSharkFindsFishWhereTheySettled(t *testing.T) {
+	// The fish moves first, next to the shark, which eats it there
+	rules, err := Parse("shark = 10 * prey", simulation.Classic{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := simulation.NewSeededWorld(1, 3, 1, 0, 0, 10, 10, 3)
+	w.Bounded = true
+	w.TieBreak = simulation.TieFirstCome
+	w.Order = simulation.OrderFishFirst
+	w.Rules = rules
+	w.SetCell(0, 0, simulation.Cell{Type: simulation.Fish})
+	w.SetCell(0, 2, simulation.Cell{Type: simulation.Shark, Energy: 3})
+
+	if eaten := w.Step(1); eaten != 1 {
+		t.Errorf("Step() = %d fish eaten, want 1", eaten)
+	}
+	for x, want := range []simulation.CellType{simulation.Empty, simulation.Shark, simulation.Empty} {
+		if got := w.Cell(0, x).Type; got != want {
+			t.Errorf("cell (0, %d) = %v, want %v", x, got, want)
+		}
+	}
+}
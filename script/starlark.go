@@ -0,0 +1,163 @@
+//go:build starlark
+
+package script
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"wa-tor/simulation"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// starlarkMaxSteps bounds the computation of one decision, so a function
+// stuck in a loop stops its animal instead of the run
+const starlarkMaxSteps = 1_000_000
+
+// turnKey holds the turn of the moving animal in the thread of a call
+const turnKey = "turn"
+
+// starlarkBuiltins are predeclared in every Starlark script besides the
+// Starlark language's own
+var starlarkBuiltins = starlark.StringDict{
+	"random": starlark.NewBuiltin("random", starlarkRandom),
+}
+
+// LoadStarlark reads a Starlark script defining the functions
+//
+//	def fish(me, cells): ...
+//	def shark(me, cells): ...
+//
+// either of which may be left out. me is the moving animal, with the fields
+// energy, age and breed, and cells are its own cell followed by those it can
+// move to, with the other Variables as fields: stay, prey and algae as
+// booleans and the rest as integers. A function returns the element of
+// cells the animal moves to, or None to stay. random() returns a number in
+// [0, 1) from the world's generator, so seeded runs stay reproducible.
+// Scripts keep no state between calls: their globals are frozen once the
+// script has run.
+func LoadStarlark(path string, base simulation.RuleSet) (*Rules, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	thread := &starlark.Thread{Name: path}
+	globals, err := starlark.ExecFile(thread, path, src, starlarkBuiltins)
+	if err != nil {
+		return nil, starlarkError(err)
+	}
+	globals.Freeze()
+
+	r := &Rules{RuleSet: base}
+	for _, kind := range []string{"fish", "shark"} {
+		v, ok := globals[kind]
+		if !ok {
+			continue
+		}
+		fn, ok := v.(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is a %s, not a function", path, kind, v.Type())
+		}
+		rule := &starlarkRule{path: path, fn: fn}
+		if kind == "fish" {
+			r.fish = rule
+		} else {
+			r.shark = rule
+		}
+	}
+	if r.fish == nil && r.shark == nil {
+		return nil, fmt.Errorf("%s: no fish or shark function", path)
+	}
+	return r, nil
+}
+
+// starlarkRule is the function of a Starlark script for one kind of animal
+type starlarkRule struct {
+	path   string
+	fn     starlark.Callable
+	failed sync.Once // Reports the first call that went wrong
+}
+
+// decide calls the function with the animal and its cells and returns the
+// move to the cell it picks. An animal whose call fails stays where it is.
+func (r *starlarkRule) decide(t *simulation.Turn, y, x int, animal simulation.Cell, cells [][]int) simulation.Move {
+	w := t.World
+	stay := simulation.Move{From: y*w.Width + x, To: y*w.Width + x, Animal: animal}
+	options := append([][]int{{y, x, 0, 0}}, cells...)
+	values := make([]starlark.Value, len(options))
+	for i, c := range options {
+		fields := starlark.StringDict{}
+		for _, name := range Variables[3:] { // Those of the cell
+			v := variable(t, animal, y, x, c, name)
+			switch name {
+			case "stay", "prey", "algae":
+				fields[name] = starlark.Bool(v != 0)
+			default:
+				fields[name] = starlark.MakeInt(int(v))
+			}
+		}
+		values[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, fields)
+	}
+	me := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"energy": starlark.MakeInt(animal.Energy),
+		"age":    starlark.MakeInt(animal.Age),
+		"breed":  starlark.MakeInt(animal.BreedTime),
+	})
+
+	thread := &starlark.Thread{Name: r.path}
+	thread.SetLocal(turnKey, t)
+	thread.SetMaxExecutionSteps(starlarkMaxSteps)
+	picked, err := starlark.Call(thread, r.fn, starlark.Tuple{me, starlark.NewList(values)}, nil)
+	if err != nil {
+		r.fail(starlarkError(err))
+		return stay
+	}
+	if picked == starlark.None {
+		return stay
+	}
+	for i, v := range values {
+		if v == picked {
+			if i == 0 {
+				return stay
+			}
+			return t.To(y, x, animal, options[i])
+		}
+	}
+	r.fail(fmt.Errorf("%s returned %s, not one of its cells", r.fn.Name(), picked.String()))
+	return stay
+}
+
+// fail reports the first call of the function that went wrong. Later ones
+// only leave their animals where they are, so a broken script does not
+// flood the output every chronon.
+func (r *starlarkRule) fail(err error) {
+	r.failed.Do(func() {
+		log.Printf("%s: %v; animals it cannot move stay where they are", r.path, err)
+	})
+}
+
+// starlarkRandom is the random() builtin of scripts
+func starlarkRandom(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	t, ok := thread.Local(turnKey).(*simulation.Turn)
+	if !ok {
+		return nil, errors.New("only available while animals move")
+	}
+	return starlark.Float(t.Rand().Float64()), nil
+}
+
+// starlarkError adds the call stack to errors raised by a script
+func starlarkError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
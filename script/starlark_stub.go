@@ -0,0 +1,15 @@
+//go:build !starlark
+
+package script
+
+import (
+	"fmt"
+
+	"wa-tor/simulation"
+)
+
+// LoadStarlark reports that this build cannot run Starlark scripts. Build
+// with -tags starlark, after go get go.starlark.net, to run them.
+func LoadStarlark(path string, base simulation.RuleSet) (*Rules, error) {
+	return nil, fmt.Errorf("%s: Starlark scripts need a build with -tags starlark", path)
+}
@@ -0,0 +1,73 @@
+//go:build starlark
+
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wa-tor/simulation"
+)
+
+// loadSource writes src to a script file and loads it
+func loadSource(t *testing.T, src string) (*Rules, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.star")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path, simulation.Classic{})
+}
+
+func TestLoadStarlarkReportsMistakes(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{"# only a comment\n", "no fish or shark function"},
+		{"fish = 1\n", "fish is a int, not a function"},
+		{"def fish(me, cells)\n", "rules.star:1"},
+		{"x = random()\n", "only available while animals move"},
+	} {
+		if _, err := loadSource(t, tc.src); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) = %v, want an error containing %q", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestStarlarkAnimalsTakeTheCellTheyPick(t *testing.T) {
+	// The fish picks the cell to its right, and the shark, with no fish
+	// around, returns None and stays
+	rules, err := loadSource(t, `
+def fish(me, cells):
+    for c in cells:
+        if c.dx == 1 and c.dy == 0:
+            return c
+    return cells[0]
+
+def shark(me, cells):
+    for c in cells:
+        if c.prey:
+            return c
+    return None
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := simulation.NewSeededWorld(1, 5, 5, 0, 0, 10, 10, 3)
+	w.Bounded = true
+	w.TieBreak = simulation.TieFirstCome
+	w.Rules = rules
+	w.SetCell(2, 2, simulation.Cell{Type: simulation.Fish})
+	w.SetCell(0, 0, simulation.Cell{Type: simulation.Shark, Energy: 3})
+
+	w.Step(1)
+
+	if got := w.Cell(2, 3).Type; got != simulation.Fish {
+		t.Errorf("cell (2, 3) = %v, want fish", got)
+	}
+	if got := w.Cell(0, 0).Type; got != simulation.Shark {
+		t.Errorf("cell (0, 0) = %v, want the shark to stay", got)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"wa-tor/simulation"
+)
+
+// Status is the summary returned by the control endpoints
+type Status struct {
+	Step      int    `json:"step"`
+	Paused    bool   `json:"paused"`
+	Ended     string `json:"ended,omitempty"`
+	Fish      int    `json:"fish"`
+	Sharks    int    `json:"sharks"`
+	FishEaten int    `json:"fishEaten"`
+	Engine    string `json:"engine"`
+	Held      bool   `json:"held,omitempty"`    // A stream client holds the steps back
+	Dropped   int    `json:"dropped,omitempty"` // Stream messages clients missed
+}
+
+// State is the full response of GET /state
+type State struct {
+	Status
+	Width  int                     `json:"width"`
+	Height int                     `json:"height"`
+	Grid   [][]simulation.CellType `json:"grid"`
+}
+
+// status summarizes the run; callers must hold mu
+func (s *Server) status() Status {
+	fish, sharks := s.world.Count()
+	return Status{
+		Step:      s.step,
+		Paused:    s.paused,
+		Ended:     s.endReason(),
+		Fish:      fish,
+		Sharks:    sharks,
+		FishEaten: s.fishEaten,
+		Engine:    string(s.engine),
+		Held:      s.held(),
+		Dropped:   s.dropped,
+	}
+}
+
+// handleState returns the statistics and the cell types of the whole grid
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	state := State{
+		Status: s.status(),
+		Width:  s.world.Width,
+		Height: s.world.Height,
+		Grid:   make([][]simulation.CellType, s.world.Height),
+	}
+	for i := range state.Grid {
+		state.Grid[i] = make([]simulation.CellType, s.world.Width)
+		for j := range state.Grid[i] {
+			state.Grid[i][j] = s.world.Cell(i, j).Type
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, state)
+}
+
+// maxStepsPerRequest bounds ?n= of POST /step, which holds the world, and
+// with it the stream and the other endpoints, until its steps are taken
+const maxStepsPerRequest = 10000
+
+// handleStep advances the simulation by ?n= steps (default 1), even while
+// paused
+func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxStepsPerRequest {
+			http.Error(w, fmt.Sprintf("n must be an integer between 1 and %d", maxStepsPerRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	for range n {
+		if !s.advance() {
+			break
+		}
+	}
+	status := s.status()
+	s.mu.Unlock()
+
+	writeJSON(w, status)
+}
+
+// handlePause toggles the background stepping, or sets it with
+// ?paused=true|false
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v := r.URL.Query().Get("paused"); v != "" {
+		paused, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "paused must be true or false", http.StatusBadRequest)
+			return
+		}
+		s.paused = paused
+	} else {
+		s.paused = !s.paused
+	}
+
+	writeJSON(w, s.status())
+}
+
+// handleReset replaces the world with one built from the posted JSON
+// parameters (same keys as the command-line flags)
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if s.reset == nil {
+		http.Error(w, "reset is not supported", http.StatusNotImplemented)
+		return
+	}
+
+	params, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	world, err := s.reset(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setWorld(world)
+	writeJSON(w, s.status())
+}
+
+// handleEngine continues the run with the engine named by ?name=, or the
+// next one if it is not given
+func (s *Server) handleEngine(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	engine := s.engine.Next()
+	if name := r.URL.Query().Get("name"); name != "" {
+		var err error
+		if engine, err = simulation.ParseEngine(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	s.engine = engine
+	writeJSON(w, s.status())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
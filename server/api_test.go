@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+func TestStepRejectsTooManySteps(t *testing.T) {
+	s := New(simulation.NewWorld(16, 16, 60, 10, 3, 8, 3), 1, 0, 1, analysis.DefaultExtinction)
+	h := s.Handler()
+	for _, tc := range []struct {
+		n    string
+		code int
+		step int
+	}{
+		{"0", http.StatusBadRequest, 0},
+		{"1000000000", http.StatusBadRequest, 0},
+		{"10001", http.StatusBadRequest, 0},
+		{"3", http.StatusOK, 3},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/step?n="+tc.n, nil))
+		if rec.Code != tc.code || s.step != tc.step {
+			t.Errorf("POST /step?n=%s = %d at step %d, want %d at step %d", tc.n, rec.Code, s.step, tc.code, tc.step)
+		}
+	}
+}
+
+func TestProfilesAreOptIn(t *testing.T) {
+	s := New(simulation.NewWorld(16, 16, 60, 10, 3, 8, 3), 1, 0, 1, analysis.DefaultExtinction)
+	for _, on := range []bool{false, true} {
+		s.SetProfiling(on)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/cmdline", nil))
+		if served := rec.Code == http.StatusOK; served != on {
+			t.Errorf("with profiling %v, GET /debug/pprof/cmdline = %d", on, rec.Code)
+		}
+	}
+}
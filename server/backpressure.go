@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Backpressure policies, applied when a stream client cannot keep up with
+// the steps
+const (
+	Disconnect = "disconnect" // Drop the client
+	Drop       = "drop"       // Skip messages, then resync the client with a keyframe
+	Sample     = "sample"     // Publish every Nth step while a client lags, then drop
+	Pause      = "pause"      // Hold the simulation until the client catches up
+)
+
+// Backpressure is the policy for stream clients that fall behind
+type Backpressure struct {
+	Policy string
+	Every  int // Steps per message while a client lags, for Sample
+}
+
+// ParseBackpressure parses disconnect, drop, pause or sample=N
+func ParseBackpressure(s string) (Backpressure, error) {
+	name, every, found := strings.Cut(s, "=")
+	switch name {
+	case Disconnect, Drop, Pause:
+		if !found {
+			return Backpressure{Policy: name}, nil
+		}
+	case Sample:
+		n, err := strconv.Atoi(every)
+		if found && err == nil && n >= 2 {
+			return Backpressure{Policy: Sample, Every: n}, nil
+		}
+		return Backpressure{}, fmt.Errorf("sample needs a rate of at least 2, e.g. sample=4")
+	}
+	return Backpressure{}, fmt.Errorf("unknown backpressure policy %q (expected disconnect, drop, pause or sample=N)", s)
+}
+
+// SetBackpressure sets what the stream does when a client falls behind
+func (s *Server) SetBackpressure(b Backpressure) {
+	s.backpressure = b
+}
+
+// lagging reports whether a stream client has at least n messages queued;
+// callers must hold mu
+func (s *Server) lagging(n int) bool {
+	for client := range s.clients {
+		if !client.stale && len(client.msgs) >= n {
+			return true
+		}
+	}
+	return false
+}
+
+// held reports whether the Pause policy keeps the simulation from stepping;
+// callers must hold mu
+func (s *Server) held() bool {
+	return s.backpressure.Policy == Pause && s.lagging(streamBuffer)
+}
+
+// sampled reports whether the Sample policy skips publishing the current
+// step; callers must hold mu
+func (s *Server) sampled() bool {
+	return s.backpressure.Policy == Sample && s.step%s.backpressure.Every != 0 && s.lagging(streamBuffer/2)
+}
@@ -0,0 +1,275 @@
+// Package server runs the simulation behind an HTTP interface so external
+// tools and notebooks can observe it without the native window.
+package server
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/frame"
+	"wa-tor/simulation"
+)
+
+//go:embed dashboard.html
+var dashboard []byte
+
+// Server steps a world in the background and serves its state over HTTP
+type Server struct {
+	mu           sync.Mutex
+	world        *simulation.World
+	threads      int
+	engine       simulation.Engine
+	maxSteps     int
+	updateFreq   int
+	step         int
+	fishEaten    int
+	paused       bool
+	rule         analysis.ExtinctionRule
+	extinction   *analysis.ExtinctionTracker
+	afterStep    func(step int, world *simulation.World)
+	reset        func(params []byte) (*simulation.World, error)
+	clients      map[*streamClient]bool // WebSocket stream subscribers
+	cells        []byte                 // Cell types last published to them
+	backpressure Backpressure
+	palette      frame.Palette
+	dashboard    []byte // The dashboard page in the colors of palette
+	dropped      int    // Stream messages clients missed
+	profiling    bool   // Serve the net/http/pprof profiles
+}
+
+// New creates a Server for the given world
+func New(world *simulation.World, threads, maxSteps, updateFreq int, extinction analysis.ExtinctionRule) *Server {
+	s := &Server{
+		threads:      threads,
+		engine:       simulation.DefaultEngine(threads),
+		maxSteps:     maxSteps,
+		updateFreq:   updateFreq,
+		rule:         extinction,
+		backpressure: Backpressure{Policy: Disconnect},
+		palette:      frame.DefaultPalette,
+		dashboard:    dashboard,
+	}
+	s.setWorld(world)
+	return s
+}
+
+// setWorld starts a new run on world; callers must hold mu once serving
+func (s *Server) setWorld(world *simulation.World) {
+	s.world = world
+	s.step = 0
+	s.fishEaten = 0
+	s.extinction = analysis.NewExtinctionTracker(s.rule)
+	fish, sharks := world.Count()
+	s.extinction.Observe(0, fish, sharks)
+	s.publish(true)
+}
+
+// SetAfterStep registers a function called after every simulation step
+func (s *Server) SetAfterStep(fn func(step int, world *simulation.World)) {
+	s.afterStep = fn
+}
+
+// SetReset registers the function that builds a new world from the JSON
+// parameters posted to /reset
+func (s *Server) SetReset(fn func(params []byte) (*simulation.World, error)) {
+	s.reset = fn
+}
+
+// SetEngine sets the engine the following steps are taken with, which
+// POST /engine changes
+func (s *Server) SetEngine(e simulation.Engine) {
+	s.engine = e
+}
+
+// SetPalette sets the colors of /frame.png and the dashboard
+func (s *Server) SetPalette(p frame.Palette) {
+	s.palette = p
+	s.dashboard = bytes.Replace(dashboard, dashboardColors(frame.DefaultPalette), dashboardColors(p), 1)
+}
+
+// SetProfiling sets whether the handler serves the net/http/pprof profiles
+// under /debug/pprof/. They expose the command line and heap and let any
+// client start CPU-heavy profiles, so they are off unless asked for.
+func (s *Server) SetProfiling(on bool) {
+	s.profiling = on
+}
+
+// dashboardColors formats the colors of the cell types as the JavaScript
+// array the dashboard paints them with
+func dashboardColors(p frame.Palette) []byte {
+	var js []string
+	for _, c := range []color.RGBA{p.Empty, p.Fish, p.Shark, p.Barrier} {
+		js = append(js, fmt.Sprintf("[%d, %d, %d]", c.R, c.G, c.B))
+	}
+	return []byte("[" + strings.Join(js, ", ") + "]")
+}
+
+// Handler returns the HTTP routes served by the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /frame.png", s.handleFramePNG)
+	mux.HandleFunc("GET /frame.npy", s.handleFrameNPY)
+	mux.HandleFunc("GET /state", s.handleState)
+	mux.HandleFunc("POST /step", s.handleStep)
+	mux.HandleFunc("POST /pause", s.handlePause)
+	mux.HandleFunc("POST /reset", s.handleReset)
+	mux.HandleFunc("POST /engine", s.handleEngine)
+	mux.HandleFunc("GET /stream", s.handleStream)
+	mux.HandleFunc("GET /{$}", s.handleDashboard)
+
+	if !s.profiling {
+		return mux
+	}
+	// Profiles of the server for go tool pprof
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// shutdownTimeout is how long Run waits for open requests, such as
+// streams, once it is stopped
+const shutdownTimeout = 2 * time.Second
+
+// Run starts stepping the simulation and serves HTTP on addr until the
+// listener fails or stop is closed. Once stopped, it stops stepping, shuts
+// the listener down and returns nil, so the caller can finish the run.
+func (s *Server) Run(addr string, stop <-chan struct{}) error {
+	stepped := make(chan struct{})
+	go s.loop(stop, stepped)
+	srv := &http.Server{Addr: addr, Handler: s.Handler()}
+	failed := make(chan error, 1)
+	go func() { failed <- srv.ListenAndServe() }()
+	fmt.Printf("Serving on %s\n", addr)
+
+	select {
+	case err := <-failed:
+		return err
+	case <-stop:
+	}
+	fmt.Println("Shutting down the server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); errors.Is(err, context.DeadlineExceeded) {
+		srv.Close() // Streams never finish by themselves
+	}
+	<-stepped
+	return nil
+}
+
+// loop advances the simulation at roughly the same pace as the window,
+// one step every updateFreq frames at 60 frames per second, until stop is
+// closed; then it closes stepped
+func (s *Server) loop(stop <-chan struct{}, stepped chan<- struct{}) {
+	defer close(stepped)
+	ticker := time.NewTicker(time.Second / 60 * time.Duration(s.updateFreq))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		if !s.paused {
+			s.advance()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// endReason returns why the run has ended, or "" if it can continue;
+// callers must hold mu
+func (s *Server) endReason() string {
+	if s.maxSteps > 0 && s.step >= s.maxSteps {
+		return "Max steps reached"
+	}
+	return s.extinction.Reason()
+}
+
+// advance performs one step unless the run has ended or a stream client
+// holds it back, and reports whether a step was taken; callers must hold mu
+func (s *Server) advance() bool {
+	if s.endReason() != "" || s.held() {
+		return false
+	}
+
+	s.fishEaten += s.world.StepWith(s.engine, s.threads)
+	s.step++
+	fish, sharks := s.world.Count()
+	s.extinction.Observe(s.step, fish, sharks)
+	if s.afterStep != nil {
+		s.afterStep(s.step, s.world)
+	}
+	s.publish(false)
+	return true
+}
+
+// handleDashboard serves a page that renders the stream in the browser
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(s.dashboard); err != nil {
+		log.Printf("dashboard: %v", err)
+	}
+}
+
+func (s *Server) handleFramePNG(w http.ResponseWriter, r *http.Request) {
+	scale := 1
+	if v := r.URL.Query().Get("scale"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 32 {
+			http.Error(w, "scale must be an integer between 1 and 32", http.StatusBadRequest)
+			return
+		}
+		scale = n
+	}
+
+	s.mu.Lock()
+	img := frame.Image(s.world, s.palette, scale)
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("frame.png: %v", err)
+	}
+}
+
+func (s *Server) handleFrameNPY(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	s.mu.Lock()
+	err := frame.WriteNPY(&buf, s.world)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("frame.npy: %v", err)
+	}
+}
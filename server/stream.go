@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"log"
+	"net/http"
+)
+
+// Stream message kinds. Every message is one kind byte followed by a raw
+// DEFLATE payload of unsigned varints and one-byte cell types:
+//
+//	keyframe: step, fish, sharks, width, height, then width*height types
+//	diff:     step, fish, sharks, count, then count (gap, type) pairs where
+//	          gap is the distance from the previous changed cell index + 1
+const (
+	streamKeyframe byte = 0
+	streamDiff     byte = 1
+)
+
+// streamBuffer is the number of messages queued per client before the
+// backpressure policy applies
+const streamBuffer = 64
+
+// streamClient is a WebSocket stream subscriber
+type streamClient struct {
+	msgs  chan []byte
+	stale bool // Messages were dropped, so the next one must be a keyframe
+}
+
+// handleStream upgrades to a WebSocket and sends a keyframe followed by the
+// cell changes of every step
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if err := checkWebSocketRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws, err := acceptWebSocket(w, r)
+	if err != nil {
+		log.Printf("stream: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	client := &streamClient{msgs: make(chan []byte, streamBuffer)}
+	s.mu.Lock()
+	s.subscribe(client)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.unsubscribe(client)
+		s.mu.Unlock()
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		ws.discardReads()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case msg, ok := <-client.msgs:
+			if !ok {
+				return // Dropped for falling behind
+			}
+			if err := ws.WriteBinary(msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// subscribe adds a stream client and queues a keyframe for it; callers must
+// hold mu. The cells last published stay the base of the next diff for the
+// other clients, so a client joining while the Sample policy skips steps is
+// resynced with a keyframe at the next publish.
+func (s *Server) subscribe(client *streamClient) {
+	if s.clients == nil {
+		s.clients = make(map[*streamClient]bool)
+	}
+	s.clients[client] = true
+	msg, cells := s.encodeKeyframe()
+	client.stale = s.cells != nil && !bytes.Equal(cells, s.cells)
+	client.msgs <- msg
+}
+
+// unsubscribe removes a stream client if it is still registered; callers
+// must hold mu
+func (s *Server) unsubscribe(client *streamClient) {
+	if s.clients[client] {
+		delete(s.clients, client)
+		close(client.msgs)
+	}
+}
+
+// publish sends the current grid to all stream clients, as a keyframe or as
+// the changes since the last published grid. Clients that missed messages
+// get a keyframe once they have room for it, and full clients are handled by
+// the backpressure policy. Callers must hold mu.
+func (s *Server) publish(keyframe bool) {
+	if len(s.clients) == 0 {
+		s.cells = nil
+		return
+	}
+	if !keyframe && s.sampled() {
+		return
+	}
+
+	var msg, resync []byte
+	if keyframe || s.cells == nil {
+		msg, s.cells = s.encodeKeyframe()
+		resync = msg
+	} else {
+		msg = s.encodeDiff(s.cells)
+	}
+	for client := range s.clients {
+		next := msg
+		if client.stale {
+			if resync == nil {
+				resync, _ = s.encodeKeyframe()
+			}
+			next = resync
+		}
+		select {
+		case client.msgs <- next:
+			client.stale = false
+		default:
+			s.dropped++
+			if s.backpressure.Policy == Disconnect {
+				s.unsubscribe(client)
+			} else {
+				client.stale = true
+			}
+		}
+	}
+}
+
+// encodeKeyframe encodes the whole grid and returns it along with the cell
+// types it contains; callers must hold mu
+func (s *Server) encodeKeyframe() ([]byte, []byte) {
+	w := s.world
+	cells := make([]byte, 0, w.Width*w.Height)
+	for _, cell := range w.Grid {
+		cells = append(cells, byte(cell.Type))
+	}
+
+	payload := s.streamHeader()
+	payload = binary.AppendUvarint(payload, uint64(w.Width))
+	payload = binary.AppendUvarint(payload, uint64(w.Height))
+	payload = append(payload, cells...)
+	return compressMessage(streamKeyframe, payload), cells
+}
+
+// encodeDiff encodes the cells whose type differs from prev and updates prev
+// to the current grid; callers must hold mu
+func (s *Server) encodeDiff(prev []byte) []byte {
+	var changes []byte
+	count, last := 0, -1
+	for idx, cell := range s.world.Grid {
+		if t := byte(cell.Type); prev[idx] != t {
+			changes = binary.AppendUvarint(changes, uint64(idx-last))
+			changes = append(changes, t)
+			prev[idx] = t
+			count++
+			last = idx
+		}
+	}
+
+	payload := s.streamHeader()
+	payload = binary.AppendUvarint(payload, uint64(count))
+	payload = append(payload, changes...)
+	return compressMessage(streamDiff, payload)
+}
+
+// streamHeader starts a payload with the step and populations; callers must
+// hold mu
+func (s *Server) streamHeader() []byte {
+	fish, sharks := s.world.Count()
+	header := binary.AppendUvarint(nil, uint64(s.step))
+	header = binary.AppendUvarint(header, uint64(fish))
+	return binary.AppendUvarint(header, uint64(sharks))
+}
+
+// compressMessage prefixes the kind byte to the DEFLATE-compressed payload
+func compressMessage(kind byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(kind)
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	zw.Write(payload)
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"wa-tor/analysis"
+	"wa-tor/simulation"
+)
+
+// streamView is the grid a stream client rebuilds from its messages
+type streamView struct {
+	step  int
+	cells []byte
+}
+
+// apply decodes a stream message into the view
+func (v *streamView) apply(t *testing.T, msg []byte) {
+	t.Helper()
+	payload, err := io.ReadAll(flate.NewReader(bytes.NewReader(msg[1:])))
+	if err != nil {
+		t.Fatalf("inflating message: %v", err)
+	}
+	r := bytes.NewReader(payload)
+	next := func() int {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatalf("reading message: %v", err)
+		}
+		return int(n)
+	}
+	v.step = next()
+	next() // Fish
+	next() // Sharks
+
+	switch msg[0] {
+	case streamKeyframe:
+		width, height := next(), next()
+		v.cells = make([]byte, width*height)
+		io.ReadFull(r, v.cells)
+	case streamDiff:
+		if v.cells == nil {
+			t.Fatal("diff before the first keyframe")
+		}
+		idx := -1
+		for range next() {
+			idx += next()
+			v.cells[idx], _ = r.ReadByte()
+		}
+	}
+}
+
+// drain applies the messages queued for the client
+func (v *streamView) drain(t *testing.T, client *streamClient) {
+	t.Helper()
+	for {
+		select {
+		case msg := <-client.msgs:
+			v.apply(t, msg)
+		default:
+			return
+		}
+	}
+}
+
+// grid returns the cell types of the world as the stream encodes them
+func grid(w *simulation.World) []byte {
+	cells := make([]byte, 0, len(w.Grid))
+	for _, cell := range w.Grid {
+		cells = append(cells, byte(cell.Type))
+	}
+	return cells
+}
+
+func TestClientJoiningASampledStreamStaysInSync(t *testing.T) {
+	world := simulation.NewWorld(32, 32, 300, 60, 3, 8, 3)
+	s := New(world, 1, 0, 1, analysis.DefaultExtinction)
+	s.SetBackpressure(Backpressure{Policy: Sample, Every: 4})
+
+	// A client that never reads makes the stream sample every 4th step
+	slow := &streamClient{msgs: make(chan []byte, streamBuffer)}
+	s.subscribe(slow)
+	for len(slow.msgs) < streamBuffer/2 {
+		slow.msgs <- nil
+	}
+
+	fast := &streamClient{msgs: make(chan []byte, streamBuffer)}
+	s.subscribe(fast)
+	var fastView, lateView streamView
+	fastView.drain(t, fast)
+
+	s.advance() // Step 1 is not published
+	late := &streamClient{msgs: make(chan []byte, streamBuffer)}
+	s.subscribe(late)
+	lateView.drain(t, late)
+	if !bytes.Equal(lateView.cells, grid(world)) {
+		t.Fatal("the joining client did not get the current grid")
+	}
+
+	for range 11 {
+		s.advance()
+		fastView.drain(t, fast)
+		lateView.drain(t, late)
+	}
+	want := grid(world)
+	for name, v := range map[string]streamView{"existing": fastView, "joining": lateView} {
+		if v.step != 12 {
+			t.Errorf("%s client is at step %d, want 12", name, v.step)
+		}
+		if !bytes.Equal(v.cells, want) {
+			t.Errorf("%s client's grid differs from the world", name)
+		}
+	}
+}
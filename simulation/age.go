@@ -0,0 +1,18 @@
+package simulation
+
+// SetFishAge makes fish die after living maxAge chronons. Fish that have
+// not aged yet, such as the initial population, get a random age below
+// maxAge so they do not all die in the same chronon. A maxAge of 0 lets
+// fish live forever again.
+func (w *World) SetFishAge(maxAge int) {
+	w.FishAge = maxAge
+	if maxAge <= 0 {
+		return
+	}
+	for i := range w.Grid {
+		cell := &w.Grid[i]
+		if cell.Type == Fish && cell.Age == 0 {
+			cell.Age = w.rng.Intn(maxAge)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package simulation
+
+// SetAlgae adds algae as a third trophic level: algae grows on empty cells
+// with the given chance per chronon and fish starve after fishStarve chronons
+// without eating it. Every empty water cell starts covered in algae and every
+// fish starts fed. A fishStarve of 0 removes the layer again.
+func (w *World) SetAlgae(fishStarve int, growth float64) {
+	w.FishStarve = fishStarve
+	w.AlgaeGrowth = growth
+	for i := range w.Grid {
+		cell := &w.Grid[i]
+		cell.Algae = fishStarve > 0 && cell.Type == Empty
+		if cell.Type == Fish {
+			cell.Energy = fishStarve
+		}
+	}
+	w.stats = nil
+	w.forgetAnimals()
+}
+
+// CountAlgae returns the number of cells covered in algae
+func (w *World) CountAlgae() int {
+	n := 0
+	for _, cell := range w.Grid {
+		if cell.Algae {
+			n++
+		}
+	}
+	return n
+}
+
+// growAlgae seeds algae on empty cells of the new grid
+func (w *World) growAlgae(newGrid []Cell) {
+	for i := range newGrid {
+		cell := &newGrid[i]
+		if cell.Type == Empty && !cell.Algae && w.rng.Float64() < w.AlgaeGrowth {
+			cell.Algae = true
+		}
+	}
+}
+
+// withAlgae returns the cells of the list that are covered in algae
+func (w *World) withAlgae(cells [][]int, grid []Cell) [][]int {
+	var result [][]int
+	for _, c := range cells {
+		if grid[c[0]*w.Width+c[1]].Algae {
+			result = append(result, c)
+		}
+	}
+	return result
+}
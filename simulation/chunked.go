@@ -0,0 +1,335 @@
+package simulation
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ChunkSize is the side in cells of the chunks a ChunkedWorld stores
+const ChunkSize = 64
+
+// ChunkedWorld is a world for huge, mostly empty oceans. It stores only the
+// ChunkSize×ChunkSize chunks that contain animals, so its memory grows with
+// the area the animals cover instead of with its size. It follows the rules
+// of a World with random tie-breaks, stepped by one thread, for fish and
+// sharks only: there is no terrain, algae, old age, species or genetics.
+type ChunkedWorld struct {
+	Width       int
+	Height      int
+	FishBreed   int
+	SharkBreed  int
+	SharkStarve int
+	SharkGain   int  // See World.SharkGain
+	Bounded     bool // See World.Bounded
+	chunks      map[chunkKey]*chunk
+	next        map[chunkKey]*chunk // Chunks Step builds, empty between steps
+	free        []*chunk            // Emptied chunks, kept for reuse
+	rng         Rand
+}
+
+// chunkKey is the position of a chunk in chunks down and across the world
+type chunkKey struct{ cy, cx int }
+
+// chunk is a block of cells in row-major order
+type chunk struct {
+	cells   [ChunkSize * ChunkSize]Cell
+	moved   [ChunkSize * ChunkSize]bool // Settled in the step being built
+	animals int
+}
+
+// NewChunkedWorld creates an empty seeded ocean; Scatter adds the animals
+func NewChunkedWorld(seed int64, width, height, fishBreed, sharkBreed, sharkStarve int) *ChunkedWorld {
+	return &ChunkedWorld{
+		Width:       width,
+		Height:      height,
+		FishBreed:   fishBreed,
+		SharkBreed:  sharkBreed,
+		SharkStarve: sharkStarve,
+		chunks:      map[chunkKey]*chunk{},
+		next:        map[chunkKey]*chunk{},
+		rng:         newPCGRand(seed),
+	}
+}
+
+// SetRand replaces the world's random number generator, see World.SetRand
+func (w *ChunkedWorld) SetRand(r Rand) {
+	w.rng = r
+}
+
+// Scatter places fish and sharks at random empty cells of the rectangle of
+// the given size whose top left cell is (y, x), with random breeding timers
+// as in NewSeededWorld. The rectangle must have room for them.
+func (w *ChunkedWorld) Scatter(y, x, height, width, numFish, numShark int) {
+	place := func(c Cell, breed int) {
+		for {
+			cy, cx := y+w.rng.Intn(height), x+w.rng.Intn(width)
+			if w.Cell(cy, cx).Type == Empty {
+				c.BreedTime = w.rng.Intn(breed)
+				w.SetCell(cy, cx, c)
+				return
+			}
+		}
+	}
+	for range numFish {
+		place(Cell{Type: Fish}, w.FishBreed)
+	}
+	for range numShark {
+		place(Cell{Type: Shark, Energy: w.SharkStarve}, w.SharkBreed)
+	}
+}
+
+// Cell returns the contents of the cell at (y, x)
+func (w *ChunkedWorld) Cell(y, x int) Cell {
+	key, i := locate(y, x)
+	if c := w.chunks[key]; c != nil {
+		return c.cells[i]
+	}
+	return Cell{}
+}
+
+// SetCell replaces the contents of the cell at (y, x), storing its chunk
+// while it holds animals
+func (w *ChunkedWorld) SetCell(y, x int, cell Cell) {
+	key, i := locate(y, x)
+	c := w.put(w.chunks, key, i, cell)
+	if c.animals == 0 {
+		delete(w.chunks, key)
+		w.recycle(c)
+	}
+}
+
+// Count returns the number of fish and sharks
+func (w *ChunkedWorld) Count() (int, int) {
+	fish, sharks := 0, 0
+	for _, c := range w.chunks {
+		for _, cell := range c.cells {
+			switch cell.Type {
+			case Fish:
+				fish++
+			case Shark:
+				sharks++
+			}
+		}
+	}
+	return fish, sharks
+}
+
+// Chunks returns the number of chunks stored
+func (w *ChunkedWorld) Chunks() int {
+	return len(w.chunks)
+}
+
+// Step performs one simulation step and returns the number of fish eaten.
+// Like World.Step, it moves the sharks and then the fish in a random order,
+// each picking a cell and then taking it unless one earlier in the order
+// took it first.
+func (w *ChunkedWorld) Step() int {
+	entities := w.entities()
+	eaten := w.moveAll(entities, Shark)
+	eaten += w.moveAll(entities, Fish)
+
+	for key, c := range w.chunks {
+		delete(w.chunks, key)
+		w.recycle(c)
+	}
+	w.chunks, w.next = w.next, w.chunks
+	for _, c := range w.chunks {
+		clear(c.moved[:])
+	}
+	// Keep only as many emptied chunks as the next step can need
+	if n := len(w.chunks); len(w.free) > n {
+		clear(w.free[n:])
+		w.free = w.free[:n]
+	}
+	return eaten
+}
+
+// entities lists the animals in random order
+func (w *ChunkedWorld) entities() []entity {
+	keys := make([]chunkKey, 0, len(w.chunks))
+	for key := range w.chunks {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b chunkKey) int {
+		return cmp.Or(cmp.Compare(a.cy, b.cy), cmp.Compare(a.cx, b.cx))
+	})
+
+	var entities []entity
+	for _, key := range keys {
+		for i, cell := range w.chunks[key].cells {
+			if cell.Type == Fish || cell.Type == Shark {
+				entities = append(entities, entity{key.cy*ChunkSize + i/ChunkSize, key.cx*ChunkSize + i%ChunkSize, cell.Type})
+			}
+		}
+	}
+	for i := len(entities) - 1; i > 0; i-- {
+		j := w.rng.Intn(i + 1)
+		entities[i], entities[j] = entities[j], entities[i]
+	}
+	return entities
+}
+
+// chunkIntent is the move an animal proposes, see intent
+type chunkIntent struct {
+	from, to [2]int // Cells as (y, x)
+	animal   Cell
+	eats     bool
+	heading  int
+}
+
+// moveAll moves the animals of one kind among entities in the two phases
+// of World.moveAll and returns the number of fish eaten
+func (w *ChunkedWorld) moveAll(entities []entity, kind CellType) int {
+	var intents []chunkIntent
+	for _, e := range entities {
+		if e.t != kind || w.moved(e.y, e.x) {
+			continue // Eaten, or of the other kind
+		}
+		animal := w.Cell(e.y, e.x)
+		animal.BreedTime++
+		animal.Age++
+		in := chunkIntent{from: [2]int{e.y, e.x}, to: [2]int{e.y, e.x}, animal: animal}
+		if kind == Shark {
+			in.animal.Energy--
+			if fish := w.adjacent(e.y, e.x, Fish); len(fish) > 0 {
+				in.to, in.heading = w.pick(fish)
+				in.eats = true
+			} else if in.animal.Energy <= 0 {
+				continue // Starved
+			} else if empty := w.adjacent(e.y, e.x, Empty); len(empty) > 0 {
+				in.to, in.heading = w.pick(empty)
+			}
+		} else if empty := w.adjacent(e.y, e.x, Empty); len(empty) > 0 {
+			in.to, in.heading = w.pick(empty)
+		}
+		intents = append(intents, in)
+	}
+
+	eaten := 0
+	for _, in := range intents {
+		if w.moved(in.to[0], in.to[1]) {
+			in.to, in.eats = in.from, false // Taken by an animal earlier in the order
+		}
+		if w.settle(in) {
+			eaten++
+		}
+	}
+	return eaten
+}
+
+// settle commits a move like World.settle and reports whether a fish was
+// eaten
+func (w *ChunkedWorld) settle(in chunkIntent) bool {
+	animal := in.animal
+	breed := w.FishBreed
+	if animal.Type == Shark {
+		breed = w.SharkBreed
+	}
+	if in.eats {
+		animal.Energy = w.SharkStarve
+		if w.SharkGain > 0 {
+			animal.Energy = min(w.SharkStarve, in.animal.Energy+w.SharkGain)
+		}
+	}
+	if animal.Type == Shark && animal.Energy <= 0 {
+		return false // Starved, having lost its fish to another shark
+	}
+
+	if in.to != in.from && animal.BreedTime >= breed {
+		child := Cell{Type: animal.Type}
+		if animal.Type == Shark {
+			child.Energy = w.SharkStarve
+		}
+		w.place(in.from, child)
+		animal.BreedTime = 0
+	}
+	if in.to != in.from {
+		animal.Heading = in.heading
+	}
+	w.place(in.to, animal)
+	return in.eats
+}
+
+// adjacent returns the cells next to (y, x), up, down, left and right, that
+// hold the given type and have not been settled in this step, as (y, x, dy,
+// dx)
+func (w *ChunkedWorld) adjacent(y, x int, t CellType) [][4]int {
+	var cells [][4]int
+	for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		ny, nx, ok := w.neighbor(y, x, dir[0], dir[1])
+		if ok && !w.moved(ny, nx) && w.Cell(ny, nx).Type == t {
+			cells = append(cells, [4]int{ny, nx, dir[0], dir[1]})
+		}
+	}
+	return cells
+}
+
+// pick chooses one of the cells returned by adjacent and returns it with
+// the heading of the move there
+func (w *ChunkedWorld) pick(cells [][4]int) ([2]int, int) {
+	c := cells[w.rng.Intn(len(cells))]
+	return [2]int{c[0], c[1]}, headingOf(c[2], c[3])
+}
+
+// neighbor returns the cell offset by (dy, dx) from (y, x), see
+// World.Neighbor
+func (w *ChunkedWorld) neighbor(y, x, dy, dx int) (ny, nx int, ok bool) {
+	ny, nx = y+dy, x+dx
+	if w.Bounded {
+		return ny, nx, ny >= 0 && ny < w.Height && nx >= 0 && nx < w.Width
+	}
+	return (ny%w.Height + w.Height) % w.Height, (nx%w.Width + w.Width) % w.Width, true
+}
+
+// moved reports whether the cell at (y, x) has been settled in this step
+func (w *ChunkedWorld) moved(y, x int) bool {
+	key, i := locate(y, x)
+	c := w.next[key]
+	return c != nil && c.moved[i]
+}
+
+// place settles a cell of the step being built
+func (w *ChunkedWorld) place(at [2]int, cell Cell) {
+	key, i := locate(at[0], at[1])
+	w.put(w.next, key, i, cell).moved[i] = true
+}
+
+// put sets cell i of the chunk at key in chunks, creating the chunk if
+// needed, and returns the chunk
+func (w *ChunkedWorld) put(chunks map[chunkKey]*chunk, key chunkKey, i int, cell Cell) *chunk {
+	c := chunks[key]
+	if c == nil {
+		c = w.newChunk()
+		chunks[key] = c
+	}
+	if c.cells[i].Type == Fish || c.cells[i].Type == Shark {
+		c.animals--
+	}
+	if cell.Type == Fish || cell.Type == Shark {
+		c.animals++
+	}
+	c.cells[i] = cell
+	return c
+}
+
+// newChunk returns an empty chunk, reusing an emptied one if there is any
+func (w *ChunkedWorld) newChunk() *chunk {
+	if n := len(w.free); n > 0 {
+		c := w.free[n-1]
+		w.free = w.free[:n-1]
+		return c
+	}
+	return &chunk{}
+}
+
+// recycle empties a chunk and keeps it for newChunk
+func (w *ChunkedWorld) recycle(c *chunk) {
+	*c = chunk{}
+	w.free = append(w.free, c)
+}
+
+// locate returns the chunk holding the cell at (y, x) and the cell's index
+// in it
+func locate(y, x int) (chunkKey, int) {
+	return chunkKey{y / ChunkSize, x / ChunkSize}, y%ChunkSize*ChunkSize + x%ChunkSize
+}
@@ -0,0 +1,54 @@
+package simulation
+
+// SetEggs gives fish a seed bank: a fish that dies of old age or starvation
+// leaves a dormant egg in its cell with the chance viability, which hatches
+// into a fish delay chronons later unless an animal enters the cell
+// meanwhile and destroys it. A delay of 0 removes the eggs again.
+func (w *World) SetEggs(delay int, viability float64) {
+	w.EggDelay = delay
+	w.EggChance = viability
+	if delay > 0 {
+		return
+	}
+	for i := range w.Grid {
+		w.Grid[i].Egg = 0
+	}
+	w.stats = nil
+	w.forgetAnimals()
+}
+
+// CountEggs returns the number of dormant eggs waiting to hatch
+func (w *World) CountEggs() int {
+	n := 0
+	for _, cell := range w.Grid {
+		if cell.Egg > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// layEgg leaves the egg of a fish that died in cell i of newGrid, if it is
+// viable
+func (w *World) layEgg(newGrid []Cell, i int) {
+	if w.EggDelay > 0 && w.rng.Float64() < w.EggChance {
+		newGrid[i].Egg = w.EggDelay
+	}
+}
+
+// incubate carries the egg in the empty cell i over to newGrid, one
+// chronon closer to hatching. An egg whose time has come hatches into a
+// fish, which stays in the cell for this step and counts as born.
+func (w *World) incubate(newGrid []Cell, moved []bool, i int, events *StepEvents) {
+	egg := w.Grid[i].Egg
+	if egg > 1 {
+		newGrid[i].Egg = egg - 1
+		return
+	}
+	fish := Cell{Type: Fish, Energy: w.FishStarve}
+	place(newGrid, i, fish)
+	moved[i] = true
+	events.FishBorn++
+	events.FishHatched++
+	w.logEvent(events, EventBorn, fish, i)
+}
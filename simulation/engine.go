@@ -0,0 +1,55 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Engine is an implementation of Step. All follow the same rules, and a
+// world can change engines between any two steps. The parallel engine
+// moves the animals tile by tile, so it evolves differently in detail from
+// the serial one, but in the same way for any number of threads. The gpu
+// engine moves all animals at once on the graphics card, see SetGPU.
+type Engine string
+
+const (
+	EngineSerial   Engine = "serial"   // One thread moves all animals in tie-break order
+	EngineParallel Engine = "parallel" // Threads move the animals of tiles, see TileSize
+	EngineGPU      Engine = "gpu"      // The graphics card moves the animals, or else the serial engine
+)
+
+// Engines lists the engines in the order the window cycles through them
+var Engines = []Engine{EngineSerial, EngineParallel, EngineGPU}
+
+// ParseEngine returns the engine with the given name
+func ParseEngine(name string) (Engine, error) {
+	for _, e := range Engines {
+		if string(e) == name {
+			return e, nil
+		}
+	}
+	names := make([]string, len(Engines))
+	for i, e := range Engines {
+		names[i] = string(e)
+	}
+	return "", fmt.Errorf("unknown engine %q (want %s)", name, strings.Join(names, ", "))
+}
+
+// DefaultEngine returns the engine Step uses with the given number of
+// threads
+func DefaultEngine(threads int) Engine {
+	if threads > 1 {
+		return EngineParallel
+	}
+	return EngineSerial
+}
+
+// Next returns the engine after e in Engines
+func (e Engine) Next() Engine {
+	for i, other := range Engines {
+		if other == e {
+			return Engines[(i+1)%len(Engines)]
+		}
+	}
+	return Engines[0]
+}
@@ -0,0 +1,139 @@
+package simulation
+
+// EventKind is what happened in an Event
+type EventKind string
+
+const (
+	EventMoved   EventKind = "moved"   // An animal moved to a neighbouring cell
+	EventBorn    EventKind = "born"    // An offspring was left behind
+	EventEaten   EventKind = "eaten"   // A shark ate a fish
+	EventStarved EventKind = "starved" // An animal ran out of energy
+	EventAged    EventKind = "aged"    // A fish died of old age
+)
+
+// Event is a move, birth, death or predation during a step. Y and X are the
+// cell it happened in: where the animal moved to, where the offspring was
+// born, where the fish was eaten, or where the animal died. FromY and FromX
+// are the cell a moving animal left.
+type Event struct {
+	Kind         EventKind
+	Animal       CellType // Fish or Shark; the fish for EventEaten
+	Species      int      // Predator species of a shark, see Cell.Species
+	Y, X         int
+	FromY, FromX int
+}
+
+// Observer is notified of what happens in a World during each Step: first
+// OnStepStart, then the events of the step in the order the animals were
+// settled (tile by tile with several threads) once they have all moved, and
+// last OnStepEnd. Embed NopObserver to implement only some of the methods.
+type Observer interface {
+	OnStepStart(w *World)
+	OnMove(e Event)
+	OnBreed(e Event)
+	OnEat(e Event)
+	OnDeath(e Event) // Starved or aged
+	OnStepEnd(w *World)
+}
+
+// NopObserver implements Observer with methods that do nothing
+type NopObserver struct{}
+
+func (NopObserver) OnStepStart(*World) {}
+func (NopObserver) OnMove(Event)       {}
+func (NopObserver) OnBreed(Event)      {}
+func (NopObserver) OnEat(Event)        {}
+func (NopObserver) OnDeath(Event)      {}
+func (NopObserver) OnStepEnd(*World)   {}
+
+// AddObserver registers o to be notified of every following Step. Events
+// are only collected while an observer is registered, as they cost Step
+// an entry for every animal that moves.
+func (w *World) AddObserver(o Observer) {
+	w.observers = append(w.observers, o)
+}
+
+// RemoveObserver stops notifying o
+func (w *World) RemoveObserver(o Observer) {
+	for i, registered := range w.observers {
+		if registered == o {
+			w.observers = append(w.observers[:i:i], w.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// eventFunc is the observer OnEvent registers
+type eventFunc struct {
+	NopObserver
+	fn func(Event)
+}
+
+func (f *eventFunc) OnBreed(e Event) { f.fn(e) }
+func (f *eventFunc) OnEat(e Event)   { f.fn(e) }
+func (f *eventFunc) OnDeath(e Event) { f.fn(e) }
+
+// OnEvent registers fn to be called with every birth, death and predation
+// of each Step, replacing the function registered before. A nil fn stops
+// the calls. It is a shorthand for an Observer.
+func (w *World) OnEvent(fn func(Event)) {
+	if w.onEvent != nil {
+		w.RemoveObserver(w.onEvent)
+		w.onEvent = nil
+	}
+	if fn != nil {
+		w.onEvent = &eventFunc{fn: fn}
+		w.AddObserver(w.onEvent)
+	}
+}
+
+// logEvent records an event at cell i if an observer is registered
+func (w *World) logEvent(events *StepEvents, kind EventKind, animal Cell, i int) {
+	if len(w.observers) == 0 {
+		return
+	}
+	events.log = append(events.log, Event{Kind: kind, Animal: animal.Type, Species: animal.Species, Y: i / w.Width, X: i % w.Width})
+}
+
+// logMove records an animal moving from cell from to cell to if an
+// observer is registered
+func (w *World) logMove(events *StepEvents, animal Cell, from, to int) {
+	if len(w.observers) == 0 {
+		return
+	}
+	events.log = append(events.log, Event{
+		Kind: EventMoved, Animal: animal.Type, Species: animal.Species,
+		Y: to / w.Width, X: to % w.Width, FromY: from / w.Width, FromX: from % w.Width,
+	})
+}
+
+// startStep tells the observers a step begins
+func (w *World) startStep() {
+	for _, o := range w.observers {
+		o.OnStepStart(w)
+	}
+}
+
+// notify passes the events of a step to the observers, then tells them the
+// step is done. Observers registered during the calls are notified from
+// the next step.
+func (w *World) notify(events *StepEvents) {
+	observers := w.observers
+	for _, e := range events.log {
+		for _, o := range observers {
+			switch e.Kind {
+			case EventMoved:
+				o.OnMove(e)
+			case EventBorn:
+				o.OnBreed(e)
+			case EventEaten:
+				o.OnEat(e)
+			default:
+				o.OnDeath(e)
+			}
+		}
+	}
+	for _, o := range observers {
+		o.OnStepEnd(w)
+	}
+}
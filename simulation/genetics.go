@@ -0,0 +1,61 @@
+package simulation
+
+// SetMutation turns on heritable traits: every animal carries its own breed
+// and starve times, offspring inherit them, and each trait of an offspring
+// differs from its parent's by one chronon with the given chance. Animals
+// without traits get those of the world or their species. A rate of 0
+// keeps the traits but stops them from changing.
+func (w *World) SetMutation(rate float64) {
+	w.Mutation = rate
+	for i := range w.Grid {
+		cell := &w.Grid[i]
+		if cell.Type == Fish || cell.Type == Shark {
+			cell.Breed, cell.Starve = w.Traits(*cell)
+		}
+	}
+}
+
+// Traits returns the breed and starve times of an animal: its own if it has
+// inherited them, otherwise those of the world or its species. The starve
+// time of fish is FishStarve, which is 0 unless fish eat algae.
+func (w *World) Traits(c Cell) (breed, starve int) {
+	if c.Type == Shark {
+		breed, starve = w.predator(c)
+	} else {
+		breed, starve = w.FishBreed, w.FishStarve
+	}
+	if c.Breed > 0 {
+		breed = c.Breed
+	}
+	if c.Starve > 0 {
+		starve = c.Starve
+	}
+	return breed, starve
+}
+
+// offspring returns a newborn of the parent's kind, inheriting the parent's
+// traits with mutation, and fed to its starve time
+func (w *World) offspring(parent Cell) Cell {
+	child := Cell{Type: parent.Type, Species: parent.Species}
+	if parent.Breed > 0 || w.Mutation > 0 {
+		breed, starve := w.Traits(parent)
+		child.Breed = w.mutate(breed)
+		if starve > 0 {
+			child.Starve = w.mutate(starve)
+		}
+	}
+	_, child.Energy = w.Traits(child)
+	return child
+}
+
+// mutate changes a trait by one chronon with chance Mutation, keeping it
+// at least 1
+func (w *World) mutate(v int) int {
+	if w.Mutation <= 0 || w.rng.Float64() >= w.Mutation {
+		return v
+	}
+	if w.rng.Intn(2) == 0 {
+		return v + 1
+	}
+	return max(1, v-1)
+}
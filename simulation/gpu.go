@@ -0,0 +1,139 @@
+package simulation
+
+// GPU decides the moves of the gpu engine on a graphics card. The grid is
+// passed as RGBA pixels, one per cell in row-major order: the cell type in
+// red, the energy in green, the breeding timer in blue and 255 in alpha.
+// Step replaces them by the grid after the step, with the red channel
+// holding the type plus 4 times where the animal came from: 0 if it
+// stayed, 1 to 4 for the neighbour in the order up, down, left, right, or
+// 5 if it was born there. Ready reports whether the card can take steps;
+// if not, the step is taken on the CPU before anything of the gpu engine's,
+// such as its seed, is drawn. Step is only called once Ready.
+type GPU interface {
+	Ready() bool
+	Step(pixels []byte, width, height int, p GPUParams)
+}
+
+// GPUParams are the parameters of the world a step on the GPU needs
+type GPUParams struct {
+	FishBreed   int
+	SharkBreed  int
+	SharkStarve int
+	SharkGain   int
+	Bounded     bool
+	Seed        float64 // Drawn from the world's generator for every step, in [0, 1)
+}
+
+// Where an animal came from, as returned by GPU.Step
+const (
+	gpuStayed = 0
+	gpuBorn   = 5
+)
+
+// gpuDirections are the offsets {dy, dx} from a cell to the neighbour an
+// animal came from, for GPU.Step origins 1 to 4
+var gpuDirections = [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+// SetGPU sets the graphics card the gpu engine steps on. Without one, the
+// gpu engine takes its steps with the serial engine.
+func (w *World) SetGPU(g GPU) {
+	w.gpu = g
+}
+
+// gpuSupported reports whether the gpu engine can step the world. It
+// implements the classic rules of fish and sharks on land and water, on a
+// square grid with random tie-breaks, and keeps timers in bytes.
+func (w *World) gpuSupported() bool {
+	if _, classic := w.rules().(Classic); !classic {
+		return false
+	}
+	t := w.Terrain
+	return w.gpu != nil && w.Width >= 3 && w.Height >= 3 &&
+		max(w.FishBreed, w.SharkBreed, w.SharkStarve) <= 255 &&
+		len(w.Species) == 0 && w.Mutation == 0 && w.FishStarve == 0 && w.FishAge == 0 &&
+		w.EggDelay == 0 && w.Schooling == 0 && !w.Flee && w.Order == OrderSharkFirst && w.TieBreak == TieRandom && !w.Hexagonal() &&
+		(t == nil || (t.Reef == nil && t.Temperature == nil && t.CurrentEast == nil))
+}
+
+// stepGPU moves the animals on the graphics card and rebuilds newGrid from
+// the moves it decided, counting what happened in events. It returns false
+// without changing anything, the world's generator and the step timing
+// included, if the world or the card cannot take the step.
+// On the card every animal picks a cell at once and each contested cell
+// picks one of its claimants at random, so the result differs in detail
+// from the other engines.
+func (w *World) stepGPU(newGrid []Cell, moved []bool, events *StepEvents) bool {
+	if !w.gpuSupported() || !w.gpu.Ready() {
+		return false
+	}
+	if len(w.gpuPixels) != 4*len(w.Grid) {
+		w.gpuPixels = make([]byte, 4*len(w.Grid))
+	}
+	pix := w.gpuPixels
+	for i, c := range w.Grid {
+		pix[4*i] = byte(c.Type)
+		pix[4*i+1] = byte(min(max(c.Energy, 0), 255))
+		pix[4*i+2] = byte(min(max(c.BreedTime, 0), 255))
+		pix[4*i+3] = 255
+	}
+	p := GPUParams{
+		FishBreed:   w.FishBreed,
+		SharkBreed:  w.SharkBreed,
+		SharkStarve: w.SharkStarve,
+		SharkGain:   w.SharkGain,
+		Bounded:     w.Bounded,
+		Seed:        w.rng.Float64(),
+	}
+	w.lap(&w.timing.Setup)
+	w.gpu.Step(pix, w.Width, w.Height, p)
+	w.lap(&w.timing.Sharks)
+
+	// moved marks the cells of the old grid whose animal lives on
+	clear(moved)
+	for i := range newGrid {
+		t, origin := CellType(pix[4*i]%4), int(pix[4*i]/4)
+		if t != Fish && t != Shark {
+			continue
+		}
+		var animal Cell
+		switch origin {
+		case gpuBorn:
+			animal = w.offspring(w.Grid[i])
+			w.logEvent(events, EventBorn, animal, i)
+			if t == Shark {
+				events.SharksBorn++
+			} else {
+				events.FishBorn++
+			}
+		case gpuStayed:
+			animal = w.Grid[i]
+			animal.Age++
+			moved[i] = true
+		default:
+			d := gpuDirections[(origin-1)%4]
+			y, x, _ := w.Neighbor(i/w.Width, i%w.Width, d[0], d[1])
+			from := y*w.Width + x
+			animal = w.Grid[from]
+			animal.Age++
+			animal.Heading = headingOf(-d[0], -d[1])
+			moved[from] = true
+			if t == Shark && w.Grid[i].Type == Fish {
+				events.FishEaten++
+				w.logEvent(events, EventEaten, w.Grid[i], i)
+			}
+			w.logMove(events, animal, from, i)
+		}
+		animal.Energy, animal.BreedTime = int(pix[4*i+1]), int(pix[4*i+2])
+		place(newGrid, i, animal)
+	}
+
+	// Sharks that are nowhere in the new grid starved
+	for i, c := range w.Grid {
+		if c.Type == Shark && !moved[i] {
+			events.SharksStarved++
+			events.starved(c.Species, 1)
+			w.logEvent(events, EventStarved, c, i)
+		}
+	}
+	return true
+}
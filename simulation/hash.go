@@ -0,0 +1,40 @@
+package simulation
+
+import "fmt"
+
+// FNV-1a constants for 64-bit hashes
+const (
+	hashOffset = 14695981039346656037
+	hashPrime  = 1099511628211
+)
+
+// Hash returns a digest of the size of the world and every field of every
+// cell. Two runs with the same seed and parameters are in the same state
+// exactly when their hashes agree, up to the rare collision; the random
+// number generator is not part of the hash, so it is the same for a world
+// and its snapshot.
+func (w *World) Hash() uint64 {
+	h := uint64(hashOffset)
+	mix := func(v int) {
+		h ^= uint64(v)
+		h *= hashPrime
+	}
+	mix(w.Width)
+	mix(w.Height)
+	for _, c := range w.Grid {
+		algae := 0
+		if c.Algae {
+			algae = 1
+		}
+		for _, v := range [...]int{int(c.Type), c.Energy, c.BreedTime, algae, c.Age, c.Species, c.Breed, c.Starve, c.Heading, c.Egg} {
+			mix(v)
+		}
+	}
+	return h
+}
+
+// ShortHash formats the first 32 bits of a Hash as 8 hex digits, short
+// enough to read out and compare at a glance
+func ShortHash(h uint64) string {
+	return fmt.Sprintf("%08x", h>>32)
+}
@@ -0,0 +1,26 @@
+package simulation
+
+// Directions of an animal's last move, kept in Cell.Heading. Up is towards
+// row 0 and left towards column 0.
+const (
+	HeadingNone = iota // Has not moved since it was born
+	HeadingUp
+	HeadingDown
+	HeadingLeft
+	HeadingRight
+)
+
+// headingOf returns the heading of a move by (dy, dx) to a neighbour
+func headingOf(dy, dx int) int {
+	switch {
+	case dy < 0:
+		return HeadingUp
+	case dy > 0:
+		return HeadingDown
+	case dx < 0:
+		return HeadingLeft
+	case dx > 0:
+		return HeadingRight
+	}
+	return HeadingNone
+}
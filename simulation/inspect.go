@@ -0,0 +1,124 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SnapshotInfo describes a snapshot without holding its grid in memory
+type SnapshotInfo struct {
+	Snapshot     // Parameters; Cells and the terrain layers are left empty
+	Cells        int
+	Fish, Sharks int
+	Layers       []string // Terrain layers present, by JSON name
+}
+
+// InspectSnapshot reads a snapshot written by Save from r one cell at a
+// time, keeping the parameters and counting the cells, and checks it like
+// ParseSnapshot does. If the parameters could be read the info is returned
+// even when the snapshot turns out to be damaged, together with the error.
+func InspectSnapshot(r io.Reader) (*SnapshotInfo, error) {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON snapshot")
+	}
+
+	info := &SnapshotInfo{}
+	fields := map[string]json.RawMessage{}
+	layers := map[string]int{}
+	var species []int
+	var err error
+	for err == nil && dec.More() {
+		var tok json.Token
+		if tok, err = dec.Token(); err != nil {
+			break
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "cells":
+			err = streamArray(dec, func() error {
+				var c Cell
+				if err := dec.Decode(&c); err != nil {
+					return err
+				}
+				info.Cells++
+				switch c.Type {
+				case Fish:
+					info.Fish++
+				case Shark:
+					info.Sharks++
+					species = append(species, c.Species)
+				}
+				return nil
+			})
+		case "reef", "temperature", "currentEast", "currentNorth":
+			info.Layers = append(info.Layers, key)
+			err = streamArray(dec, func() error {
+				layers[key]++
+				var v json.RawMessage
+				return dec.Decode(&v)
+			})
+		default:
+			var v json.RawMessage
+			if err = dec.Decode(&v); err == nil {
+				fields[key] = v
+			}
+		}
+	}
+
+	// Decode the other fields like a snapshot without its grid
+	data, _ := json.Marshal(fields)
+	if _, ok := fields["width"]; !ok || json.Unmarshal(data, &info.Snapshot) != nil {
+		return nil, fmt.Errorf("not a snapshot")
+	}
+	if err == nil {
+		_, err = dec.Token()
+	}
+	if err != nil {
+		return info, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	n := info.Width * info.Height
+	if info.Width < 1 || info.Height < 1 || info.Cells != n {
+		return info, fmt.Errorf("grid size does not match cell count")
+	}
+	for _, layer := range info.Layers {
+		if layers[layer] != n {
+			return info, fmt.Errorf("%s layer does not match the grid size", layer)
+		}
+	}
+	if layers["currentEast"] != layers["currentNorth"] {
+		return info, fmt.Errorf("current layers do not match")
+	}
+	if info.TieBreak != "" {
+		if _, err := ParseTieBreak(info.TieBreak); err != nil {
+			return info, err
+		}
+	}
+	for _, id := range species {
+		if id < 0 || id > len(info.Species) {
+			return info, fmt.Errorf("cell species %d is not listed", id)
+		}
+	}
+	return info, nil
+}
+
+// streamArray reads a JSON array, calling element for each element while
+// it is the next value of the decoder. A null counts as an empty array.
+func streamArray(dec *json.Decoder, element func() error) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("expected an array")
+	}
+	for dec.More() {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
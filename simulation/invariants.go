@@ -0,0 +1,125 @@
+package simulation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// StepEvents counts what happened to the animals during a step
+type StepEvents struct {
+	Fish, Sharks  int // Populations before the step
+	FishBorn      int
+	SharksBorn    int
+	FishEaten     int
+	FishStarved   int
+	FishAged      int // Fish that died of old age
+	FishHatched   int // Fish of FishBorn that hatched from dormant eggs
+	SharksStarved int
+	// Sharks starved by species, indexed like Cell.Species; species past
+	// the end of the slice lost none
+	SpeciesStarved []int
+
+	log []Event // Events in settling order, if an observer is registered
+}
+
+// add adds the counts of events, such as those of one tile, to e
+func (e *StepEvents) add(o StepEvents) {
+	e.FishBorn += o.FishBorn
+	e.SharksBorn += o.SharksBorn
+	e.FishEaten += o.FishEaten
+	e.FishStarved += o.FishStarved
+	e.FishAged += o.FishAged
+	e.FishHatched += o.FishHatched
+	e.SharksStarved += o.SharksStarved
+	for species, n := range o.SpeciesStarved {
+		e.starved(species, n)
+	}
+	e.log = append(e.log, o.log...)
+}
+
+// starved counts n sharks of a species starving
+func (e *StepEvents) starved(species, n int) {
+	for len(e.SpeciesStarved) <= species {
+		e.SpeciesStarved = append(e.SpeciesStarved, 0)
+	}
+	e.SpeciesStarved[species] += n
+}
+
+// LastStep returns what happened during the last call to Step, or zero
+// counts before the first
+func (w *World) LastStep() StepEvents {
+	if w.events == nil {
+		return StepEvents{}
+	}
+	events := *w.events
+	events.SpeciesStarved = slices.Clone(events.SpeciesStarved)
+	return events
+}
+
+// CheckInvariants checks that every cell holds a state the rules can
+// produce and, once the world has stepped, that the populations differ
+// from those before the last Step only by the births and deaths it counted.
+// It must be called right after Step: changes made to the grid since, such
+// as painting cells or migration, count as violations. The error names the
+// first cell found wrong.
+func (w *World) CheckInvariants() error {
+	for i, c := range w.Grid {
+		if err := w.checkCell(i, c); err != nil {
+			return fmt.Errorf("cell x=%d, y=%d: %v", i%w.Width, i/w.Width, err)
+		}
+	}
+	if w.events == nil {
+		return nil
+	}
+
+	e := w.events
+	fish, sharks := w.Count()
+	if want := e.Fish + e.FishBorn - e.FishEaten - e.FishStarved - e.FishAged; fish != want {
+		return fmt.Errorf("%d fish, but %d before the step + %d born - %d eaten - %d starved - %d died of age = %d",
+			fish, e.Fish, e.FishBorn, e.FishEaten, e.FishStarved, e.FishAged, want)
+	}
+	if want := e.Sharks + e.SharksBorn - e.SharksStarved; sharks != want {
+		return fmt.Errorf("%d sharks, but %d before the step + %d born - %d starved = %d",
+			sharks, e.Sharks, e.SharksBorn, e.SharksStarved, want)
+	}
+	return nil
+}
+
+// cellNames names the cell types in messages
+var cellNames = [...]string{Empty: "empty", Fish: "fish", Shark: "shark", Barrier: "barrier"}
+
+// checkCell returns what is impossible about cell i holding c, if anything
+func (w *World) checkCell(i int, c Cell) error {
+	if c.Type < Empty || c.Type > Barrier {
+		return fmt.Errorf("unknown cell type %d", c.Type)
+	}
+	name := cellNames[c.Type]
+	if w.Terrain != nil && len(w.Terrain.Land) > 0 && w.Terrain.Land[i] != (c.Type == Barrier) {
+		return fmt.Errorf("%s cell where the terrain's land is %v", name, w.Terrain.Land[i])
+	}
+
+	animal := c
+	animal.Algae = false
+	animal.Egg = 0
+	switch {
+	case c.Egg < 0 || c.Egg > 0 && (c.Type != Empty || c.Egg > w.EggDelay):
+		return fmt.Errorf("%s cell with an egg hatching in %d chronons", name, c.Egg)
+	case c.Type == Empty || c.Type == Barrier:
+		if animal != (Cell{Type: c.Type}) {
+			return fmt.Errorf("%s cell holds animal state %+v", name, c)
+		}
+	case c.BreedTime < 0 || c.Age < 0 || c.Breed < 0 || c.Starve < 0:
+		return fmt.Errorf("%s with a negative timer or trait: %+v", name, c)
+	case c.Type == Fish && c.Species != 0:
+		return fmt.Errorf("fish of predator species %d", c.Species)
+	case c.Type == Fish && w.FishStarve > 0 && c.Energy <= 0:
+		return fmt.Errorf("fish with no energy left: %+v", c)
+	case c.Type == Fish && w.FishAge > 0 && c.Age > w.FishAge:
+		return fmt.Errorf("fish aged %d, over the limit of %d", c.Age, w.FishAge)
+	case c.Type == Shark && (c.Species < 0 || c.Species > len(w.Species)):
+		return fmt.Errorf("shark of unknown species %d", c.Species)
+	case c.Type == Shark && c.Energy <= 0:
+		return fmt.Errorf("shark with no energy left: %+v", c)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package simulation
+
+// Migrate moves each fish and shark of src to a random empty water cell of
+// dst with probability fraction. Migrants keep their energy and breeding
+// timers; if dst has no room left the remaining migrants stay home. It
+// returns the number of fish and sharks moved.
+func Migrate(src, dst *World, fraction float64) (fish, sharks int) {
+	var free [][2]int
+	for i := 0; i < dst.Height; i++ {
+		for j := 0; j < dst.Width; j++ {
+			if dst.Grid[i*dst.Width+j].Type == Empty {
+				free = append(free, [2]int{i, j})
+			}
+		}
+	}
+
+	for i := 0; i < src.Height && len(free) > 0; i++ {
+		for j := 0; j < src.Width && len(free) > 0; j++ {
+			cell := src.Grid[i*src.Width+j]
+			if (cell.Type != Fish && cell.Type != Shark) || src.rng.Float64() >= fraction {
+				continue
+			}
+
+			// Take a random free cell, swapping it out of the list
+			k := dst.rng.Intn(len(free))
+			target := free[k]
+			free[k] = free[len(free)-1]
+			free = free[:len(free)-1]
+
+			dst.Grid[target[0]*dst.Width+target[1]] = cell
+			src.Grid[i*src.Width+j] = Cell{}
+			if cell.Type == Fish {
+				fish++
+			} else {
+				sharks++
+			}
+		}
+	}
+
+	src.stats, dst.stats = nil, nil
+	src.forgetAnimals()
+	dst.forgetAnimals()
+	return fish, sharks
+}
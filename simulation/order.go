@@ -0,0 +1,61 @@
+package simulation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Order decides which species moves first in a chronon. The step is a
+// schedule of passes, each moving the animals of some kinds in the two
+// phases of moveAll; animals of a later pass see those of earlier passes
+// where they settled.
+type Order int
+
+const (
+	OrderSharkFirst  Order = iota // Sharks move, then fish, the classic Wa-Tor rule
+	OrderFishFirst                // Fish move, then sharks hunt them where they settled
+	OrderInterleaved              // Sharks and fish move in one pass, in tie-break order
+	OrderRandom                   // Sharks or fish first, chosen at random every chronon
+)
+
+// Orders lists the order names accepted by ParseOrder
+var Orders = []string{"shark-first", "fish-first", "interleaved", "random"}
+
+// ParseOrder returns the order with the given name
+func ParseOrder(name string) (Order, error) {
+	if i := slices.Index(Orders, name); i >= 0 {
+		return Order(i), nil
+	}
+	return OrderSharkFirst, fmt.Errorf("unknown update order %q (expected shark-first, fish-first, interleaved or random)", name)
+}
+
+// String returns the name of the order
+func (o Order) String() string {
+	if o < 0 || int(o) >= len(Orders) {
+		return fmt.Sprintf("Order(%d)", int(o))
+	}
+	return Orders[o]
+}
+
+// Passes in which each order moves the animals
+var (
+	sharksThenFish = [][]CellType{{Shark}, {Fish}}
+	fishThenSharks = [][]CellType{{Fish}, {Shark}}
+	allAtOnce      = [][]CellType{{Shark, Fish}}
+)
+
+// schedule returns the passes of the next step, drawing the first species
+// for OrderRandom
+func (w *World) schedule() [][]CellType {
+	switch w.Order {
+	case OrderFishFirst:
+		return fishThenSharks
+	case OrderInterleaved:
+		return allAtOnce
+	case OrderRandom:
+		if w.rng.Intn(2) == 1 {
+			return fishThenSharks
+		}
+	}
+	return sharksThenFish
+}
@@ -0,0 +1,246 @@
+package simulation
+
+import "math"
+
+// PackedWorld is a world stored as a struct of arrays: one byte for the
+// type of every cell and two int16 for its energy and breeding timer,
+// instead of the array of Cell structs of a World. A step reads only the
+// arrays it needs, so far more cells fit in each cache line, which pays off
+// on big grids. It follows the rules of a World with random tie-breaks,
+// stepped by one thread, for fish and sharks only: there is no terrain,
+// algae, old age, headings, species or genetics, and timers must fit in an
+// int16.
+type PackedWorld struct {
+	Width       int
+	Height      int
+	FishBreed   int
+	SharkBreed  int
+	SharkStarve int
+	SharkGain   int  // See World.SharkGain
+	Bounded     bool // See World.Bounded
+	cells       packedCells
+	next        packedCells    // Cells Step builds, empty between steps
+	moved       []bool         // Cells of next settled in the step being built
+	order       []int          // Animals in the order they move, reused by Step
+	intents     []packedIntent // Moves of the pass being settled, reused by Step
+	rng         Rand
+}
+
+// packedCells holds the cells of a PackedWorld in row-major order
+type packedCells struct {
+	types  []uint8
+	energy []int16
+	breed  []int16
+}
+
+func newPackedCells(n int) packedCells {
+	return packedCells{make([]uint8, n), make([]int16, n), make([]int16, n)}
+}
+
+// packedIntent is the move an animal proposes, see PackedWorld.moveAll
+type packedIntent struct {
+	from, to int
+	energy   int
+	breed    int
+	eats     bool
+}
+
+// NewPackedWorld creates a packed world with the animals NewSeededWorld
+// would place for the same arguments
+func NewPackedWorld(seed int64, width, height, numFish, numShark, fishBreed, sharkBreed, sharkStarve int) *PackedWorld {
+	return PackWorld(NewSeededWorld(seed, width, height, numFish, numShark, fishBreed, sharkBreed, sharkStarve))
+}
+
+// PackWorld returns a packed copy of the fish and sharks of w, with a
+// generator seeded from that of w
+func PackWorld(w *World) *PackedWorld {
+	n := w.Width * w.Height
+	p := &PackedWorld{
+		Width:       w.Width,
+		Height:      w.Height,
+		FishBreed:   w.FishBreed,
+		SharkBreed:  w.SharkBreed,
+		SharkStarve: w.SharkStarve,
+		SharkGain:   w.SharkGain,
+		Bounded:     w.Bounded,
+		cells:       newPackedCells(n),
+		next:        newPackedCells(n),
+		moved:       make([]bool, n),
+		rng:         newPCGRand(int64(w.rng.Intn(math.MaxInt))),
+	}
+	for i, c := range w.Grid {
+		if c.Type == Fish || c.Type == Shark {
+			p.set(i, c)
+		}
+	}
+	return p
+}
+
+// SetRand replaces the world's random number generator, see World.SetRand
+func (w *PackedWorld) SetRand(r Rand) {
+	w.rng = r
+}
+
+// Cell returns the contents of the cell at (y, x)
+func (w *PackedWorld) Cell(y, x int) Cell {
+	i := y*w.Width + x
+	return Cell{
+		Type:      CellType(w.cells.types[i]),
+		Energy:    int(w.cells.energy[i]),
+		BreedTime: int(w.cells.breed[i]),
+	}
+}
+
+// SetCell replaces the type, energy and breeding timer of the cell at
+// (y, x); the rest of cell is not stored
+func (w *PackedWorld) SetCell(y, x int, cell Cell) {
+	w.set(y*w.Width+x, cell)
+}
+
+func (w *PackedWorld) set(i int, cell Cell) {
+	w.cells.types[i] = uint8(cell.Type)
+	w.cells.energy[i] = int16(cell.Energy)
+	w.cells.breed[i] = int16(cell.BreedTime)
+}
+
+// Count returns the number of fish and sharks
+func (w *PackedWorld) Count() (int, int) {
+	fish, sharks := 0, 0
+	for _, t := range w.cells.types {
+		switch CellType(t) {
+		case Fish:
+			fish++
+		case Shark:
+			sharks++
+		}
+	}
+	return fish, sharks
+}
+
+// Step performs one simulation step and returns the number of fish eaten,
+// moving the sharks and then the fish like ChunkedWorld.Step
+func (w *PackedWorld) Step() int {
+	w.shuffle()
+	eaten := w.moveAll(Shark)
+	eaten += w.moveAll(Fish)
+
+	w.cells, w.next = w.next, w.cells
+	clear(w.next.types)
+	clear(w.next.energy)
+	clear(w.next.breed)
+	clear(w.moved)
+	return eaten
+}
+
+// shuffle lists the animals in random order
+func (w *PackedWorld) shuffle() {
+	w.order = w.order[:0]
+	for i, t := range w.cells.types {
+		if CellType(t) == Fish || CellType(t) == Shark {
+			w.order = append(w.order, i)
+		}
+	}
+	for i := len(w.order) - 1; i > 0; i-- {
+		j := w.rng.Intn(i + 1)
+		w.order[i], w.order[j] = w.order[j], w.order[i]
+	}
+}
+
+// moveAll moves the animals of one kind in the two phases of
+// World.moveAll and returns the number of fish eaten
+func (w *PackedWorld) moveAll(kind CellType) int {
+	intents := w.intents[:0]
+	var cells [4]int
+	for _, i := range w.order {
+		if CellType(w.cells.types[i]) != kind || w.moved[i] {
+			continue // Eaten, or of the other kind
+		}
+		in := packedIntent{from: i, to: i, energy: int(w.cells.energy[i]), breed: int(w.cells.breed[i]) + 1}
+		if kind == Shark {
+			in.energy--
+			if n := w.adjacent(i, Fish, &cells); n > 0 {
+				in.to, in.eats = cells[w.rng.Intn(n)], true
+			} else if in.energy <= 0 {
+				continue // Starved
+			} else if n := w.adjacent(i, Empty, &cells); n > 0 {
+				in.to = cells[w.rng.Intn(n)]
+			}
+		} else if n := w.adjacent(i, Empty, &cells); n > 0 {
+			in.to = cells[w.rng.Intn(n)]
+		}
+		intents = append(intents, in)
+	}
+	w.intents = intents
+
+	eaten := 0
+	for _, in := range intents {
+		if w.moved[in.to] {
+			in.to, in.eats = in.from, false // Taken by an animal earlier in the order
+		}
+		if w.settle(kind, in) {
+			eaten++
+		}
+	}
+	return eaten
+}
+
+// settle commits a move like World.settle and reports whether a fish was
+// eaten
+func (w *PackedWorld) settle(kind CellType, in packedIntent) bool {
+	breed := w.FishBreed
+	if kind == Shark {
+		breed = w.SharkBreed
+	}
+	if in.eats {
+		gained := w.SharkStarve
+		if w.SharkGain > 0 {
+			gained = min(w.SharkStarve, in.energy+w.SharkGain)
+		}
+		in.energy = gained
+	}
+	if kind == Shark && in.energy <= 0 {
+		return false // Starved, having lost its fish to another shark
+	}
+
+	if in.to != in.from && in.breed >= breed {
+		energy := 0
+		if kind == Shark {
+			energy = w.SharkStarve
+		}
+		w.place(in.from, kind, energy, 0)
+		in.breed = 0
+	}
+	w.place(in.to, kind, in.energy, in.breed)
+	return in.eats
+}
+
+// adjacent stores in cells the cells next to i, up, down, left and right,
+// that hold the given type and have not been settled in this step, and
+// returns how many there are
+func (w *PackedWorld) adjacent(i int, t CellType, cells *[4]int) int {
+	y, x := i/w.Width, i%w.Width
+	n := 0
+	for _, dir := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		ny, nx := y+dir[0], x+dir[1]
+		if w.Bounded {
+			if ny < 0 || ny >= w.Height || nx < 0 || nx >= w.Width {
+				continue
+			}
+		} else {
+			ny, nx = (ny+w.Height)%w.Height, (nx+w.Width)%w.Width
+		}
+		if j := ny*w.Width + nx; !w.moved[j] && CellType(w.cells.types[j]) == t {
+			cells[n] = j
+			n++
+		}
+	}
+	return n
+}
+
+// place settles a cell of the step being built
+func (w *PackedWorld) place(i int, kind CellType, energy, breed int) {
+	w.next.types[i] = uint8(kind)
+	w.next.energy[i] = int16(energy)
+	w.next.breed[i] = int16(breed)
+	w.moved[i] = true
+}
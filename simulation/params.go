@@ -0,0 +1,100 @@
+package simulation
+
+import "fmt"
+
+// Parameters lists the names accepted by SetParameter, matching the
+// command-line flags that set them at startup
+var Parameters = []string{"fbreed", "sbreed", "starve", "sgain", "fstarve", "algae", "fishage", "mutation", "school"}
+
+// Parameter returns the current value of a named parameter
+func (w *World) Parameter(name string) (float64, error) {
+	switch name {
+	case "fbreed":
+		return float64(w.FishBreed), nil
+	case "sbreed":
+		return float64(w.SharkBreed), nil
+	case "starve":
+		return float64(w.SharkStarve), nil
+	case "sgain":
+		return float64(w.SharkGain), nil
+	case "fstarve":
+		return float64(w.FishStarve), nil
+	case "algae":
+		return w.AlgaeGrowth, nil
+	case "fishage":
+		return float64(w.FishAge), nil
+	case "mutation":
+		return w.Mutation, nil
+	case "school":
+		return w.Schooling, nil
+	default:
+		return 0, fmt.Errorf("unknown parameter %q", name)
+	}
+}
+
+// CheckParameter reports whether value is valid for a named parameter.
+// Breed and starve times must be at least 1 (fstarve may be 0 to remove the
+// algae layer, fishage 0 to make fish immortal), algae and mutation must
+// be in [0, 1] and school must not be negative.
+func CheckParameter(name string, value float64) error {
+	n := int(value)
+	switch name {
+	case "algae", "mutation":
+		if value < 0 || value > 1 {
+			return fmt.Errorf("%s must be in [0, 1]", name)
+		}
+	case "school":
+		if value < 0 {
+			return fmt.Errorf("school must not be negative")
+		}
+	case "fstarve", "sgain", "fishage":
+		if n < 0 || float64(n) != value {
+			return fmt.Errorf("%s must be a non-negative integer", name)
+		}
+	case "fbreed", "sbreed", "starve":
+		if n < 1 || float64(n) != value {
+			return fmt.Errorf("%s must be a positive integer", name)
+		}
+	default:
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	return nil
+}
+
+// SetParameter changes a named parameter of the running world and returns
+// its previous value
+func (w *World) SetParameter(name string, value float64) (float64, error) {
+	if err := CheckParameter(name, value); err != nil {
+		return 0, err
+	}
+	old, _ := w.Parameter(name)
+
+	n := int(value)
+	switch name {
+	case "fbreed":
+		w.FishBreed = n
+	case "sbreed":
+		w.SharkBreed = n
+	case "starve":
+		w.SharkStarve = n
+	case "sgain":
+		w.SharkGain = n
+	case "fstarve":
+		if (w.FishStarve > 0) != (n > 0) {
+			w.SetAlgae(n, w.AlgaeGrowth) // Add or remove the algae layer
+		}
+		w.FishStarve = n
+	case "algae":
+		w.AlgaeGrowth = value
+	case "fishage":
+		w.FishAge = n
+	case "mutation":
+		if w.Mutation == 0 && value > 0 {
+			w.SetMutation(value) // Give every animal its own traits
+		}
+		w.Mutation = value
+	case "school":
+		w.Schooling = value
+	}
+	return old, nil
+}
@@ -0,0 +1,67 @@
+package simulation
+
+// predationMap counts the fish eaten in every cell over a sliding window of
+// steps. It observes the world it tracks.
+type predationMap struct {
+	NopObserver
+	window int
+	width  int
+	counts []int32   // Fish eaten per cell during the window
+	steps  [][]int32 // Cells where fish were eaten, per step of the window, as a ring
+	next   int       // Ring position of the oldest step once the window is full
+	cur    int       // Ring position of the step being taken
+}
+
+// TrackPredation makes Step count the fish eaten in every cell over its
+// last window steps, read with Predation. A window of 0 stops tracking.
+func (w *World) TrackPredation(window int) {
+	if w.predation != nil {
+		w.RemoveObserver(w.predation)
+		w.predation = nil
+	}
+	if window > 0 {
+		w.predation = &predationMap{window: window, width: w.Width, counts: make([]int32, len(w.Grid))}
+		w.AddObserver(w.predation)
+	}
+}
+
+// PredationWindow returns the number of steps Predation covers, or 0 if
+// predation is not tracked
+func (w *World) PredationWindow() int {
+	if w.predation == nil {
+		return 0
+	}
+	return w.predation.window
+}
+
+// Predation returns the number of fish eaten in every cell, in the order of
+// Grid, during the last steps of the window set with TrackPredation, or nil
+// if predation is not tracked. Step updates the slice in place.
+func (w *World) Predation() []int32 {
+	if w.predation == nil {
+		return nil
+	}
+	return w.predation.counts
+}
+
+// OnStepStart makes room for the step about to be taken, dropping the
+// oldest step once the window is full
+func (p *predationMap) OnStepStart(*World) {
+	if len(p.steps) < p.window {
+		p.steps = append(p.steps, nil)
+		p.next = len(p.steps) - 1
+	}
+	for _, i := range p.steps[p.next] {
+		p.counts[i]--
+	}
+	p.steps[p.next] = p.steps[p.next][:0]
+	p.cur = p.next
+	p.next = (p.next + 1) % p.window
+}
+
+// OnEat counts a fish eaten during the step
+func (p *predationMap) OnEat(e Event) {
+	i := int32(e.Y*p.width + e.X)
+	p.steps[p.cur] = append(p.steps[p.cur], i)
+	p.counts[i]++
+}
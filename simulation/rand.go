@@ -0,0 +1,88 @@
+package simulation
+
+import "math/rand/v2"
+
+// Rand is the source of every random decision a world makes: placement,
+// movement order, the choice among free neighbours, warm water and
+// mutations. *rand.Rand of math/rand satisfies it.
+type Rand interface {
+	// Intn returns a number in [0, n)
+	Intn(n int) int
+	// Float64 returns a number in [0, 1)
+	Float64() float64
+}
+
+// SetRand replaces the world's random number generator, for example with a
+// fixed sequence in tests. Seed installs a seeded pcgRand again.
+func (w *World) SetRand(r Rand) {
+	w.rng = r
+	w.seed = 0
+}
+
+// pcgRand is the generator worlds are seeded with: a PCG, whose state can
+// be saved in a snapshot and restored, so a resumed run draws the same
+// numbers as the run it was saved from
+type pcgRand struct {
+	src *rand.PCG
+	rng *rand.Rand
+}
+
+// pcgStream is the second word of the PCG seed, the same for every world
+const pcgStream = 0x5761546f72 // "WaTor"
+
+// newPCGRand returns a generator seeded with seed
+func newPCGRand(seed int64) *pcgRand {
+	src := rand.NewPCG(uint64(seed), pcgStream)
+	return &pcgRand{src: src, rng: rand.New(src)}
+}
+
+func (r *pcgRand) Intn(n int) int {
+	return r.rng.IntN(n)
+}
+
+func (r *pcgRand) Float64() float64 {
+	return r.rng.Float64()
+}
+
+// RandState returns the state of the world's generator, or nil if it was
+// replaced with SetRand by one whose state cannot be saved
+func (w *World) RandState() []byte {
+	r, ok := w.rng.(*pcgRand)
+	if !ok {
+		return nil
+	}
+	state, _ := r.src.MarshalBinary()
+	return state
+}
+
+// SetRandState puts the world's generator back in a state returned by
+// RandState
+func (w *World) SetRandState(state []byte) error {
+	r := newPCGRand(w.seed)
+	if err := r.src.UnmarshalBinary(state); err != nil {
+		return err
+	}
+	w.rng = r
+	return nil
+}
+
+// tileRand is a splitmix64 generator. Step seeds one for every tile it
+// hands to a thread, which is much cheaper than seeding a *rand.Rand.
+type tileRand uint64
+
+// next returns the next 64 random bits
+func (r *tileRand) next() uint64 {
+	*r += 0x9e3779b97f4a7c15
+	z := uint64(*r)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (r *tileRand) Intn(n int) int {
+	return int(r.next() % uint64(n))
+}
+
+func (r *tileRand) Float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
@@ -0,0 +1,219 @@
+package simulation
+
+import "slices"
+
+// RuleSet decides what each animal does in a chronon: which cell it wants,
+// whether it breeds and when it starves. The world settles the moves, so no
+// two animals end up in one cell and births and deaths are counted alike
+// whatever the rules. Downstream packages implement their own ecologies by
+// embedding Classic and overriding some of its methods.
+type RuleSet interface {
+	// MoveShark ages the shark at (y, x) and picks the cell it wants. It
+	// returns false if the shark dies where it is.
+	MoveShark(t *Turn, y, x int) (Move, bool)
+	// MoveFish ages the fish at (y, x) and picks the cell it wants. It
+	// returns false if the fish dies of old age where it is.
+	MoveFish(t *Turn, y, x int) (Move, bool)
+	// Hunger spends one chronon of an animal's energy. A shark whose
+	// energy is 0 or less starves unless it eats in the same chronon, and
+	// so does a fish that cannot find algae.
+	Hunger(w *World, animal *Cell, rng Rand)
+	// Breeds reports whether an animal leaving its cell leaves an
+	// offspring behind
+	Breeds(w *World, animal Cell, rng Rand) bool
+}
+
+// Move is the cell an animal wants this chronon. If an animal earlier in
+// the order takes To first, the animal stays at From instead.
+type Move struct {
+	From, To int  // Cell indexes, equal if the animal stays
+	Animal   Cell // The animal, one chronon older
+	Eats     bool // A shark eating the fish at To
+	Heading  int  // Direction from From to To
+}
+
+// Turn is what the rules see of a step in progress: the world as it was
+// at the start of the step, and which cells have already been settled
+type Turn struct {
+	World   *World
+	newGrid []Cell
+	moved   []bool
+	settled []CellType // Kinds that moved in earlier passes, see Order
+}
+
+// Free returns the neighbours of (y, x) that held cellType at the start of
+// the step and have not been settled since, as {y, x, dy, dx}. Animals of a
+// kind that moved in an earlier pass are found where they settled instead.
+func (t *Turn) Free(y, x int, cellType CellType) [][]int {
+	if slices.Contains(t.settled, cellType) {
+		return t.World.settledCells(y, x, cellType, t.newGrid, t.moved)
+	}
+	return t.World.getAdjacentCells(y, x, cellType, t.moved)
+}
+
+// Settled returns cell i as it is after the animals settled so far, such
+// as the sharks when fish move
+func (t *Turn) Settled(i int) Cell {
+	return t.newGrid[i]
+}
+
+// sharks returns the grid holding the sharks as fleeing fish see them:
+// where they settled if they moved in an earlier pass, or else where they
+// stood at the start of the step, as when fish move first or in the same
+// pass
+func (t *Turn) sharks() []Cell {
+	if slices.Contains(t.settled, Shark) {
+		return t.newGrid
+	}
+	return t.World.Grid
+}
+
+// Cell returns cell i as Free sees it: holding an animal of an earlier pass
+// where it settled, or else as it was at the start of the step
+func (t *Turn) Cell(i int) Cell {
+	if t.moved[i] && slices.Contains(t.settled, t.newGrid[i].Type) {
+		return t.newGrid[i]
+	}
+	return t.World.Grid[i]
+}
+
+// Choose picks one of the cells returned by Free at random, favouring
+// those downstream of the ocean current at (y, x)
+func (t *Turn) Choose(y, x int, cells [][]int) []int {
+	return t.World.choose(y, x, cells)
+}
+
+// Rand returns the random number generator of the step
+func (t *Turn) Rand() Rand {
+	return t.World.rng
+}
+
+// To returns the move of the animal at (y, x) to the cell picked from Free
+func (t *Turn) To(y, x int, animal Cell, cell []int) Move {
+	w := t.World
+	return Move{From: y*w.Width + x, To: cell[0]*w.Width + cell[1], Animal: animal, Heading: headingOf(cell[2], cell[3])}
+}
+
+// Classic is the deterministic rule set of the original Wa-Tor: an animal
+// breeds on the first move after its breed time has passed, and starves
+// once its starve time has passed without eating. Sharks eat an adjacent
+// fish if they can, and fish move to a random empty neighbour.
+type Classic struct{}
+
+// MoveShark moves the shark to an adjacent fish if there is one, and
+// otherwise to an empty cell unless it starves
+func (Classic) MoveShark(t *Turn, y, x int) (Move, bool) {
+	w := t.World
+	i := y*w.Width + x
+	shark := w.Grid[i]
+	shark.Age++
+	w.rules().Hunger(w, &shark, t.Rand())
+	if temp := w.Terrain.TemperatureAt(y, x); temp > 0 && t.Rand().Float64() < temp {
+		shark.Energy--
+	}
+	shark.BreedTime++
+	in := Move{From: i, To: i, Animal: shark}
+
+	if fishCells := t.Free(y, x, Fish); len(fishCells) > 0 {
+		in = t.To(y, x, shark, t.Choose(y, x, fishCells))
+		in.Eats = true
+	} else if shark.Energy <= 0 {
+		return in, false
+	} else if emptyCells := t.Free(y, x, Empty); len(emptyCells) > 0 {
+		in = t.To(y, x, shark, t.Choose(y, x, emptyCells))
+	}
+	return in, true
+}
+
+// MoveFish moves the fish to an empty cell, preferring those away from
+// sharks when fish flee, then algae when fish can starve, and weighing
+// them by the fish next to them when fish school
+func (Classic) MoveFish(t *Turn, y, x int) (Move, bool) {
+	w := t.World
+	i := y*w.Width + x
+	fish := w.Grid[i]
+	fish.BreedTime++
+	if w.Terrain.IsReef(y, x) {
+		fish.BreedTime++
+	}
+	fish.Age++
+	if w.FishAge > 0 && fish.Age > w.FishAge {
+		return Move{}, false
+	}
+	if w.FishStarve > 0 {
+		w.rules().Hunger(w, &fish, t.Rand())
+	}
+	in := Move{From: i, To: i, Animal: fish}
+
+	emptyCells := t.Free(y, x, Empty)
+	if w.Flee {
+		emptyCells = w.safest(emptyCells, t.sharks())
+	}
+	if w.FishStarve > 0 {
+		if algaeCells := w.withAlgae(emptyCells, t.newGrid); len(algaeCells) > 0 {
+			emptyCells = algaeCells
+		}
+	}
+	if len(emptyCells) > 0 {
+		in = t.To(y, x, fish, w.chooseNear(y, x, emptyCells, w.Schooling))
+	}
+	return in, true
+}
+
+// Hunger takes one unit of energy
+func (Classic) Hunger(w *World, animal *Cell, rng Rand) {
+	animal.Energy--
+}
+
+// Breeds reports whether the animal's breed time has passed
+func (Classic) Breeds(w *World, animal Cell, rng Rand) bool {
+	breed, _ := w.Traits(animal)
+	return animal.BreedTime >= breed
+}
+
+// Stochastic replaces the timers by a trial every chronon: an animal that
+// moves breeds with a fixed chance, and a predator that does not eat
+// starves with a fixed chance, so the time to either is geometric rather
+// than fixed. A chance of 0 stands for 1 over the animal's breed or starve
+// time, which keeps the mean times of the classic rules. Fish that eat
+// algae starve with 1 over their starve time. Animals move as in Classic.
+type Stochastic struct {
+	Classic
+	FishBreed   float64 `json:"fishBreed,omitempty"`   // Chance that a moving fish breeds
+	SharkBreed  float64 `json:"sharkBreed,omitempty"`  // Chance that a moving predator breeds
+	SharkStarve float64 `json:"sharkStarve,omitempty"` // Chance that a predator starves in a chronon without fish
+}
+
+// Hunger empties the animal's energy with the chance of starving, and
+// otherwise leaves it as it is
+func (s Stochastic) Hunger(w *World, animal *Cell, rng Rand) {
+	_, starve := w.Traits(*animal)
+	chance := s.SharkStarve
+	if animal.Type == Fish || chance == 0 {
+		chance = 1 / float64(max(1, starve))
+	}
+	if rng.Float64() < chance {
+		animal.Energy = 0
+	}
+}
+
+// Breeds rolls the chance of breeding
+func (s Stochastic) Breeds(w *World, animal Cell, rng Rand) bool {
+	chance := s.FishBreed
+	if animal.Type == Shark {
+		chance = s.SharkBreed
+	}
+	if chance == 0 {
+		breed, _ := w.Traits(animal)
+		chance = 1 / float64(max(1, breed))
+	}
+	return rng.Float64() < chance
+}
+
+// rules returns the rule set of the world, Classic unless Rules is set
+func (w *World) rules() RuleSet {
+	if w.Rules == nil {
+		return Classic{}
+	}
+	return w.Rules
+}
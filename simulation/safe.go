@@ -0,0 +1,85 @@
+package simulation
+
+import "sync"
+
+// SafeWorld wraps a World for programs that step it on one goroutine and
+// query it from others, such as a dashboard reading statistics while a
+// simulation loop runs. Every method holds a lock for as long as it uses the
+// world: Step and the methods that change the world exclusively, the others
+// shared with each other. The wrapped world must not be used directly while
+// other goroutines use the SafeWorld.
+type SafeWorld struct {
+	mu    sync.RWMutex
+	world *World
+}
+
+// NewSafeWorld wraps w
+func NewSafeWorld(w *World) *SafeWorld {
+	return &SafeWorld{world: w}
+}
+
+// Step performs one simulation step and returns the number of fish eaten
+func (s *SafeWorld) Step(threads int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.world.Step(threads)
+}
+
+// Count returns the number of fish and sharks
+func (s *SafeWorld) Count() (fish, sharks int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.world.Count()
+}
+
+// Stats returns the statistics of the world. It holds the lock exclusively,
+// since the first call after a change computes and keeps the result.
+func (s *SafeWorld) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.world.Stats()
+}
+
+// Cell returns the contents of the cell at (y, x)
+func (s *SafeWorld) Cell(y, x int) Cell {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.world.Cell(y, x)
+}
+
+// SetCell replaces the contents of the cell at (y, x)
+func (s *SafeWorld) SetCell(y, x int, c Cell) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.world.SetCell(y, x, c)
+}
+
+// SetParameter changes a named parameter and returns its previous value
+func (s *SafeWorld) SetParameter(name string, value float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.world.SetParameter(name, value)
+}
+
+// Snapshot captures the world state after the given number of steps
+func (s *SafeWorld) Snapshot(step int) *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.world.Snapshot(step)
+}
+
+// View calls fn with the world for reading, alongside other readers. fn
+// must not change the world, call Stats or keep it after returning.
+func (s *SafeWorld) View(fn func(w *World)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.world)
+}
+
+// Update calls fn with exclusive use of the world, for any other change.
+// fn must not keep the world after returning.
+func (s *SafeWorld) Update(fn func(w *World)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.world)
+}
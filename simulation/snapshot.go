@@ -0,0 +1,200 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Snapshot is a complete, serializable copy of a world's state
+type Snapshot struct {
+	Step         int       `json:"step"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	FishBreed    int       `json:"fishBreed"`
+	SharkBreed   int       `json:"sharkBreed"`
+	SharkStarve  int       `json:"sharkStarve"`
+	SharkGain    int       `json:"sharkGain,omitempty"`
+	FishStarve   int       `json:"fishStarve,omitempty"`
+	AlgaeGrowth  float64   `json:"algaeGrowth,omitempty"`
+	FishAge      int       `json:"fishAge,omitempty"`
+	Mutation     float64   `json:"mutation,omitempty"`
+	Schooling    float64   `json:"schooling,omitempty"`
+	Flee         bool      `json:"flee,omitempty"`
+	EggDelay     int       `json:"eggDelay,omitempty"`
+	EggChance    float64   `json:"eggChance,omitempty"`
+	Bounded      bool      `json:"bounded,omitempty"`
+	Species      []Species `json:"species,omitempty"`
+	TieBreak     string    `json:"tieBreak,omitempty"`
+	Order        string    `json:"order,omitempty"`
+	Topology     string    `json:"topology,omitempty"`
+	Seed         int64     `json:"seed,omitempty"` // Seed the world's generator was last given (0=unknown)
+	RandState    []byte    `json:"rand,omitempty"` // State of the world's generator, see World.RandState
+	Cells        []Cell    `json:"cells"`
+	Reef         []bool    `json:"reef,omitempty"`
+	Temperature  []float64 `json:"temperature,omitempty"`
+	CurrentEast  []float64 `json:"currentEast,omitempty"`
+	CurrentNorth []float64 `json:"currentNorth,omitempty"`
+
+	// Rules of the world if they are Stochastic; other rule sets are not saved
+	Stochastic *Stochastic `json:"stochastic,omitempty"`
+}
+
+// Snapshot captures the world state after the given number of steps
+func (w *World) Snapshot(step int) *Snapshot {
+	s := &Snapshot{
+		Step:        step,
+		Width:       w.Width,
+		Height:      w.Height,
+		FishBreed:   w.FishBreed,
+		SharkBreed:  w.SharkBreed,
+		SharkStarve: w.SharkStarve,
+		SharkGain:   w.SharkGain,
+		FishStarve:  w.FishStarve,
+		AlgaeGrowth: w.AlgaeGrowth,
+		FishAge:     w.FishAge,
+		Mutation:    w.Mutation,
+		Schooling:   w.Schooling,
+		Flee:        w.Flee,
+		EggDelay:    w.EggDelay,
+		EggChance:   w.EggChance,
+		Bounded:     w.Bounded,
+		Species:     w.Species,
+		TieBreak:    w.TieBreak.String(),
+		Order:       w.Order.String(),
+		Seed:        w.seed,
+		RandState:   w.RandState(),
+		Cells:       make([]Cell, 0, w.Width*w.Height),
+	}
+	if w.Hexagonal() {
+		s.Topology = "hex"
+	}
+	if w.Terrain != nil {
+		s.Reef = w.Terrain.Reef
+		s.Temperature = w.Terrain.Temperature
+		s.CurrentEast = w.Terrain.CurrentEast
+		s.CurrentNorth = w.Terrain.CurrentNorth
+	}
+	if rules, ok := w.Rules.(Stochastic); ok {
+		s.Stochastic = &rules
+	}
+	s.Cells = append(s.Cells, w.Grid...)
+	return s
+}
+
+// World rebuilds a world from the snapshot, continuing with the given seed
+func (s *Snapshot) World(seed int64) *World {
+	w := &World{
+		Width:       s.Width,
+		Height:      s.Height,
+		Grid:        make([]Cell, s.Width*s.Height),
+		FishBreed:   s.FishBreed,
+		SharkBreed:  s.SharkBreed,
+		SharkStarve: s.SharkStarve,
+		SharkGain:   s.SharkGain,
+		FishStarve:  s.FishStarve,
+		AlgaeGrowth: s.AlgaeGrowth,
+		FishAge:     s.FishAge,
+		Mutation:    s.Mutation,
+		Schooling:   s.Schooling,
+		Flee:        s.Flee,
+		EggDelay:    s.EggDelay,
+		EggChance:   s.EggChance,
+		Bounded:     s.Bounded,
+		Species:     s.Species,
+		Terrain: &Terrain{
+			Width:        s.Width,
+			Height:       s.Height,
+			Land:         make([]bool, s.Width*s.Height),
+			Reef:         s.Reef,
+			Temperature:  s.Temperature,
+			CurrentEast:  s.CurrentEast,
+			CurrentNorth: s.CurrentNorth,
+		},
+	}
+	copy(w.Grid, s.Cells)
+	for i, cell := range w.Grid {
+		w.Terrain.Land[i] = cell.Type == Barrier
+	}
+	w.TieBreak, _ = ParseTieBreak(s.TieBreak)
+	w.Order, _ = ParseOrder(s.Order)
+	if s.Topology != "" {
+		w.Topology, _ = ParseTopology(s.Topology)
+	}
+	if s.Stochastic != nil {
+		w.Rules = *s.Stochastic
+	}
+	w.Seed(seed)
+	return w
+}
+
+// Continue rebuilds the world with its generator in the state the snapshot
+// recorded, so it goes on exactly as the world it was taken of. Without a
+// recorded state the generator is seeded with Seed.
+func (s *Snapshot) Continue() (*World, error) {
+	w := s.World(s.Seed)
+	if s.RandState != nil {
+		if err := w.SetRandState(s.RandState); err != nil {
+			return nil, fmt.Errorf("generator state: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// Save writes the snapshot to a JSON file
+func (s *Snapshot) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadSnapshot reads a snapshot written by Save
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := ParseSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseSnapshot decodes and checks a snapshot written by Save
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	n := s.Width * s.Height
+	if s.Width < 1 || s.Height < 1 || len(s.Cells) != n ||
+		(s.Reef != nil && len(s.Reef) != n) || (s.Temperature != nil && len(s.Temperature) != n) ||
+		(s.CurrentEast != nil && len(s.CurrentEast) != n) || len(s.CurrentNorth) != len(s.CurrentEast) {
+		return nil, fmt.Errorf("grid size does not match cell count")
+	}
+	if s.TieBreak != "" {
+		if _, err := ParseTieBreak(s.TieBreak); err != nil {
+			return nil, err
+		}
+	}
+	if s.Order != "" {
+		if _, err := ParseOrder(s.Order); err != nil {
+			return nil, err
+		}
+	}
+	if s.Topology != "" {
+		if _, err := ParseTopology(s.Topology); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range s.Cells {
+		if c.Species < 0 || c.Species > len(s.Species) {
+			return nil, fmt.Errorf("cell species %d is not listed", c.Species)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,98 @@
+package simulation
+
+import "slices"
+
+// SparseDensity is the share of cells holding animals below which the
+// serial engine keeps a list of the animals and visits only them and their
+// neighbours instead of every cell of the grid
+const SparseDensity = 0.1
+
+// Sparse reports whether the next step of the serial engine takes the
+// sparse path, see SparseDensity
+func (w *World) Sparse() bool {
+	return w.animals != nil && float64(len(w.animals)) < SparseDensity*float64(len(w.Grid)) && w.sparseRules()
+}
+
+// sparseRules reports whether the settings of the world allow the sparse
+// path: every animal must only move to a neighbour, and cells without
+// animals must stay empty, so no algae or eggs. Barriers are ruled out when
+// the list is first built, see trackAnimals.
+func (w *World) sparseRules() bool {
+	switch w.rules().(type) {
+	case Classic, Stochastic:
+		return w.FishStarve == 0 && w.EggDelay == 0
+	}
+	return false
+}
+
+// forgetAnimals drops the list of animals after the grid was changed
+// outside Step; the next serial step rebuilds it
+func (w *World) forgetAnimals() {
+	w.animals = nil
+}
+
+// countAnimals counts the animals of the list in events
+func (w *World) countAnimals(events *StepEvents) {
+	for _, i := range w.animals {
+		if w.Grid[i].Type == Fish {
+			events.Fish++
+		} else {
+			events.Sharks++
+		}
+	}
+}
+
+// trackAnimals keeps the list of animals up to date after a step, now that
+// the new grid is w.Grid and the old one w.next. After a sparse step, every
+// animal of the new grid was settled in a cell of an old animal or one of
+// its neighbours, so only those are visited; the settled marks are cleared
+// on the way, as are the old animals in w.next, so the next sparse step
+// needs no clearing. After any other step of the serial engine on a sparse
+// world the grid is scanned once, giving up on any barrier, algae or egg.
+func (w *World) trackAnimals(engine Engine, sparse bool, events *StepEvents) {
+	old := w.animals
+	w.animals, w.clean = nil, false
+	if engine != EngineSerial || !w.sparseRules() || float64(events.Fish+events.Sharks) >= SparseDensity*float64(len(w.Grid)) {
+		return
+	}
+
+	animals := w.spare[:0]
+	if !sparse {
+		for i, c := range w.Grid {
+			switch {
+			case c.Type == Fish || c.Type == Shark:
+				animals = append(animals, i)
+			case c != Cell{}:
+				return
+			}
+		}
+		w.animals, w.spare = animals, old
+		return
+	}
+
+	for _, i := range old {
+		y, x := i/w.Width, i%w.Width
+		animals = w.collect(animals, i)
+		for _, d := range w.Directions(y) {
+			if ny, nx, ok := w.Neighbor(y, x, d[0], d[1]); ok {
+				animals = w.collect(animals, ny*w.Width+nx)
+			}
+		}
+		w.next[i] = Cell{}
+	}
+	slices.Sort(animals)
+	w.animals, w.spare, w.clean = animals, old, true
+}
+
+// collect appends cell i to animals if an animal was settled there, and
+// clears its settled mark so it is collected once
+func (w *World) collect(animals []int, i int) []int {
+	if !w.moved[i] {
+		return animals
+	}
+	w.moved[i] = false
+	if t := w.Grid[i].Type; t == Fish || t == Shark {
+		animals = append(animals, i)
+	}
+	return animals
+}
@@ -0,0 +1,84 @@
+package simulation
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Species describes an additional predator species. Every predator is a
+// Shark cell; Cell.Species selects its parameters, with 0 meaning the
+// world's own SharkBreed and SharkStarve and i > 0 meaning World.Species[i-1].
+type Species struct {
+	Name   string     `json:"name"`
+	Breed  int        `json:"breed"`
+	Starve int        `json:"starve"`
+	Color  color.RGBA `json:"color"`
+}
+
+// AddSpecies registers a predator species and places count individuals of
+// it on random empty cells. It returns the species index stored in their
+// cells.
+func (w *World) AddSpecies(s Species, count int) (int, error) {
+	if s.Breed < 1 || s.Starve < 1 {
+		return 0, fmt.Errorf("species %q: breed and starve must be positive", s.Name)
+	}
+	empty := 0
+	for _, cell := range w.Grid {
+		if cell.Type == Empty {
+			empty++
+		}
+	}
+	if count > empty {
+		return 0, fmt.Errorf("species %q: only %d empty cells left for %d individuals", s.Name, empty, count)
+	}
+
+	w.Species = append(w.Species, s)
+	id := len(w.Species)
+	for range count {
+		for {
+			x := w.rng.Intn(w.Width)
+			y := w.rng.Intn(w.Height)
+			if w.Grid[y*w.Width+x].Type == Empty {
+				w.Grid[y*w.Width+x] = Cell{
+					Type:      Shark,
+					Energy:    s.Starve,
+					BreedTime: w.rng.Intn(s.Breed),
+					Species:   id,
+				}
+				break
+			}
+		}
+	}
+	w.stats = nil
+	w.forgetAnimals()
+	return id, nil
+}
+
+// SpeciesName returns the name of a predator species index
+func (w *World) SpeciesName(id int) string {
+	if id <= 0 || id > len(w.Species) {
+		return "Sharks"
+	}
+	return w.Species[id-1].Name
+}
+
+// CountSpecies returns the number of predators of each species, starting
+// with the world's own sharks
+func (w *World) CountSpecies() []int {
+	counts := make([]int, len(w.Species)+1)
+	for _, cell := range w.Grid {
+		if cell.Type == Shark && cell.Species < len(counts) {
+			counts[cell.Species]++
+		}
+	}
+	return counts
+}
+
+// predator returns the breed and starve times of a shark's species
+func (w *World) predator(shark Cell) (breed, starve int) {
+	if shark.Species <= 0 || shark.Species > len(w.Species) {
+		return w.SharkBreed, w.SharkStarve
+	}
+	s := w.Species[shark.Species-1]
+	return s.Breed, s.Starve
+}
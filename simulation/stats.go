@@ -0,0 +1,142 @@
+package simulation
+
+// Stats summarises the state of a world
+type Stats struct {
+	Water         int     // Cells that are not land
+	Fish          int     // Fish of the world
+	Sharks        int     // Predators of all species
+	Algae         int     // Cells covered in algae
+	Eggs          int     // Dormant fish eggs
+	FishDensity   float64 // Share of water cells holding a fish
+	SharkDensity  float64 // Share of water cells holding a predator
+	FishEnergy    float64 // Mean energy of fish (only used when fish eat algae)
+	SharkEnergy   float64 // Mean energy of predators
+	FishBreed     float64 // Mean breeding timer of fish
+	SharkBreed    float64 // Mean breeding timer of predators
+	FishClusters  Clusters
+	SharkClusters Clusters
+}
+
+// Clusters summarises the groups of animals of one kind that touch each
+// other horizontally or vertically, across wrapped edges
+type Clusters struct {
+	Count   int     // Number of clusters
+	Largest int     // Animals in the largest cluster
+	Mean    float64 // Mean animals per cluster
+}
+
+// Stats returns statistics of the world for embedders and displays. The
+// result is kept until the world changes through Step, SetCell or another
+// method of World, so callers can ask for it as often as they like; code
+// that writes Grid directly must use SetCell instead.
+func (w *World) Stats() Stats {
+	if w.stats != nil {
+		return *w.stats
+	}
+
+	var s Stats
+	var fishEnergy, sharkEnergy, fishBreed, sharkBreed int
+	clusters := newClusterLabels(w.Width * w.Height)
+	for i := range w.Height {
+		for j := range w.Width {
+			cell := w.Grid[i*w.Width+j]
+			if cell.Algae {
+				s.Algae++
+			}
+			if cell.Egg > 0 {
+				s.Eggs++
+			}
+			switch cell.Type {
+			case Barrier:
+				continue
+			case Fish:
+				s.Fish++
+				fishEnergy += cell.Energy
+				fishBreed += cell.BreedTime
+			case Shark:
+				s.Sharks++
+				sharkEnergy += cell.Energy
+				sharkBreed += cell.BreedTime
+			}
+			s.Water++
+			if cell.Type != Empty {
+				w.joinClusters(clusters, i, j)
+			}
+		}
+	}
+
+	s.FishDensity = ratio(s.Fish, s.Water)
+	s.SharkDensity = ratio(s.Sharks, s.Water)
+	s.FishEnergy = ratio(fishEnergy, s.Fish)
+	s.SharkEnergy = ratio(sharkEnergy, s.Sharks)
+	s.FishBreed = ratio(fishBreed, s.Fish)
+	s.SharkBreed = ratio(sharkBreed, s.Sharks)
+	s.FishClusters = w.summarizeClusters(clusters, Fish)
+	s.SharkClusters = w.summarizeClusters(clusters, Shark)
+
+	w.stats = &s
+	return s
+}
+
+// ratio returns n/d, or 0 if d is 0
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// clusterLabels is a union-find forest over the cell indexes of the grid
+type clusterLabels []int
+
+// newClusterLabels creates a forest in which every cell is its own cluster
+func newClusterLabels(cells int) clusterLabels {
+	parent := make(clusterLabels, cells)
+	for i := range parent {
+		parent[i] = i
+	}
+	return parent
+}
+
+// find returns the root of the cluster of cell i
+func (c clusterLabels) find(i int) int {
+	for c[i] != i {
+		c[i] = c[c[i]]
+		i = c[i]
+	}
+	return i
+}
+
+// joinClusters merges the animal at (y, x) with neighbouring animals of the
+// same kind earlier in grid order, which have been labelled already. Cells on
+// the last row and column also meet the first row and column on a torus.
+func (w *World) joinClusters(c clusterLabels, y, x int) {
+	kind := w.Grid[y*w.Width+x].Type
+	for _, d := range w.Directions(y) {
+		ny, nx, ok := w.Neighbor(y, x, d[0], d[1])
+		if !ok || w.Grid[ny*w.Width+nx].Type != kind || ny*w.Width+nx > y*w.Width+x {
+			continue
+		}
+		c[c.find(ny*w.Width+nx)] = c.find(y*w.Width + x)
+	}
+}
+
+// summarizeClusters counts the clusters of one kind of animal
+func (w *World) summarizeClusters(c clusterLabels, kind CellType) Clusters {
+	sizes := map[int]int{}
+	animals := 0
+	for i := range w.Height {
+		for j := range w.Width {
+			if w.Grid[i*w.Width+j].Type == kind {
+				sizes[c.find(i*w.Width+j)]++
+				animals++
+			}
+		}
+	}
+
+	result := Clusters{Count: len(sizes), Mean: ratio(animals, len(sizes))}
+	for _, size := range sizes {
+		result.Largest = max(result.Largest, size)
+	}
+	return result
+}
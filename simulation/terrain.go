@@ -0,0 +1,70 @@
+package simulation
+
+// Terrain describes the static environment of a world. All slices are
+// row-major with Width*Height entries; nil slices mean "none"/uniform.
+type Terrain struct {
+	Width  int
+	Height int
+
+	// Land cells become Barrier cells that nothing can enter
+	Land []bool
+
+	// Fish on reef cells advance their breeding timer twice as fast
+	Reef []bool
+
+	// Sea temperature from 0 (cold) to 1 (warm); sharks in warm water
+	// lose an extra unit of energy with probability equal to the temperature
+	Temperature []float64
+
+	// Ocean current components towards the east and north. Animals prefer
+	// to move with the current: a neighbour in direction d is chosen with
+	// weight 1 + d·current, so the current's length should be at most 1.
+	CurrentEast  []float64
+	CurrentNorth []float64
+}
+
+// NewOcean returns terrain with no land, reefs or temperature variation
+func NewOcean(width, height int) *Terrain {
+	return &Terrain{Width: width, Height: height}
+}
+
+// WaterCells returns the number of cells animals can occupy
+func (t *Terrain) WaterCells() int {
+	n := t.Width * t.Height
+	for _, land := range t.Land {
+		if land {
+			n--
+		}
+	}
+	return n
+}
+
+// IsReef reports whether (y, x) is a reef cell
+func (t *Terrain) IsReef(y, x int) bool {
+	return t != nil && t.Reef != nil && t.Reef[y*t.Width+x]
+}
+
+// TemperatureAt returns the sea temperature at (y, x)
+func (t *Terrain) TemperatureAt(y, x int) float64 {
+	if t == nil || t.Temperature == nil {
+		return 0
+	}
+	return t.Temperature[y*t.Width+x]
+}
+
+// CurrentAt returns the east and north components of the current at (y, x)
+func (t *Terrain) CurrentAt(y, x int) (east, north float64) {
+	if t == nil || t.CurrentEast == nil {
+		return 0, 0
+	}
+	return t.CurrentEast[y*t.Width+x], t.CurrentNorth[y*t.Width+x]
+}
+
+// SetCurrent makes the same current flow through every cell
+func (t *Terrain) SetCurrent(east, north float64) {
+	t.CurrentEast = make([]float64, t.Width*t.Height)
+	t.CurrentNorth = make([]float64, t.Width*t.Height)
+	for i := range t.CurrentEast {
+		t.CurrentEast[i], t.CurrentNorth[i] = east, north
+	}
+}
@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TieBreak decides which animal gets a cell that several want in the same
+// chronon. Moves are committed one at a time, so the policy is the order in
+// which they are committed. Which species moves first is up to Order.
+type TieBreak int
+
+const (
+	TieRandom    TieBreak = iota // Shuffled order, the classic Wa-Tor rule
+	TieEnergy                    // Animals with the most energy first, ties shuffled
+	TieFirstCome                 // Row-major grid order, top left first
+)
+
+// TieBreaks lists the policy names accepted by ParseTieBreak
+var TieBreaks = []string{"random", "energy", "first-come"}
+
+// ParseTieBreak returns the policy with the given name
+func ParseTieBreak(name string) (TieBreak, error) {
+	if i := slices.Index(TieBreaks, name); i >= 0 {
+		return TieBreak(i), nil
+	}
+	return TieRandom, fmt.Errorf("unknown tie-break policy %q (expected random, energy or first-come)", name)
+}
+
+// String returns the name of the policy
+func (t TieBreak) String() string {
+	if t < 0 || int(t) >= len(TieBreaks) {
+		return fmt.Sprintf("TieBreak(%d)", int(t))
+	}
+	return TieBreaks[t]
+}
+
+// entity is an animal waiting to move in the current chronon
+type entity struct {
+	y, x int
+	t    CellType
+}
+
+// entities collects the animals of the grid in the order they move
+func (w *World) entities() []entity {
+	if w.animals == nil {
+		return w.entitiesIn(0, w.Height, 0, w.Width)
+	}
+	entities := make([]entity, len(w.animals))
+	for k, i := range w.animals {
+		entities[k] = entity{i / w.Width, i % w.Width, w.Grid[i].Type}
+	}
+	return w.order(entities)
+}
+
+// entitiesIn collects the animals in rows y0..y1-1 and columns x0..x1-1 in
+// the order they move
+func (w *World) entitiesIn(y0, y1, x0, x1 int) []entity {
+	entities := make([]entity, 0, (y1-y0)*(x1-x0))
+	for i := y0; i < y1; i++ {
+		for j := x0; j < x1; j++ {
+			if t := w.Grid[i*w.Width+j].Type; t == Fish || t == Shark {
+				entities = append(entities, entity{i, j, t})
+			}
+		}
+	}
+	return w.order(entities)
+}
+
+// order puts entities, listed in grid order, in the order they move
+func (w *World) order(entities []entity) []entity {
+	if w.TieBreak == TieFirstCome {
+		return entities
+	}
+
+	// Shuffle entities using Fisher-Yates algorithm for random chronon ordering
+	for i := len(entities) - 1; i > 0; i-- {
+		j := w.rng.Intn(i + 1)
+		entities[i], entities[j] = entities[j], entities[i]
+	}
+	if w.TieBreak == TieEnergy {
+		slices.SortStableFunc(entities, func(a, b entity) int {
+			return w.Grid[b.y*w.Width+b.x].Energy - w.Grid[a.y*w.Width+a.x].Energy
+		})
+	}
+	return entities
+}
@@ -0,0 +1,85 @@
+package simulation
+
+// DefaultTileSize is the side of the tiles Step shares among its threads
+// when TileSize is 0
+const DefaultTileSize = 32
+
+// tile is a rectangle of the grid whose animals one thread moves
+type tile struct {
+	y0, y1, x0, x1 int // Rows y0..y1-1 and columns x0..x1-1
+	rng            tileRand
+	entities       []entity   // Animals of the tile in the order they move, once listed
+	events         StepEvents // What happened to them
+}
+
+// tilePhases splits the grid into tiles of about TileSize cells a side and
+// groups them into phases whose tiles can be processed at the same time.
+//
+// An animal writes only its own cell and its neighbours, and reads cells
+// other tiles write up to tileReach cells away, so two tiles may be
+// processed together if more cells than that separate them. Tiles are
+// colored like a checkerboard with two colors per axis, and a third for
+// the last row or column of tiles if their number is odd, so that tiles of
+// the same color never touch, even across wrapped edges. Each combination of
+// colors is a phase. Every tile is at least tileReach+1 cells wide, so the
+// tile between two of the same color keeps them far enough apart.
+func (w *World) tilePhases() [][]*tile {
+	size := w.TileSize
+	if size <= 0 {
+		size = DefaultTileSize
+	}
+	size = max(size, w.tileReach()+1)
+	down := max(1, w.Height/size)
+	across := max(1, w.Width/size)
+
+	var phases [9][]*tile
+	for ty := range down {
+		for tx := range across {
+			t := &tile{
+				y0: ty * w.Height / down, y1: (ty + 1) * w.Height / down,
+				x0: tx * w.Width / across, x1: (tx + 1) * w.Width / across,
+			}
+			phase := 3*tileColor(ty, down) + tileColor(tx, across)
+			phases[phase] = append(phases[phase], t)
+		}
+	}
+
+	var result [][]*tile
+	for _, p := range phases {
+		if len(p) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// tileReach returns how far from its cell an animal reads the cells being
+// settled: its neighbours, or theirs too when fish flee, since they count
+// the sharks next to the cells they may move to
+func (w *World) tileReach() int {
+	if w.Flee {
+		return 2
+	}
+	return 1
+}
+
+// tileColor returns the color of tile i of n along one axis
+func tileColor(i, n int) int {
+	if n > 1 && n%2 == 1 && i == n-1 {
+		return 2
+	}
+	return i % 2
+}
+
+// stepTile moves the animals of a pass in a tile, counting what happens
+// in the tile's events. It draws random numbers from the tile's own generator, so
+// the outcome does not depend on which thread runs it or when.
+func (w *World) stepTile(t *tile, kinds, settled []CellType, newGrid []Cell, moved []bool) {
+	local := *w // Shares the grid, buffers and parameters, but not the generator
+	local.rng = &t.rng
+	if t.entities == nil {
+		t.entities = local.entitiesIn(t.y0, t.y1, t.x0, t.x1)
+	}
+
+	local.moveAll(t.entities, kinds, settled, newGrid, moved, &t.events)
+}
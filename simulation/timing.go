@@ -0,0 +1,66 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+)
+
+// StepTiming is the time the phases of a step took, see LastStepTiming
+type StepTiming struct {
+	Setup  time.Duration // Clearing the buffers, settling barriers, algae and eggs and ordering the animals
+	Sharks time.Duration // Moving the sharks; the gpu engine and OrderInterleaved move all animals at once, counted here
+	Fish   time.Duration // Moving the fish
+	Total  time.Duration // The whole step but its observers, so also growing algae and the bookkeeping
+}
+
+// Add adds the times of o to t, to total them over several steps
+func (t *StepTiming) Add(o StepTiming) {
+	t.Setup += o.Setup
+	t.Sharks += o.Sharks
+	t.Fish += o.Fish
+	t.Total += o.Total
+}
+
+// Per returns the times divided by n, to average them over n steps
+func (t StepTiming) Per(n int) StepTiming {
+	if n <= 0 {
+		return StepTiming{}
+	}
+	d := time.Duration(n)
+	return StepTiming{t.Setup / d, t.Sharks / d, t.Fish / d, t.Total / d}
+}
+
+// String returns the times rounded to microseconds, the whole step first
+func (t StepTiming) String() string {
+	r := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	return fmt.Sprintf("%v (setup %v, sharks %v, fish %v)", r(t.Total), r(t.Setup), r(t.Sharks), r(t.Fish))
+}
+
+// pass returns the phase a pass moving the given kinds is timed in
+func (t *StepTiming) pass(kinds []CellType) *time.Duration {
+	if kinds[0] == Fish {
+		return &t.Fish
+	}
+	return &t.Sharks
+}
+
+// LastStepTiming returns the time the phases of the last step took, or
+// zero before the first step. Observers see the timing of the step they are
+// notified of.
+func (w *World) LastStepTiming() StepTiming {
+	return w.timing
+}
+
+// startTiming starts timing a step
+func (w *World) startTiming() {
+	w.timing = StepTiming{}
+	w.startTime = time.Now()
+	w.lapStart = w.startTime
+}
+
+// lap adds the time since the previous lap to phase
+func (w *World) lap(phase *time.Duration) {
+	now := time.Now()
+	*phase += now.Sub(w.lapStart)
+	w.lapStart = now
+}
@@ -0,0 +1,74 @@
+package simulation
+
+import "fmt"
+
+// Topology decides which cells of a world are neighbours: animals move,
+// hunt and flee only among the neighbours of their cell
+type Topology interface {
+	// Directions returns the offsets {dy, dx} from a cell in row y to its
+	// neighbours, for World.Neighbor
+	Directions(y int) [][2]int
+}
+
+// Square is the classic topology: every cell has four neighbours, up,
+// down, left and right
+type Square struct{}
+
+// Hex lays the cells out as hexagons in offset rows: every odd row is
+// shifted half a cell to the right, so a cell has six neighbours, two in the
+// row above, two in the row below and one on either side. A wrapped hex
+// world needs an even height, or the rows meeting across the top and bottom
+// edges would be shifted the same way.
+type Hex struct{}
+
+var (
+	squareDirections  = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	hexEvenDirections = [][2]int{{-1, -1}, {-1, 0}, {1, -1}, {1, 0}, {0, -1}, {0, 1}}
+	hexOddDirections  = [][2]int{{-1, 0}, {-1, 1}, {1, 0}, {1, 1}, {0, -1}, {0, 1}}
+)
+
+// Directions returns the four offsets, the same in every row
+func (Square) Directions(int) [][2]int { return squareDirections }
+
+// Directions returns the six offsets of row y, which depend on whether it
+// is shifted
+func (Hex) Directions(y int) [][2]int {
+	if y%2 != 0 {
+		return hexOddDirections
+	}
+	return hexEvenDirections
+}
+
+// Topologies lists the topology names accepted by ParseTopology
+var Topologies = []string{"square", "hex"}
+
+// ParseTopology returns the topology with the given name
+func ParseTopology(name string) (Topology, error) {
+	switch name {
+	case "square":
+		return Square{}, nil
+	case "hex":
+		return Hex{}, nil
+	}
+	return Square{}, fmt.Errorf("unknown topology %q (expected square or hex)", name)
+}
+
+// topology returns the topology of the world, Square if none is set
+func (w *World) topology() Topology {
+	if w.Topology == nil {
+		return Square{}
+	}
+	return w.Topology
+}
+
+// Hexagonal reports whether the world is laid out as hexagons, see Hex
+func (w *World) Hexagonal() bool {
+	_, hex := w.Topology.(Hex)
+	return hex
+}
+
+// Directions returns the offsets {dy, dx} from a cell in row y to its
+// neighbours by the topology of the world, for Neighbor
+func (w *World) Directions(y int) [][2]int {
+	return w.topology().Directions(y)
+}
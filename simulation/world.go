@@ -1,8 +1,11 @@
 package simulation
 
 import (
+	"math"
 	"math/rand"
+	"slices"
 	"sync"
+	"time"
 )
 
 // CellType represents the type of entity in a cell
@@ -12,50 +15,111 @@ const (
 	Empty CellType = iota
 	Fish
 	Shark
+	Barrier
 )
 
 // Cell represents a single cell in the grid
 type Cell struct {
-	Type      CellType
-	Energy    int
-	BreedTime int
+	Type      CellType `json:"t,omitempty"`
+	Energy    int      `json:"e,omitempty"`
+	BreedTime int      `json:"b,omitempty"`
+	Algae     bool     `json:"a,omitempty"`  // Algae growing in the cell, see SetAlgae
+	Age       int      `json:"g,omitempty"`  // Chronons the animal has lived, see World.FishAge
+	Species   int      `json:"s,omitempty"`  // Predator species of a shark, see World.Species
+	Breed     int      `json:"gb,omitempty"` // Heritable breed time (0=that of the world or species), see SetMutation
+	Starve    int      `json:"gs,omitempty"` // Heritable starve time (0=that of the world or species)
+	Heading   int      `json:"h,omitempty"`  // Direction of the animal's last move, see HeadingNone
+	Egg       int      `json:"eg,omitempty"` // Chronons until the dormant fish egg in an empty cell hatches (0=none), see SetEggs
 }
 
-// World represents the Wa-Tor world
+// World represents the Wa-Tor world. A World is not safe for concurrent
+// use: Step, Stats and the Set methods change it, and nothing else may use
+// it meanwhile. Programs that query it from other goroutines while it steps
+// should wrap it in a SafeWorld.
 type World struct {
 	Width       int
 	Height      int
-	Grid        [][]Cell
+	Grid        []Cell // Cells in row-major order: (y, x) is Grid[y*Width+x]; reused by Step, so copy it to keep it
 	FishBreed   int
 	SharkBreed  int
 	SharkStarve int
+	SharkGain   int       // Energy gained per fish eaten, capped at SharkStarve (0=refill to SharkStarve)
+	FishStarve  int       // Chronons a fish survives without algae (0=fish never starve)
+	AlgaeGrowth float64   // Chance per chronon that algae grows on an empty cell
+	FishAge     int       // Chronons a fish lives before dying of old age (0=fish never die of old age)
+	Mutation    float64   // Chance that an inherited trait changes, see SetMutation
+	Schooling   float64   // Extra weight per fish next to a cell fish may move to (0=no schooling)
+	Flee        bool      // Fish avoid cells next to sharks, see Classic.MoveFish
+	EggDelay    int       // Chronons a dormant fish egg waits before hatching (0=no eggs), see SetEggs
+	EggChance   float64   // Chance that a fish dying of old age or starvation leaves a viable egg
+	Bounded     bool      // Edges are walls instead of wrapping around (a torus)
+	Species     []Species // Additional predator species, see AddSpecies
+	TieBreak    TieBreak  // Order in which animals claim contested cells
+	Order       Order     // Which species moves first in a step
+	TileSize    int       // Side of the tiles Step shares among threads (0=DefaultTileSize; at least 3 when Flee)
+	Rules       RuleSet   // How animals move, breed and starve (nil=Classic)
+	Topology    Topology  // Which cells are neighbours (nil=Square)
+	Terrain     *Terrain
+	rng         Rand
+	seed        int64         // Seed of rng, recorded in snapshots (0=unknown)
+	stats       *Stats        // Result of Stats until the grid changes
+	next        []Cell        // Grid being built by Step, swapped with Grid afterwards
+	moved       []bool        // Cells of next that have been settled this step
+	events      *StepEvents   // What happened during the last Step (nil before the first)
+	predation   *predationMap // Fish eaten per cell, if tracked
+	observers   []Observer    // Notified of every step, see AddObserver
+	onEvent     *eventFunc    // Observer registered by OnEvent
+	gpu         GPU           // Graphics card of the gpu engine, see SetGPU
+	gpuPixels   []byte        // Grid passed to gpu, reused by every step
+	timing      StepTiming    // Phases of the last step, see LastStepTiming
+	startTime   time.Time     // Start of the step being timed
+	lapStart    time.Time     // Start of the phase being timed
+	animals     []int         // Cells of Grid holding animals in grid order, if known, see Sparse
+	spare       []int         // Buffer for the next list of animals
+	clean       bool          // next and moved are clear, see trackAnimals
 }
 
-// NewWorld creates a new Wa-Tor world
+// NewWorld creates a new Wa-Tor world with a randomly chosen seed
 func NewWorld(width, height, numFish, numShark, fishBreed, sharkBreed, sharkStarve int) *World {
+	return NewSeededWorld(rand.Int63(), width, height, numFish, numShark, fishBreed, sharkBreed, sharkStarve)
+}
+
+// NewSeededWorld creates a new Wa-Tor world whose initial placement and
+// subsequent steps are fully determined by seed
+func NewSeededWorld(seed int64, width, height, numFish, numShark, fishBreed, sharkBreed, sharkStarve int) *World {
+	return NewWorldOnTerrain(seed, NewOcean(width, height), numFish, numShark, fishBreed, sharkBreed, sharkStarve)
+}
+
+// NewWorldOnTerrain creates a seeded world shaped by the given terrain. The
+// terrain must have at least numFish+numShark water cells.
+func NewWorldOnTerrain(seed int64, t *Terrain, numFish, numShark, fishBreed, sharkBreed, sharkStarve int) *World {
+	width, height := t.Width, t.Height
 	w := &World{
 		Width:       width,
 		Height:      height,
-		Grid:        make([][]Cell, height),
+		Grid:        make([]Cell, width*height),
 		FishBreed:   fishBreed,
 		SharkBreed:  sharkBreed,
 		SharkStarve: sharkStarve,
+		Terrain:     t,
 	}
+	w.Seed(seed)
 
-	// Initialize empty grid
-	for i := range height {
-		w.Grid[i] = make([]Cell, width)
+	// Initialize empty grid with land as barriers
+	for i, land := range t.Land {
+		if land {
+			w.Grid[i].Type = Barrier
+		}
 	}
-
 	// Place fish randomly
 	for range numFish {
 		for {
-			x := rand.Intn(width)
-			y := rand.Intn(height)
-			if w.Grid[y][x].Type == Empty {
-				w.Grid[y][x] = Cell{
+			x := w.rng.Intn(width)
+			y := w.rng.Intn(height)
+			if w.Grid[y*w.Width+x].Type == Empty {
+				w.Grid[y*w.Width+x] = Cell{
 					Type:      Fish,
-					BreedTime: rand.Intn(fishBreed),
+					BreedTime: w.rng.Intn(fishBreed),
 				}
 				break
 			}
@@ -65,13 +129,13 @@ func NewWorld(width, height, numFish, numShark, fishBreed, sharkBreed, sharkStar
 	// Place sharks randomly
 	for range numShark {
 		for {
-			x := rand.Intn(width)
-			y := rand.Intn(height)
-			if w.Grid[y][x].Type == Empty {
-				w.Grid[y][x] = Cell{
+			x := w.rng.Intn(width)
+			y := w.rng.Intn(height)
+			if w.Grid[y*w.Width+x].Type == Empty {
+				w.Grid[y*w.Width+x] = Cell{
 					Type:      Shark,
 					Energy:    sharkStarve,
-					BreedTime: rand.Intn(sharkBreed),
+					BreedTime: w.rng.Intn(sharkBreed),
 				}
 				break
 			}
@@ -81,283 +145,410 @@ func NewWorld(width, height, numFish, numShark, fishBreed, sharkBreed, sharkStar
 	return w
 }
 
+// Seed resets the world's random number generator
+func (w *World) Seed(seed int64) {
+	w.rng = newPCGRand(seed)
+	w.seed = seed
+}
+
 // Count returns the number of fish and sharks
 func (w *World) Count() (int, int) {
 	fish, sharks := 0, 0
-	for i := 0; i < w.Height; i++ {
-		for j := 0; j < w.Width; j++ {
-			switch w.Grid[i][j].Type {
-			case Fish:
-				fish++
-			case Shark:
-				sharks++
-			}
+	for _, cell := range w.Grid {
+		switch cell.Type {
+		case Fish:
+			fish++
+		case Shark:
+			sharks++
 		}
 	}
 	return fish, sharks
 }
 
-// Step performs one simulation step
-func (w *World) Step(threads int) int {
-	newGrid := make([][]Cell, w.Height)
-	for i := 0; i < w.Height; i++ {
-		newGrid[i] = make([]Cell, w.Width)
-	}
+// Cell returns the contents of the cell at (y, x)
+func (w *World) Cell(y, x int) Cell {
+	return w.Grid[y*w.Width+x]
+}
 
-	moved := make([][]bool, w.Height)
-	for i := 0; i < w.Height; i++ {
-		moved[i] = make([]bool, w.Width)
-	}
+// SetCell replaces the contents of the cell at (y, x)
+func (w *World) SetCell(y, x int, c Cell) {
+	w.Grid[y*w.Width+x] = c
+	w.stats = nil
+	w.forgetAnimals()
+}
 
-	var fishEaten int
-	if threads == 1 {
-		fishEaten = w.stepSingle(newGrid, moved)
-	} else {
-		fishEaten = w.stepParallel(newGrid, moved, threads)
+// AddAnimal puts a fish or shark into the cell at (y, x) the way the
+// initial animals of a new world are placed: with a random breeding timer
+// and, for a shark, full energy
+func (w *World) AddAnimal(y, x int, t CellType) {
+	c := Cell{Type: t, BreedTime: w.rng.Intn(w.FishBreed)}
+	if t == Shark {
+		c = Cell{Type: t, Energy: w.SharkStarve, BreedTime: w.rng.Intn(w.SharkBreed)}
 	}
-
-	w.Grid = newGrid
-	return fishEaten
+	w.SetCell(y, x, c)
 }
 
-func (w *World) stepSingle(newGrid [][]Cell, moved [][]bool) int {
-	fishEaten := 0
+// Step performs one simulation step with the default engine for the number
+// of threads and returns the number of fish eaten. LastStep tells what else
+// happened.
+func (w *World) Step(threads int) int {
+	return w.StepWith(DefaultEngine(threads), threads)
+}
 
-	// Collect all entities with their positions
-	type entity struct {
-		y, x int
-		t    CellType
+// StepWith performs one simulation step with the given engine, which uses
+// up to threads threads, and returns the number of fish eaten
+func (w *World) StepWith(engine Engine, threads int) int {
+	w.startStep()
+	w.startTiming()
+
+	// Reuse the buffers of the previous step, which a sparse step leaves
+	// clear
+	sparse := engine == EngineSerial && w.Sparse()
+	if len(w.next) != len(w.Grid) {
+		w.next = make([]Cell, len(w.Grid))
+		w.moved = make([]bool, len(w.Grid))
+	} else if !sparse || !w.clean {
+		clear(w.next)
+		clear(w.moved)
 	}
+	newGrid, moved := w.next, w.moved
 
-	entities := make([]entity, 0, w.Height*w.Width)
-	for i := 0; i < w.Height; i++ {
-		for j := 0; j < w.Width; j++ {
-			if w.Grid[i][j].Type != Empty {
-				entities = append(entities, entity{i, j, w.Grid[i][j].Type})
+	// Barriers never move, algae stays where it grew and eggs hatch where
+	// they were laid; a sparse world has none of them
+	events := &StepEvents{}
+	if sparse {
+		w.countAnimals(events)
+	} else {
+		for i, cell := range w.Grid {
+			switch cell.Type {
+			case Fish:
+				events.Fish++
+			case Shark:
+				events.Sharks++
+			case Barrier:
+				newGrid[i] = cell
+				moved[i] = true
 			}
-		}
-	}
-
-	// Shuffle entities using Fisher-Yates algorithm for random chronon ordering
-	for i := len(entities) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		entities[i], entities[j] = entities[j], entities[i]
-	}
-
-	// Process entities in random order, sharks before fish within same priority
-	// First pass: sharks
-	for _, e := range entities {
-		if e.t == Shark && !moved[e.y][e.x] {
-			eaten := w.moveShark(e.y, e.x, newGrid, moved)
-			if eaten {
-				fishEaten++
+			newGrid[i].Algae = cell.Algae
+			if cell.Egg > 0 {
+				w.incubate(newGrid, moved, i, events)
 			}
 		}
 	}
 
-	// Second pass: fish
-	for _, e := range entities {
-		if e.t == Fish && !moved[e.y][e.x] {
-			w.moveFish(e.y, e.x, newGrid, moved)
-		}
+	switch {
+	case engine == EngineGPU && w.stepGPU(newGrid, moved, events):
+	case engine == EngineParallel:
+		w.stepParallel(newGrid, moved, max(1, threads), events)
+	default:
+		w.stepSingle(newGrid, moved, events)
+	}
+	if w.FishStarve > 0 {
+		w.growAlgae(newGrid)
 	}
 
-	return fishEaten
+	w.Grid, w.next = newGrid, w.Grid
+	w.trackAnimals(engine, sparse, events)
+	w.stats = nil
+	w.events = events
+	w.timing.Total = time.Since(w.startTime)
+	w.notify(events)
+	return events.FishEaten
 }
 
-func (w *World) stepParallel(newGrid [][]Cell, moved [][]bool, threads int) int {
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	fishEaten := 0
+func (w *World) stepSingle(newGrid []Cell, moved []bool, events *StepEvents) {
+	// Process entities in tie-break order, pass by pass of the schedule
+	passes := w.schedule()
+	entities := w.entities()
+	w.lap(&w.timing.Setup)
+	for i, kinds := range passes {
+		w.moveAll(entities, kinds, slices.Concat(passes[:i]...), newGrid, moved, events)
+		w.lap(w.timing.pass(kinds))
+	}
+}
 
-	// Collect all entities with their positions
-	type entity struct {
-		y, x int
-		t    CellType
+// stepParallel moves the animals tile by tile, pass by pass. The tiles of
+// each phase (see tilePhases) are handed to a pool of threads through a
+// channel, so threads that finish sparse tiles take on more. Cells claimed
+// by an earlier phase are taken for later ones, so an animal on a tile
+// border loses a contested cell to a neighbouring tile of an earlier phase;
+// within a tile the tie-break policy decides. Each tile draws from its own
+// generator, seeded in order from the world's, so the result depends on the
+// tile size but not on the number of threads. Each tile counts its own
+// events, which are added up once all have moved.
+func (w *World) stepParallel(newGrid []Cell, moved []bool, threads int, events *StepEvents) {
+	passes := w.schedule()
+	phases := w.tilePhases()
+	for _, phase := range phases {
+		for _, t := range phase {
+			t.rng = tileRand(w.rng.Intn(math.MaxInt))
+		}
 	}
 
-	entities := make([]entity, 0, w.Height*w.Width)
-	for i := 0; i < w.Height; i++ {
-		for j := 0; j < w.Width; j++ {
-			if w.Grid[i][j].Type != Empty {
-				entities = append(entities, entity{i, j, w.Grid[i][j].Type})
+	w.lap(&w.timing.Setup)
+
+	for p, kinds := range passes {
+		settled := slices.Concat(passes[:p]...)
+		for _, phase := range phases {
+			tiles := make(chan *tile)
+			var wg sync.WaitGroup
+			for range min(threads, len(phase)) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for t := range tiles {
+						w.stepTile(t, kinds, settled, newGrid, moved)
+					}
+				}()
+			}
+			for _, t := range phase {
+				tiles <- t
 			}
+			close(tiles)
+			wg.Wait()
 		}
+		w.lap(w.timing.pass(kinds))
 	}
-
-	// Shuffle entities using Fisher-Yates algorithm for random chronon ordering
-	for i := len(entities) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		entities[i], entities[j] = entities[j], entities[i]
+	for _, phase := range phases {
+		for _, t := range phase {
+			events.add(t.events)
+		}
 	}
+}
 
-	// Separate sharks and fish
-	sharks := make([]entity, 0, len(entities)/2)
-	fish := make([]entity, 0, len(entities)/2)
+// moveAll moves the animals of the given kinds among entities in two
+// phases, counting births and deaths in events; settled are the kinds that
+// moved in earlier passes of the step. In the intent phase every animal picks a
+// cell as if it were alone, by the RuleSet of the world: with Classic, sharks
+// an adjacent fish, or else an empty cell, and fish an empty cell, preferring
+// algae if they eat it. Cells settled before the pass, such as those of
+// sharks when fish move, are not offered, except that sharks hunt fish that
+// moved first where they settled.
+// In the commit phase, animals are settled in the order of entities, so a
+// cell wanted by several goes to the first of them and the others stay
+// where they are; an animal's own cell is never offered to another of its
+// kind, so it is always free to stay. Only the commit phase places animals
+// in newGrid, so no animal can lose its cell to another or be placed twice.
+// Cells that animals leave or die in are marked settled, so that when both
+// kinds move in one pass a shark cannot eat a fish that has gone, and a
+// fish eaten before its turn does not move.
+func (w *World) moveAll(entities []entity, kinds, settled []CellType, newGrid []Cell, moved []bool, events *StepEvents) {
+	rules := w.rules()
+	turn := &Turn{World: w, newGrid: newGrid, moved: moved, settled: settled}
+	intents := make([]Move, 0, len(entities))
 	for _, e := range entities {
-		switch e.t {
-		case Shark:
-			sharks = append(sharks, e)
-		case Fish:
-			fish = append(fish, e)
+		if !slices.Contains(kinds, e.t) || moved[e.y*w.Width+e.x] {
+			continue // Eaten, or of another pass
+		}
+		kind := e.t
+		var in Move
+		var alive bool
+		if kind == Shark {
+			in, alive = rules.MoveShark(turn, e.y, e.x)
+		} else {
+			in, alive = rules.MoveFish(turn, e.y, e.x)
+		}
+		if !alive {
+			moved[e.y*w.Width+e.x] = true
+		}
+		switch {
+		case alive:
+			intents = append(intents, in)
+		case kind == Shark:
+			events.SharksStarved++
+			events.starved(w.Grid[e.y*w.Width+e.x].Species, 1)
+			w.logEvent(events, EventStarved, w.Grid[e.y*w.Width+e.x], e.y*w.Width+e.x)
+		default:
+			events.FishAged++
+			w.logEvent(events, EventAged, w.Grid[e.y*w.Width+e.x], e.y*w.Width+e.x)
+			w.layEgg(newGrid, e.y*w.Width+e.x)
 		}
 	}
 
-	// Process sharks in parallel
-	entitiesPerThread := (len(sharks) + threads - 1) / threads
-	for t := range threads {
-		start := t * entitiesPerThread
-		end := min(start+entitiesPerThread, len(sharks))
-		if start >= len(sharks) {
-			break
+	for _, in := range intents {
+		if moved[in.From] {
+			continue // Eaten by a shark earlier in the order
 		}
-
-		wg.Add(1)
-		go func(sharkSlice []entity) {
-			defer wg.Done()
-			localEaten := 0
-			for _, e := range sharkSlice {
-				mu.Lock()
-				if !moved[e.y][e.x] {
-					eaten := w.moveShark(e.y, e.x, newGrid, moved)
-					if eaten {
-						localEaten++
-					}
-				}
-				mu.Unlock()
-			}
-			mu.Lock()
-			fishEaten += localEaten
-			mu.Unlock()
-		}(sharks[start:end])
-	}
-	wg.Wait()
-
-	// Process fish in parallel
-	entitiesPerThread = (len(fish) + threads - 1) / threads
-	for t := range threads {
-		start := t * entitiesPerThread
-		end := min(start+entitiesPerThread, len(fish))
-		if start >= len(fish) {
-			break
+		if moved[in.To] && !(in.Eats && newGrid[in.To].Type == Fish) {
+			in.To, in.Eats = in.From, false // Taken by an animal earlier in the order
 		}
-
-		wg.Add(1)
-		go func(fishSlice []entity) {
-			defer wg.Done()
-			for _, e := range fishSlice {
-				mu.Lock()
-				if !moved[e.y][e.x] {
-					w.moveFish(e.y, e.x, newGrid, moved)
-				}
-				mu.Unlock()
-			}
-		}(fish[start:end])
+		w.settle(in, newGrid, moved, events)
 	}
-	wg.Wait()
-
-	return fishEaten
 }
 
-func (w *World) moveShark(y, x int, newGrid [][]Cell, moved [][]bool) bool {
-	shark := w.Grid[y][x]
-	shark.Energy--
-	shark.BreedTime++
-
-	// Find adjacent cells with fish
-	fishCells := w.getAdjacentCells(y, x, Fish, moved)
-	var targetY, targetX int
-	fishEaten := false
-
-	if len(fishCells) > 0 {
-		// Eat a fish
-		idx := rand.Intn(len(fishCells))
-		targetY, targetX = fishCells[idx][0], fishCells[idx][1]
-		shark.Energy = w.SharkStarve
-		fishEaten = true
-	} else {
-		// Move to empty cell
-		emptyCells := w.getAdjacentCells(y, x, Empty, moved)
-		if len(emptyCells) > 0 {
-			idx := rand.Intn(len(emptyCells))
-			targetY, targetX = emptyCells[idx][0], emptyCells[idx][1]
+// settle commits a move: the animal feeds or starves, leaves an offspring
+// behind if it moved and its breeding timer expired, and takes its cell in
+// newGrid. What happened is counted in events.
+func (w *World) settle(in Move, newGrid []Cell, moved []bool, events *StepEvents) {
+	animal := in.Animal
+	_, starve := w.Traits(animal)
+	switch {
+	case in.Eats:
+		events.FishEaten++
+		prey := w.Grid[in.To]
+		if moved[in.To] {
+			prey = newGrid[in.To] // Settled before the shark moved
+		}
+		w.logEvent(events, EventEaten, prey, in.To)
+		if w.SharkGain > 0 {
+			animal.Energy = min(starve, animal.Energy+w.SharkGain)
 		} else {
-			// Can't move, stay in place
-			targetY, targetX = y, x
+			animal.Energy = starve
 		}
+	case animal.Type == Fish && w.FishStarve > 0 && newGrid[in.To].Algae:
+		newGrid[in.To].Algae = false
+		animal.Energy = starve
 	}
-
-	// Check if shark dies
-	if shark.Energy <= 0 {
-		// Shark dies, leave empty
-		if targetY != y || targetX != x {
-			newGrid[targetY][targetX] = Cell{Type: Empty}
-			moved[targetY][targetX] = true
+	if animal.Energy <= 0 && (animal.Type == Shark || w.FishStarve > 0) {
+		// Starved, leaving its cells free but trampling any egg
+		newGrid[in.To].Egg = 0
+		if animal.Type == Shark {
+			events.SharksStarved++
+			events.starved(animal.Species, 1)
+		} else {
+			events.FishStarved++
+			w.layEgg(newGrid, in.To)
 		}
-		return fishEaten
+		w.logEvent(events, EventStarved, animal, in.To)
+		moved[in.From] = true
+		return
 	}
 
-	// Move shark
-	if shark.BreedTime >= w.SharkBreed {
-		// Breed
-		newGrid[y][x] = Cell{
-			Type:      Shark,
-			Energy:    w.SharkStarve,
-			BreedTime: 0,
+	// Animals breed as they leave a cell; one that cannot move keeps its
+	// timer and breeds on its next move
+	if in.To != in.From && w.rules().Breeds(w, animal, w.rng) {
+		child := w.offspring(animal)
+		place(newGrid, in.From, child)
+		moved[in.From] = true
+		w.logEvent(events, EventBorn, child, in.From)
+		animal.BreedTime = 0
+		if animal.Type == Shark {
+			events.SharksBorn++
+		} else {
+			events.FishBorn++
 		}
-		moved[y][x] = true
-		shark.BreedTime = 0
 	}
+	if in.To != in.From {
+		animal.Heading = in.Heading
+		w.logMove(events, animal, in.From, in.To)
+		moved[in.From] = true
+	}
+	place(newGrid, in.To, animal)
+	moved[in.To] = true
+}
 
-	newGrid[targetY][targetX] = shark
-	moved[targetY][targetX] = true
+// place puts an animal or empty cell into cell i of newGrid, keeping the
+// algae there
+func place(newGrid []Cell, i int, c Cell) {
+	c.Algae = newGrid[i].Algae
+	newGrid[i] = c
+}
 
-	return fishEaten
+// Neighbor returns the cell offset by (dy, dx) from (y, x). On a bounded
+// world ok is false if that cell lies outside the grid; otherwise the
+// coordinates wrap around the edges.
+func (w *World) Neighbor(y, x, dy, dx int) (ny, nx int, ok bool) {
+	ny, nx = y+dy, x+dx
+	if w.Bounded {
+		return ny, nx, ny >= 0 && ny < w.Height && nx >= 0 && nx < w.Width
+	}
+	return (ny%w.Height + w.Height) % w.Height, (nx%w.Width + w.Width) % w.Width, true
 }
 
-func (w *World) moveFish(y, x int, newGrid [][]Cell, moved [][]bool) {
-	fish := w.Grid[y][x]
-	fish.BreedTime++
+func (w *World) getAdjacentCells(y, x int, cellType CellType, moved []bool) [][]int {
+	var cells [][]int
 
-	// Find empty adjacent cells
-	emptyCells := w.getAdjacentCells(y, x, Empty, moved)
-	var targetY, targetX int
+	for _, dir := range w.Directions(y) {
+		ny, nx, ok := w.Neighbor(y, x, dir[0], dir[1])
+		if !ok {
+			continue
+		}
 
-	if len(emptyCells) > 0 {
-		idx := rand.Intn(len(emptyCells))
-		targetY, targetX = emptyCells[idx][0], emptyCells[idx][1]
-	} else {
-		// Can't move
-		targetY, targetX = y, x
+		if i := ny*w.Width + nx; !moved[i] && w.Grid[i].Type == cellType {
+			cells = append(cells, []int{ny, nx, dir[0], dir[1]})
+		}
 	}
 
-	// Move fish
-	if fish.BreedTime >= w.FishBreed {
-		// Breed
-		newGrid[y][x] = Cell{
-			Type:      Fish,
-			BreedTime: 0,
+	return cells
+}
+
+// settledCells returns the neighbours of (y, x) that have been settled in
+// newGrid with cellType, like getAdjacentCells
+func (w *World) settledCells(y, x int, cellType CellType, newGrid []Cell, moved []bool) [][]int {
+	var cells [][]int
+	for _, dir := range w.Directions(y) {
+		ny, nx, ok := w.Neighbor(y, x, dir[0], dir[1])
+		if i := ny*w.Width + nx; ok && moved[i] && newGrid[i].Type == cellType {
+			cells = append(cells, []int{ny, nx, dir[0], dir[1]})
 		}
-		moved[y][x] = true
-		fish.BreedTime = 0
 	}
+	return cells
+}
 
-	newGrid[targetY][targetX] = fish
-	moved[targetY][targetX] = true
+// adjacentIn counts the cells of grid next to (y, x) that hold cellType
+func (w *World) adjacentIn(grid []Cell, y, x int, cellType CellType) int {
+	n := 0
+	for _, dir := range w.Directions(y) {
+		ny, nx, ok := w.Neighbor(y, x, dir[0], dir[1])
+		if ok && grid[ny*w.Width+nx].Type == cellType {
+			n++
+		}
+	}
+	return n
 }
 
-func (w *World) getAdjacentCells(y, x int, cellType CellType, moved [][]bool) [][]int {
-	var cells [][]int
-	directions := [][]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+// safest returns the cells of the list next to the fewest sharks in grid,
+// which holds them where fleeing fish see them, see Turn.sharks
+func (w *World) safest(cells [][]int, grid []Cell) [][]int {
+	var result [][]int
+	fewest := 0
+	for _, c := range cells {
+		n := w.adjacentIn(grid, c[0], c[1], Shark)
+		if len(result) == 0 || n < fewest {
+			result, fewest = result[:0], n
+		}
+		if n == fewest {
+			result = append(result, c)
+		}
+	}
+	return result
+}
 
-	for _, dir := range directions {
-		ny := (y + dir[0] + w.Height) % w.Height
-		nx := (x + dir[1] + w.Width) % w.Width
+// choose picks one of the adjacent cells returned by getAdjacentCells,
+// favouring those downstream of the ocean current at (y, x)
+func (w *World) choose(y, x int, cells [][]int) []int {
+	return w.chooseNear(y, x, cells, 0)
+}
 
-		if !moved[ny][nx] && w.Grid[ny][nx].Type == cellType {
-			cells = append(cells, []int{ny, nx})
+// chooseNear is choose for a fish that schools: the weight of each cell is
+// further multiplied by 1 + school*n, where n is the number of other fish
+// next to it at the start of the step
+func (w *World) chooseNear(y, x int, cells [][]int, school float64) []int {
+	east, north := w.Terrain.CurrentAt(y, x)
+	if east == 0 && north == 0 && school == 0 {
+		return cells[w.rng.Intn(len(cells))]
+	}
+
+	weights := make([]float64, len(cells))
+	total := 0.0
+	for i, c := range cells {
+		// c[2], c[3] is the step (dy, dx) taken to reach the cell
+		weights[i] = max(0, 1+float64(c[3])*east-float64(c[2])*north)
+		if school > 0 {
+			// The fish at (y, x) is next to the cell too
+			weights[i] *= 1 + school*float64(w.adjacentIn(w.Grid, c[0], c[1], Fish)-1)
 		}
+		total += weights[i]
+	}
+	if total == 0 {
+		return cells[w.rng.Intn(len(cells))]
 	}
 
-	return cells
+	r := w.rng.Float64() * total
+	for i, c := range cells {
+		if r -= weights[i]; r < 0 {
+			return c
+		}
+	}
+	return cells[len(cells)-1]
 }
@@ -0,0 +1,1014 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sequence is a Rand that returns fixed values in turn, and 0 once they
+// run out
+type sequence struct {
+	ints   []int
+	floats []float64
+}
+
+func (s *sequence) Intn(n int) int {
+	if len(s.ints) == 0 {
+		return 0
+	}
+	v := s.ints[0]
+	s.ints = s.ints[1:]
+	if v < 0 || v >= n {
+		panic("sequence value out of range")
+	}
+	return v
+}
+
+func (s *sequence) Float64() float64 {
+	if len(s.floats) == 0 {
+		return 0
+	}
+	v := s.floats[0]
+	s.floats = s.floats[1:]
+	return v
+}
+
+// emptyWorld returns an empty width x height world driven by r
+func emptyWorld(width, height int, r Rand) *World {
+	w := NewSeededWorld(1, width, height, 0, 0, 10, 10, 3)
+	w.SetRand(r)
+	return w
+}
+
+func TestFishMovesToChosenNeighbor(t *testing.T) {
+	// Neighbours are offered up, down, left, right; pick left
+	w := emptyWorld(5, 5, &sequence{ints: []int{2}})
+	w.SetCell(2, 2, Cell{Type: Fish})
+
+	w.Step(1)
+
+	if got := w.Cell(2, 1); got.Type != Fish || got.BreedTime != 1 || got.Heading != HeadingLeft {
+		t.Errorf("cell (2, 1) = %+v, want fish with breed time 1 heading left", got)
+	}
+	if got := w.Cell(2, 2).Type; got != Empty {
+		t.Errorf("cell (2, 2) = %v, want empty", got)
+	}
+}
+
+func TestFishBreedsWhenTimerExpires(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{ints: []int{3}})
+	w.FishBreed = 2
+	w.SetCell(2, 2, Cell{Type: Fish, BreedTime: 1})
+
+	w.Step(1)
+
+	if got := w.Cell(2, 3); got.Type != Fish || got.BreedTime != 0 {
+		t.Errorf("parent = %+v, want fish with breed time 0", got)
+	}
+	if got := w.Cell(2, 2); got.Type != Fish || got.BreedTime != 0 {
+		t.Errorf("offspring = %+v, want fish with breed time 0", got)
+	}
+}
+
+func TestSchoolingFavorsCellsNextToFish(t *testing.T) {
+	// Up touches the fish at (0, 2), so with weight 9 it gets 10 of the
+	// 13 shares and the other neighbours 1 each
+	for _, tc := range []struct {
+		r    float64
+		want []int
+	}{
+		{r: 0.7, want: []int{1, 2}},
+		{r: 0.8, want: []int{3, 2}},
+	} {
+		w := emptyWorld(5, 5, &sequence{floats: []float64{tc.r}})
+		w.SetCell(0, 2, Cell{Type: Fish})
+		w.SetCell(2, 2, Cell{Type: Fish})
+
+		cells := w.getAdjacentCells(2, 2, Empty, make([]bool, 25))
+		if got := w.chooseNear(2, 2, cells, 9); got[0] != tc.want[0] || got[1] != tc.want[1] {
+			t.Errorf("r=%g: chose (%d, %d), want (%d, %d)", tc.r, got[0], got[1], tc.want[0], tc.want[1])
+		}
+	}
+}
+
+func TestFleeingFishAvoidSharks(t *testing.T) {
+	// The shark moves up to (3, 3) first, which leaves up and left as the
+	// cells of the fish not next to it; the fish takes the second of them
+	for _, tc := range []struct {
+		flee bool
+		want [2]int
+	}{
+		{flee: false, want: [2]int{3, 2}}, // Down, the second of all four
+		{flee: true, want: [2]int{2, 1}},
+	} {
+		w := emptyWorld(5, 5, &sequence{ints: []int{0, 1}})
+		w.Bounded = true
+		w.TieBreak = TieFirstCome
+		w.Flee = tc.flee
+		w.SetCell(4, 3, Cell{Type: Shark, Energy: 3})
+		w.SetCell(2, 2, Cell{Type: Fish})
+
+		w.Step(1)
+
+		if got := w.Cell(tc.want[0], tc.want[1]).Type; got != Fish {
+			t.Errorf("flee=%v: cell (%d, %d) = %v, want fish", tc.flee, tc.want[0], tc.want[1], got)
+		}
+	}
+
+	// Moving first, the fish flees the shark where it stands: up and right
+	// are next to it, so the fish takes down, the first of the others
+	w := emptyWorld(5, 5, &sequence{})
+	w.Bounded = true
+	w.TieBreak = TieFirstCome
+	w.Flee = true
+	w.Order = OrderFishFirst
+	w.SetCell(1, 3, Cell{Type: Shark, Energy: 3})
+	w.SetCell(2, 2, Cell{Type: Fish})
+
+	w.Step(1)
+
+	if got := w.Cell(3, 2).Type; got != Fish {
+		t.Errorf("fish-first: cell (3, 2) = %v, want fish", got)
+	}
+}
+
+func TestSharkEatsChosenFish(t *testing.T) {
+	// Two shuffle draws, then the shark eats the second fish offered (right)
+	// and the other fish moves to the first cell offered (up)
+	w := emptyWorld(5, 5, &sequence{ints: []int{0, 0, 1, 0}})
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 2})
+	w.SetCell(1, 2, Cell{Type: Fish})
+	w.SetCell(2, 3, Cell{Type: Fish})
+
+	if eaten := w.Step(1); eaten != 1 {
+		t.Errorf("Step() = %d fish eaten, want 1", eaten)
+	}
+
+	if got := w.Cell(2, 3); got.Type != Shark || got.Energy != w.SharkStarve {
+		t.Errorf("shark = %+v, want shark with energy %d", got, w.SharkStarve)
+	}
+	if got := w.Cell(0, 2).Type; got != Fish {
+		t.Errorf("cell (0, 2) = %v, want the surviving fish", got)
+	}
+	if fish, sharks := w.Count(); fish != 1 || sharks != 1 {
+		t.Errorf("Count() = %d, %d, want 1, 1", fish, sharks)
+	}
+}
+
+func TestStochasticRulesRollForStarvation(t *testing.T) {
+	for _, tc := range []struct {
+		roll   float64
+		starve bool
+	}{
+		{roll: 0.4, starve: true},
+		{roll: 0.6, starve: false}, // Energy stays as it was
+	} {
+		w := emptyWorld(1, 1, &sequence{floats: []float64{tc.roll}})
+		w.Rules = Stochastic{SharkStarve: 0.5}
+		w.SetCell(0, 0, Cell{Type: Shark, Energy: 3})
+
+		w.Step(1)
+
+		want := Cell{Type: Shark, Energy: 3, BreedTime: 1, Age: 1}
+		if tc.starve {
+			want = Cell{}
+		}
+		if got := w.Cell(0, 0); got != want {
+			t.Errorf("roll %g: cell = %+v, want %+v", tc.roll, got, want)
+		}
+	}
+}
+
+func TestStochasticRulesRollForBreeding(t *testing.T) {
+	// The fish moves left and breeds with chance 1/2, although its breed
+	// time of 10 is far from over
+	for _, tc := range []struct {
+		roll   float64
+		breeds bool
+	}{
+		{roll: 0.3, breeds: true},
+		{roll: 0.7, breeds: false},
+	} {
+		w := emptyWorld(5, 5, &sequence{ints: []int{2}, floats: []float64{tc.roll}})
+		w.Rules = Stochastic{FishBreed: 0.5}
+		w.SetCell(2, 2, Cell{Type: Fish})
+
+		w.Step(1)
+
+		if got := w.Cell(2, 2).Type == Fish; got != tc.breeds {
+			t.Errorf("roll %g: offspring left = %v, want %v", tc.roll, got, tc.breeds)
+		}
+	}
+}
+
+// anchoredSharks is a custom ecology built on Classic: sharks never leave
+// their cell but still eat the fish next to them
+type anchoredSharks struct{ Classic }
+
+func (r anchoredSharks) MoveShark(t *Turn, y, x int) (Move, bool) {
+	in, alive := r.Classic.MoveShark(t, y, x)
+	if !in.Eats {
+		in.To = in.From
+	}
+	return in, alive
+}
+
+func TestCustomRuleSetDecidesMoves(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{})
+	w.Rules = anchoredSharks{}
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 5})
+
+	w.Step(1)
+
+	if got := w.Cell(2, 2).Type; got != Shark {
+		t.Fatalf("shark left its cell with no fish in reach, cell = %v", got)
+	}
+
+	// Eating still moves it onto the fish
+	w.SetCell(2, 3, Cell{Type: Fish})
+	w.Step(1)
+	if got := w.Cell(2, 3).Type; got != Shark {
+		t.Errorf("shark did not eat the fish next to it, cell = %v", got)
+	}
+}
+
+func TestSharkGainIsCapped(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{})
+	w.SharkGain = 2
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 2})
+	w.SetCell(1, 2, Cell{Type: Fish})
+
+	w.Step(1)
+
+	if got := w.Cell(1, 2); got.Type != Shark || got.Energy != 3 {
+		t.Errorf("shark = %+v, want energy 3 (1 left + 2 gained, capped at 3)", got)
+	}
+}
+
+func TestSharkStarves(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{})
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 1})
+
+	w.Step(1)
+
+	if fish, sharks := w.Count(); fish != 0 || sharks != 0 {
+		t.Errorf("Count() = %d, %d, want 0, 0", fish, sharks)
+	}
+}
+
+func TestBoundedWorldDoesNotWrap(t *testing.T) {
+	for _, tc := range []struct {
+		bounded bool
+		want    int
+	}{
+		{bounded: false, want: 2}, // Left wraps around to the last column
+		{bounded: true, want: 1},  // Right is the only neighbour
+	} {
+		w := emptyWorld(3, 1, &sequence{})
+		w.Bounded = tc.bounded
+		w.SetCell(0, 0, Cell{Type: Fish})
+
+		w.Step(1)
+
+		if got := w.Cell(0, tc.want).Type; got != Fish {
+			t.Errorf("bounded=%v: cell (0, %d) = %v, want fish", tc.bounded, tc.want, got)
+		}
+	}
+}
+
+func TestContestedCellGoesToFirstInOrder(t *testing.T) {
+	// Both fish want the middle cell; in grid order the left one moves first
+	w := emptyWorld(3, 1, &sequence{})
+	w.Bounded = true
+	w.TieBreak = TieFirstCome
+	w.SetCell(0, 0, Cell{Type: Fish})
+	w.SetCell(0, 2, Cell{Type: Fish})
+
+	w.Step(1)
+
+	for x, want := range []CellType{Empty, Fish, Fish} {
+		if got := w.Cell(0, x).Type; got != want {
+			t.Errorf("cell (0, %d) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestStarvingSharkLeavesCellFree(t *testing.T) {
+	// The shark moves first but starves, so the fish gets the middle cell
+	w := emptyWorld(3, 1, &sequence{})
+	w.Bounded = true
+	w.TieBreak = TieFirstCome
+	w.SetCell(0, 0, Cell{Type: Shark, Energy: 1})
+	w.SetCell(0, 2, Cell{Type: Fish})
+
+	w.Step(1)
+
+	for x, want := range []CellType{Empty, Fish, Empty} {
+		if got := w.Cell(0, x).Type; got != want {
+			t.Errorf("cell (0, %d) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestOrderDecidesWhoMovesFirst(t *testing.T) {
+	// Moving first, the fish escapes to the right out of the shark's reach;
+	// otherwise the shark eats it, even in one pass when it comes first
+	for _, tc := range []struct {
+		order Order
+		want  []CellType
+	}{
+		{OrderSharkFirst, []CellType{Empty, Shark, Empty, Empty}},
+		{OrderFishFirst, []CellType{Shark, Empty, Fish, Empty}},
+		{OrderInterleaved, []CellType{Empty, Shark, Empty, Empty}},
+	} {
+		w := emptyWorld(4, 1, &sequence{})
+		w.Bounded = true
+		w.TieBreak = TieFirstCome
+		w.Order = tc.order
+		w.SetCell(0, 0, Cell{Type: Shark, Energy: 3})
+		w.SetCell(0, 1, Cell{Type: Fish})
+
+		w.Step(1)
+
+		for x, want := range tc.want {
+			if got := w.Cell(0, x).Type; got != want {
+				t.Errorf("%s: cell (0, %d) = %v, want %v", tc.order, x, got, want)
+			}
+		}
+	}
+
+	// Every order keeps the grid consistent in both engines
+	for _, order := range []Order{OrderFishFirst, OrderInterleaved, OrderRandom} {
+		for _, engine := range []Engine{EngineSerial, EngineParallel} {
+			w := NewSeededWorld(7, 40, 24, 300, 60, 3, 10, 3)
+			w.TileSize = 8
+			w.Order = order
+			for step := range 30 {
+				w.StepWith(engine, 2)
+				if err := w.CheckInvariants(); err != nil {
+					t.Fatalf("%s, %s engine, step %d: %v", order, engine, step+1, err)
+				}
+			}
+		}
+	}
+}
+
+func TestHexCellsHaveSixNeighbours(t *testing.T) {
+	w := emptyWorld(6, 4, &sequence{})
+	w.Topology = Hex{}
+	moved := make([]bool, len(w.Grid))
+	for y := range w.Height {
+		for x := range w.Width {
+			cells := w.getAdjacentCells(y, x, Empty, moved)
+			if len(cells) != 6 {
+				t.Fatalf("cell (%d, %d) has %d neighbours, want 6", y, x, len(cells))
+			}
+			// Neighbours are mutual, also across the wrapped edges
+			for _, c := range cells {
+				back := w.getAdjacentCells(c[0], c[1], Empty, moved)
+				if !slices.ContainsFunc(back, func(b []int) bool { return b[0] == y && b[1] == x }) {
+					t.Errorf("(%d, %d) is next to (%d, %d) but not the other way", y, x, c[0], c[1])
+				}
+			}
+		}
+	}
+
+	// The first neighbour offered is up and left of a fish in an even row, but
+	// straight up in an odd row, which is shifted half a cell right
+	for _, tc := range []struct{ y, wantX int }{{2, 1}, {1, 2}} {
+		w := emptyWorld(4, 4, &sequence{})
+		w.Topology = Hex{}
+		w.SetCell(tc.y, 2, Cell{Type: Fish})
+		w.Step(1)
+		if got := w.Cell(tc.y-1, tc.wantX).Type; got != Fish {
+			t.Errorf("fish from row %d: cell (%d, %d) = %v, want fish", tc.y, tc.y-1, tc.wantX, got)
+		}
+	}
+
+	w = NewSeededWorld(7, 40, 24, 300, 60, 3, 10, 3)
+	w.TileSize = 8
+	w.Topology = Hex{}
+	for step := range 20 {
+		w.StepWith(EngineParallel, 2)
+		if err := w.CheckInvariants(); err != nil {
+			t.Fatalf("step %d: %v", step+1, err)
+		}
+	}
+	if !w.Snapshot(20).World(1).Hexagonal() {
+		t.Error("snapshot lost the hex topology")
+	}
+}
+
+func TestTrappedFishKeepsBreedingTimer(t *testing.T) {
+	w := emptyWorld(1, 1, &sequence{})
+	w.FishBreed = 2
+	w.SetCell(0, 0, Cell{Type: Fish, BreedTime: 1})
+
+	w.Step(1)
+
+	if got := w.Cell(0, 0); got.Type != Fish || got.BreedTime != 2 {
+		t.Errorf("cell = %+v, want fish with breed time 2", got)
+	}
+}
+
+func TestEggHatchesAfterDelay(t *testing.T) {
+	// The fish dies of old age in the first step and its egg hatches two
+	// chronons later
+	w := emptyWorld(1, 1, &sequence{})
+	w.FishAge = 1
+	w.SetEggs(2, 1)
+	w.SetCell(0, 0, Cell{Type: Fish, Age: 1})
+
+	for step, want := range []Cell{{Egg: 2}, {Egg: 1}, {Type: Fish}} {
+		w.Step(1)
+		if err := w.CheckInvariants(); err != nil {
+			t.Fatalf("step %d: %v", step+1, err)
+		}
+		if got := w.Cell(0, 0); got != want {
+			t.Errorf("step %d: cell = %+v, want %+v", step+1, got, want)
+		}
+	}
+	if got := w.LastStep().FishHatched; got != 1 {
+		t.Errorf("FishHatched = %d, want 1", got)
+	}
+}
+
+func TestStepConservesAnimals(t *testing.T) {
+	// Without breeding and starvation, sharks are never lost or duplicated
+	// and fish only disappear by being eaten, on crowded grids where many
+	// animals want the same cells
+	for _, tieBreak := range []TieBreak{TieRandom, TieEnergy, TieFirstCome} {
+		for _, threads := range []int{1, 3} {
+			w := NewSeededWorld(11, 30, 30, 400, 300, 1000, 1000, 1000)
+			w.TieBreak = tieBreak
+			w.TileSize = 4
+			for i := range w.Grid {
+				w.Grid[i].BreedTime = 0
+			}
+			for step := range 30 {
+				fish, sharks := w.Count()
+				eaten := w.Step(threads)
+				gotFish, gotSharks := w.Count()
+				if gotSharks != sharks || gotFish != fish-eaten {
+					t.Fatalf("%v, %d threads, step %d: fish %d -> %d with %d eaten, sharks %d -> %d",
+						tieBreak, threads, step, fish, gotFish, eaten, sharks, gotSharks)
+				}
+			}
+		}
+	}
+}
+
+func TestStepKeepsInvariants(t *testing.T) {
+	// Breeding, starvation of fish and sharks and old age all happen
+	for _, threads := range []int{1, 3} {
+		w := NewSeededWorld(5, 30, 30, 300, 80, 3, 6, 3)
+		w.SetAlgae(4, 0.1)
+		w.SetFishAge(12)
+		w.TileSize = 4
+		var events StepEvents
+		for step := range 60 {
+			w.Step(threads)
+			if err := w.CheckInvariants(); err != nil {
+				t.Fatalf("%d threads, step %d: %v", threads, step, err)
+			}
+			last := w.LastStep()
+			starved := 0
+			for _, n := range last.SpeciesStarved {
+				starved += n
+			}
+			if starved != last.SharksStarved {
+				t.Fatalf("%d threads, step %d: %d sharks starved by species, %d in all",
+					threads, step, starved, last.SharksStarved)
+			}
+			events.add(last)
+		}
+		if events.FishBorn == 0 || events.SharksBorn == 0 || events.FishEaten == 0 ||
+			events.FishStarved == 0 || events.FishAged == 0 || events.SharksStarved == 0 {
+			t.Errorf("%d threads: events %+v, want some of each", threads, events)
+		}
+	}
+}
+
+func TestCheckInvariantsReportsViolations(t *testing.T) {
+	w := NewSeededWorld(5, 10, 10, 20, 5, 3, 6, 3)
+	w.Step(1)
+	for i, c := range w.Grid {
+		if c.Type == Empty {
+			w.Grid[i] = Cell{Type: Fish}
+			break
+		}
+	}
+	if err := w.CheckInvariants(); err == nil || !strings.Contains(err.Error(), "fish") {
+		t.Errorf("fish added after the step: error %v", err)
+	}
+
+	w.Step(1)
+	w.SetCell(4, 7, Cell{Type: Shark})
+	if err := w.CheckInvariants(); err == nil || !strings.Contains(err.Error(), "x=7, y=4") {
+		t.Errorf("shark with no energy: error %v, want it at x=7, y=4", err)
+	}
+}
+
+func TestPredationCoversWindow(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{})
+	w.TrackPredation(2)
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 3})
+	w.SetCell(2, 1, Cell{Type: Fish})
+
+	for step, want := range []int32{1, 1, 0} {
+		w.Step(1)
+		if got := w.Predation()[2*5+1]; got != want {
+			t.Errorf("after step %d: %d fish eaten at (2, 1), want %d", step+1, got, want)
+		}
+	}
+}
+
+func TestEventsMatchCounts(t *testing.T) {
+	for _, threads := range []int{1, 3} {
+		w := NewSeededWorld(5, 30, 30, 300, 80, 3, 6, 3)
+		w.SetAlgae(4, 0.1)
+		w.SetFishAge(12)
+		w.TileSize = 4
+		counts := map[EventKind]int{}
+		w.OnEvent(func(e Event) { counts[e.Kind]++ })
+		for step := range 40 {
+			clear(counts)
+			w.Step(threads)
+			last := w.LastStep()
+			want := map[EventKind]int{
+				EventBorn:    last.FishBorn + last.SharksBorn,
+				EventEaten:   last.FishEaten,
+				EventStarved: last.FishStarved + last.SharksStarved,
+				EventAged:    last.FishAged,
+			}
+			for kind, n := range want {
+				if counts[kind] != n {
+					t.Fatalf("%d threads, step %d: %d %s events, want %d", threads, step, counts[kind], kind, n)
+				}
+			}
+		}
+	}
+}
+
+// recorder is an Observer that lists the calls it gets
+type recorder struct {
+	NopObserver
+	calls []string
+}
+
+func (r *recorder) OnStepStart(*World) { r.calls = append(r.calls, "start") }
+func (r *recorder) OnMove(e Event) {
+	r.calls = append(r.calls, fmt.Sprintf("move (%d, %d) -> (%d, %d)", e.FromY, e.FromX, e.Y, e.X))
+}
+func (r *recorder) OnBreed(e Event) {
+	r.calls = append(r.calls, fmt.Sprintf("breed (%d, %d)", e.Y, e.X))
+}
+func (r *recorder) OnStepEnd(*World) { r.calls = append(r.calls, "end") }
+
+func TestObserverFollowsStep(t *testing.T) {
+	// Neighbours are offered up, down, left, right; pick left
+	w := emptyWorld(5, 5, &sequence{ints: []int{2}})
+	w.SetCell(2, 2, Cell{Type: Fish, BreedTime: 9})
+	r := &recorder{}
+	w.AddObserver(r)
+
+	w.Step(1)
+	want := []string{"start", "breed (2, 2)", "move (2, 2) -> (2, 1)", "end"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls %q, want %q", r.calls, want)
+	}
+
+	w.RemoveObserver(r)
+	w.Step(1)
+	if len(r.calls) != len(want) {
+		t.Errorf("removed observer got %q", r.calls[len(want):])
+	}
+}
+
+func TestAgeFollowsAnimals(t *testing.T) {
+	w := emptyWorld(5, 5, &sequence{})
+	w.SetCell(2, 2, Cell{Type: Shark, Energy: 9, Age: 4})
+	w.SetCell(0, 0, Cell{Type: Fish, BreedTime: 2})
+	w.FishBreed = 3
+
+	w.Step(1)
+	for i, c := range w.Grid {
+		switch {
+		case c.Type == Shark && c.Age != 5:
+			t.Errorf("shark at %d aged %d, want 5", i, c.Age)
+		case c.Type == Fish && i == 0 && c.Age != 0:
+			t.Errorf("offspring aged %d, want 0", c.Age)
+		case c.Type == Fish && i != 0 && c.Age != 1:
+			t.Errorf("parent fish at %d aged %d, want 1", i, c.Age)
+		}
+	}
+}
+
+func TestChunkedWorldFollowsWorld(t *testing.T) {
+	// A world of one chunk lists its animals in the same order as a World,
+	// so both draw the same random numbers
+	for _, bounded := range []bool{false, true} {
+		w := NewSeededWorld(3, ChunkSize, ChunkSize, 600, 150, 3, 8, 4)
+		w.Bounded = bounded
+		c := NewChunkedWorld(0, ChunkSize, ChunkSize, 3, 8, 4)
+		c.Bounded = bounded
+		for y := range w.Height {
+			for x := range w.Width {
+				c.SetCell(y, x, w.Cell(y, x))
+			}
+		}
+		w.SetRand(rand.New(rand.NewSource(9)))
+		c.SetRand(rand.New(rand.NewSource(9)))
+
+		for step := range 40 {
+			if got, want := c.Step(), w.Step(1); got != want {
+				t.Fatalf("bounded=%v, step %d: %d fish eaten, want %d", bounded, step+1, got, want)
+			}
+			for y := range w.Height {
+				for x := range w.Width {
+					if got, want := c.Cell(y, x), w.Cell(y, x); got != want {
+						t.Fatalf("bounded=%v, step %d: cell (%d, %d) = %+v, want %+v", bounded, step+1, y, x, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestChunkedWorldStoresOccupiedChunks(t *testing.T) {
+	c := NewChunkedWorld(1, 1<<20, 1<<20, 3, 8, 4)
+	c.Scatter(1000, 1000, 40, 40, 300, 60)
+	if n := c.Chunks(); n < 1 || n > 4 {
+		t.Fatalf("%d chunks for animals within 40x40 cells", n)
+	}
+	for range 20 {
+		c.Step()
+	}
+	if fish, sharks := c.Count(); fish+sharks == 0 || c.Chunks() > 9 {
+		t.Errorf("after 20 steps: %d fish, %d sharks in %d chunks", fish, sharks, c.Chunks())
+	}
+
+	c.SetCell(5, 5, Cell{Type: Shark, Energy: 1})
+	chunks := c.Chunks()
+	c.SetCell(5, 5, Cell{})
+	if c.Chunks() != chunks-1 {
+		t.Errorf("emptying the only animal of a chunk kept %d of %d chunks", c.Chunks(), chunks)
+	}
+}
+
+func TestSparseStepsFollowFullSteps(t *testing.T) {
+	// Forgetting the list of animals before every step makes b scan the
+	// whole grid, while a visits only its animals
+	a := NewSeededWorld(4, 200, 150, 300, 100, 10, 8, 4)
+	b := NewSeededWorld(4, 200, 150, 300, 100, 10, 8, 4)
+	sparse := 0
+	for step := range 40 {
+		if a.Sparse() {
+			sparse++
+		}
+		if step == 20 {
+			a.SetCell(0, 0, Cell{Type: Shark, Energy: 4})
+			b.SetCell(0, 0, Cell{Type: Shark, Energy: 4})
+		}
+		b.forgetAnimals()
+		if got, want := a.StepWith(EngineSerial, 1), b.StepWith(EngineSerial, 1); got != want {
+			t.Fatalf("step %d: %d fish eaten, want %d", step+1, got, want)
+		}
+		if !reflect.DeepEqual(a.Grid, b.Grid) {
+			t.Fatalf("step %d: sparse grid diverged from the full one", step+1)
+		}
+		if err := a.CheckInvariants(); err != nil {
+			t.Fatalf("step %d: %v", step+1, err)
+		}
+	}
+	if sparse < 30 {
+		t.Errorf("%d of 40 steps took the sparse path", sparse)
+	}
+}
+
+func TestPackedWorldFollowsWorld(t *testing.T) {
+	// Both list their animals in grid order before shuffling, so they draw
+	// the same random numbers
+	for _, bounded := range []bool{false, true} {
+		w := NewSeededWorld(3, 50, 40, 600, 150, 3, 8, 4)
+		w.Bounded = bounded
+		p := PackWorld(w)
+		w.SetRand(rand.New(rand.NewSource(9)))
+		p.SetRand(rand.New(rand.NewSource(9)))
+
+		for step := range 40 {
+			if got, want := p.Step(), w.Step(1); got != want {
+				t.Fatalf("bounded=%v, step %d: %d fish eaten, want %d", bounded, step+1, got, want)
+			}
+			for y := range w.Height {
+				for x := range w.Width {
+					c := w.Cell(y, x)
+					want := Cell{Type: c.Type, Energy: c.Energy, BreedTime: c.BreedTime}
+					if got := p.Cell(y, x); got != want {
+						t.Fatalf("bounded=%v, step %d: cell (%d, %d) = %+v, want %+v", bounded, step+1, y, x, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSeededWorldsAreDeterministic(t *testing.T) {
+	a := NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3)
+	b := NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3)
+	for range 50 {
+		a.Step(1)
+		b.Step(1)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Error("worlds with the same seed diverged")
+	}
+}
+
+func TestHashTellsWhetherWorldsAgree(t *testing.T) {
+	a := NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3)
+	b := NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3)
+	for range 20 {
+		a.Step(1)
+		b.Step(1)
+	}
+	if a.Hash() != b.Hash() {
+		t.Fatal("worlds with the same seed have different hashes")
+	}
+
+	// A single unit of energy tells them apart
+	c := a.Cell(0, 0)
+	c.Energy++
+	b.SetCell(0, 0, c)
+	if a.Hash() == b.Hash() {
+		t.Error("hash did not change with a cell")
+	}
+}
+
+func TestCheckpointsDoNotChangeTheRun(t *testing.T) {
+	newWorld := func() *World {
+		w := NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3)
+		w.Rules = Stochastic{}
+		return w
+	}
+	plain, checkpointed := newWorld(), newWorld()
+	dir := t.TempDir()
+	var resumed *World
+	for step := 1; step <= 60; step++ {
+		plain.Step(1)
+		checkpointed.Step(1)
+		if resumed != nil {
+			resumed.Step(1)
+		}
+		if step%10 == 0 {
+			path := fmt.Sprintf("%s/checkpoint-%d.bin", dir, step)
+			if err := checkpointed.Snapshot(step).SaveCheckpoint(path); err != nil {
+				t.Fatal(err)
+			}
+			if step == 20 {
+				s, err := LoadCheckpoint(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resumed, err = s.Continue(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if checkpointed.Hash() != plain.Hash() {
+			t.Fatalf("run with checkpoints diverged from the plain run at step %d", step)
+		}
+		if resumed != nil && resumed.Hash() != plain.Hash() {
+			t.Fatalf("run resumed at step 20 diverged from the plain run at step %d", step)
+		}
+	}
+}
+
+func TestParallelStepDoesNotDependOnThreads(t *testing.T) {
+	// Tiles of 8 cells give several tiles per phase, and an odd number of
+	// them across
+	a := NewSeededWorld(7, 40, 24, 300, 60, 3, 10, 3)
+	b := NewSeededWorld(7, 40, 24, 300, 60, 3, 10, 3)
+	a.TileSize, b.TileSize = 8, 8
+	for range 50 {
+		a.Step(2)
+		b.Step(5)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Error("parallel steps with 2 and 5 threads diverged")
+	}
+}
+
+func TestParallelFleeingDoesNotDependOnThreads(t *testing.T) {
+	// Fleeing fish look two cells ahead, past the gap tiles of 2 would
+	// leave; run with -race to catch tiles reaching each other's cells
+	a := NewSeededWorld(7, 64, 64, 800, 200, 3, 10, 3)
+	b := NewSeededWorld(7, 64, 64, 800, 200, 3, 10, 3)
+	for _, w := range []*World{a, b} {
+		w.TileSize = 2
+		w.Flee = true
+	}
+	for range 40 {
+		a.Step(2)
+		b.Step(8)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Error("parallel steps of fleeing fish with 2 and 8 threads diverged")
+	}
+}
+
+func TestEnginesCanBeSwitchedMidRun(t *testing.T) {
+	// Switching engines keeps the state: both worlds run serially, then one
+	// continues in parallel with a single thread and the other with four
+	a := NewSeededWorld(9, 40, 24, 300, 60, 3, 10, 3)
+	b := NewSeededWorld(9, 40, 24, 300, 60, 3, 10, 3)
+	a.TileSize, b.TileSize = 8, 8
+	for range 20 {
+		a.StepWith(EngineSerial, 4)
+		b.StepWith(EngineSerial, 1)
+	}
+	for step := range 20 {
+		a.StepWith(EngineParallel, 1)
+		b.StepWith(EngineParallel, 4)
+		if err := a.CheckInvariants(); err != nil {
+			t.Fatalf("step %d after switching: %v", step, err)
+		}
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Error("worlds diverged after switching to the parallel engine")
+	}
+}
+
+// driftGPU moves every animal a cell to the right where that cell is
+// empty, unless it is broken
+type driftGPU struct {
+	broken bool
+}
+
+func (g driftGPU) Ready() bool { return !g.broken }
+
+func (driftGPU) Step(pix []byte, width, height int, p GPUParams) {
+	before := slices.Clone(pix)
+	for i := 0; i < width*height; i++ {
+		right := i/width*width + (i%width+1)%width
+		if t := CellType(before[4*i]); (t == Fish || t == Shark) && before[4*right] == byte(Empty) {
+			copy(pix[4*right:4*right+4], before[4*i:4*i+4])
+			pix[4*right] += 4 * 3 // From the left
+			pix[4*i] = byte(Empty)
+		}
+	}
+}
+
+func TestGPUEngineFollowsTheCard(t *testing.T) {
+	// Without a card the gpu engine steps like the serial one
+	a := NewSeededWorld(5, 40, 24, 300, 60, 3, 10, 3)
+	b := NewSeededWorld(5, 40, 24, 300, 60, 3, 10, 3)
+	for range 10 {
+		a.StepWith(EngineGPU, 1)
+		b.StepWith(EngineSerial, 1)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Fatal("gpu engine without a card diverged from the serial engine")
+	}
+
+	// Nor does a card that cannot step take random numbers from the steps
+	a.SetGPU(driftGPU{broken: true})
+	for range 10 {
+		a.StepWith(EngineGPU, 1)
+		b.StepWith(EngineSerial, 1)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Fatal("gpu engine falling back from a broken card diverged from the serial engine")
+	}
+
+	a.SetGPU(driftGPU{})
+	fish, sharks := a.Count()
+	before := slices.Clone(a.Grid)
+	a.StepWith(EngineGPU, 1)
+	if err := a.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if f, s := a.Count(); f != fish || s != sharks {
+		t.Errorf("Count() = %d, %d after drifting, want %d, %d", f, s, fish, sharks)
+	}
+	for i, c := range before {
+		right := i/a.Width*a.Width + (i%a.Width+1)%a.Width
+		if c.Type == Shark && before[right].Type == Empty && (a.Grid[right].Type != Shark || a.Grid[right].Age != c.Age+1) {
+			t.Fatalf("shark at cell %d did not drift right", i)
+		}
+	}
+	if ev := a.LastStep(); ev.FishBorn+ev.SharksBorn+ev.FishEaten+ev.SharksStarved != 0 {
+		t.Errorf("LastStep() = %+v, want no births or deaths", ev)
+	}
+
+	// The card settles contested cells at random, so other tie-breaks step
+	// on the CPU
+	a = NewSeededWorld(5, 40, 24, 300, 60, 3, 10, 3)
+	b = NewSeededWorld(5, 40, 24, 300, 60, 3, 10, 3)
+	a.TieBreak, b.TieBreak = TieFirstCome, TieFirstCome
+	a.SetGPU(driftGPU{})
+	for range 10 {
+		a.StepWith(EngineGPU, 1)
+		b.StepWith(EngineSerial, 1)
+	}
+	if !reflect.DeepEqual(a.Grid, b.Grid) {
+		t.Error("gpu engine stepped first-come tie-breaks on the card")
+	}
+}
+
+// timingObserver keeps the timing each step reports to its observers
+type timingObserver struct {
+	NopObserver
+	seen []StepTiming
+}
+
+func (o *timingObserver) OnStepEnd(w *World) { o.seen = append(o.seen, w.LastStepTiming()) }
+
+func TestStepTimingCoversThePhases(t *testing.T) {
+	w := NewSeededWorld(7, 100, 100, 2500, 500, 3, 10, 3)
+	o := &timingObserver{}
+	w.AddObserver(o)
+	for _, engine := range []Engine{EngineSerial, EngineParallel} {
+		w.StepWith(engine, 2)
+		timing := w.LastStepTiming()
+		if timing.Sharks <= 0 || timing.Fish <= 0 || timing.Total < timing.Setup+timing.Sharks+timing.Fish {
+			t.Errorf("%s engine: LastStepTiming() = %+v", engine, timing)
+		}
+		if o.seen[len(o.seen)-1] != timing {
+			t.Errorf("%s engine: observer saw %+v, want %+v", engine, o.seen[len(o.seen)-1], timing)
+		}
+	}
+}
+
+func TestSafeWorldAllowsQueriesWhileStepping(t *testing.T) {
+	// Run with -race: readers query the world while it steps
+	s := NewSafeWorld(NewSeededWorld(7, 40, 40, 300, 60, 3, 10, 3))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				stats := s.Stats()
+				if fish, sharks := s.Count(); fish < 0 || sharks < 0 || stats.Water != 40*40 {
+					t.Errorf("Count() = %d, %d, Stats().Water = %d", fish, sharks, stats.Water)
+				}
+				s.View(func(w *World) { _ = w.Cell(0, 0) })
+			}
+		}()
+	}
+	for range 50 {
+		s.Step(2)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, size := range []int{100, 500, 1000} {
+		for _, threads := range []int{1, 4} {
+			b.Run(fmt.Sprintf("%dx%d/threads=%d", size, size, threads), func(b *testing.B) {
+				// Start with a quarter of the cells fish and a twentieth sharks
+				w := NewSeededWorld(1, size, size, size*size/4, size*size/20, 3, 10, 3)
+				b.ResetTimer()
+				for range b.N {
+					w.Step(threads)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkLayouts(b *testing.B) {
+	// The same world stepped by one thread as an array of Cell structs and
+	// as a PackedWorld; World.Step also keeps statistics and events, so the
+	// difference is not the layout alone
+	for _, size := range []int{500, 2000} {
+		b.Run(fmt.Sprintf("%dx%d/structs", size, size), func(b *testing.B) {
+			w := NewSeededWorld(1, size, size, size*size/4, size*size/20, 3, 10, 3)
+			b.ResetTimer()
+			for range b.N {
+				w.StepWith(EngineSerial, 1)
+			}
+		})
+		b.Run(fmt.Sprintf("%dx%d/arrays", size, size), func(b *testing.B) {
+			w := NewPackedWorld(1, size, size, size*size/4, size*size/20, 3, 10, 3)
+			b.ResetTimer()
+			for range b.N {
+				w.Step()
+			}
+		})
+	}
+}
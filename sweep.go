@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/runner"
+	"wa-tor/simulation"
+)
+
+// sweepRange is an inclusive range of integer parameter values
+type sweepRange struct {
+	lo, hi int
+}
+
+// parseSweepRange parses a single value ("7") or an inclusive range ("3..15")
+func parseSweepRange(s string) (sweepRange, error) {
+	lo, hi, isRange := strings.Cut(s, "..")
+	if !isRange {
+		hi = lo
+	}
+	a, errLo := strconv.Atoi(lo)
+	b, errHi := strconv.Atoi(hi)
+	if errLo != nil || errHi != nil || a < 1 || b < a {
+		return sweepRange{}, fmt.Errorf("invalid range %q, expected N or LO..HI of positive integers", s)
+	}
+	return sweepRange{lo: a, hi: b}, nil
+}
+
+// sweepPoint is one combination of swept parameters
+type sweepPoint struct {
+	fishBreed, sharkBreed, starve int
+}
+
+// runSweep implements "wa-tor sweep [flags]": it runs every combination of
+// the -fbreed, -sbreed and -starve ranges headless, -reps times each, and
+// writes one CSV row per run. Repetition r of every combination uses seed
+// -seed+r, so combinations are compared on the same starting worlds.
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	size := fs.Int("size", 80, "Grid dimensions (square)")
+	fish := fs.Int("fish", 500, "Starting population of fish")
+	sharks := fs.Int("sharks", 100, "Starting population of sharks")
+	fishBreed := fs.String("fbreed", "10", "Fish breeding times, N or LO..HI")
+	sharkBreed := fs.String("sbreed", "10", "Shark breeding times, N or LO..HI")
+	starve := fs.String("starve", "8", "Shark starvation times, N or LO..HI")
+	steps := fs.Int("steps", 1000, "Maximum steps per run")
+	reps := fs.Int("reps", 5, "Runs per combination")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "Seed of the first repetition (repetition r uses seed+r)")
+	threads := fs.Int("threads", runtime.NumCPU(), "Number of runs to execute concurrently")
+	out := fs.String("out", "sweep.csv", "Results CSV file")
+	rule := analysis.DefaultExtinction
+	fs.IntVar(&rule.Threshold, "extinct-below", rule.Threshold, "Species with fewer individuals than this count as extinct (see -extinct-for)")
+	fs.IntVar(&rule.Duration, "extinct-for", rule.Duration, "Consecutive steps a species must stay below -extinct-below to count as extinct")
+	stopStable := fs.Bool("stop-on-equilibrium", false, "End each run once both populations oscillate stably, instead of running all -steps")
+	equilibrium := analysis.DefaultEquilibrium
+	fs.IntVar(&equilibrium.Window, "equilibrium-window", equilibrium.Window, "Steps per window compared by -stop-on-equilibrium")
+	fs.Float64Var(&equilibrium.Tolerance, "equilibrium-tolerance", equilibrium.Tolerance, "Largest change of the mean and amplitude between windows, relative to the mean, that counts as stable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size < 1 || *fish < 0 || *sharks < 0 || *steps < 1 || *reps < 1 || *threads < 1 || rule.Threshold < 1 || rule.Duration < 1 {
+		return fmt.Errorf("size, steps, reps, threads and extinction parameters must be positive")
+	}
+	if equilibrium.Window < 1 || equilibrium.Tolerance < 0 {
+		return fmt.Errorf("equilibrium-window must be positive and equilibrium-tolerance not negative")
+	}
+	if *fish+*sharks > *size**size {
+		return fmt.Errorf("too many entities for grid size")
+	}
+
+	var ranges [3]sweepRange
+	for i, s := range []string{*fishBreed, *sharkBreed, *starve} {
+		r, err := parseSweepRange(s)
+		if err != nil {
+			return err
+		}
+		ranges[i] = r
+	}
+	var points []sweepPoint
+	for fb := ranges[0].lo; fb <= ranges[0].hi; fb++ {
+		for sb := ranges[1].lo; sb <= ranges[1].hi; sb++ {
+			for st := ranges[2].lo; st <= ranges[2].hi; st++ {
+				points = append(points, sweepPoint{fishBreed: fb, sharkBreed: sb, starve: st})
+			}
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Printf("Sweeping %d combinations x %d repetitions on a %dx%d world, Fish: %d, Sharks: %d, Steps: %d\n",
+		len(points), *reps, *size, *size, *fish, *sharks, *steps)
+	start := time.Now()
+	opt := runner.Options{MaxSteps: *steps, Threads: 1, Extinction: rule}
+	if *stopStable {
+		opt.Equilibrium = &equilibrium
+	}
+	results := runner.RunAll(len(points)**reps, *threads, func(i int) runner.Result {
+		p, s := points[i / *reps], *seed+int64(i%*reps)
+		w := simulation.NewSeededWorld(s, *size, *size, *fish, *sharks, p.fishBreed, p.sharkBreed, p.starve)
+		return runner.Run(w, s, opt)
+	})
+
+	bw := bufio.NewWriter(f)
+	fmt.Fprintln(bw, "fbreed,sbreed,starve,seed,steps,fish_extinct,shark_extinct,mean_fish,mean_sharks,period,shark_period,phase_lag,equilibrium")
+	for i, r := range results {
+		p := points[i / *reps]
+		fmt.Fprintf(bw, "%d,%d,%d,%d,%d,%d,%d,%.1f,%.1f,%.1f,%.1f,%.0f,%d\n", p.fishBreed, p.sharkBreed, p.starve,
+			r.Seed, r.Steps, r.FishExtinct, r.SharkExtinct, r.MeanFish, r.MeanSharks, r.Period, r.SharkPeriod, r.PhaseLag, r.Equilibrium)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	for i, p := range points {
+		printSweepPoint(p, results[i**reps:(i+1)**reps])
+	}
+	fmt.Printf("Wrote %d runs to %s in %v\n", len(results), *out, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// printSweepPoint prints a one-line summary of the runs of a combination
+func printSweepPoint(p sweepPoint, results []runner.Result) {
+	s := runner.Summarize(results)
+	var periods, lags []float64
+	for _, r := range results {
+		if r.Period > 0 {
+			periods = append(periods, r.Period)
+		}
+		if r.PhaseLag >= 0 {
+			lags = append(lags, r.PhaseLag)
+		}
+	}
+	stable := 0
+	for _, r := range results {
+		if r.Equilibrium >= 0 {
+			stable++
+		}
+	}
+	fmt.Printf("fbreed %d, sbreed %d, starve %d: fish extinct %d/%d, sharks extinct %d/%d",
+		p.fishBreed, p.sharkBreed, p.starve, s.FishExtinct, s.Runs, s.SharkExtinct, s.Runs)
+	if stable > 0 {
+		fmt.Printf(", stable %d/%d", stable, s.Runs)
+	}
+	if len(periods) > 0 {
+		fmt.Printf(", period %.1f", runner.NewStat(periods).Mean)
+	}
+	if len(lags) > 0 {
+		fmt.Printf(", shark lag %.1f", runner.NewStat(lags).Mean)
+	}
+	fmt.Println()
+}
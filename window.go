@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"wa-tor/analysis"
+	"wa-tor/config"
+	"wa-tor/frame"
+	"wa-tor/rendering"
+	"wa-tor/simulation"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// runGame shows the world in a window until it is closed or the run ends,
+// falling back to headless mode if no window can be opened
+func runGame(cfg *config.Config, world *simulation.World, start int, timeline *analysis.Timeline, hooks *runHooks, ring *frame.RingLog, interrupt <-chan struct{}) error {
+	world.TrackPredation(cfg.HeatmapWindow)
+	cellSize := windowCellSize(cfg, world.Width, world.Height)
+	game := rendering.NewGame(
+		world,
+		cfg.Threads,
+		cellSize,
+		cfg.Steps,
+		cfg.UpdateFreq,
+		extinctionRule(cfg),
+	)
+	game.SetStep(start)
+	game.SetAfterStep(hooks.afterStep)
+	game.SetInterrupt(interrupt)
+	if cfg.Equilibrium {
+		game.SetEquilibrium(equilibriumRule(cfg))
+	}
+	game.SetEngine(cfg.StepEngine())
+	game.SetPalette(palette(cfg))
+	game.SetThemeSwitch(cfg.Theme, func(name string) frame.Palette {
+		c := *cfg
+		c.Theme = name
+		saveTheme(name)
+		return palette(&c)
+	})
+	if cfg.Sprites != "" {
+		if err := game.SetSprites(cfg.Sprites); err != nil {
+			return err
+		}
+	}
+	game.SetTimeline(timeline)
+	game.SetMeanField(cfg.MeanField != "")
+	if triggers, _ := analysis.ParsePauseTriggers(cfg.PauseOn); len(triggers) > 0 {
+		game.SetAutoPause(triggers)
+	}
+	game.SetReset(resetFunc(cfg))
+	game.SetSeed(cfg.Seed)
+	game.SetAdaptiveSpeed(cfg.Adaptive)
+	game.SetCalendar(cfg.Calendar())
+	if len(cfg.HUD) > 0 {
+		if err := game.SetHUD(cfg.HUD); err != nil {
+			return err
+		}
+	}
+	game.SetRenderBudget(time.Duration(cfg.RenderBudget * float64(time.Millisecond)))
+	game.SetLowPower(lowPower(cfg))
+	game.SetOnScreenshot(func(step int, world *simulation.World) {
+		savePNG(cfg, step, world)
+	})
+	if ring != nil {
+		game.SetOnDump(func() { dumpRingLog(cfg, ring) })
+	}
+
+	// Set up window
+	ebiten.SetWindowSize(world.Width*cellSize, world.Height*cellSize)
+	ebiten.SetWindowTitle("Wa-Tor Simulation")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+
+	// Run game, falling back to headless mode if no window can be opened
+	if err := runWindow(game); err != nil {
+		if game.Started() {
+			log.Fatal(err)
+		}
+		fmt.Printf("\nCould not open a window: %v\n", err)
+		fmt.Println("Falling back to headless mode (use -serve to watch the simulation in a browser)")
+		runHeadless(world, cfg, start, hooks.afterStep, interrupt)
+		hooks.finish()
+		return nil
+	}
+
+	// Print final statistics
+	fish, sharks := game.World().Count()
+	step, fishEaten, elapsed := game.GetStats()
+	fmt.Printf("\nSimulation completed at step %d\n", step)
+	printSimulatedTime(cfg, step)
+	fmt.Printf("Final populations - Fish: %d, Sharks: %d\n", fish, sharks)
+	printSpecies(game.World())
+	fmt.Printf("Total fish eaten: %d\n", fishEaten)
+	fmt.Printf("Total execution time: %v\n", elapsed)
+	if step > start {
+		fmt.Printf("Average time per step: %v\n", elapsed/time.Duration(step-start))
+	}
+	printStepTiming(game.StepTiming())
+	if err := printSpeedup(cfg, step-start); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	hooks.finish()
+	return nil
+}
+
+// saveTheme remembers the theme chosen with L for the following runs
+func saveTheme(name string) {
+	s, err := config.LoadSettings()
+	if err == nil {
+		s.Theme = name
+		err = s.Save()
+	}
+	if err != nil {
+		fmt.Printf("Error saving settings: %v\n", err)
+	}
+}
+
+// runWindow runs the game window until it is closed. Errors and panics
+// raised while the window is being created are returned as errors.
+func runWindow(game *rendering.Game) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if game.Started() {
+				panic(r)
+			}
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if err := ebiten.RunGame(game); err != nil && err != ebiten.Termination {
+		return err
+	}
+	return nil
+}
+
+// Cell size used when -cellsize is 0 for image files and for a window when
+// no monitor can be found, and the share of the monitor an automatically
+// sized window may cover, leaving room for the window decorations and task
+// bars
+const (
+	defaultCellSize = 8
+	monitorShare    = 0.9
+)
+
+// windowCellSize returns the cell size of the window: -cellsize, or for 0
+// the largest cell size at which a width x height grid fits the primary
+// monitor, reporting the choice
+func windowCellSize(cfg *config.Config, width, height int) int {
+	if cfg.CellSize > 0 {
+		return cfg.CellSize
+	}
+	var mw, mh int
+	if m := ebiten.Monitor(); m != nil {
+		mw, mh = m.Size()
+	}
+	if mw <= 0 || mh <= 0 {
+		fmt.Printf("Cell size: %d pixels (no monitor found)\n", defaultCellSize)
+		return defaultCellSize
+	}
+	size := max(1, min(int(float64(mw)*monitorShare)/width, int(float64(mh)*monitorShare)/height))
+	fmt.Printf("Cell size: %d pixels (fits the %dx%d monitor)\n", size, mw, mh)
+	return size
+}
+
+// fileCellSize returns the cell size of the images written to files, such
+// as recordings and PNG frames: -cellsize, or defaultCellSize for 0, so
+// they do not depend on the monitor
+func fileCellSize(cfg *config.Config) int {
+	if cfg.CellSize > 0 {
+		return cfg.CellSize
+	}
+	return defaultCellSize
+}